@@ -519,8 +519,259 @@ type CEL struct {
 	// The variables defined here will be available under `variables` in other expressions of the policy.
 	// +optional
 	Variables []v1alpha1.Variable `json:"variables,omitempty" yaml:"variables,omitempty"`
+
+	// AllowedEnvSources declares the sources container environment variables may be populated from.
+	// When set, the rule fails if a container environment variable is populated from another source.
+	// The resolved source of every container environment variable is available under `envSources`
+	// in the expressions. The referenced values are never exposed.
+	// +optional
+	AllowedEnvSources []EnvSource `json:"allowedEnvSources,omitempty" yaml:"allowedEnvSources,omitempty"`
+
+	// FieldProjection is a list of dot separated field paths, e.g. `spec.replicas`.
+	// When set, the object and the old object are trimmed to these fields, along with `apiVersion` and `kind`,
+	// before the expressions are evaluated. Expressions may only reference these fields or fields below them.
+	// +optional
+	FieldProjection []string `json:"fieldProjection,omitempty" yaml:"fieldProjection,omitempty"`
+
+	// ComputedLabels are merged onto the labels of the object before the expressions are evaluated,
+	// overriding existing keys. They are only used for the evaluation and are never persisted.
+	// +optional
+	ComputedLabels map[string]string `json:"computedLabels,omitempty" yaml:"computedLabels,omitempty"`
+
+	// MutuallyExclusive is a list of groups of label and annotation keys.
+	// At most one key of each group may be set on the object, the rule fails naming the conflicting keys otherwise.
+	// +optional
+	MutuallyExclusive []MutuallyExclusiveKeys `json:"mutuallyExclusive,omitempty" yaml:"mutuallyExclusive,omitempty"`
+
+	// Connect configures how CONNECT requests, e.g. `pods/exec`, are handled. Defaults to `Skip` which skips the rule.
+	// With `Evaluate`, `object` is the options of the request, e.g. a `PodExecOptions`, and `oldObject` is null.
+	// The connected resource is available under `request.name` and `request.subResource`.
+	// +optional
+	Connect ConnectAction `json:"connect,omitempty" yaml:"connect,omitempty"`
+
+	// AllowedRegistries declares the registries container images may be pulled from.
+	// When set, the rule fails naming the images pulled from other registries.
+	// The images of the containers are available under `images` in the expressions.
+	// +optional
+	AllowedRegistries *AllowedRegistries `json:"allowedRegistries,omitempty" yaml:"allowedRegistries,omitempty"`
+
+	// RequiredOwners is a list of owner kinds. When set, the rule fails unless the object
+	// has an owner reference matching one of them, e.g. a `ReplicaSet` of the `apps` group.
+	// +optional
+	RequiredOwners []RequiredOwner `json:"requiredOwners,omitempty" yaml:"requiredOwners,omitempty"`
+
+	// RelatedResources is a list of kinds of the resources evaluated together with the object, e.g. by the CLI.
+	// The resources of these kinds are available under `relatedResources` in the expressions.
+	// It is empty when the rule is evaluated for an admission request.
+	// +optional
+	RelatedResources []RelatedResourceKind `json:"relatedResources,omitempty" yaml:"relatedResources,omitempty"`
+
+	// ExternalData declares an HTTP data source. The JSON document it returns is available under `externalData`
+	// in the expressions. External data sources must be enabled in the Kyverno configuration.
+	// +optional
+	ExternalData *ExternalData `json:"externalData,omitempty" yaml:"externalData,omitempty"`
+
+	// AllowedFields is a list of allow-lists of fields. The rule fails naming the unexpected fields
+	// when a field of the object has fields outside of its allow-list.
+	// +optional
+	AllowedFields []AllowedFields `json:"allowedFields,omitempty" yaml:"allowedFields,omitempty"`
+
+	// LowercaseMetadata exposes copies of the labels and annotations of the object with lowercased keys
+	// under `lowercaseLabels` and `lowercaseAnnotations` in the expressions, e.g. for case insensitive comparisons.
+	// The labels and annotations of the object are left intact.
+	// +optional
+	LowercaseMetadata *LowercaseMetadata `json:"lowercaseMetadata,omitempty" yaml:"lowercaseMetadata,omitempty"`
+
+	// GracePeriod is the duration, starting at the creation of the policy, during which failed validations
+	// are reported as warnings instead of failures, e.g. `72h`. The rule is enforced once the grace period ends.
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty" yaml:"gracePeriod,omitempty"`
+
+	// MessageRedactions is a list of JSON pointers, e.g. `/spec/template/spec/containers/0/env`.
+	// The values they point to are set to null in the copy of the object used to evaluate message expressions,
+	// so that messages can embed the object without leaking them. Validations are evaluated against the object itself.
+	// +optional
+	MessageRedactions []string `json:"messageRedactions,omitempty" yaml:"messageRedactions,omitempty"`
+
+	// ExcludeSelfFromParams excludes the incoming resource from the collected params, e.g. when a selector of
+	// a self-referential policy matches the resource being validated. Params are compared by UID when both have one,
+	// by api version, kind, namespace and name otherwise.
+	// +optional
+	ExcludeSelfFromParams bool `json:"excludeSelfFromParams,omitempty" yaml:"excludeSelfFromParams,omitempty"`
+
+	// CostBudget is the runtime cost budget of the evaluation of the expressions against each param.
+	// Defaults to the cost budget of ValidatingAdmissionPolicies, it can be raised up to ten times that budget
+	// for expensive expressions evaluated against large resources.
+	// +optional
+	CostBudget *int64 `json:"costBudget,omitempty" yaml:"costBudget,omitempty"`
+
+	// FailFast stops evaluating the expressions against the remaining params once they are denied with a param.
+	// By default the expressions are evaluated against all params.
+	// +optional
+	FailFast bool `json:"failFast,omitempty" yaml:"failFast,omitempty"`
+
+	// ParamFieldSelector is a field selector the params of paramRef and of additionalParams must match,
+	// e.g. `spec.tier=gold,status.phase!=Failed`. Fields are dot separated paths of the params.
+	// +optional
+	ParamFieldSelector string `json:"paramFieldSelector,omitempty" yaml:"paramFieldSelector,omitempty"`
+
+	// AdditionalParams references params of other kinds, e.g. namespace overrides of cluster wide defaults.
+	// The expressions are evaluated against the union of the params of paramRef and of these references,
+	// params referenced more than once are evaluated once.
+	// +optional
+	AdditionalParams []CELParams `json:"additionalParams,omitempty" yaml:"additionalParams,omitempty"`
+
+	// NamespaceSelector selects the namespaces of the resources the expressions are evaluated against,
+	// based on the labels of the namespace. Namespaces are matched against their own labels and
+	// cluster scoped resources are always evaluated. Defaults to all namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+
+	// Warn reports the failed expressions as admission warnings instead of denying the resource,
+	// the resource is admitted when the expressions only produce warnings.
+	// +optional
+	Warn bool `json:"warn,omitempty" yaml:"warn,omitempty"`
+
+	// ReinvocationPolicy tells whether the rule is evaluated again when the resource of the request was mutated
+	// after the rule was evaluated, e.g. by the mutate rules applied in the same request. With IfNeeded the rule
+	// is evaluated once more against the mutated resource and its outcome replaces the first one.
+	// Allowed values are Never and IfNeeded. Defaults to Never.
+	// +kubebuilder:validation:Enum=Never;IfNeeded
+	// +optional
+	ReinvocationPolicy *admissionregistrationv1.ReinvocationPolicyType `json:"reinvocationPolicy,omitempty" yaml:"reinvocationPolicy,omitempty"`
+
+	// ParamNamespaceSelector selects the namespaces the params of paramRef are collected from, the params of all the
+	// selected namespaces are evaluated. It only applies to namespace-scoped param kinds and can't be set along with
+	// paramRef.namespace. The number of selected namespaces is bounded by the celMaxParamNamespaces flag.
+	// +optional
+	ParamNamespaceSelector *metav1.LabelSelector `json:"paramNamespaceSelector,omitempty" yaml:"paramNamespaceSelector,omitempty"`
+
+	// EvaluateWithoutParams evaluates the expressions once without params in addition to the evaluations against
+	// each param, e.g. for expressions that don't reference params in composite policies. The rule fails when any
+	// of the evaluations denies the resource. By default the expressions are only evaluated against the params.
+	// +optional
+	EvaluateWithoutParams bool `json:"evaluateWithoutParams,omitempty" yaml:"evaluateWithoutParams,omitempty"`
+
+	// ParamsFromOwners only evaluates the params owned by one of the owners of the resource, e.g. the params owned
+	// by the ReplicaSet of a Pod. Owners are matched by UID when both references have one, by api version, kind and
+	// name otherwise. Owners and their dependents share their namespace, the params of other namespaces are never
+	// evaluated. The resources without owners are handled according to paramRef.parameterNotFoundAction.
+	// Params are selected by paramRef.selector, the params of other owners don't count toward the maximum number of params.
+	// +optional
+	ParamsFromOwners bool `json:"paramsFromOwners,omitempty" yaml:"paramsFromOwners,omitempty"`
+
+	// SuggestionExpression is evaluated when the resource is denied and tells which value would pass, e.g.
+	// "'set spec.replicas to at most ' + string(params.maxReplicas)". It must return a string and has access to the
+	// same variables as message expressions. The suggestion is advisory, the resource is never mutated.
+	// +optional
+	SuggestionExpression string `json:"suggestionExpression,omitempty" yaml:"suggestionExpression,omitempty"`
+}
+
+// CELParams references the params of a kind.
+type CELParams struct {
+	// ParamKind is a tuple of Group Kind and Version.
+	ParamKind v1alpha1.ParamKind `json:"paramKind" yaml:"paramKind"`
+
+	// ParamRef references a parameter resource.
+	ParamRef v1alpha1.ParamRef `json:"paramRef" yaml:"paramRef"`
+}
+
+// LowercaseMetadata configures the lowercased copies of the labels and annotations of the object.
+type LowercaseMetadata struct {
+	// Values also lowercases the values of the labels and annotations.
+	// +optional
+	Values bool `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// AllowedFields lists the fields allowed in a field of the object.
+type AllowedFields struct {
+	// Path is the dot separated path of the field, e.g. `spec.template.spec`. Defaults to the root of the object.
+	// +optional
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// Fields is the list of the fields allowed in the field.
+	Fields []string `json:"fields" yaml:"fields"`
+}
+
+// ExternalData is an HTTP data source returning a JSON document.
+type ExternalData struct {
+	// URL is the HTTP or HTTPS endpoint of the data source, e.g. `https://registry.example.com/allowed`.
+	URL string `json:"url" yaml:"url"`
+
+	// CacheTTL is the duration the fetched document is reused for, e.g. `5m`.
+	CacheTTL metav1.Duration `json:"cacheTTL" yaml:"cacheTTL"`
+}
+
+// RelatedResourceKind identifies the kind of related resources.
+type RelatedResourceKind struct {
+	// APIVersion is the API group version of the resources, e.g. `apps/v1`.
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+
+	// Kind is the kind of the resources, e.g. `Deployment`.
+	Kind string `json:"kind" yaml:"kind"`
 }
 
+// RequiredOwner identifies the kind of an owner.
+type RequiredOwner struct {
+	// APIGroup is the group of the owner, empty for the core group.
+	// +optional
+	APIGroup string `json:"apiGroup,omitempty" yaml:"apiGroup,omitempty"`
+
+	// Kind is the kind of the owner, e.g. `ReplicaSet`.
+	Kind string `json:"kind" yaml:"kind"`
+}
+
+// AllowedRegistries lists the approved registries inline or in the parameter resources.
+type AllowedRegistries struct {
+	// Registries is a list of approved registries, e.g. `ghcr.io` or `registry.example.com/team`.
+	// +optional
+	Registries []string `json:"registries,omitempty" yaml:"registries,omitempty"`
+
+	// ParamField is the dot separated path of the field of the parameter resource listing approved registries,
+	// e.g. `data.registries`. The field holds a list or a comma separated string.
+	// +optional
+	ParamField string `json:"paramField,omitempty" yaml:"paramField,omitempty"`
+}
+
+// ConnectAction configures how CONNECT requests are handled.
+// +kubebuilder:validation:Enum=Skip;Evaluate
+type ConnectAction string
+
+const (
+	// ConnectSkip skips the rule for CONNECT requests.
+	ConnectSkip ConnectAction = "Skip"
+	// ConnectEvaluate evaluates the expressions against the options of CONNECT requests.
+	ConnectEvaluate ConnectAction = "Evaluate"
+)
+
+// MutuallyExclusiveKeys is a group of label and annotation keys of which at most one may be set.
+type MutuallyExclusiveKeys struct {
+	// Labels is a list of label keys.
+	// +optional
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// Annotations is a list of annotation keys.
+	// +optional
+	Annotations []string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// EnvSource identifies where the value of a container environment variable comes from.
+// +kubebuilder:validation:Enum=Value;ConfigMapKeyRef;SecretKeyRef;FieldRef;ResourceFieldRef
+type EnvSource string
+
+const (
+	// EnvSourceValue means the value is set inline.
+	EnvSourceValue EnvSource = "Value"
+	// EnvSourceConfigMapKeyRef means the value is read from a key of a ConfigMap.
+	EnvSourceConfigMapKeyRef EnvSource = "ConfigMapKeyRef"
+	// EnvSourceSecretKeyRef means the value is read from a key of a Secret.
+	EnvSourceSecretKeyRef EnvSource = "SecretKeyRef"
+	// EnvSourceFieldRef means the value is read from a field of the pod.
+	EnvSourceFieldRef EnvSource = "FieldRef"
+	// EnvSourceResourceFieldRef means the value is read from a resource of the container.
+	EnvSourceResourceFieldRef EnvSource = "ResourceFieldRef"
+)
+
 func (c *CEL) HasParam() bool {
 	return c.ParamKind != nil && c.ParamRef != nil
 }
@@ -59,6 +59,48 @@ func (in *APICall) DeepCopy() *APICall {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedFields) DeepCopyInto(out *AllowedFields) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedFields.
+func (in *AllowedFields) DeepCopy() *AllowedFields {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedRegistries) DeepCopyInto(out *AllowedRegistries) {
+	*out = *in
+	if in.Registries != nil {
+		in, out := &in.Registries, &out.Registries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedRegistries.
+func (in *AllowedRegistries) DeepCopy() *AllowedRegistries {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedRegistries)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AnyAllConditions) DeepCopyInto(out *AnyAllConditions) {
 	*out = *in
@@ -243,6 +285,99 @@ func (in *CEL) DeepCopyInto(out *CEL) {
 		*out = make([]v1alpha1.Variable, len(*in))
 		copy(*out, *in)
 	}
+	if in.AllowedEnvSources != nil {
+		in, out := &in.AllowedEnvSources, &out.AllowedEnvSources
+		*out = make([]EnvSource, len(*in))
+		copy(*out, *in)
+	}
+	if in.FieldProjection != nil {
+		in, out := &in.FieldProjection, &out.FieldProjection
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ComputedLabels != nil {
+		in, out := &in.ComputedLabels, &out.ComputedLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MutuallyExclusive != nil {
+		in, out := &in.MutuallyExclusive, &out.MutuallyExclusive
+		*out = make([]MutuallyExclusiveKeys, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowedRegistries != nil {
+		in, out := &in.AllowedRegistries, &out.AllowedRegistries
+		*out = new(AllowedRegistries)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequiredOwners != nil {
+		in, out := &in.RequiredOwners, &out.RequiredOwners
+		*out = make([]RequiredOwner, len(*in))
+		copy(*out, *in)
+	}
+	if in.RelatedResources != nil {
+		in, out := &in.RelatedResources, &out.RelatedResources
+		*out = make([]RelatedResourceKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExternalData != nil {
+		in, out := &in.ExternalData, &out.ExternalData
+		*out = new(ExternalData)
+		**out = **in
+	}
+	if in.AllowedFields != nil {
+		in, out := &in.AllowedFields, &out.AllowedFields
+		*out = make([]AllowedFields, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LowercaseMetadata != nil {
+		in, out := &in.LowercaseMetadata, &out.LowercaseMetadata
+		*out = new(LowercaseMetadata)
+		**out = **in
+	}
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MessageRedactions != nil {
+		in, out := &in.MessageRedactions, &out.MessageRedactions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CostBudget != nil {
+		in, out := &in.CostBudget, &out.CostBudget
+		*out = new(int64)
+		**out = **in
+	}
+	if in.AdditionalParams != nil {
+		in, out := &in.AdditionalParams, &out.AdditionalParams
+		*out = make([]CELParams, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReinvocationPolicy != nil {
+		in, out := &in.ReinvocationPolicy, &out.ReinvocationPolicy
+		*out = new(admissionregistrationv1.ReinvocationPolicyType)
+		**out = **in
+	}
+	if in.ParamNamespaceSelector != nil {
+		in, out := &in.ParamNamespaceSelector, &out.ParamNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -256,6 +391,24 @@ func (in *CEL) DeepCopy() *CEL {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CELParams) DeepCopyInto(out *CELParams) {
+	*out = *in
+	out.ParamKind = in.ParamKind
+	in.ParamRef.DeepCopyInto(&out.ParamRef)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CELParams.
+func (in *CELParams) DeepCopy() *CELParams {
+	if in == nil {
+		return nil
+	}
+	out := new(CELParams)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CTLog) DeepCopyInto(out *CTLog) {
 	*out = *in
@@ -538,6 +691,23 @@ func (in *DryRunOption) DeepCopy() *DryRunOption {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalData) DeepCopyInto(out *ExternalData) {
+	*out = *in
+	out.CacheTTL = in.CacheTTL
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalData.
+func (in *ExternalData) DeepCopy() *ExternalData {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalData)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ForEachMutation) DeepCopyInto(out *ForEachMutation) {
 	*out = *in
@@ -881,6 +1051,22 @@ func (in *KeylessAttestor) DeepCopy() *KeylessAttestor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LowercaseMetadata) DeepCopyInto(out *LowercaseMetadata) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LowercaseMetadata.
+func (in *LowercaseMetadata) DeepCopy() *LowercaseMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(LowercaseMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Manifests) DeepCopyInto(out *Manifests) {
 	*out = *in
@@ -979,6 +1165,32 @@ func (in *Mutation) DeepCopy() *Mutation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MutuallyExclusiveKeys) DeepCopyInto(out *MutuallyExclusiveKeys) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MutuallyExclusiveKeys.
+func (in *MutuallyExclusiveKeys) DeepCopy() *MutuallyExclusiveKeys {
+	if in == nil {
+		return nil
+	}
+	out := new(MutuallyExclusiveKeys)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ObjectFieldBinding) DeepCopyInto(out *ObjectFieldBinding) {
 	*out = *in
@@ -1157,6 +1369,22 @@ func (in *Rekor) DeepCopy() *Rekor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RelatedResourceKind) DeepCopyInto(out *RelatedResourceKind) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelatedResourceKind.
+func (in *RelatedResourceKind) DeepCopy() *RelatedResourceKind {
+	if in == nil {
+		return nil
+	}
+	out := new(RelatedResourceKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RequestData) DeepCopyInto(out *RequestData) {
 	*out = *in
@@ -1178,6 +1406,22 @@ func (in *RequestData) DeepCopy() *RequestData {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequiredOwner) DeepCopyInto(out *RequiredOwner) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequiredOwner.
+func (in *RequiredOwner) DeepCopy() *RequiredOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(RequiredOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceDescription) DeepCopyInto(out *ResourceDescription) {
 	*out = *in
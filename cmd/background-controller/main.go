@@ -121,6 +121,7 @@ func main() {
 		internal.WithEventsClient(),
 		internal.WithApiServerClient(),
 		internal.WithMetadataClient(),
+		internal.WithCEL(),
 		internal.WithFlagSets(flagset),
 	)
 	// parse flags
@@ -195,6 +196,7 @@ func main() {
 			setup.RegistrySecretLister,
 			apicall.NewAPICallConfiguration(maxAPICallResponseLength),
 			gcstore,
+			internal.CELEngineOptions(setup.Logger, eventGenerator, event.GeneratePolicyController)...,
 		)
 		// start informers and wait for cache sync
 		if !internal.StartInformersAndWaitForCacheSync(signalCtx, setup.Logger, kyvernoInformer) {
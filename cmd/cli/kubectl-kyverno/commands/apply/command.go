@@ -31,6 +31,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/clients/dclient"
 	"github.com/kyverno/kyverno/pkg/config"
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	policyvalidate "github.com/kyverno/kyverno/pkg/policy/validate"
 	gitutils "github.com/kyverno/kyverno/pkg/utils/git"
 	policyvalidation "github.com/kyverno/kyverno/pkg/validation/policy"
 	"github.com/spf13/cobra"
@@ -49,29 +50,34 @@ type SkippedInvalidPolicies struct {
 }
 
 type ApplyCommandConfig struct {
-	KubeConfig     string
-	Context        string
-	Namespace      string
-	MutateLogPath  string
-	Variables      []string
-	ValuesFile     string
-	UserInfoPath   string
-	Cluster        bool
-	PolicyReport   bool
-	Stdin          bool
-	RegistryAccess bool
-	AuditWarn      bool
-	ResourcePaths  []string
-	PolicyPaths    []string
-	GitBranch      string
-	warnExitCode   int
-	warnNoPassed   bool
-	Exception      []string
-	ContinueOnFail bool
+	KubeConfig       string
+	Context          string
+	Namespace        string
+	MutateLogPath    string
+	Variables        []string
+	ValuesFile       string
+	UserInfoPath     string
+	Cluster          bool
+	PolicyReport     bool
+	Stdin            bool
+	RegistryAccess   bool
+	AuditWarn        bool
+	ResourcePaths    []string
+	PolicyPaths      []string
+	GitBranch        string
+	warnExitCode     int
+	warnNoPassed     bool
+	Exception        []string
+	ContinueOnFail   bool
+	DetailedResults  bool
+	DefaultNamespace string
+	AllDecisions     bool
+	// MaxAuditAnnotations is the maximum number of audit annotations of a CEL validation rule, unbounded when zero
+	MaxAuditAnnotations int
 }
 
 func Command() *cobra.Command {
-	var removeColor, detailedResults, table bool
+	var removeColor, table bool
 	applyCommandConfig := &ApplyCommandConfig{}
 	cmd := &cobra.Command{
 		Use:          "apply",
@@ -92,7 +98,7 @@ func Command() *cobra.Command {
 			if applyCommandConfig.PolicyReport {
 				printReport(out, responses, applyCommandConfig.AuditWarn)
 			} else if table {
-				printTable(out, detailedResults, applyCommandConfig.AuditWarn, responses...)
+				printTable(out, applyCommandConfig.DetailedResults, applyCommandConfig.AuditWarn, responses...)
 			} else {
 				printViolations(out, rc)
 			}
@@ -118,10 +124,13 @@ func Command() *cobra.Command {
 	cmd.Flags().IntVar(&applyCommandConfig.warnExitCode, "warn-exit-code", 0, "Set the exit code for warnings; if failures or errors are found, will exit 1")
 	cmd.Flags().BoolVar(&applyCommandConfig.warnNoPassed, "warn-no-pass", false, "Specify if warning exit code should be raised if no objects satisfied a policy; can be used together with --warn-exit-code flag")
 	cmd.Flags().BoolVar(&removeColor, "remove-color", false, "Remove any color from output")
-	cmd.Flags().BoolVar(&detailedResults, "detailed-results", false, "If set to true, display detailed results")
+	cmd.Flags().BoolVar(&applyCommandConfig.DetailedResults, "detailed-results", false, "If set to true, display detailed results")
 	cmd.Flags().BoolVarP(&table, "table", "t", false, "Show results in table format")
 	cmd.Flags().StringSliceVarP(&applyCommandConfig.Exception, "exception", "e", nil, "Policy exception to be considered when evaluating policies against resources")
 	cmd.Flags().StringSliceVarP(&applyCommandConfig.Exception, "exceptions", "", nil, "Policy exception to be considered when evaluating policies against resources")
+	cmd.Flags().StringVar(&applyCommandConfig.DefaultNamespace, "default-namespace", "", "Namespace used by CEL validations for namespaced resources without one")
+	cmd.Flags().BoolVar(&applyCommandConfig.AllDecisions, "all-decisions", false, "If set to true, report the decision of every CEL expression and param instead of the first failure")
+	cmd.Flags().IntVar(&applyCommandConfig.MaxAuditAnnotations, "cel-max-audit-annotations", policyvalidate.DefaultMaxAuditAnnotations, "Maximum number of audit annotations declared by a CEL validation rule, policies declaring more are invalid. Zero means no limit.")
 	cmd.Flags().BoolVar(&applyCommandConfig.ContinueOnFail, "continue-on-fail", false, "If set to true, will continue to apply policies on the next resource upon failure to apply to the current resource instead of exiting out")
 	return cmd
 }
@@ -267,7 +276,9 @@ func (c *ApplyCommandConfig) applyPolicytoResource(
 	validPolicies := make([]kyvernov1.PolicyInterface, 0, len(policies))
 	for _, pol := range policies {
 		// TODO we should return this info to the caller
-		_, err := policyvalidation.Validate(pol, nil, nil, nil, true, config.KyvernoUserName(config.KyvernoServiceAccountName()))
+		_, err := policyvalidation.Validate(pol, nil, nil, nil, true, config.KyvernoUserName(config.KyvernoServiceAccountName()), policyvalidate.Options{
+			MaxAuditAnnotations: c.MaxAuditAnnotations,
+		})
 		if err != nil {
 			log.Log.Error(err, "policy validation error")
 			rc.IncrementError(1)
@@ -301,6 +312,10 @@ func (c *ApplyCommandConfig) applyPolicytoResource(
 			AuditWarn:            c.AuditWarn,
 			Subresources:         vars.Subresources(),
 			Out:                  out,
+			DetailedResults:      c.DetailedResults,
+			RelatedResources:     resources,
+			DefaultNamespace:     c.DefaultNamespace,
+			AllDecisions:         c.AllDecisions,
 		}
 		ers, err := processor.ApplyPoliciesOnResource()
 		if err != nil {
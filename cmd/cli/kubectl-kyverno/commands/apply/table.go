@@ -1,6 +1,7 @@
 package apply
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/output/color"
@@ -48,9 +49,28 @@ func printTable(out io.Writer, compact, auditWarn bool, engineResponses ...engin
 				row.Result = color.ResultSkip()
 			}
 			row.Message = ruleResponse.Message()
+			if compact {
+				for _, result := range ruleResponse.ExpressionResults() {
+					row.Message += "\n" + formatExpressionResult(result)
+				}
+			}
 			resultsTable.Add(row)
 		}
 	}
 	printer := table.NewTablePrinter(out)
 	printer.Print(resultsTable.Rows(compact))
 }
+
+func formatExpressionResult(result engineapi.ExpressionResult) string {
+	msg := fmt.Sprintf("%s => %t", result.Expression, result.Result)
+	if result.Precondition {
+		msg = "precondition " + msg
+	}
+	if result.Param != "" {
+		msg += fmt.Sprintf(" (param %s)", result.Param)
+	}
+	if result.Error != "" {
+		msg += fmt.Sprintf(" (error: %s)", result.Error)
+	}
+	return msg
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/command"
+	policyvalidate "github.com/kyverno/kyverno/pkg/policy/validate"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +27,7 @@ func Command(keychain authn.Keychain) *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&options.imageRef, "image", "i", "", "image reference to push to or pull from")
+	cmd.Flags().IntVar(&options.maxAuditAnnotations, "cel-max-audit-annotations", policyvalidate.DefaultMaxAuditAnnotations, "Maximum number of audit annotations declared by a CEL validation rule, policies declaring more are invalid. Zero means no limit.")
 	if err := cmd.MarkFlagRequired("image"); err != nil {
 		log.Println("WARNING", err)
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/report"
 	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/test/filter"
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	policyvalidate "github.com/kyverno/kyverno/pkg/policy/validate"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/cache"
 )
@@ -22,6 +23,7 @@ func Command() *cobra.Command {
 	var testCase string
 	var fileName, gitBranch string
 	var registryAccess, failOnly, removeColor, detailedResults bool
+	var maxAuditAnnotations int
 	cmd := &cobra.Command{
 		Use:          "test [local folder or git repository]...",
 		Short:        command.FormatDescription(true, websiteUrl, false, description...),
@@ -31,7 +33,7 @@ func Command() *cobra.Command {
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, dirPath []string) (err error) {
 			color.Init(removeColor)
-			return testCommandExecute(cmd.OutOrStdout(), dirPath, fileName, gitBranch, testCase, registryAccess, failOnly, detailedResults)
+			return testCommandExecute(cmd.OutOrStdout(), dirPath, fileName, gitBranch, testCase, registryAccess, failOnly, detailedResults, maxAuditAnnotations)
 		},
 	}
 	cmd.Flags().StringVarP(&fileName, "file-name", "f", "kyverno-test.yaml", "Test filename")
@@ -41,6 +43,7 @@ func Command() *cobra.Command {
 	cmd.Flags().BoolVar(&failOnly, "fail-only", false, "If set to true, display all the failing test only as output for the test command")
 	cmd.Flags().BoolVar(&removeColor, "remove-color", false, "Remove any color from output")
 	cmd.Flags().BoolVar(&detailedResults, "detailed-results", false, "If set to true, display detailed results")
+	cmd.Flags().IntVar(&maxAuditAnnotations, "cel-max-audit-annotations", policyvalidate.DefaultMaxAuditAnnotations, "Maximum number of audit annotations declared by a CEL validation rule, policies declaring more are invalid. Zero means no limit.")
 	return cmd
 }
 
@@ -59,6 +62,7 @@ func testCommandExecute(
 	registryAccess bool,
 	failOnly bool,
 	detailedResults bool,
+	maxAuditAnnotations int,
 ) (err error) {
 	// check input dir
 	if len(dirPath) == 0 {
@@ -116,7 +120,7 @@ func testCommandExecute(
 				continue
 			}
 			resourcePath := filepath.Dir(test.Path)
-			responses, err := runTest(out, test, registryAccess)
+			responses, err := runTest(out, test, registryAccess, maxAuditAnnotations)
 			if err != nil {
 				return fmt.Errorf("failed to run test (%w)", err)
 			}
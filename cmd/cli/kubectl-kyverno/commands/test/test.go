@@ -24,11 +24,12 @@ import (
 	"github.com/kyverno/kyverno/pkg/clients/dclient"
 	"github.com/kyverno/kyverno/pkg/config"
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	policyvalidate "github.com/kyverno/kyverno/pkg/policy/validate"
 	policyvalidation "github.com/kyverno/kyverno/pkg/validation/policy"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func runTest(out io.Writer, testCase test.TestCase, registryAccess bool) ([]engineapi.EngineResponse, error) {
+func runTest(out io.Writer, testCase test.TestCase, registryAccess bool, maxAuditAnnotations int) ([]engineapi.EngineResponse, error) {
 	// don't process test case with errors
 	if testCase.Err != nil {
 		return nil, testCase.Err
@@ -153,7 +154,9 @@ func runTest(out io.Writer, testCase test.TestCase, registryAccess bool) ([]engi
 	validPolicies := make([]kyvernov1.PolicyInterface, 0, len(results.Policies))
 	for _, pol := range results.Policies {
 		// TODO we should return this info to the caller
-		_, err := policyvalidation.Validate(pol, nil, nil, nil, true, config.KyvernoUserName(config.KyvernoServiceAccountName()))
+		_, err := policyvalidation.Validate(pol, nil, nil, nil, true, config.KyvernoUserName(config.KyvernoServiceAccountName()), policyvalidate.Options{
+			MaxAuditAnnotations: maxAuditAnnotations,
+		})
 		if err != nil {
 			log.Log.Error(err, "skipping invalid policy", "name", pol.GetName())
 			continue
@@ -179,6 +182,7 @@ func runTest(out io.Writer, testCase test.TestCase, registryAccess bool) ([]engi
 			Client:                    dClient,
 			Subresources:              vars.Subresources(),
 			Out:                       out,
+			RelatedResources:          resources,
 		}
 		ers, err := processor.ApplyPoliciesOnResource()
 		if err != nil {
@@ -23,6 +23,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/adapters"
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
 	"github.com/kyverno/kyverno/pkg/engine/factories"
+	"github.com/kyverno/kyverno/pkg/engine/handlers/validation"
 	"github.com/kyverno/kyverno/pkg/engine/jmespath"
 	"github.com/kyverno/kyverno/pkg/engine/mutate/patch"
 	"github.com/kyverno/kyverno/pkg/engine/policycontext"
@@ -54,6 +55,15 @@ type PolicyProcessor struct {
 	AuditWarn                 bool
 	Subresources              []v1alpha1.Subresource
 	Out                       io.Writer
+	// DetailedResults attaches the outcome of every evaluated CEL expression to validate.cel rule responses
+	DetailedResults bool
+	// RelatedResources are the resources evaluated together with the resource, they are exposed to the
+	// expressions of validate.cel rules declaring their kinds
+	RelatedResources []*unstructured.Unstructured
+	// DefaultNamespace is the namespace validate.cel rules evaluate namespaced resources lacking one in
+	DefaultNamespace string
+	// AllDecisions reports the decision of every expression and param of validate.cel rules instead of the first failure
+	AllDecisions bool
 }
 
 func (p *PolicyProcessor) ApplyPoliciesOnResource() ([]engineapi.EngineResponse, error) {
@@ -72,6 +82,40 @@ func (p *PolicyProcessor) ApplyPoliciesOnResource() ([]engineapi.EngineResponse,
 	if rclient == nil {
 		rclient = registryclient.NewOrDie()
 	}
+	var engineOptions []engine.Option
+	if p.DetailedResults {
+		engineOptions = append(engineOptions, engine.WithValidateCELOptions(validation.WithExpressionResults()))
+	}
+	if len(p.RelatedResources) != 0 {
+		related := make([]unstructured.Unstructured, 0, len(p.RelatedResources))
+		for _, resource := range p.RelatedResources {
+			related = append(related, *resource)
+		}
+		engineOptions = append(engineOptions, engine.WithValidateCELOptions(validation.WithRelatedResources(related)))
+		// the namespaces of the resources are defined along with them
+		namespaces, err := namespacesOf(p.RelatedResources)
+		if err != nil {
+			return nil, err
+		}
+		if len(namespaces) != 0 {
+			engineOptions = append(engineOptions, engine.WithValidateCELOptions(validation.WithNamespaces(namespaces)))
+		}
+	}
+	if p.AllDecisions {
+		engineOptions = append(engineOptions, engine.WithValidateCELOptions(validation.WithAllDecisions()))
+	}
+	if p.DefaultNamespace != "" {
+		celOptions := []validation.ValidateCELOption{validation.WithDefaultNamespace(p.DefaultNamespace)}
+		// without a cluster, the scope of resources is known for the built-in kinds and the custom resources defined among the resources
+		if client == nil {
+			mapper, err := restMapperOf(p.RelatedResources)
+			if err != nil {
+				return nil, err
+			}
+			celOptions = append(celOptions, validation.WithRESTMapper(mapper))
+		}
+		engineOptions = append(engineOptions, engine.WithValidateCELOptions(celOptions...))
+	}
 	eng := engine.NewEngine(
 		cfg,
 		config.NewDefaultMetricsConfiguration(),
@@ -81,6 +125,7 @@ func (p *PolicyProcessor) ApplyPoliciesOnResource() ([]engineapi.EngineResponse,
 		imageverifycache.DisabledImageVerifyCache(),
 		store.ContextLoaderFactory(p.Store, nil),
 		policyExceptionLister,
+		engineOptions...,
 	)
 	gvk, subresource := resource.GroupVersionKind(), ""
 	// If --cluster flag is not set, then we need to find the top level resource GVK and subresource
@@ -0,0 +1,130 @@
+package processor
+
+import (
+	"io/fs"
+	"path"
+	"reflect"
+	"sync"
+
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/data"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterScopedKinds are the cluster scoped kinds of the client-go scheme, its other kinds are namespaced
+var clusterScopedKinds = sets.New(
+	schema.GroupKind{Kind: "ComponentStatus"},
+	schema.GroupKind{Kind: "Namespace"},
+	schema.GroupKind{Kind: "Node"},
+	schema.GroupKind{Kind: "PersistentVolume"},
+	schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"},
+	schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"},
+	schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"},
+	schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	schema.GroupKind{Group: "authentication.k8s.io", Kind: "SelfSubjectReview"},
+	schema.GroupKind{Group: "authentication.k8s.io", Kind: "TokenReview"},
+	schema.GroupKind{Group: "authorization.k8s.io", Kind: "SelfSubjectAccessReview"},
+	schema.GroupKind{Group: "authorization.k8s.io", Kind: "SelfSubjectRulesReview"},
+	schema.GroupKind{Group: "authorization.k8s.io", Kind: "SubjectAccessReview"},
+	schema.GroupKind{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"},
+	schema.GroupKind{Group: "certificates.k8s.io", Kind: "ClusterTrustBundle"},
+	schema.GroupKind{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"},
+	schema.GroupKind{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"},
+	schema.GroupKind{Group: "internal.apiserver.k8s.io", Kind: "StorageVersion"},
+	schema.GroupKind{Group: "networking.k8s.io", Kind: "IPAddress"},
+	schema.GroupKind{Group: "networking.k8s.io", Kind: "IngressClass"},
+	schema.GroupKind{Group: "networking.k8s.io", Kind: "ServiceCIDR"},
+	schema.GroupKind{Group: "node.k8s.io", Kind: "RuntimeClass"},
+	schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	schema.GroupKind{Group: "resource.k8s.io", Kind: "ResourceClass"},
+	schema.GroupKind{Group: "scheduling.k8s.io", Kind: "PriorityClass"},
+	schema.GroupKind{Group: "storage.k8s.io", Kind: "CSIDriver"},
+	schema.GroupKind{Group: "storage.k8s.io", Kind: "CSINode"},
+	schema.GroupKind{Group: "storage.k8s.io", Kind: "StorageClass"},
+	schema.GroupKind{Group: "storage.k8s.io", Kind: "VolumeAttachment"},
+	schema.GroupKind{Group: "storage.k8s.io", Kind: "VolumeAttributesClass"},
+)
+
+// builtinScopes are the scopes of the kinds of the client-go scheme and of the embedded CRDs, they are computed once
+var builtinScopes = sync.OnceValues(func() (map[schema.GroupVersionKind]meta.RESTScope, error) {
+	scopes := map[schema.GroupVersionKind]meta.RESTScope{
+		apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"): meta.RESTScopeRoot,
+		{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}:    meta.RESTScopeRoot,
+	}
+	// lists, options and events don't have an object meta, they aren't resources
+	objectType := reflect.TypeOf((*metav1.Object)(nil)).Elem()
+	for gvk, t := range scheme.Scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || !reflect.PointerTo(t).Implements(objectType) {
+			continue
+		}
+		if clusterScopedKinds.Has(gvk.GroupKind()) {
+			scopes[gvk] = meta.RESTScopeRoot
+		} else {
+			scopes[gvk] = meta.RESTScopeNamespace
+		}
+	}
+	files, err := fs.Glob(data.Crds(), path.Join(data.CrdsFolder, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(data.Crds(), file)
+		if err != nil {
+			return nil, err
+		}
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := yaml.Unmarshal(content, &crd); err != nil {
+			return nil, err
+		}
+		addCustomResourceScopes(scopes, crd)
+	}
+	return scopes, nil
+})
+
+// restMapperOf returns a REST mapper telling the scope of the built-in kinds and of the custom resources defined among the resources,
+// the scope of the other kinds is unknown.
+func restMapperOf(resources []*unstructured.Unstructured) (meta.RESTMapper, error) {
+	builtin, err := builtinScopes()
+	if err != nil {
+		return nil, err
+	}
+	scopes := make(map[schema.GroupVersionKind]meta.RESTScope, len(builtin))
+	for gvk, scope := range builtin {
+		scopes[gvk] = scope
+	}
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+		if gvk.Group != apiextensionsv1.GroupName || gvk.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.UnstructuredContent(), &crd); err != nil {
+			return nil, err
+		}
+		addCustomResourceScopes(scopes, crd)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk, scope := range scopes {
+		mapper.Add(gvk, scope)
+	}
+	return mapper, nil
+}
+
+// addCustomResourceScopes adds the scope of the kind defined by the CRD, in all its versions.
+func addCustomResourceScopes(scopes map[schema.GroupVersionKind]meta.RESTScope, crd apiextensionsv1.CustomResourceDefinition) {
+	scope := meta.RESTScopeRoot
+	if crd.Spec.Scope == apiextensionsv1.NamespaceScoped {
+		scope = meta.RESTScopeNamespace
+	}
+	for _, version := range crd.Spec.Versions {
+		scopes[schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}] = scope
+	}
+}
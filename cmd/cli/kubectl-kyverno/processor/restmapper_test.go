@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_restMapperOf(t *testing.T) {
+	tenants := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "tenants.example.com"},
+		"spec": map[string]interface{}{
+			"group":    "example.com",
+			"scope":    "Cluster",
+			"names":    map[string]interface{}{"kind": "Tenant", "plural": "tenants"},
+			"versions": []interface{}{map[string]interface{}{"name": "v1"}},
+		},
+	}}
+	mapper, err := restMapperOf([]*unstructured.Unstructured{tenants})
+	assert.NilError(t, err)
+	testCases := []struct {
+		gvk       schema.GroupVersionKind
+		known     bool
+		namespace bool
+	}{
+		{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, known: true, namespace: true},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, known: true, namespace: true},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, known: true},
+		{gvk: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, known: true},
+		{gvk: schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "ClusterPolicy"}, known: true},
+		{gvk: schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "Policy"}, known: true, namespace: true},
+		{gvk: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Tenant"}, known: true},
+		{gvk: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Team"}},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "PodList"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.gvk.String(), func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(tc.gvk.GroupKind(), tc.gvk.Version)
+			if !tc.known {
+				assert.Assert(t, meta.IsNoMatchError(err), err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, mapping.Scope.Name() == meta.RESTScopeNameNamespace, tc.namespace)
+		})
+	}
+}
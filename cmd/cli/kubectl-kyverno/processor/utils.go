@@ -2,6 +2,9 @@ package processor
 
 import (
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func policyHasValidateOrVerifyImageChecks(policy kyvernov1.PolicyInterface) bool {
@@ -13,3 +16,20 @@ func policyHasValidateOrVerifyImageChecks(policy kyvernov1.PolicyInterface) bool
 	}
 	return false
 }
+
+// namespacesOf returns the namespaces defined among the resources.
+func namespacesOf(resources []*unstructured.Unstructured) ([]corev1.Namespace, error) {
+	var namespaces []corev1.Namespace
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+		if gvk.Group != "" || gvk.Version != "v1" || gvk.Kind != "Namespace" {
+			continue
+		}
+		var namespace corev1.Namespace
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.UnstructuredContent(), &namespace); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"flag"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/handlers/validation"
+	"github.com/kyverno/kyverno/pkg/event"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+)
+
+// celFlags are the flags configuring the evaluation of CEL validation rules, they are shared by the controllers running the engine.
+// The CLI doesn't take them, it evaluates the rules with the defaults of the engine.
+type celFlags struct {
+	concurrencyLimit           int
+	queueTimeout               time.Duration
+	maxAuditAnnotationsLength  int
+	paramFetchTimeout          time.Duration
+	paramsPageSize             int64
+	maxParams                  int
+	maxParamNamespaces         int
+	parameterNotFoundAction    string
+	ruleTimeout                time.Duration
+	externalDataTimeout        time.Duration
+	externalDataCacheSize      int
+	externalDataURLs           string
+	externalDataAllowLocal     bool
+	aggregateDenials           bool
+	deduplicateDenials         bool
+	clusterContext             string
+	compilationCacheSize       int
+	semverLibrary              bool
+	paramCacheSize             int
+	paramCacheTTL              time.Duration
+	containersLibrary          bool
+	clockLibrary               bool
+	typedObjects               bool
+	fieldPaths                 bool
+	contextVariables           bool
+	authorizerBreakerThreshold int
+	authorizerBreakerCooldown  time.Duration
+	authorizerBreakerDecision  string
+	failClosedCompilation      bool
+	errorEventInterval         time.Duration
+}
+
+var cel celFlags
+
+func initCELFlags() {
+	flag.IntVar(&cel.concurrencyLimit, "celConcurrencyLimit", 0, "Maximum number of concurrent CEL evaluations of a single policy. Zero means no limit.")
+	flag.DurationVar(&cel.queueTimeout, "celQueueTimeout", time.Second, "Time a CEL evaluation waits for a slot when the concurrency limit of its policy is reached, e.g., 500ms, 1s.")
+	flag.IntVar(&cel.maxAuditAnnotationsLength, "celMaxAuditAnnotationsLength", validation.DefaultMaxAuditAnnotationsLength, "Maximum total length of the keys and values of the audit annotations published by a CEL validation rule, the values exceeding it are dropped. Zero means no limit.")
+	flag.DurationVar(&cel.paramFetchTimeout, "celParamFetchTimeout", validation.DefaultParamFetchTimeout, "Time allowed to fetch the parameter resources of a CEL validation rule, e.g., 500ms, 5s.")
+	flag.Int64Var(&cel.paramsPageSize, "celParamsPageSize", validation.DefaultParamsPageSize, "Number of parameter resources of a CEL validation rule listed at once. Zero disables paging.")
+	flag.IntVar(&cel.maxParams, "celMaxParams", validation.DefaultMaxParams, "Maximum number of parameter resources evaluated by a CEL validation rule, rules selecting more fail. Zero means no limit.")
+	flag.IntVar(&cel.maxParamNamespaces, "celMaxParamNamespaces", validation.DefaultMaxParamNamespaces, "Maximum number of namespaces selected by the paramNamespaceSelector of a CEL validation rule, rules selecting more fail. Zero means no limit.")
+	flag.StringVar(&cel.parameterNotFoundAction, "celParameterNotFoundAction", "", "Default action, Allow or Deny, taken when no parameter resources are found for the paramRefs of CEL validation rules lacking a parameterNotFoundAction. The action of a paramRef takes precedence, missing params are allowed when neither is set.")
+	flag.DurationVar(&cel.ruleTimeout, "celRuleTimeout", validation.DefaultRuleTimeout, "Time allowed to evaluate a CEL validation rule, including the fetch of its namespace and parameter resources, e.g., 500ms, 5s. Zero means no limit.")
+	flag.DurationVar(&cel.externalDataTimeout, "celExternalDataTimeout", 0, "Time allowed to fetch the external data source of a CEL validation rule, e.g., 500ms, 5s. Zero disables external data sources.")
+	flag.IntVar(&cel.externalDataCacheSize, "celExternalDataCacheSize", validation.DefaultExternalDataCacheSize, "Maximum number of documents of the external data sources of CEL validation rules kept until their cacheTTL expires. Zero disables the cache.")
+	flag.StringVar(&cel.externalDataURLs, "celExternalDataURLs", "", "Comma separated hosts and URL prefixes the external data sources of CEL validation rules may fetch, e.g. registry.example.com,https://data.example.com/kyverno/. Policies declaring other URLs are rejected.")
+	flag.BoolVar(&cel.externalDataAllowLocal, "celExternalDataAllowLocal", false, "Allow the external data sources of CEL validation rules to fetch loopback and link-local addresses.")
+	flag.BoolVar(&cel.aggregateDenials, "celAggregateDenials", false, "Report the denials of all params and expressions of a CEL validation rule instead of the first one.")
+	flag.BoolVar(&cel.deduplicateDenials, "celDeduplicateDenials", true, "Report identical aggregated denials of a CEL validation rule once.")
+	flag.StringVar(&cel.clusterContext, "celClusterContext", "", "Comma separated key=value pairs describing the cluster, available under clusterContext in CEL expressions, e.g. --celClusterContext=env=prod,region=eu-west-1")
+	flag.IntVar(&cel.compilationCacheSize, "celCompilationCacheSize", validation.DefaultCompilationCacheSize, "Maximum number of compiled CEL validation rules reused across evaluations. Zero disables the compilation cache.")
+	flag.BoolVar(&cel.semverLibrary, "celSemverLibrary", false, "Enable the semantic version functions in CEL validation rules, e.g. semver(object.spec.version).satisfies('>=1.25.0').")
+	flag.IntVar(&cel.paramCacheSize, "celParamCacheSize", validation.DefaultParamCacheSize, "Maximum number of lists of parameter resources selected by CEL validation rules reused across evaluations. Zero disables the param cache.")
+	flag.DurationVar(&cel.paramCacheTTL, "celParamCacheTTL", validation.DefaultParamCacheTTL, "Time the lists of parameter resources selected by CEL validation rules are reused, e.g., 1s, 5s. Zero disables the param cache.")
+	flag.BoolVar(&cel.containersLibrary, "celContainersLibrary", false, "Enable the container functions in CEL validation rules, e.g. allContainers(object).all(c, has(c.resources.limits)).")
+	flag.BoolVar(&cel.clockLibrary, "celClockLibrary", false, "Enable the time functions in CEL validation rules, e.g. now() < timestamp(object.metadata.annotations.expires). Rules calling them are not generated as ValidatingAdmissionPolicies.")
+	flag.BoolVar(&cel.typedObjects, "celTypedObjects", false, "Evaluate CEL validation rules against the typed form of built-in kinds, normalizing fields such as quantities as the API server does.")
+	flag.DurationVar(&cel.errorEventInterval, "celErrorEventInterval", validation.DefaultErrorEventInterval, "Minimum interval between two PolicyError events emitted on a policy for a CEL validation rule ending in error. Zero disables the events.")
+	flag.BoolVar(&cel.fieldPaths, "celFieldPaths", false, "Attach the paths of the object fields referenced by the denying CEL expressions to the rule responses and policy report results.")
+	flag.BoolVar(&cel.contextVariables, "celContextVariables", false, "Expose the values loaded by the context entries of CEL validation rules under 'context' in their expressions.")
+	flag.IntVar(&cel.authorizerBreakerThreshold, "celAuthorizerBreakerThreshold", 0, "Number of consecutive failed authorization checks of CEL expressions after which the checks are short-circuited for celAuthorizerBreakerCooldown. Zero disables the circuit breaker.")
+	flag.DurationVar(&cel.authorizerBreakerCooldown, "celAuthorizerBreakerCooldown", validation.DefaultAuthorizerBreakerCooldown, "Time the authorization checks of CEL expressions are short-circuited once the circuit breaker opened, e.g., 10s, 1m.")
+	flag.StringVar(&cel.authorizerBreakerDecision, "celAuthorizerBreakerDecision", string(validation.AuthorizerBreakerError), "Decision, Allow, Deny or Error, of the authorization checks of CEL expressions short-circuited by the open circuit breaker.")
+	flag.BoolVar(&cel.failClosedCompilation, "celFailClosedCompilation", false, "Fail the CEL validation rules whose expressions fail to compile, denying the resources in enforce mode, instead of ending them in error.")
+}
+
+// CELExternalDataURLs returns the URLs the external data sources of CEL validation rules may fetch.
+func CELExternalDataURLs(logger logr.Logger) celutils.ExternalDataURLs {
+	externalDataURLs, err := celutils.ParseExternalDataURLs(cel.externalDataURLs, cel.externalDataAllowLocal)
+	checkError(logger, err, "invalid celExternalDataURLs flag")
+	return externalDataURLs
+}
+
+// CELEngineOptions returns the engine options evaluating the CEL validation rules as configured by the flags,
+// the PolicyError events of the rules ending in error are emitted from the given source.
+func CELEngineOptions(logger logr.Logger, eventGenerator event.Interface, source event.Source) []engine.Option {
+	clusterContext, err := validation.ParseClusterContext(cel.clusterContext)
+	checkError(logger, err, "invalid celClusterContext flag")
+	parameterNotFoundAction, err := validation.ParseParameterNotFoundAction(cel.parameterNotFoundAction)
+	checkError(logger, err, "invalid celParameterNotFoundAction flag")
+	authorizerBreakerDecision, err := validation.ParseAuthorizerBreakerDecision(cel.authorizerBreakerDecision)
+	checkError(logger, err, "invalid celAuthorizerBreakerDecision flag")
+	options := []engine.Option{
+		engine.WithValidateCELOptions(
+			validation.WithMaxAuditAnnotationsLength(cel.maxAuditAnnotationsLength),
+			validation.WithParamFetchTimeout(cel.paramFetchTimeout),
+			validation.WithParamLimits(cel.paramsPageSize, cel.maxParams),
+			validation.WithMaxParamNamespaces(cel.maxParamNamespaces),
+			validation.WithDefaultParameterNotFoundAction(parameterNotFoundAction),
+			validation.WithRuleTimeout(cel.ruleTimeout),
+		),
+	}
+	if cel.concurrencyLimit > 0 {
+		limiter := validation.NewConcurrencyLimiter(cel.concurrencyLimit, cel.queueTimeout)
+		options = append(options, engine.WithValidateCELOptions(validation.WithConcurrencyLimiter(limiter)))
+	}
+	if cel.externalDataTimeout > 0 {
+		fetcher := validation.NewHTTPExternalDataFetcher(validation.NewExternalDataHTTPClient(cel.externalDataAllowLocal), CELExternalDataURLs(logger), cel.externalDataTimeout, validation.DefaultMaxExternalDataLength, cel.externalDataCacheSize)
+		options = append(options, engine.WithValidateCELOptions(validation.WithExternalDataFetcher(fetcher)))
+	}
+	if cel.aggregateDenials {
+		options = append(options, engine.WithValidateCELOptions(validation.WithDenialAggregation(cel.deduplicateDenials)))
+	}
+	if len(clusterContext) != 0 {
+		options = append(options, engine.WithValidateCELOptions(validation.WithClusterContext(clusterContext)))
+	}
+	if cel.compilationCacheSize > 0 {
+		cache := validation.NewCompilationCache(cel.compilationCacheSize)
+		options = append(options, engine.WithValidateCELOptions(validation.WithCompilationCache(cache)))
+	}
+	if cel.semverLibrary {
+		options = append(options, engine.WithValidateCELOptions(validation.WithSemverLibrary()))
+	}
+	if cel.containersLibrary {
+		options = append(options, engine.WithValidateCELOptions(validation.WithContainersLibrary()))
+	}
+	if cel.clockLibrary {
+		options = append(options, engine.WithValidateCELOptions(validation.WithClockLibrary()))
+	}
+	if cel.typedObjects {
+		options = append(options, engine.WithValidateCELOptions(validation.WithTypedObjects()))
+	}
+	if cel.fieldPaths {
+		options = append(options, engine.WithValidateCELOptions(validation.WithFieldPaths()))
+	}
+	if cel.contextVariables {
+		options = append(options, engine.WithValidateCELOptions(validation.WithContextVariables()))
+	}
+	if cel.authorizerBreakerThreshold > 0 {
+		breaker := validation.NewAuthorizerBreaker(cel.authorizerBreakerThreshold, cel.authorizerBreakerCooldown, authorizerBreakerDecision)
+		options = append(options, engine.WithValidateCELOptions(validation.WithAuthorizerBreaker(breaker)))
+	}
+	if cel.failClosedCompilation {
+		options = append(options, engine.WithValidateCELOptions(validation.WithFailClosedCompilation()))
+	}
+	if cel.errorEventInterval > 0 {
+		recorder := validation.NewErrorEventRecorder(eventGenerator, source, cel.errorEventInterval)
+		options = append(options, engine.WithValidateCELOptions(validation.WithErrorEvents(recorder)))
+	}
+	if cel.paramCacheSize > 0 && cel.paramCacheTTL > 0 {
+		cache := validation.NewParamCache(cel.paramCacheSize, cel.paramCacheTTL)
+		options = append(options, engine.WithValidateCELOptions(validation.WithParamCache(cache)))
+	}
+	return options
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/informers"
 	"github.com/kyverno/kyverno/pkg/leaderelection"
 	"github.com/kyverno/kyverno/pkg/logging"
+	policyvalidate "github.com/kyverno/kyverno/pkg/policy/validate"
 	"github.com/kyverno/kyverno/pkg/policycache"
 	"github.com/kyverno/kyverno/pkg/tls"
 	"github.com/kyverno/kyverno/pkg/toggle"
@@ -252,6 +253,7 @@ func main() {
 		maxAuditWorkers              int
 		maxAuditCapacity             int
 		maxAdmissionReports          int
+		celMaxAuditAnnotations       int
 	)
 	flagset := flag.NewFlagSet("kyverno", flag.ExitOnError)
 	flagset.BoolVar(&dumpPayload, "dumpPayload", false, "Set this flag to activate/deactivate debug mode.")
@@ -275,6 +277,8 @@ func main() {
 	flagset.IntVar(&maxAuditWorkers, "maxAuditWorkers", 8, "Maximum number of workers for audit policy processing")
 	flagset.IntVar(&maxAuditCapacity, "maxAuditCapacity", 1000, "Maximum capacity of the audit policy task queue")
 	flagset.IntVar(&maxAdmissionReports, "maxAdmissionReports", 10000, "Maximum number of admission reports before we stop creating new ones")
+	// policies are validated by the admission controller only, the other CEL flags are shared with the controllers running the engine
+	flagset.IntVar(&celMaxAuditAnnotations, "celMaxAuditAnnotations", policyvalidate.DefaultMaxAuditAnnotations, "Maximum number of audit annotations declared by a CEL validation rule, policies declaring more are rejected. Zero means no limit.")
 	// config
 	appConfig := internal.NewConfiguration(
 		internal.WithProfiling(),
@@ -294,6 +298,7 @@ func main() {
 		internal.WithEventsClient(),
 		internal.WithApiServerClient(),
 		internal.WithMetadataClient(),
+		internal.WithCEL(),
 		internal.WithFlagSets(flagset),
 	)
 	// parse flags
@@ -311,6 +316,7 @@ func main() {
 			setup.Logger.Error(errors.New("exiting... tlsSecretName is a required flag"), "exiting... tlsSecretName is a required flag")
 			os.Exit(1)
 		}
+		externalDataURLs := internal.CELExternalDataURLs(setup.Logger)
 		// check if validating admission policies are registered in the API server
 		generateValidatingAdmissionPolicy := toggle.FromContext(context.TODO()).GenerateValidatingAdmissionPolicy()
 		if generateValidatingAdmissionPolicy {
@@ -420,6 +426,7 @@ func main() {
 			setup.RegistrySecretLister,
 			apicall.NewAPICallConfiguration(maxAPICallResponseLength),
 			gcstore,
+			internal.CELEngineOptions(setup.Logger, eventGenerator, event.AdmissionController)...,
 		)
 		// create non leader controllers
 		nonLeaderControllers, nonLeaderBootstrap := createNonLeaderControllers(
@@ -514,6 +521,10 @@ func main() {
 			setup.KyvernoDynamicClient,
 			setup.KyvernoClient,
 			backgroundServiceAccountName,
+			policyvalidate.Options{
+				MaxAuditAnnotations: celMaxAuditAnnotations,
+				ExternalDataURLs:    &externalDataURLs,
+			},
 		)
 		ephrs, err := StartAdmissionReportsCounter(signalCtx, setup.MetadataClient)
 		if err != nil {
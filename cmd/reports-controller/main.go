@@ -232,6 +232,7 @@ func main() {
 		internal.WithKyvernoDynamicClient(),
 		internal.WithEventsClient(),
 		internal.WithApiServerClient(),
+		internal.WithCEL(),
 		internal.WithFlagSets(flagset),
 	)
 	// parse flags
@@ -308,6 +309,7 @@ func main() {
 			setup.RegistrySecretLister,
 			apicall.NewAPICallConfiguration(maxAPICallResponseLength),
 			gcstore,
+			internal.CELEngineOptions(setup.Logger, eventGenerator, event.PolicyController)...,
 		)
 		// start informers and wait for cache sync
 		if !internal.StartInformersAndWaitForCacheSync(ctx, setup.Logger, kyvernoInformer) {
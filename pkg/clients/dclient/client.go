@@ -40,6 +40,8 @@ type Interface interface {
 	// ListResource returns the list of resources in unstructured/json format
 	// Access items using []Items
 	ListResource(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector) (*unstructured.UnstructuredList, error)
+	// ListResourcePage returns a page of at most limit resources, the list is continued with the continue token of the previous page
+	ListResourcePage(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error)
 	// DeleteResource deletes the specified resource
 	DeleteResource(ctx context.Context, apiVersion string, kind string, namespace string, name string, dryRun bool) error
 	// CreateResource creates object for the specified resource/namespace
@@ -182,6 +184,16 @@ func (c *client) ListResource(ctx context.Context, apiVersion string, kind strin
 	return c.getResourceInterface(apiVersion, kind, namespace).List(ctx, options)
 }
 
+// ListResourcePage returns a page of at most limit resources in unstructured/json format
+// The list is continued with the continue token of the previous page, it is empty for the first page
+func (c *client) ListResourcePage(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	options := metav1.ListOptions{Limit: limit, Continue: continueToken}
+	if lselector != nil {
+		options.LabelSelector = metav1.FormatLabelSelector(lselector)
+	}
+	return c.getResourceInterface(apiVersion, kind, namespace).List(ctx, options)
+}
+
 // DeleteResource deletes the specified resource
 func (c *client) DeleteResource(ctx context.Context, apiVersion string, kind string, namespace string, name string, dryRun bool) error {
 	options := metav1.DeleteOptions{}
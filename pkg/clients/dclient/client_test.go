@@ -102,6 +102,10 @@ func TestCRUDResource(t *testing.T) {
 	if err != nil {
 		t.Errorf("ListResource not working: %s", err)
 	}
+	_, err = f.client.ListResourcePage(context.TODO(), "", "thekind", "ns-foo", nil, 10, "")
+	if err != nil {
+		t.Errorf("ListResourcePage not working: %s", err)
+	}
 	// DeleteResouce
 	err = f.client.DeleteResource(context.TODO(), "", "thekind", "ns-foo", "name-bar", false)
 	if err != nil {
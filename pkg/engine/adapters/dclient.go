@@ -55,6 +55,10 @@ func (a *dclientAdapter) ListResource(ctx context.Context, apiVersion string, ki
 	return a.client.ListResource(ctx, apiVersion, kind, namespace, lselector)
 }
 
+func (a *dclientAdapter) ListResourcePage(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	return a.client.ListResourcePage(ctx, apiVersion, kind, namespace, lselector, limit, continueToken)
+}
+
 func (a *dclientAdapter) IsNamespaced(group, version, kind string) (bool, error) {
 	gvrss, err := a.client.Discovery().FindResources(group, version, kind, "")
 	if err != nil {
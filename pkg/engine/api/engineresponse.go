@@ -198,23 +198,28 @@ func (er EngineResponse) GetValidationFailureAction() kyvernov1.ValidationFailur
 	if polType := pol.GetType(); polType == ValidatingAdmissionPolicyType {
 		return ""
 	}
-	spec := pol.AsKyvernoPolicy().GetSpec()
+	return ResolveValidationFailureAction(pol.AsKyvernoPolicy().GetSpec(), er.PatchedResource.GetNamespace(), er.namespaceLabels)
+}
+
+// ResolveValidationFailureAction returns the validation failure action of the policy that applies to the given namespace,
+// taking the validation failure action overrides into account.
+func ResolveValidationFailureAction(spec *kyvernov1.Spec, namespace string, namespaceLabels map[string]string) kyvernov1.ValidationFailureAction {
 	for _, v := range spec.ValidationFailureActionOverrides {
 		if !v.Action.IsValid() {
 			continue
 		}
 		if v.Namespaces == nil {
-			hasPass, err := utils.CheckSelector(v.NamespaceSelector, er.namespaceLabels)
+			hasPass, err := utils.CheckSelector(v.NamespaceSelector, namespaceLabels)
 			if err == nil && hasPass {
 				return v.Action
 			}
 		}
 		for _, ns := range v.Namespaces {
-			if wildcard.Match(ns, er.PatchedResource.GetNamespace()) {
+			if wildcard.Match(ns, namespace) {
 				if v.NamespaceSelector == nil {
 					return v.Action
 				}
-				hasPass, err := utils.CheckSelector(v.NamespaceSelector, er.namespaceLabels)
+				hasPass, err := utils.CheckSelector(v.NamespaceSelector, namespaceLabels)
 				if err == nil && hasPass {
 					return v.Action
 				}
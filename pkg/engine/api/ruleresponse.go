@@ -2,7 +2,9 @@ package api
 
 import (
 	"fmt"
+	"time"
 
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
 	kyvernov2beta1 "github.com/kyverno/kyverno/api/kyverno/v2beta1"
 	pssutils "github.com/kyverno/kyverno/pkg/pss/utils"
 	"k8s.io/api/admissionregistration/v1alpha1"
@@ -21,6 +23,42 @@ type PodSecurityChecks struct {
 	Checks []pssutils.PSSCheckResult
 }
 
+// ExpressionResult details the outcome of a single CEL expression evaluation
+type ExpressionResult struct {
+	// Expression is the evaluated CEL expression
+	Expression string
+	// Result is true when the expression evaluated to true
+	Result bool
+	// Error is the evaluation error, if any
+	Error string
+	// Param is the key of the parameter resource the expression was evaluated with, if any
+	Param string
+	// Precondition is true when the expression is a CEL precondition
+	Precondition bool
+}
+
+// AuthorizerCalls counts the authorization checks made by the CEL expressions of a rule
+type AuthorizerCalls struct {
+	// Requests is the number of checks sent to the API server
+	Requests int
+	// CacheHits is the number of checks answered from the decisions cached during the evaluation
+	CacheHits int
+	// ShortCircuits is the number of checks answered by the open authorizer circuit breaker without being sent
+	ShortCircuits int
+}
+
+// ParamReference identifies a parameter resource of a CEL rule
+type ParamReference struct {
+	// APIVersion is the API group version of the param
+	APIVersion string
+	// Kind is the kind of the param
+	Kind string
+	// Namespace is the namespace of the param, empty for cluster scoped params
+	Namespace string
+	// Name is the name of the param
+	Name string
+}
+
 // RuleResponse details for each rule application
 type RuleResponse struct {
 	// name is the rule name specified in policy
@@ -49,6 +87,36 @@ type RuleResponse struct {
 	binding *v1alpha1.ValidatingAdmissionPolicyBinding
 	// emitWarning enable passing rule message as warning to api server warning header
 	emitWarning bool
+	// expressionResults contains the outcome of each evaluated CEL expression (only if requested)
+	expressionResults []ExpressionResult
+	// objectDigest is the digest of the object the CEL expressions were evaluated against (only for CEL rules)
+	objectDigest string
+	// compiledAt is the time the CEL expressions were compiled (only for CEL rules)
+	compiledAt time.Time
+	// evaluationID identifies the evaluation in the logs (only for CEL rules)
+	evaluationID string
+	// validationFailureAction is the validation failure action that applies to the namespace of the resource (only for CEL rules)
+	validationFailureAction kyvernov1.ValidationFailureAction
+	// paramResourceVersions are the resource versions of the params indexed by their namespace/name keys (only for CEL rules)
+	paramResourceVersions map[string]string
+	// authorizerCalls counts the authorization checks made by the expressions (only for CEL rules)
+	authorizerCalls AuthorizerCalls
+	// cost is the runtime cost of the evaluated expressions (only for CEL rules)
+	cost int64
+	// deniedParams are the params the object was denied with (only for failed CEL rules using params)
+	deniedParams []ParamReference
+	// duration is the time spent compiling and evaluating the expressions (only for CEL rules)
+	duration time.Duration
+	// auditAnnotations are the audit annotations published by the evaluation indexed by their keys (only for CEL rules)
+	auditAnnotations map[string]string
+	// reason is the machine-readable reason of the failure, e.g. Forbidden or Invalid (only for failed CEL rules)
+	reason metav1.StatusReason
+	// fieldPaths are the paths of the object fields referenced by the denying expressions, e.g. spec.containers[0].image (only for failed CEL rules)
+	fieldPaths []string
+	// validationType is the engine that evaluated the rule, e.g. CEL (only for CEL rules)
+	validationType ValidationType
+	// suggestion tells which value would pass the rule, it is advisory (only for failed CEL rules)
+	suggestion string
 }
 
 func NewRuleResponse(name string, ruleType RuleType, msg string, status RuleStatus) *RuleResponse {
@@ -125,6 +193,81 @@ func (r RuleResponse) WithEmitWarning(emitWarning bool) *RuleResponse {
 	return &r
 }
 
+func (r RuleResponse) WithExpressionResults(results []ExpressionResult) *RuleResponse {
+	r.expressionResults = results
+	return &r
+}
+
+func (r RuleResponse) WithObjectDigest(digest string) *RuleResponse {
+	r.objectDigest = digest
+	return &r
+}
+
+func (r RuleResponse) WithCompiledAt(compiledAt time.Time) *RuleResponse {
+	r.compiledAt = compiledAt
+	return &r
+}
+
+func (r RuleResponse) WithEvaluationID(evaluationID string) *RuleResponse {
+	r.evaluationID = evaluationID
+	return &r
+}
+
+func (r RuleResponse) WithValidationFailureAction(action kyvernov1.ValidationFailureAction) *RuleResponse {
+	r.validationFailureAction = action
+	return &r
+}
+
+func (r RuleResponse) WithParamResourceVersions(versions map[string]string) *RuleResponse {
+	r.paramResourceVersions = versions
+	return &r
+}
+
+func (r RuleResponse) WithAuthorizerCalls(calls AuthorizerCalls) *RuleResponse {
+	r.authorizerCalls = calls
+	return &r
+}
+
+func (r RuleResponse) WithCost(cost int64) *RuleResponse {
+	r.cost = cost
+	return &r
+}
+
+func (r RuleResponse) WithDeniedParams(params []ParamReference) *RuleResponse {
+	r.deniedParams = params
+	return &r
+}
+
+func (r RuleResponse) WithDuration(duration time.Duration) *RuleResponse {
+	r.duration = duration
+	return &r
+}
+
+func (r RuleResponse) WithAuditAnnotations(annotations map[string]string) *RuleResponse {
+	r.auditAnnotations = annotations
+	return &r
+}
+
+func (r RuleResponse) WithReason(reason metav1.StatusReason) *RuleResponse {
+	r.reason = reason
+	return &r
+}
+
+func (r RuleResponse) WithFieldPaths(paths []string) *RuleResponse {
+	r.fieldPaths = paths
+	return &r
+}
+
+func (r RuleResponse) WithValidationType(validationType ValidationType) *RuleResponse {
+	r.validationType = validationType
+	return &r
+}
+
+func (r RuleResponse) WithSuggestion(suggestion string) *RuleResponse {
+	r.suggestion = suggestion
+	return &r
+}
+
 func (r *RuleResponse) Stats() ExecutionStats {
 	return r.stats
 }
@@ -173,6 +316,66 @@ func (r *RuleResponse) EmitWarning() bool {
 	return r.emitWarning
 }
 
+func (r *RuleResponse) ExpressionResults() []ExpressionResult {
+	return r.expressionResults
+}
+
+func (r *RuleResponse) ObjectDigest() string {
+	return r.objectDigest
+}
+
+func (r *RuleResponse) CompiledAt() time.Time {
+	return r.compiledAt
+}
+
+func (r *RuleResponse) EvaluationID() string {
+	return r.evaluationID
+}
+
+func (r *RuleResponse) ValidationFailureAction() kyvernov1.ValidationFailureAction {
+	return r.validationFailureAction
+}
+
+func (r *RuleResponse) ParamResourceVersions() map[string]string {
+	return r.paramResourceVersions
+}
+
+func (r *RuleResponse) AuthorizerCalls() AuthorizerCalls {
+	return r.authorizerCalls
+}
+
+func (r *RuleResponse) Cost() int64 {
+	return r.cost
+}
+
+func (r *RuleResponse) DeniedParams() []ParamReference {
+	return r.deniedParams
+}
+
+func (r *RuleResponse) Duration() time.Duration {
+	return r.duration
+}
+
+func (r *RuleResponse) AuditAnnotations() map[string]string {
+	return r.auditAnnotations
+}
+
+func (r *RuleResponse) Reason() metav1.StatusReason {
+	return r.reason
+}
+
+func (r *RuleResponse) FieldPaths() []string {
+	return r.fieldPaths
+}
+
+func (r *RuleResponse) ValidationType() ValidationType {
+	return r.validationType
+}
+
+func (r *RuleResponse) Suggestion() string {
+	return r.suggestion
+}
+
 // HasStatus checks if rule status is in a given list
 func (r *RuleResponse) HasStatus(status ...RuleStatus) bool {
 	for _, s := range status {
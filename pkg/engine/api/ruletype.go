@@ -13,3 +13,11 @@ const (
 	// ImageVerify type for image verification
 	ImageVerify RuleType = "ImageVerify"
 )
+
+// ValidationType represents the engine evaluating a validation rule
+type ValidationType string
+
+const (
+	// CELValidation type for validation rules evaluated with CEL expressions
+	CELValidation ValidationType = "CEL"
+)
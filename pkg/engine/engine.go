@@ -12,6 +12,7 @@ import (
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
 	enginecontext "github.com/kyverno/kyverno/pkg/engine/context"
 	"github.com/kyverno/kyverno/pkg/engine/handlers"
+	"github.com/kyverno/kyverno/pkg/engine/handlers/validation"
 	"github.com/kyverno/kyverno/pkg/engine/internal"
 	"github.com/kyverno/kyverno/pkg/engine/jmespath"
 	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
@@ -35,13 +36,26 @@ type engine struct {
 	ivCache              imageverifycache.Client
 	contextLoader        engineapi.ContextLoaderFactory
 	exceptionSelector    engineapi.PolicyExceptionSelector
+	validateCELOptions   []validation.ValidateCELOption
 	// metrics
-	resultCounter     metric.Int64Counter
-	durationHistogram metric.Float64Histogram
+	resultCounter                  metric.Int64Counter
+	durationHistogram              metric.Float64Histogram
+	authorizerCallsCounter         metric.Int64Counter
+	authorizerShortCircuitsCounter metric.Int64Counter
 }
 
 type handlerFactory = func() (handlers.Handler, error)
 
+// Option configures optional behaviour of the engine
+type Option func(e *engine)
+
+// WithValidateCELOptions sets the options used to create validate.cel rule handlers
+func WithValidateCELOptions(options ...validation.ValidateCELOption) Option {
+	return func(e *engine) {
+		e.validateCELOptions = append(e.validateCELOptions, options...)
+	}
+}
+
 func NewEngine(
 	configuration config.Configuration,
 	metricsConfiguration config.MetricsConfiguration,
@@ -51,6 +65,7 @@ func NewEngine(
 	ivCache imageverifycache.Client,
 	contextLoader engineapi.ContextLoaderFactory,
 	exceptionSelector engineapi.PolicyExceptionSelector,
+	options ...Option,
 ) engineapi.Engine {
 	meter := otel.GetMeterProvider().Meter(metrics.MeterName)
 	resultCounter, err := meter.Int64Counter(
@@ -67,18 +82,48 @@ func NewEngine(
 	if err != nil {
 		logging.Error(err, "failed to register metric kyverno_policy_execution_duration_seconds")
 	}
-	return &engine{
-		configuration:        configuration,
-		metricsConfiguration: metricsConfiguration,
-		jp:                   jp,
-		client:               client,
-		rclientFactory:       rclientFactory,
-		ivCache:              ivCache,
-		contextLoader:        contextLoader,
-		exceptionSelector:    exceptionSelector,
-		resultCounter:        resultCounter,
-		durationHistogram:    durationHistogram,
+	authorizerCallsCounter, err := meter.Int64Counter(
+		"kyverno_cel_authorizer_calls",
+		metric.WithDescription("can be used to track the authorization checks made by the CEL expressions of validate.cel rules, checks answered from the cache of the evaluation are reported separately from the requests sent to the API server"),
+	)
+	if err != nil {
+		logging.Error(err, "failed to register metric kyverno_cel_authorizer_calls")
+	}
+	authorizerShortCircuitsCounter, err := meter.Int64Counter(
+		"kyverno_cel_authorizer_short_circuits",
+		metric.WithDescription("can be used to track the authorization checks of the CEL expressions of validate.cel rules answered by the open authorizer circuit breaker without being sent to the API server, e.g. during an outage of the authorization backend"),
+	)
+	if err != nil {
+		logging.Error(err, "failed to register metric kyverno_cel_authorizer_short_circuits")
+	}
+	compilationFailuresCounter, err := meter.Int64Counter(
+		"kyverno_cel_compilation_failures",
+		metric.WithDescription("can be used to track the failures to compile the CEL expressions of validate.cel rules by policy, rule and compilation stage, rules failing to compile error on every evaluation"),
+	)
+	if err != nil {
+		logging.Error(err, "failed to register metric kyverno_cel_compilation_failures")
+	}
+	e := &engine{
+		configuration:                  configuration,
+		metricsConfiguration:           metricsConfiguration,
+		jp:                             jp,
+		client:                         client,
+		rclientFactory:                 rclientFactory,
+		ivCache:                        ivCache,
+		contextLoader:                  contextLoader,
+		exceptionSelector:              exceptionSelector,
+		resultCounter:                  resultCounter,
+		durationHistogram:              durationHistogram,
+		authorizerCallsCounter:         authorizerCallsCounter,
+		authorizerShortCircuitsCounter: authorizerShortCircuitsCounter,
+	}
+	if compilationFailuresCounter != nil {
+		e.validateCELOptions = append(e.validateCELOptions, validation.WithCompilationFailureCounter(compilationFailuresCounter))
+	}
+	for _, option := range options {
+		option(e)
 	}
+	return e
 }
 
 func (e *engine) Validate(
@@ -2,43 +2,547 @@ package validation
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
 	kyvernov2beta1 "github.com/kyverno/kyverno/api/kyverno/v2beta1"
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	enginecontext "github.com/kyverno/kyverno/pkg/engine/context"
 	"github.com/kyverno/kyverno/pkg/engine/handlers"
-	"github.com/kyverno/kyverno/pkg/engine/internal"
 	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
 	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
-	datautils "github.com/kyverno/kyverno/pkg/utils/data"
-	vaputils "github.com/kyverno/kyverno/pkg/validatingadmissionpolicy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apiserver/pkg/admission"
 	"k8s.io/apiserver/pkg/admission/plugin/cel"
 	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
 	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
-	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	authorizerapi "k8s.io/apiserver/pkg/authorization/authorizer"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
 	"k8s.io/client-go/tools/cache"
 )
 
+const (
+	// DefaultMaxAuditAnnotationsLength is the default maximum total length of the keys and values of the audit annotations published by a rule.
+	DefaultMaxAuditAnnotationsLength = 32 * 1024
+	// DefaultParamFetchTimeout is the default time allowed to fetch the parameter resources of a rule.
+	DefaultParamFetchTimeout = 5 * time.Second
+	// DefaultRuleTimeout is the default time allowed to evaluate a rule, including the calls made to the cluster.
+	DefaultRuleTimeout = 10 * time.Second
+	// DefaultParamsPageSize is the default number of parameter resources listed at once.
+	DefaultParamsPageSize = 500
+	// DefaultMaxParams is the default maximum number of parameter resources evaluated by a rule.
+	DefaultMaxParams = 1000
+	// DefaultMaxParamNamespaces is the default maximum number of namespaces a rule collects its parameter resources from.
+	DefaultMaxParamNamespaces = 20
+	// maxAuditAnnotationValueLength is the maximum length of the values of audit annotations, as for ValidatingAdmissionPolicies
+	maxAuditAnnotationValueLength = 10 * 1024
+)
+
 type validateCELHandler struct {
-	client engineapi.Client
+	client      engineapi.Client
+	paramLoader ParamLoader
+	explainPass bool
+	// explainAll attaches the outcome of the evaluated expressions to all rule responses, including failed ones
+	explainAll bool
+	// allDecisions reports every decision of every param as a rule response instead of stopping at the first denial
+	allDecisions bool
+	limiter      *ConcurrencyLimiter
+	// maxAuditAnnotationsLength is the maximum total length of the published audit annotations of a rule, unbounded when zero
+	maxAuditAnnotationsLength int
+	// paramFetchTimeout is the time allowed to fetch the parameter resources of a rule
+	paramFetchTimeout time.Duration
+	// ruleTimeout is the time allowed to evaluate a rule, the evaluation is unbounded when zero
+	ruleTimeout time.Duration
+	// paramLimits bounds the listing of the parameter resources of a rule
+	paramLimits paramLimits
+	// relatedResources are the resources evaluated together with the object
+	relatedResources []unstructured.Unstructured
+	// externalData fetches the documents of external data sources, they are disabled when nil
+	externalData ExternalDataFetcher
+	// aggregateDenials reports the denials of all params and expressions instead of the first one
+	aggregateDenials bool
+	// deduplicateDenials reports identical aggregated denials once
+	deduplicateDenials bool
+	// defaultNamespace is the namespace of namespaced resources lacking one, they are evaluated as is when empty
+	defaultNamespace string
+	// restMapper tells the scope of resources when no client is available, their scope is unknown when nil
+	restMapper meta.RESTMapper
+	// namespaces are the definitions of the namespaces indexed by their names, they are used without a client
+	namespaces map[string]corev1.Namespace
+	// costEstimator prices the function calls of expressions, the standard Kubernetes cost model is used when nil
+	costEstimator interpreter.ActualCostEstimator
+	// clusterContext describes the cluster to the expressions, e.g. its environment or region
+	clusterContext map[string]string
+	// semver declares the semantic version functions in the expressions
+	semver bool
+	// containers declares the container functions in the expressions
+	containers bool
+	// clock declares the time functions reading the clock of the handler in the expressions
+	clock bool
+	// typedObjects evaluates the objects of built-in kinds in their typed form
+	typedObjects bool
+	// fieldPaths attaches the paths of the object fields referenced by the denying expressions to the responses
+	fieldPaths bool
+	// contextVariables exposes the values loaded by the context entries of rules to the expressions
+	contextVariables bool
+	// failClosedCompilation fails the rules whose expressions fail to compile instead of ending them in error
+	failClosedCompilation bool
+	// errorEvents emits events on the policies of the rules ending in error, no events are emitted when nil
+	errorEvents *ErrorEventRecorder
+	// compilationFailures counts the failures to compile the expressions of rules, they aren't counted when nil
+	compilationFailures metric.Int64Counter
+	// compilationCache keeps the compiled expressions of rules across evaluations, rules are compiled on every evaluation when nil
+	compilationCache *CompilationCache
+	// paramCache keeps the lists of params selected by label selector across evaluations, they are listed on every evaluation when nil
+	paramCache *ParamCache
+	// now returns the current time, it timestamps compilations, ends grace periods and is read by the now() function of the expressions
+	now func() time.Time
+	// authorizerBreaker short-circuits the authorization checks after consecutive failures, they are always sent when nil
+	authorizerBreaker *AuthorizerBreaker
+	// newAuthorizer creates the authorizer of the expressions referencing it
+	newAuthorizer AuthorizerFactory
+	// newValidator creates the validator evaluating the compiled expressions
+	newValidator func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator
+}
+
+type ValidateCELOption func(h *validateCELHandler)
+
+// WithParamLoader overrides the ParamLoader used to resolve parameter resources.
+func WithParamLoader(loader ParamLoader) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramLoader = loader
+	}
+}
+
+// WithAuthorizerFactory overrides the authorizer of the expressions, e.g. to answer the checks from a cache
+// or from another authorization backend. The checks are sent to the API server as SubjectAccessReviews by default.
+func WithAuthorizerFactory(factory AuthorizerFactory) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.newAuthorizer = factory
+	}
+}
+
+// WithPassExplanation attaches the outcome of every evaluated precondition and validation expression
+// to passing and skipped rule responses.
+func WithPassExplanation() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.explainPass = true
+	}
+}
+
+// WithExpressionResults attaches the outcome of every evaluated precondition and validation expression
+// to all rule responses, including failed and errored ones, e.g. to debug policies with many expressions.
+func WithExpressionResults() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.explainPass = true
+		h.explainAll = true
+	}
+}
+
+// WithAllDecisions reports the decision of every expression evaluated against every param as a separate rule response,
+// instead of stopping at the first denial, e.g. to author policies. Denials aren't aggregated in this mode.
+func WithAllDecisions() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.allDecisions = true
+	}
+}
+
+// WithConcurrencyLimiter bounds the number of concurrent evaluations of each policy.
+func WithConcurrencyLimiter(limiter *ConcurrencyLimiter) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.limiter = limiter
+	}
+}
+
+// WithMaxAuditAnnotationsLength overrides the maximum total length of the keys and values of the audit annotations
+// published by a rule, the values exceeding it are dropped. Zero means no limit.
+func WithMaxAuditAnnotationsLength(max int) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.maxAuditAnnotationsLength = max
+	}
+}
+
+// WithParamFetchTimeout overrides the time allowed to fetch the parameter resources of a rule.
+func WithParamFetchTimeout(timeout time.Duration) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramFetchTimeout = timeout
+	}
+}
+
+// WithRuleTimeout overrides the time allowed to evaluate a rule, including the fetch of its namespace and params
+// and the calls of its expressions to the authorizer. Zero disables the timeout.
+func WithRuleTimeout(timeout time.Duration) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.ruleTimeout = timeout
+	}
+}
+
+// WithParamLimits lists the parameter resources of rules by pages of the given size and bounds the number
+// of parameter resources evaluated by a rule, rules selecting more params fail. Zero disables the bound.
+func WithParamLimits(pageSize int64, max int) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramLimits.pageSize = pageSize
+		h.paramLimits.max = max
+	}
+}
+
+// WithDefaultParameterNotFoundAction sets the action taken when no params are found for the paramRefs lacking a
+// parameterNotFoundAction, e.g. Deny to fail closed cluster-wide. The action of a paramRef always takes precedence,
+// params are allowed to be missing when neither is set.
+func WithDefaultParameterNotFoundAction(action admissionregistrationv1alpha1.ParameterNotFoundActionType) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramLimits.notFoundAction = action
+	}
+}
+
+// WithMaxParamNamespaces bounds the number of namespaces selected by the paramNamespaceSelector of a rule,
+// rules selecting more namespaces fail so that the params of the whole cluster aren't listed.
+func WithMaxParamNamespaces(max int) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramLimits.namespaces = max
+	}
+}
+
+// WithRelatedResources provides the resources evaluated together with the object, e.g. the documents of a manifest.
+// The resources of the kinds declared by a rule are available under `relatedResources` in its expressions.
+func WithRelatedResources(resources []unstructured.Unstructured) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.relatedResources = resources
+	}
+}
+
+// WithExternalDataFetcher enables the external data sources declared by rules using the given fetcher.
+func WithExternalDataFetcher(fetcher ExternalDataFetcher) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.externalData = fetcher
+	}
+}
+
+// WithDenialAggregation reports the denials of all params and expressions of a rule in its message, separated by semicolons,
+// instead of the first one. Identical denials are reported once when deduplicate is true.
+func WithDenialAggregation(deduplicate bool) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.aggregateDenials = true
+		h.deduplicateDenials = deduplicate
+	}
+}
+
+// WithDefaultNamespace evaluates namespaced resources lacking a namespace, e.g. in offline evaluations,
+// as if they were admitted in the given namespace. Cluster scoped resources and resources of unknown scope are left untouched,
+// the scope is discovered with the client or told by the REST mapper, see WithRESTMapper.
+func WithDefaultNamespace(namespace string) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.defaultNamespace = namespace
+	}
+}
+
+// WithRESTMapper tells the scope of resources without a client, e.g. in offline evaluations.
+func WithRESTMapper(mapper meta.RESTMapper) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.restMapper = mapper
+	}
+}
+
+// WithNamespaces provides the definitions of the namespaces of the evaluated resources, e.g. the namespaces of a resources file
+// in offline evaluations, so that their labels and annotations are available under `namespaceObject`. They are only used without a client,
+// the namespaces lacking a definition only have a name.
+func WithNamespaces(namespaces []corev1.Namespace) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.namespaces = make(map[string]corev1.Namespace, len(namespaces))
+		for _, namespace := range namespaces {
+			h.namespaces[namespace.Name] = namespace
+		}
+	}
+}
+
+// WithCostEstimator overrides the runtime cost of the function calls of expressions, e.g. to weight operations
+// according to the size of the objects of a cluster. The cost budget of the evaluations is unchanged.
+func WithCostEstimator(estimator interpreter.ActualCostEstimator) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.costEstimator = estimator
+	}
+}
+
+// WithSemverLibrary declares the semantic version functions, e.g. `semver(object.spec.version).satisfies('>=1.25.0')`,
+// in the expressions of all rules. See celutils.SemverLibrary.
+func WithSemverLibrary() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.semver = true
+	}
+}
+
+// WithAuthorizerBreaker short-circuits the authorization checks of the expressions with the given breaker
+// when the authorization backend keeps failing, see AuthorizerBreaker.
+func WithAuthorizerBreaker(breaker *AuthorizerBreaker) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.authorizerBreaker = breaker
+	}
+}
+
+// WithClock overrides the clock of the handler, e.g. to evaluate time-dependent expressions deterministically in tests.
+// It ends the grace periods of rules and is read by the now() function of the expressions, see WithClockLibrary.
+func WithClock(now func() time.Time) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.now = now
+	}
+}
+
+// WithClockLibrary declares the time functions reading the clock of the handler, e.g.
+// `now() < timestamp(object.metadata.annotations.expires)`, in the expressions of all rules. See celutils.ClockLibrary.
+// The API server doesn't declare them, the rules calling them are not generated as ValidatingAdmissionPolicies.
+func WithClockLibrary() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.clock = true
+	}
+}
+
+// WithContainersLibrary declares the container functions, e.g. `allContainers(object).all(c, has(c.resources.limits))`,
+// in the expressions of all rules. See celutils.ContainersLibrary.
+func WithContainersLibrary() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.containers = true
+	}
+}
+
+// WithTypedObjects decodes the objects of built-in kinds, e.g. Pods, to their typed form before they are evaluated,
+// so that their fields are normalized as by the API server, e.g. quantities are canonical. Custom resources are
+// evaluated as is.
+func WithTypedObjects() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.typedObjects = true
+	}
+}
+
+// WithFieldPaths attaches the paths of the object fields referenced by the denying expressions, e.g.
+// spec.containers[0].image, to the responses of failed rules so that clients can point at the offending fields.
+// The paths are derived from the expressions and are best-effort, see celutils.FieldPaths.
+func WithFieldPaths() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.fieldPaths = true
+	}
 }
 
-func NewValidateCELHandler(client engineapi.Client) (handlers.Handler, error) {
-	return validateCELHandler{
-		client: client,
-	}, nil
+// WithContextVariables exposes the values loaded by the context entries of rules, e.g. API calls or config maps,
+// under `context` in the expressions of the rules, e.g. `context.registryAllowlist.data`. They are namespaced
+// so that the names of the entries can't collide with the variables of the expressions, e.g. object or params.
+func WithContextVariables() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.contextVariables = true
+	}
+}
+
+// WithFailClosedCompilation fails the rules whose expressions, variables, message expressions, audit annotations
+// or preconditions fail to compile, denying the resources in enforce mode. Such rules end in error otherwise, which
+// is not blocking, and only the rules whose validation expressions fail to compile skip their evaluation.
+func WithFailClosedCompilation() ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.failClosedCompilation = true
+	}
+}
+
+// WithClusterContext exposes the given entries describing the cluster, e.g. its environment or region,
+// under `clusterContext` in the expressions of all rules. Its size is bounded, see MaxClusterContextEntries.
+func WithClusterContext(clusterContext map[string]string) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.clusterContext = clusterContext
+	}
+}
+
+// WithCompilationCache reuses the compiled expressions of rules kept by the given cache across evaluations.
+func WithCompilationCache(cache *CompilationCache) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.compilationCache = cache
+	}
+}
+
+// WithParamCache reuses the lists of params selected by label selector kept by the given cache across evaluations.
+func WithParamCache(cache *ParamCache) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.paramCache = cache
+	}
+}
+
+// WithErrorEvents emits a Warning event on the policy of the rules ending in error with the given recorder,
+// e.g. when their expressions fail to compile or to evaluate.
+func WithErrorEvents(recorder *ErrorEventRecorder) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.errorEvents = recorder
+	}
+}
+
+// WithCompilationFailureCounter counts the failures to compile the expressions of rules with the given counter,
+// by policy, rule and compilation stage.
+func WithCompilationFailureCounter(counter metric.Int64Counter) ValidateCELOption {
+	return func(h *validateCELHandler) {
+		h.compilationFailures = counter
+	}
+}
+
+func NewValidateCELHandler(client engineapi.Client, options ...ValidateCELOption) (handlers.Handler, error) {
+	return newValidateCELHandler(client, options...)
+}
+
+// NewValidateCELBatchHandler returns a handler evaluating the CEL rules of a policy against the same request, the rules
+// share the authorizer, the versioned admission attributes of the resource and the lookup of its namespace. A handler
+// must be created for each evaluation of a policy.
+func NewValidateCELBatchHandler(client engineapi.Client, options ...ValidateCELOption) (handlers.Handler, error) {
+	h, err := newValidateCELHandler(client, options...)
+	if err != nil {
+		return nil, err
+	}
+	return batchHandler{handler: h, batch: &ruleBatch{}}, nil
+}
+
+func newValidateCELHandler(client engineapi.Client, options ...ValidateCELOption) (validateCELHandler, error) {
+	h := validateCELHandler{
+		client:                    client,
+		paramLoader:               NewClientParamLoader(client),
+		newAuthorizer:             NewClientAuthorizer,
+		newValidator:              validatingadmissionpolicy.NewValidator,
+		now:                       time.Now,
+		maxAuditAnnotationsLength: DefaultMaxAuditAnnotationsLength,
+		paramFetchTimeout:         DefaultParamFetchTimeout,
+		ruleTimeout:               DefaultRuleTimeout,
+		paramLimits:               paramLimits{pageSize: DefaultParamsPageSize, max: DefaultMaxParams, namespaces: DefaultMaxParamNamespaces},
+	}
+	for _, option := range options {
+		option(&h)
+	}
+	if err := checkClusterContext(h.clusterContext); err != nil {
+		return validateCELHandler{}, err
+	}
+	if _, err := ParseParameterNotFoundAction(string(h.paramLimits.notFoundAction)); err != nil {
+		return validateCELHandler{}, err
+	}
+	return h, nil
+}
+
+// objectInterfaces convert the admitted objects, they are built once as the scheme is empty and never mutated,
+// the admitted objects are unstructured
+var objectInterfaces = admission.NewObjectInterfacesFromScheme(runtime.NewScheme())
+
+// ruleBatch holds the state shared by the evaluations of the rules of a batch.
+type ruleBatch struct {
+	// authorizer authorizes the requests of the expressions, it is created by the first rule
+	authorizer authorizerapi.Authorizer
+	// versionedAttr are the attributes of the admitted object, they are created by the first rule evaluating it unchanged
+	versionedAttr *admission.VersionedAttributes
+	// object is the content of the resource the attributes were created for, e.g. a reinvoked rule evaluates another one
+	object map[string]interface{}
+	// namespaces are the namespaces fetched by the rules indexed by their names, errors are not kept
+	namespaces map[string]*corev1.Namespace
+	// digest is the digest of the content of the last evaluated object, it is reused by the rules evaluating it unchanged
+	digest   string
+	digested map[string]interface{}
+}
+
+// batchHandler evaluates the rules of a policy with the state of its batch.
+type batchHandler struct {
+	handler validateCELHandler
+	batch   *ruleBatch
+}
+
+func (h batchHandler) Process(
+	ctx context.Context,
+	logger logr.Logger,
+	policyContext engineapi.PolicyContext,
+	resource unstructured.Unstructured,
+	rule kyvernov1.Rule,
+	contextLoader engineapi.EngineContextLoader,
+	exceptions []kyvernov2beta1.PolicyException,
+) (unstructured.Unstructured, []engineapi.RuleResponse) {
+	return h.handler.processRule(ctx, logger, policyContext, resource, rule, contextLoader, exceptions, h.batch)
 }
 
 func (h validateCELHandler) Process(
+	ctx context.Context,
+	logger logr.Logger,
+	policyContext engineapi.PolicyContext,
+	resource unstructured.Unstructured,
+	rule kyvernov1.Rule,
+	contextLoader engineapi.EngineContextLoader,
+	exceptions []kyvernov2beta1.PolicyException,
+) (unstructured.Unstructured, []engineapi.RuleResponse) {
+	return h.processRule(ctx, logger, policyContext, resource, rule, contextLoader, exceptions, &ruleBatch{})
+}
+
+func (h validateCELHandler) processRule(
+	ctx context.Context,
+	logger logr.Logger,
+	policyContext engineapi.PolicyContext,
+	resource unstructured.Unstructured,
+	rule kyvernov1.Rule,
+	contextLoader engineapi.EngineContextLoader,
+	exceptions []kyvernov2beta1.PolicyException,
+	batch *ruleBatch,
+) (unstructured.Unstructured, []engineapi.RuleResponse) {
+	// the evaluation id correlates the log lines of an evaluation with its rule responses
+	evaluationID := rand.String(8)
+	logger = logger.WithValues("evaluationId", evaluationID)
+	logger.V(4).Info("evaluating CEL validation rule")
+	// resolve the validation failure action that applies to the namespace of the resource
+	namespace := resource.GetNamespace()
+	if resource.Object == nil {
+		namespace = policyContext.OldResource().GetNamespace()
+	}
+	namespace = requestNamespace(policyContext, namespace)
+	action := engineapi.ResolveValidationFailureAction(policyContext.Policy().GetSpec(), namespace, policyContext.NamespaceLabels())
+	evaluated := resource
+	resource, responses := h.process(ctx, logger, policyContext, resource, rule, contextLoader, exceptions, batch)
+	// evaluate the rule once more when the resource was mutated in the meantime, the new outcome replaces the first one
+	if reinvoke, latest := needsReinvocation(rule, policyContext, evaluated); reinvoke {
+		logger.V(3).Info("re-evaluating CEL validation rule against the mutated resource")
+		resource, responses = h.process(ctx, logger, policyContext, latest, rule, contextLoader, exceptions, &ruleBatch{})
+	}
+	for i := range responses {
+		responses[i] = *responses[i].WithEvaluationID(evaluationID).WithValidationFailureAction(action).WithValidationType(engineapi.CELValidation)
+		logger.V(4).Info("evaluated CEL validation rule", "status", responses[i].Status())
+		if h.errorEvents != nil {
+			h.errorEvents.Record(policyContext.Policy(), responses[i])
+		}
+	}
+	return resource, responses
+}
+
+// needsReinvocation tells whether the rule asks to be evaluated again and the resource of the policy context,
+// which is returned, differs from the evaluated one.
+func needsReinvocation(rule kyvernov1.Rule, policyContext engineapi.PolicyContext, evaluated unstructured.Unstructured) (bool, unstructured.Unstructured) {
+	policy := rule.Validation.CEL.ReinvocationPolicy
+	if policy == nil || *policy != admissionregistrationv1.IfNeededReinvocationPolicy {
+		return false, evaluated
+	}
+	latest := policyContext.NewResource()
+	if latest.Object == nil || reflect.DeepEqual(latest.Object, evaluated.Object) {
+		return false, evaluated
+	}
+	return true, latest
+}
+
+func (h validateCELHandler) process(
 	ctx context.Context,
 	logger logr.Logger,
 	policyContext engineapi.PolicyContext,
@@ -46,6 +550,7 @@ func (h validateCELHandler) Process(
 	rule kyvernov1.Rule,
 	_ engineapi.EngineContextLoader,
 	exceptions []kyvernov2beta1.PolicyException,
+	batch *ruleBatch,
 ) (unstructured.Unstructured, []engineapi.RuleResponse) {
 	// check if there is a policy exception matches the incoming resource
 	exception := engineutils.MatchesException(exceptions, policyContext, logger)
@@ -66,204 +571,678 @@ func (h validateCELHandler) Process(
 	vapStatus := policyContext.Policy().GetStatus().ValidatingAdmissionPolicy
 	if vapStatus.Generated {
 		logger.V(3).Info("skipping CEL validation due to the generation of its corresponding ValidatingAdmissionPolicy")
-		return resource, nil
+		return resource, handlers.WithResponses(
+			engineapi.RuleSkip(rule.Name, engineapi.Validation, "handled by generated ValidatingAdmissionPolicy"),
+		)
 	}
 
-	// get resource's name, namespace, GroupVersionResource, and GroupVersionKind
-	gvr := schema.GroupVersionResource(policyContext.RequestResource())
-	gvk, _ := policyContext.ResourceKind()
-	policyKind := policyContext.Policy().GetKind()
-	policyName := policyContext.Policy().GetName()
+	// CONNECT requests carry the options of the request, e.g. PodExecOptions, instead of the connected resource
+	connect := policyContext.Operation() == kyvernov1.Connect
+	if connect && rule.Validation.CEL.Connect != kyvernov1.ConnectEvaluate {
+		return resource, handlers.WithSkip(rule, engineapi.Validation, "rule skipped for CONNECT requests")
+	}
 
-	// in case of UPDATE requests, set the oldObject to the current resource before it gets updated
-	var object, oldObject runtime.Object
-	oldResource := policyContext.OldResource()
-	if oldResource.Object == nil {
-		oldObject = nil
-	} else {
-		oldObject = oldResource.DeepCopyObject()
+	// wait for an evaluation slot of the policy
+	if h.limiter != nil {
+		policyKey, _ := cache.MetaNamespaceKeyFunc(policyContext.Policy())
+		release, err := h.limiter.Acquire(ctx, policyKey)
+		if err != nil {
+			return resource, handlers.WithError(rule, engineapi.Validation, "failed to acquire a CEL evaluation slot", err)
+		}
+		defer release()
 	}
 
-	var ns, name string
-	// in case of DELETE request, get the name and the namespace from the old object
-	if resource.Object == nil {
-		ns = oldResource.GetNamespace()
-		name = oldResource.GetName()
-		object = nil
-	} else {
-		ns = resource.GetNamespace()
-		name = resource.GetName()
-		object = resource.DeepCopyObject()
-	}
-
-	// check if the rule uses parameter resources
-	hasParam := rule.Validation.CEL.HasParam()
-	// extract preconditions written as CEL expressions
-	matchConditions := rule.CELPreconditions
-	// extract CEL expressions used in validations and audit annotations
-	variables := rule.Validation.CEL.Variables
-	validations := rule.Validation.CEL.Expressions
-	for i := range validations {
-		if validations[i].Message == "" {
-			validations[i].Message = rule.Validation.Message
+	e, responses := h.newRuleEvaluation(logger, policyContext, resource, rule, batch)
+	if responses != nil {
+		return resource, responses
+	}
+	// the fetch of the namespace, params and referenced resources and the evaluation are bounded, so that the webhook
+	// answers in time
+	e.ctx, e.ruleCtx = ctx, ctx
+	if h.ruleTimeout > 0 {
+		var cancel context.CancelFunc
+		e.ruleCtx, cancel = context.WithTimeout(ctx, h.ruleTimeout)
+		defer cancel()
+	}
+	// each stage ends the evaluation when it returns the responses of the rule
+	for _, stage := range []func() []engineapi.RuleResponse{
+		e.bind,
+		e.compileExpressions,
+		e.selectNamespace,
+		e.buildAttributes,
+		e.collectParams,
+		e.evaluate,
+	} {
+		if responses := stage(); responses != nil {
+			return resource, responses
 		}
 	}
-	auditAnnotations := rule.Validation.CEL.AuditAnnotations
+	return resource, e.responses()
+}
 
-	optionalVars := cel.OptionalVariableDeclarations{HasParams: hasParam, HasAuthorizer: true}
-	expressionOptionalVars := cel.OptionalVariableDeclarations{HasParams: hasParam, HasAuthorizer: false}
-	// compile CEL expressions
-	compiler, err := celutils.NewCompiler(validations, auditAnnotations, vaputils.ConvertMatchConditionsV1(matchConditions), variables)
+// compile compiles the expressions of a rule with the given inputs.
+func (h validateCELHandler) compile(inputs compilationInputs) (compiledRule, error) {
+	compilerOptions := []environment.VersionedOptions{celutils.Bindings(inputs.Bindings...)}
+	// price the function calls of the expressions with the custom cost estimator
+	if h.costEstimator != nil {
+		compilerOptions = append(compilerOptions, celutils.CostEstimator(h.costEstimator))
+	}
+	if h.semver {
+		compilerOptions = append(compilerOptions, celutils.SemverLibrary())
+	}
+	if h.containers {
+		compilerOptions = append(compilerOptions, celutils.ContainersLibrary())
+	}
+	// the time functions read the clock of the handler
+	if h.clock {
+		compilerOptions = append(compilerOptions, celutils.ClockLibrary(h.now))
+	}
+	compiler, err := celutils.NewCompiler(inputs.Validations, inputs.AuditAnnotations, inputs.MatchConditions, inputs.Variables, compilerOptions...)
 	if err != nil {
-		return resource, handlers.WithError(rule, engineapi.Validation, "Error while creating composited compiler", err)
-	}
-	compiler.CompileVariables(optionalVars)
-	filter := compiler.CompileValidateExpressions(optionalVars)
-	messageExpressionfilter := compiler.CompileMessageExpressions(expressionOptionalVars)
-	auditAnnotationFilter := compiler.CompileAuditAnnotationsExpressions(optionalVars)
-	matchConditionFilter := compiler.CompileMatchExpressions(optionalVars)
-
-	// newMatcher will be used to check if the incoming resource matches the CEL preconditions
-	newMatcher := matchconditions.NewMatcher(matchConditionFilter, nil, policyKind, "", policyName)
-	// newValidator will be used to validate CEL expressions against the incoming object
-	validator := validatingadmissionpolicy.NewValidator(filter, newMatcher, auditAnnotationFilter, messageExpressionfilter, nil)
-
-	var namespace *corev1.Namespace
-	// Special case, the namespace object has the namespace of itself.
-	// unset it if the incoming object is a namespace
-	if gvk.Kind == "Namespace" && gvk.Version == "v1" && gvk.Group == "" {
-		ns = ""
-	}
-	if ns != "" {
-		if h.client != nil {
-			namespace, err = h.client.GetNamespace(ctx, ns, metav1.GetOptions{})
-			if err != nil {
-				return resource, handlers.WithResponses(
-					engineapi.RuleError(rule.Name, engineapi.Validation, "Error getting the resource's namespace", err),
-				)
-			}
-		} else {
-			namespace = &corev1.Namespace{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: ns,
-				},
-			}
+		return compiledRule{}, err
+	}
+	optionalVars := cel.OptionalVariableDeclarations{HasParams: inputs.HasParam, HasAuthorizer: inputs.HasAuthorizer}
+	expressionOptionalVars := cel.OptionalVariableDeclarations{HasParams: inputs.HasParam, HasAuthorizer: false}
+	compiled := compiledRule{compiledAt: h.now()}
+	// the redactions and the field selector are parsed once along with the expressions, invalid field selectors are
+	// rejected when the policy is created
+	compiled.redactions = parseRedactions(inputs.Redactions)
+	if inputs.ParamFieldSelector != "" {
+		selector, err := fields.ParseSelector(inputs.ParamFieldSelector)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("invalid paramFieldSelector: %w", err)
+		}
+		compiled.paramFieldSelector = selector
+	}
+	if errs := compiler.CompileVariables(optionalVars); len(errs) != 0 {
+		compiled.failedStages = append(compiled.failedStages, compilationStageVariables)
+		compiled.compilationErrors = append(compiled.compilationErrors, errs...)
+	}
+	compiled.filter = compiler.CompileValidateExpressions(optionalVars)
+	compiled.messageFilter = compiler.CompileMessageExpressions(expressionOptionalVars)
+	compiled.auditAnnotationFilter = compiler.CompileAuditAnnotationsExpressions(optionalVars)
+	compiled.matchConditionFilter = compiler.CompileMatchExpressions(optionalVars)
+	compiled.suggestionFilter = compiler.CompileSuggestionExpression(inputs.Suggestion, expressionOptionalVars)
+	for _, stage := range []struct {
+		name   string
+		filter cel.Filter
+	}{
+		{compilationStageValidate, compiled.filter},
+		{compilationStageMessage, compiled.messageFilter},
+		{compilationStageAudit, compiled.auditAnnotationFilter},
+		{compilationStageMatch, compiled.matchConditionFilter},
+		{compilationStageSuggestion, compiled.suggestionFilter},
+	} {
+		if errs := stage.filter.CompilationErrors(); len(errs) != 0 {
+			compiled.failedStages = append(compiled.failedStages, stage.name)
+			compiled.compilationErrors = append(compiled.compilationErrors, errs...)
 		}
 	}
+	// point at the validation expressions failing to compile
+	if len(compiled.filter.CompilationErrors()) != 0 {
+		compiled.validationErrors = compiler.ValidationErrors(optionalVars)
+	}
+	return compiled, nil
+}
 
-	requestInfo := policyContext.AdmissionInfo()
-	userInfo := internal.NewUser(requestInfo.AdmissionUserInfo.Username, requestInfo.AdmissionUserInfo.UID, requestInfo.AdmissionUserInfo.Groups)
-	attr := admission.NewAttributesRecord(object, oldObject, gvk, ns, name, gvr, "", admission.Operation(policyContext.Operation()), nil, false, &userInfo)
-	o := admission.NewObjectInterfacesFromScheme(runtime.NewScheme())
-	versionedAttr, err := admission.NewVersionedAttributes(attr, attr.GetKind(), o)
-	if err != nil {
-		return resource, handlers.WithError(rule, engineapi.Validation, "error while creating versioned attributes", err)
+// compilationFailure returns the response of a rule whose expressions failed to compile, it fails the rule
+// in fail closed mode and ends it in error otherwise.
+func (h validateCELHandler) compilationFailure(rule kyvernov1.Rule, msg string, err error) []engineapi.RuleResponse {
+	if h.failClosedCompilation {
+		return handlers.WithFail(rule, engineapi.Validation, fmt.Sprintf("%s: %s", msg, err.Error()))
+	}
+	return handlers.WithError(rule, engineapi.Validation, msg, err)
+}
+
+// recordCompilationFailure counts a failure to compile the expressions of a rule at the given stage.
+func (h validateCELHandler) recordCompilationFailure(ctx context.Context, policyName, ruleName, stage string) {
+	if h.compilationFailures == nil {
+		return
 	}
-	authorizer := internal.NewAuthorizer(h.client, gvk)
-	// validate the incoming object against the rule
-	var validationResults []validatingadmissionpolicy.ValidateResult
-	if hasParam {
-		paramKind := rule.Validation.CEL.ParamKind
-		paramRef := rule.Validation.CEL.ParamRef
+	h.compilationFailures.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("policy_name", policyName),
+		attribute.String("rule_name", ruleName),
+		attribute.String("stage", stage),
+	))
+}
 
-		params, err := collectParams(ctx, h.client, paramKind, paramRef, ns)
+// failedVariable returns the failure of the variables used by an expression failing to evaluate, given the errors
+// of the rule's compilation and the variables which failed to evaluate. The innermost failure is returned when
+// variables use failed variables: a variable failing to compile or the first variable failing to evaluate.
+func failedVariable(expression string, variables []admissionregistrationv1alpha1.Variable, compilationErrors []error, failures []celutils.VariableError) (celutils.VariableError, bool) {
+	expressions := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		expressions[variable.Name] = variable.Expression
+	}
+	uncompiled := map[string]celutils.VariableError{}
+	for _, err := range compilationErrors {
+		var variableErr celutils.VariableError
+		if errors.As(err, &variableErr) {
+			uncompiled[variableErr.Name] = variableErr
+		}
+	}
+	used := usedVariables(expression, expressions)
+	var failed *celutils.VariableError
+	for i := range failures {
+		if slices.Contains(used, failures[i].Name) {
+			failed = &failures[i]
+			// the variable may fail because of a variable it uses failing to compile
+			used = usedVariables(expressions[failed.Name], expressions)
+			break
+		}
+	}
+	for _, name := range used {
+		if variableErr, ok := uncompiled[name]; ok {
+			return variableErr, true
+		}
+	}
+	if failed != nil {
+		return *failed, true
+	}
+	return celutils.VariableError{}, false
+}
+
+// usedVariables returns the names of the variables referenced by an expression, directly or through the expressions
+// of the variables it references, in the order they are referenced.
+func usedVariables(expression string, expressions map[string]string) []string {
+	var used []string
+	var visit func(expression string)
+	visit = func(expression string) {
+		references, err := celutils.FieldReferences(expression, "variables")
 		if err != nil {
-			return resource, handlers.WithResponses(
-				engineapi.RuleError(rule.Name, engineapi.Validation, "error in parameterized resource", err),
-			)
+			return
+		}
+		for _, reference := range references {
+			if len(reference) < 2 || slices.Contains(used, reference[1]) {
+				continue
+			}
+			used = append(used, reference[1])
+			visit(expressions[reference[1]])
 		}
+	}
+	visit(expression)
+	return used
+}
+
+// selectsNamespace tells whether a namespace with the given labels is selected by the selector.
+func selectsNamespace(selector *metav1.LabelSelector, namespaceLabels map[string]string) (bool, error) {
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return namespaceSelector.Matches(labels.Set(namespaceLabels)), nil
+}
 
-		for _, param := range params {
-			validationResults = append(validationResults, validator.Validate(ctx, gvr, versionedAttr, param, namespace, celconfig.RuntimeCELCostBudget, &authorizer))
+// isDenied tells whether the result has a denial.
+func isDenied(result validatingadmissionpolicy.ValidateResult) bool {
+	for _, decision := range result.Decisions {
+		if decision.Action == validatingadmissionpolicy.ActionDeny {
+			return true
 		}
-	} else {
-		validationResults = append(validationResults, validator.Validate(ctx, gvr, versionedAttr, nil, namespace, celconfig.RuntimeCELCostBudget, &authorizer))
 	}
+	return false
+}
 
-	for _, validationResult := range validationResults {
-		// no validations are returned if preconditions aren't met
-		if datautils.DeepEqual(validationResult, validatingadmissionpolicy.ValidateResult{}) {
-			return resource, handlers.WithResponses(
-				engineapi.RuleSkip(rule.Name, engineapi.Validation, "cel preconditions not met"),
-			)
+// denialKey identifies identical denials when they are deduplicated
+type denialKey struct {
+	message string
+	action  validatingadmissionpolicy.PolicyDecisionAction
+}
+
+// withFallbackMessages returns a copy of the validations with their static message set.
+// The message of a failed validation is taken from, in order of precedence:
+//  1. its messageExpression, when it evaluates to a non empty, single line string
+//  2. its message
+//  3. the message of the rule
+//  4. a default message naming the failed expression
+//
+// The first level is applied by the validator which falls back to the static message set here.
+func withFallbackMessages(validations []admissionregistrationv1alpha1.Validation, ruleMessage string) []admissionregistrationv1alpha1.Validation {
+	result := make([]admissionregistrationv1alpha1.Validation, 0, len(validations))
+	for _, validation := range validations {
+		if strings.TrimSpace(validation.Message) == "" {
+			validation.Message = ruleMessage
+		}
+		if strings.TrimSpace(validation.Message) == "" {
+			validation.Message = fmt.Sprintf("failed expression: %s", strings.TrimSpace(validation.Expression))
 		}
+		result = append(result, validation)
+	}
+	return result
+}
 
-		for _, decision := range validationResult.Decisions {
-			switch decision.Action {
-			case validatingadmissionpolicy.ActionAdmit:
-				if decision.Evaluation == validatingadmissionpolicy.EvalError {
-					return resource, handlers.WithResponses(
-						engineapi.RuleError(rule.Name, engineapi.Validation, decision.Message, nil),
-					)
-				}
-			case validatingadmissionpolicy.ActionDeny:
-				return resource, handlers.WithResponses(
-					engineapi.RuleFail(rule.Name, engineapi.Validation, decision.Message),
-				)
-			}
+// contextValues returns the values loaded by the given context entries indexed by their names,
+// the entries whose values can't be queried are left out.
+func contextValues(logger logr.Logger, entries []kyvernov1.ContextEntry, jsonContext enginecontext.Interface) map[string]interface{} {
+	values := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		value, err := jsonContext.Query(entry.Name)
+		if err != nil {
+			logger.V(4).Info("failed to query the value of the context entry", "name", entry.Name, "error", err.Error())
+			continue
 		}
+		values[entry.Name] = value
 	}
+	return values
+}
 
-	msg := fmt.Sprintf("Validation rule '%s' passed.", rule.Name)
-	return resource, handlers.WithResponses(
-		engineapi.RulePass(rule.Name, engineapi.Validation, msg),
-	)
+// sameContent tells whether the given contents are the same map, the objects of a request aren't copied.
+func sameContent(a, b map[string]interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
 }
 
-func collectParams(ctx context.Context, client engineapi.Client, paramKind *admissionregistrationv1alpha1.ParamKind, paramRef *admissionregistrationv1alpha1.ParamRef, namespace string) ([]runtime.Object, error) {
-	var params []runtime.Object
+// bindingNames returns the sorted names of the variables bound to the given values, they are declared when the
+// expressions are compiled.
+func bindingNames(bindings map[string]interface{}) []string {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// referencesVariable tells whether one of the expressions references the variable, identifiers are looked up in the
+// parsed expressions so that string literals and field names are ignored. Expressions failing to parse are assumed to
+// reference it, they fail when compiled anyway.
+func referencesVariable(expressions []string, name string) bool {
+	for _, expression := range expressions {
+		if expression == "" {
+			continue
+		}
+		references, err := celutils.FieldReferences(expression, name)
+		if err != nil || len(references) != 0 {
+			return true
+		}
+	}
+	return false
+}
 
-	apiVersion := paramKind.APIVersion
-	kind := paramKind.Kind
-	gv, err := schema.ParseGroupVersion(apiVersion)
+// requestField returns a string field of the admission request, e.g. its name, it is empty when unset.
+func requestField(policyContext engineapi.PolicyContext, field string) string {
+	value, err := policyContext.JSONContext().Query("request." + field)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse the parameter resource group version")
+		return ""
 	}
+	str, _ := value.(string)
+	return str
+}
+
+// requestNamespace returns the namespace of the admission request, it is authoritative over the namespace of the
+// objects which may be unset or disagree with the request, e.g. for the options of CONNECT requests or malformed requests.
+// The given namespace is returned when the request has no namespace, e.g. for background scans.
+func requestNamespace(policyContext engineapi.PolicyContext, namespace string) string {
+	if ns := requestField(policyContext, "namespace"); ns != "" {
+		return ns
+	}
+	return namespace
+}
 
-	// If `paramKind` is cluster-scoped, then paramRef.namespace MUST be unset.
-	// If `paramKind` is namespace-scoped, the namespace of the object being evaluated for admission will be used
-	// when paramRef.namespace is left unset.
-	var paramsNamespace string
-	isNamespaced, err := client.IsNamespaced(gv.Group, gv.Version, kind)
+// requestKindOf returns the kind of the object submitted with the request.
+// It differs from the kind of the resource for subresources, e.g. `autoscaling/v1, Kind=Scale` for `deployments/scale`.
+func requestKindOf(gvk schema.GroupVersionKind, subresource string, resource, oldResource unstructured.Unstructured) schema.GroupVersionKind {
+	if subresource == "" {
+		return gvk
+	}
+	if resource.Object != nil && !resource.GroupVersionKind().Empty() {
+		return resource.GroupVersionKind()
+	}
+	if oldResource.Object != nil && !oldResource.GroupVersionKind().Empty() {
+		return oldResource.GroupVersionKind()
+	}
+	return gvk
+}
+
+// publishedAuditAnnotations returns the audit annotations published by the evaluations indexed by their keys.
+// As for ValidatingAdmissionPolicies, values are truncated and the distinct values of a key evaluated against several
+// params are joined with commas. Annotations failing to evaluate are not published.
+// The total length of the keys and values is bounded by maxLength unless it is zero, the values published last are
+// dropped first. The number of dropped values is returned.
+func publishedAuditAnnotations(validationResults []validatingadmissionpolicy.ValidateResult, maxLength int) (map[string]string, int) {
+	var keys []string
+	values := map[string][]string{}
+	length, dropped := 0, 0
+	for _, validationResult := range validationResults {
+		for _, auditAnnotation := range validationResult.AuditAnnotations {
+			if auditAnnotation.Action != validatingadmissionpolicy.AuditAnnotationActionPublish {
+				continue
+			}
+			value := auditAnnotation.Value
+			if len(value) > maxAuditAnnotationValueLength {
+				value = value[:maxAuditAnnotationValueLength]
+			}
+			keyValues, found := values[auditAnnotation.Key]
+			if slices.Contains(keyValues, value) {
+				continue
+			}
+			// the first value of a key is counted with the key, the next ones with their separator
+			valueLength := len(value) + len(", ")
+			if !found {
+				valueLength = len(auditAnnotation.Key) + len(value)
+			}
+			if maxLength > 0 && length+valueLength > maxLength {
+				dropped++
+				continue
+			}
+			length += valueLength
+			if !found {
+				keys = append(keys, auditAnnotation.Key)
+			}
+			values[auditAnnotation.Key] = append(keyValues, value)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, dropped
+	}
+	annotations := make(map[string]string, len(keys))
+	for _, key := range keys {
+		annotations[key] = strings.Join(values[key], ", ")
+	}
+	return annotations, dropped
+}
+
+// isDryRun tells whether the request is a dry run, it is false when evaluating outside of an admission request, e.g. in the CLI.
+func isDryRun(policyContext engineapi.PolicyContext) bool {
+	dryRun, err := policyContext.JSONContext().Query("request.dryRun")
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if resource is namespaced or not (%w)", err)
+		return false
+	}
+	value, _ := dryRun.(bool)
+	return value
+}
+
+// withComputedLabels merges the given labels onto the labels of a copy of the object, overriding existing keys
+func withComputedLabels(obj runtime.Object, computedLabels map[string]string) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return obj
+	}
+	u = u.DeepCopy()
+	labels := u.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string, len(computedLabels))
+	}
+	for key, value := range computedLabels {
+		labels[key] = value
 	}
+	u.SetLabels(labels)
+	return u
+}
 
-	// check if `paramKind` is namespace-scoped
-	if isNamespaced {
-		// set params namespace to the incoming object's namespace by default.
-		paramsNamespace = namespace
-		if paramRef.Namespace != "" {
-			paramsNamespace = paramRef.Namespace
-		} else if paramsNamespace == "" {
-			return nil, fmt.Errorf("can't use namespaced paramRef to match cluster-scoped resources")
+// lowercased returns a copy of the given map with lowercased keys, and lowercased values when requested.
+// Keys colliding once lowercased are resolved in lexical order, the first key wins.
+func lowercased(values map[string]string, lowercaseValues bool) map[string]string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	result := make(map[string]string, len(values))
+	for _, key := range keys {
+		lowered := strings.ToLower(key)
+		if _, ok := result[lowered]; ok {
+			continue
 		}
-	} else {
-		// It isn't allowed to set namespace for cluster-scoped params
-		if paramRef.Namespace != "" {
-			return nil, fmt.Errorf("paramRef.namespace must not be provided for a cluster-scoped `paramKind`")
+		value := values[key]
+		if lowercaseValues {
+			value = strings.ToLower(value)
 		}
+		result[lowered] = value
 	}
+	return result
+}
 
-	if paramRef.Name != "" {
-		param, err := client.GetResource(ctx, apiVersion, kind, paramsNamespace, paramRef.Name, "")
-		if err != nil {
-			return nil, err
+// validateWithRecover runs the validator and converts a panic raised during the evaluation into an error
+func validateWithRecover(
+	ctx context.Context,
+	logger logr.Logger,
+	validator validatingadmissionpolicy.Validator,
+	gvr schema.GroupVersionResource,
+	versionedAttr *admission.VersionedAttributes,
+	param runtime.Object,
+	namespace *corev1.Namespace,
+	costBudget int64,
+	authz authorizerapi.Authorizer,
+) (result validatingadmissionpolicy.ValidateResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.V(1).Info("recovered from a panic during CEL evaluation", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("CEL evaluation panicked: %v", r)
 		}
-		return []runtime.Object{param}, nil
-	} else if paramRef.Selector != nil {
-		paramList, err := client.ListResource(ctx, apiVersion, kind, paramsNamespace, paramRef.Selector)
-		if err != nil {
-			return nil, err
+	}()
+	return validator.Validate(ctx, gvr, versionedAttr, param, namespace, costBudget, authz), nil
+}
+
+// expressionRecorder collects the outcome of the expressions evaluated by the filters it wraps
+type expressionRecorder struct {
+	// param is the key of the parameter resource being evaluated
+	param   string
+	results []engineapi.ExpressionResult
+}
+
+func (r *expressionRecorder) wrap(filter cel.Filter, precondition bool) cel.Filter {
+	return &recordingFilter{
+		Filter:       filter,
+		recorder:     r,
+		precondition: precondition,
+	}
+}
+
+// recordingFilter records the evaluation results of the wrapped filter
+type recordingFilter struct {
+	cel.Filter
+	recorder     *expressionRecorder
+	precondition bool
+}
+
+func (f *recordingFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	results, remainingBudget, err := f.Filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	for _, result := range results {
+		if result.ExpressionAccessor == nil {
+			continue
+		}
+		expressionResult := engineapi.ExpressionResult{
+			Expression:   result.ExpressionAccessor.GetExpression(),
+			Result:       result.EvalResult == celtypes.True,
+			Param:        f.recorder.param,
+			Precondition: f.precondition,
+		}
+		if result.Error != nil {
+			expressionResult.Error = result.Error.Error()
+		}
+		f.recorder.results = append(f.recorder.results, expressionResult)
+	}
+	return results, remainingBudget, err
+}
+
+// costBudgetExhausted is the beginning of the details of the errors of the filters running out of cost budget,
+// the validator of the API server tells them apart the same way
+const costBudgetExhausted = "validation failed due to running out of cost budget"
+
+// isBudgetExhausted tells whether the error is the one of a filter running out of the cost budget of its expressions.
+func isBudgetExhausted(err error) bool {
+	var celErr *apiservercel.Error
+	return errors.As(err, &celErr) && celErr.Type == apiservercel.ErrorTypeInvalid && strings.HasPrefix(celErr.Detail, costBudgetExhausted)
+}
+
+// evaluationRecorder keeps the errors of the evaluations of the validator, its decisions only carry their messages
+type evaluationRecorder struct {
+	current     *evaluation
+	evaluations []*evaluation
+}
+
+// evaluation keeps the errors of an evaluation of the validator
+type evaluation struct {
+	// conditionErr is the error of the preconditions as a whole, e.g. when they run out of cost budget
+	conditionErr error
+	// conditions are the preconditions failing to evaluate
+	conditions []conditionFailure
+	// validated tells whether the validations were evaluated, they aren't when the preconditions fail
+	validated bool
+	// err is the error of the validations as a whole, e.g. when they run out of cost budget
+	err error
+	// results are the results of the validations, in the order of the decisions
+	results []cel.EvaluationResult
+	// failures are the failures reported by the programs, e.g. the variables failing to evaluate
+	failures celutils.Failures
+}
+
+type conditionFailure struct {
+	name       string
+	expression string
+	err        error
+}
+
+// start returns the context of the next evaluation of the validator, the evaluations are kept in the order they start.
+func (r *evaluationRecorder) start(ctx context.Context) context.Context {
+	r.current = &evaluation{}
+	r.evaluations = append(r.evaluations, r.current)
+	return celutils.WithFailures(ctx, &r.current.failures)
+}
+
+func (r *evaluationRecorder) wrapConditions(filter cel.Filter) cel.Filter {
+	return &conditionErrorFilter{
+		Filter:   filter,
+		recorder: r,
+	}
+}
+
+func (r *evaluationRecorder) wrapValidations(filter cel.Filter) cel.Filter {
+	return &validationErrorFilter{
+		Filter:   filter,
+		recorder: r,
+	}
+}
+
+// preconditionsExhausted tells whether the preconditions of an evaluation ran out of cost budget, either the budget
+// of all the preconditions or the cost limit of a single one.
+func (r *evaluationRecorder) preconditionsExhausted() bool {
+	for _, evaluation := range r.evaluations {
+		if isBudgetExhausted(evaluation.conditionErr) {
+			return true
 		}
-		for i := range paramList.Items {
-			params = append(params, &paramList.Items[i])
+		for _, condition := range evaluation.conditions {
+			if slices.Contains(evaluation.failures.CostLimitExceeded, condition.expression) {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+// failedCondition returns the first precondition failing to evaluate when the preconditions failed,
+// the validations are not evaluated then.
+func (e *evaluation) failedCondition() (conditionFailure, bool) {
+	if e.validated || len(e.conditions) == 0 {
+		return conditionFailure{}, false
+	}
+	return e.conditions[0], true
+}
+
+// cause returns the error the decision of the validation at the given index was built from, or an error carrying
+// the message of the decision when none was recorded.
+func (e *evaluation) cause(i int, message string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if i < len(e.results) && e.results[i].Error != nil {
+		return e.results[i].Error
+	}
+	return errors.New(message)
+}
 
-	if len(params) == 0 && paramRef.ParameterNotFoundAction != nil && *paramRef.ParameterNotFoundAction == admissionregistrationv1alpha1.DenyAction {
-		return nil, fmt.Errorf("no params found")
+// costExhausted tells whether the validation at the given index ran out of cost budget, either the budget
+// of all the validations or the cost limit of a single one.
+func (e *evaluation) costExhausted(i int) bool {
+	if isBudgetExhausted(e.err) {
+		return true
 	}
+	if i < len(e.results) && e.results[i].ExpressionAccessor != nil {
+		return slices.Contains(e.failures.CostLimitExceeded, e.results[i].ExpressionAccessor.GetExpression())
+	}
+	return false
+}
+
+// conditionErrorFilter records the preconditions of the wrapped filter failing to evaluate
+type conditionErrorFilter struct {
+	cel.Filter
+	recorder *evaluationRecorder
+}
+
+func (f *conditionErrorFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	results, remainingBudget, err := f.Filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	evaluation := f.recorder.current
+	if evaluation == nil {
+		return results, remainingBudget, err
+	}
+	evaluation.conditionErr = err
+	for _, result := range results {
+		if result.Error == nil {
+			continue
+		}
+		if condition, ok := result.ExpressionAccessor.(*matchconditions.MatchCondition); ok {
+			evaluation.conditions = append(evaluation.conditions, conditionFailure{name: condition.Name, expression: condition.Expression, err: result.Error})
+		}
+	}
+	return results, remainingBudget, err
+}
 
-	return params, nil
+// validationErrorFilter records the results of the wrapped validations filter
+type validationErrorFilter struct {
+	cel.Filter
+	recorder *evaluationRecorder
+}
+
+func (f *validationErrorFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	results, remainingBudget, err := f.Filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	if evaluation := f.recorder.current; evaluation != nil {
+		evaluation.validated = true
+		evaluation.err = err
+		evaluation.results = results
+	}
+	return results, remainingBudget, err
+}
+
+// messageFallbackFilter drops the error of the wrapped message expressions filter, e.g. when they run out of
+// cost budget, the validator then falls back to the static messages of the failed validations
+type messageFallbackFilter struct {
+	cel.Filter
+	logger logr.Logger
+}
+
+func (f *messageFallbackFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	results, remainingBudget, err := f.Filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	if err != nil {
+		f.logger.V(3).Info("failed to evaluate CEL message expressions, falling back to the static messages", "error", err.Error())
+		return nil, remainingBudget, nil
+	}
+	return results, remainingBudget, nil
+}
+
+// costCounter sums the runtime cost of the expressions evaluated by the filters it wraps
+type costCounter struct {
+	cost int64
+}
+
+func (c *costCounter) wrap(filter cel.Filter) cel.Filter {
+	return &costFilter{
+		Filter:  filter,
+		counter: c,
+	}
+}
+
+// costFilter adds the runtime cost of the wrapped filter to a counter, the whole budget is spent when it runs out
+type costFilter struct {
+	cel.Filter
+	counter *costCounter
+}
+
+func (f *costFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	results, remainingBudget, err := f.Filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	if remainingBudget < 0 {
+		f.counter.cost += runtimeCELCostBudget
+	} else {
+		f.counter.cost += runtimeCELCostBudget - remainingBudget
+	}
+	return results, remainingBudget, err
 }
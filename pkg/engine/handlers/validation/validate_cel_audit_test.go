@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+)
+
+func Test_ValidateCEL_AuditAnnotations(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression string
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name:       "passing validation",
+			expression: "object.spec.replicas <= 5",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "failing validation",
+			expression: "object.spec.replicas <= 2",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: tc.expression, Message: "too many replicas"},
+			}
+			rule.Validation.CEL.AuditAnnotations = []admissionregistrationv1alpha1.AuditAnnotation{
+				{Key: "replicas", ValueExpression: "string(object.spec.replicas)"},
+				{Key: "unset", ValueExpression: "null"},
+				// structured values are serialized to JSON
+				{Key: "limits", ValueExpression: "{'replicas': object.spec.replicas, 'max': 5}"},
+				{Key: "bounds", ValueExpression: "[1, object.spec.replicas]"},
+			}
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.DeepEqual(t, responses[0].AuditAnnotations(), map[string]string{
+				"replicas": "3",
+				"limits":   `{"max":5,"replicas":3}`,
+				"bounds":   "[1,3]",
+			})
+		})
+	}
+}
+
+func Test_publishedAuditAnnotations(t *testing.T) {
+	long := strings.Repeat("a", maxAuditAnnotationValueLength+1)
+	results := []validatingadmissionpolicy.ValidateResult{
+		{
+			AuditAnnotations: []validatingadmissionpolicy.PolicyAuditAnnotation{
+				{Key: "team", Value: "a", Action: validatingadmissionpolicy.AuditAnnotationActionPublish},
+				{Key: "skipped", Action: validatingadmissionpolicy.AuditAnnotationActionExclude},
+				{Key: "long", Value: long, Action: validatingadmissionpolicy.AuditAnnotationActionPublish},
+			},
+		},
+		{
+			AuditAnnotations: []validatingadmissionpolicy.PolicyAuditAnnotation{
+				{Key: "team", Value: "b", Action: validatingadmissionpolicy.AuditAnnotationActionPublish},
+				{Key: "team", Value: "a", Action: validatingadmissionpolicy.AuditAnnotationActionPublish},
+			},
+		},
+	}
+	got, dropped := publishedAuditAnnotations(results, 0)
+	assert.DeepEqual(t, got, map[string]string{
+		"team": "a, b",
+		"long": long[:maxAuditAnnotationValueLength],
+	})
+	assert.Equal(t, dropped, 0)
+	got, _ = publishedAuditAnnotations(nil, 0)
+	assert.Assert(t, got == nil)
+
+	// the values published last are dropped when the annotations are too long
+	got, dropped = publishedAuditAnnotations(results, len("team")+len("a")+len("long")+maxAuditAnnotationValueLength)
+	assert.DeepEqual(t, got, map[string]string{
+		"team": "a",
+		"long": long[:maxAuditAnnotationValueLength],
+	})
+	assert.Equal(t, dropped, 1)
+	got, dropped = publishedAuditAnnotations(results, len("team")+len("a, b"))
+	assert.DeepEqual(t, got, map[string]string{
+		"team": "a, b",
+	})
+	assert.Equal(t, dropped, 1)
+}
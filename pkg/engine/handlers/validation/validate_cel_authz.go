@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/internal"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizerapi "k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// AuthorizerFactory creates the authorizer answering the checks of the expressions evaluated against resources of the given kind.
+type AuthorizerFactory func(client engineapi.Client, resourceKind schema.GroupVersionKind) authorizerapi.Authorizer
+
+// NewClientAuthorizer is the default AuthorizerFactory, the checks are sent to the API server as SubjectAccessReviews.
+func NewClientAuthorizer(client engineapi.Client, resourceKind schema.GroupVersionKind) authorizerapi.Authorizer {
+	authz := internal.NewAuthorizer(client, resourceKind)
+	return &authz
+}
+
+type authorizerDecision struct {
+	decision authorizerapi.Decision
+	reason   string
+}
+
+// countingAuthorizer counts the authorization checks made by the expressions of an evaluation.
+// The decisions are cached for the evaluation, identical checks, e.g. made once per param, are only sent once.
+// The checks aren't sent while the breaker, if any, is open.
+type countingAuthorizer struct {
+	authorizer authorizerapi.Authorizer
+	breaker    *AuthorizerBreaker
+	lock       sync.Mutex
+	decisions  map[string]authorizerDecision
+	calls      engineapi.AuthorizerCalls
+}
+
+func newCountingAuthorizer(authz authorizerapi.Authorizer, breaker *AuthorizerBreaker) *countingAuthorizer {
+	return &countingAuthorizer{
+		authorizer: authz,
+		breaker:    breaker,
+		decisions:  map[string]authorizerDecision{},
+	}
+}
+
+func (a *countingAuthorizer) Authorize(ctx context.Context, attributes authorizerapi.Attributes) (authorizerapi.Decision, string, error) {
+	key := authorizerKey(attributes)
+	a.lock.Lock()
+	if cached, ok := a.decisions[key]; ok {
+		a.calls.CacheHits++
+		a.lock.Unlock()
+		return cached.decision, cached.reason, nil
+	}
+	if a.breaker != nil {
+		// short-circuited decisions aren't cached, the check is sent again once the breaker closes
+		if open, decision, reason, err := a.breaker.shortCircuit(); open {
+			a.calls.ShortCircuits++
+			a.lock.Unlock()
+			return decision, reason, err
+		}
+	}
+	a.calls.Requests++
+	a.lock.Unlock()
+	decision, reason, err := a.authorizer.Authorize(ctx, attributes)
+	if a.breaker != nil {
+		a.breaker.record(err)
+	}
+	// errors are not cached, the check is sent again
+	if err == nil {
+		a.lock.Lock()
+		a.decisions[key] = authorizerDecision{decision: decision, reason: reason}
+		a.lock.Unlock()
+	}
+	return decision, reason, err
+}
+
+// Calls returns the number of authorization checks made so far.
+func (a *countingAuthorizer) Calls() engineapi.AuthorizerCalls {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.calls
+}
+
+// usesAuthorizer tells whether the expressions of a rule reference the authorizer.
+func usesAuthorizer(inputs compilationInputs) bool {
+	var expressions []string
+	for _, validation := range inputs.Validations {
+		expressions = append(expressions, validation.Expression)
+	}
+	for _, auditAnnotation := range inputs.AuditAnnotations {
+		expressions = append(expressions, auditAnnotation.ValueExpression)
+	}
+	for _, matchCondition := range inputs.MatchConditions {
+		expressions = append(expressions, matchCondition.Expression)
+	}
+	for _, variable := range inputs.Variables {
+		expressions = append(expressions, variable.Expression)
+	}
+	return referencesVariable(expressions, "authorizer")
+}
+
+func authorizerKey(attributes authorizerapi.Attributes) string {
+	var user string
+	if info := attributes.GetUser(); info != nil {
+		user = info.GetName()
+	}
+	return strings.Join([]string{
+		user,
+		attributes.GetVerb(),
+		attributes.GetNamespace(),
+		attributes.GetAPIGroup(),
+		attributes.GetAPIVersion(),
+		attributes.GetResource(),
+		attributes.GetSubresource(),
+		attributes.GetName(),
+		attributes.GetPath(),
+	}, "\x00")
+}
@@ -0,0 +1,178 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func Test_ValidateCEL_AuthorizerCalls(t *testing.T) {
+	expressions := []admissionregistrationv1alpha1.Validation{
+		{Expression: "authorizer.group('apps').resource('deployments').namespace('default').check('create').allowed()"},
+		{Expression: "authorizer.group('').resource('pods').namespace('default').check('delete').allowed()"},
+	}
+
+	t.Run("distinct checks", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = expressions
+
+		client := &fakeClient{}
+		handler, err := NewValidateCELHandler(client)
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		assert.Equal(t, responses[0].AuthorizerCalls(), engineapi.AuthorizerCalls{Requests: 2})
+		assert.Equal(t, len(client.calls), 2)
+	})
+
+	t.Run("checks repeated for each param are cached", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = expressions
+		loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+			newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+			newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+		}}
+
+		client := &fakeClient{}
+		handler, err := NewValidateCELHandler(client, WithParamLoader(loader))
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		assert.Equal(t, responses[0].AuthorizerCalls(), engineapi.AuthorizerCalls{Requests: 2, CacheHits: 2})
+		assert.Equal(t, len(client.calls), 2)
+	})
+
+	t.Run("the authorizer is only available to expressions referencing it", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+
+		handler, err := NewValidateCELHandler(&fakeClient{})
+		assert.NilError(t, err)
+		h := handler.(validateCELHandler)
+		var authorizers []authorizer.Authorizer
+		newValidator := h.newValidator
+		h.newValidator = func(filter cel.Filter, matcher matchconditions.Matcher, auditAnnotations cel.Filter, messages cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+			return authorizerRecordingValidator{Validator: newValidator(filter, matcher, auditAnnotations, messages, failPolicy), authorizers: &authorizers}
+		}
+		_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		assert.DeepEqual(t, authorizers, []authorizer.Authorizer{nil})
+	})
+
+	t.Run("a custom authorizer", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = expressions
+
+		client := &fakeClient{}
+		var kinds []schema.GroupVersionKind
+		authz := &resourceAuthorizer{allowed: map[string]bool{"deployments": true}}
+		handler, err := NewValidateCELHandler(client, WithAuthorizerFactory(func(_ engineapi.Client, resourceKind schema.GroupVersionKind) authorizer.Authorizer {
+			kinds = append(kinds, resourceKind)
+			return authz
+		}))
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+		assert.DeepEqual(t, kinds, []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}})
+		assert.DeepEqual(t, authz.resources, []string{"deployments", "pods"})
+		// no access reviews are sent
+		assert.Equal(t, len(client.calls), 0)
+	})
+}
+
+// resourceAuthorizer allows the checks of the given resources and denies the others
+type resourceAuthorizer struct {
+	allowed   map[string]bool
+	resources []string
+}
+
+func (a *resourceAuthorizer) Authorize(_ context.Context, attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.resources = append(a.resources, attributes.GetResource())
+	if a.allowed[attributes.GetResource()] {
+		return authorizer.DecisionAllow, "", nil
+	}
+	return authorizer.DecisionDeny, "", nil
+}
+
+// authorizerRecordingValidator records the authorizers the wrapped validator is called with
+type authorizerRecordingValidator struct {
+	validatingadmissionpolicy.Validator
+	authorizers *[]authorizer.Authorizer
+}
+
+func (v authorizerRecordingValidator) Validate(ctx context.Context, matchedResource schema.GroupVersionResource, versionedAttr *admission.VersionedAttributes, versionedParams runtime.Object, namespace *corev1.Namespace, runtimeCELCostBudget int64, authz authorizer.Authorizer) validatingadmissionpolicy.ValidateResult {
+	*v.authorizers = append(*v.authorizers, authz)
+	return v.Validator.Validate(ctx, matchedResource, versionedAttr, versionedParams, namespace, runtimeCELCostBudget, authz)
+}
+
+func Test_usesAuthorizer(t *testing.T) {
+	testCases := []struct {
+		name   string
+		inputs compilationInputs
+		want   bool
+	}{
+		{
+			name:   "no reference",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.replicas <= 2"}}},
+		},
+		{
+			name:   "referenced by a validation",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "authorizer.group('').resource('pods').check('create').allowed()"}}},
+			want:   true,
+		},
+		{
+			name:   "referenced by a variable",
+			inputs: compilationInputs{Variables: []admissionregistrationv1alpha1.Variable{{Name: "check", Expression: "authorizer.requestResource.check('update')"}}},
+			want:   true,
+		},
+		{
+			name:   "referenced by a precondition",
+			inputs: compilationInputs{MatchConditions: []admissionregistrationv1.MatchCondition{{Name: "allowed", Expression: "authorizer.serviceAccount('default', 'sa').group('').resource('pods').check('get').allowed()"}}},
+			want:   true,
+		},
+		{
+			name:   "other identifiers",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.authorizers.size() < 2"}}},
+		},
+		{
+			name:   "field named like the authorizer",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.authorizer == 'rbac'"}}},
+		},
+		{
+			name:   "string literal",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.metadata.name != 'authorizer'"}}},
+		},
+		{
+			name:   "referenced in a macro",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "['get', 'list'].all(v, authorizer.group('').resource('pods').check(v).allowed())"}}},
+			want:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, usesAuthorizer(tc.inputs), tc.want)
+		})
+	}
+}
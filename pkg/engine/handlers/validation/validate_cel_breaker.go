@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	authorizerapi "k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// DefaultAuthorizerBreakerCooldown is the default time the authorization checks are short-circuited once the breaker opened.
+const DefaultAuthorizerBreakerCooldown = 30 * time.Second
+
+// ErrAuthorizerCircuitOpen is returned by the authorization checks short-circuited with the Error decision.
+var ErrAuthorizerCircuitOpen = errors.New("the authorizer circuit breaker is open after consecutive failed authorization checks")
+
+// AuthorizerBreakerDecision is the outcome of the authorization checks short-circuited by an open breaker.
+type AuthorizerBreakerDecision string
+
+const (
+	// AuthorizerBreakerAllow allows the short-circuited checks.
+	AuthorizerBreakerAllow AuthorizerBreakerDecision = "Allow"
+	// AuthorizerBreakerDeny denies the short-circuited checks.
+	AuthorizerBreakerDeny AuthorizerBreakerDecision = "Deny"
+	// AuthorizerBreakerError fails the short-circuited checks, the expressions making them fail to evaluate.
+	AuthorizerBreakerError AuthorizerBreakerDecision = "Error"
+)
+
+// ParseAuthorizerBreakerDecision parses the decision of the short-circuited checks, Allow, Deny or Error.
+func ParseAuthorizerBreakerDecision(value string) (AuthorizerBreakerDecision, error) {
+	switch decision := AuthorizerBreakerDecision(value); decision {
+	case AuthorizerBreakerAllow, AuthorizerBreakerDeny, AuthorizerBreakerError:
+		return decision, nil
+	default:
+		return "", fmt.Errorf("invalid authorizer breaker decision %q, it must be either Allow, Deny or Error", value)
+	}
+}
+
+// AuthorizerBreakerState is the state of an authorizer circuit breaker.
+type AuthorizerBreakerState string
+
+const (
+	// AuthorizerBreakerClosed sends the checks to the API server.
+	AuthorizerBreakerClosed AuthorizerBreakerState = "Closed"
+	// AuthorizerBreakerOpen short-circuits the checks until the cooldown is over.
+	AuthorizerBreakerOpen AuthorizerBreakerState = "Open"
+	// AuthorizerBreakerHalfOpen sends a single check to the API server after the cooldown and short-circuits the others
+	// until it completes, its failure opens the breaker again.
+	AuthorizerBreakerHalfOpen AuthorizerBreakerState = "HalfOpen"
+)
+
+// AuthorizerBreaker protects the evaluations from a slow or failing authorization backend. Once threshold consecutive
+// authorization checks failed, e.g. timed out, the checks of all rules are answered with the configured decision
+// without being sent to the API server until the cooldown is over. The next check is then sent as a probe, the other
+// checks are still short-circuited until it completes, it closes the breaker when it succeeds and opens it again otherwise.
+type AuthorizerBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	decision  AuthorizerBreakerDecision
+	now       func() time.Time
+	lock      sync.Mutex
+	// failures is the number of consecutive failed checks
+	failures int
+	// openedAt is the time the breaker opened, it is zero when the breaker is closed
+	openedAt time.Time
+	// probing is true while the probe of the half-open breaker is in flight
+	probing bool
+}
+
+// NewAuthorizerBreaker returns a breaker opening after threshold consecutive failed checks, the checks are answered
+// with the given decision during the cooldown.
+func NewAuthorizerBreaker(threshold int, cooldown time.Duration, decision AuthorizerBreakerDecision) *AuthorizerBreaker {
+	return &AuthorizerBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		decision:  decision,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *AuthorizerBreaker) State() AuthorizerBreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state()
+}
+
+func (b *AuthorizerBreaker) state() AuthorizerBreakerState {
+	if b.openedAt.IsZero() {
+		return AuthorizerBreakerClosed
+	}
+	if b.now().Sub(b.openedAt) < b.cooldown {
+		return AuthorizerBreakerOpen
+	}
+	return AuthorizerBreakerHalfOpen
+}
+
+// shortCircuit returns the outcome of a check when the breaker is open, or half-open with its probe in flight, the
+// check is sent otherwise. The check sent by a half-open breaker is its probe, its outcome must be recorded.
+func (b *AuthorizerBreaker) shortCircuit() (bool, authorizerapi.Decision, string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state() {
+	case AuthorizerBreakerClosed:
+		return false, authorizerapi.DecisionNoOpinion, "", nil
+	case AuthorizerBreakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+			return false, authorizerapi.DecisionNoOpinion, "", nil
+		}
+	}
+	reason := "short-circuited by the authorizer circuit breaker"
+	switch b.decision {
+	case AuthorizerBreakerAllow:
+		return true, authorizerapi.DecisionAllow, reason, nil
+	case AuthorizerBreakerDeny:
+		return true, authorizerapi.DecisionDeny, reason, nil
+	default:
+		return true, authorizerapi.DecisionDeny, reason, ErrAuthorizerCircuitOpen
+	}
+}
+
+// record records the outcome of a check sent to the API server.
+func (b *AuthorizerBreaker) record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	// the breaker is closed or opened again below, a new probe is sent after the next cooldown
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	// a failure after the cooldown opens the breaker again
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,182 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// flakyAuthorizer fails the checks while err is set and allows them otherwise
+type flakyAuthorizer struct {
+	err   error
+	calls int
+}
+
+func (a *flakyAuthorizer) Authorize(context.Context, authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.calls++
+	if a.err != nil {
+		return authorizer.DecisionDeny, "", a.err
+	}
+	return authorizer.DecisionAllow, "", nil
+}
+
+func TestAuthorizerBreaker(t *testing.T) {
+	breaker := NewAuthorizerBreaker(2, time.Minute, AuthorizerBreakerError)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	failure := errors.New("the server was unable to return a response in the time allotted")
+
+	breaker.record(failure)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerClosed)
+	// a success resets the consecutive failures
+	breaker.record(nil)
+	breaker.record(failure)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerClosed)
+	breaker.record(failure)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerOpen)
+	open, decision, _, err := breaker.shortCircuit()
+	assert.Assert(t, open)
+	assert.Equal(t, decision, authorizer.DecisionDeny)
+	assert.Equal(t, err, ErrAuthorizerCircuitOpen)
+
+	// a single probe is sent once the cooldown is over, the other checks are short-circuited until it completes
+	now = now.Add(time.Minute)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerHalfOpen)
+	open, _, _, _ = breaker.shortCircuit()
+	assert.Assert(t, !open)
+	open, _, _, _ = breaker.shortCircuit()
+	assert.Assert(t, open)
+	// a failed probe opens the breaker again
+	breaker.record(failure)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerOpen)
+
+	// a successful probe closes the breaker
+	now = now.Add(time.Minute)
+	open, _, _, _ = breaker.shortCircuit()
+	assert.Assert(t, !open)
+	breaker.record(nil)
+	assert.Equal(t, breaker.State(), AuthorizerBreakerClosed)
+	open, _, _, _ = breaker.shortCircuit()
+	assert.Assert(t, !open)
+}
+
+// blockingAuthorizer allows the checks once released
+type blockingAuthorizer struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (a *blockingAuthorizer) Authorize(ctx context.Context, _ authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.calls.Add(1)
+	select {
+	case <-a.release:
+		return authorizer.DecisionAllow, "", nil
+	case <-ctx.Done():
+		return authorizer.DecisionDeny, "", ctx.Err()
+	}
+}
+
+func TestAuthorizerBreaker_SingleProbe(t *testing.T) {
+	breaker := NewAuthorizerBreaker(1, time.Minute, AuthorizerBreakerDeny)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	breaker.record(errors.New("the server was unable to return a response in the time allotted"))
+	assert.Equal(t, breaker.State(), AuthorizerBreakerOpen)
+	now = now.Add(time.Minute)
+
+	authz := &blockingAuthorizer{release: make(chan struct{})}
+	attributes := authorizer.AttributesRecord{Verb: "create", Resource: "deployments", ResourceRequest: true}
+	const checks = 20
+	var wg sync.WaitGroup
+	var shortCircuited atomic.Int32
+	// every check is made concurrently through its own evaluation, only one of them is sent
+	for i := 0; i < checks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counting := newCountingAuthorizer(authz, breaker)
+			_, _, _ = counting.Authorize(context.TODO(), attributes)
+			shortCircuited.Add(int32(counting.Calls().ShortCircuits))
+		}()
+	}
+	// the probe completes once the other checks are short-circuited
+	assert.Assert(t, waitFor(func() bool { return shortCircuited.Load() == checks-1 }))
+	assert.Equal(t, authz.calls.Load(), int32(1))
+	assert.Equal(t, breaker.State(), AuthorizerBreakerHalfOpen)
+	close(authz.release)
+	wg.Wait()
+	assert.Equal(t, authz.calls.Load(), int32(1))
+	assert.Equal(t, breaker.State(), AuthorizerBreakerClosed)
+}
+
+// waitFor polls the condition until it holds or a few seconds passed.
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseAuthorizerBreakerDecision(t *testing.T) {
+	for _, value := range []string{"Allow", "Deny", "Error"} {
+		decision, err := ParseAuthorizerBreakerDecision(value)
+		assert.NilError(t, err)
+		assert.Equal(t, string(decision), value)
+	}
+	_, err := ParseAuthorizerBreakerDecision("")
+	assert.Error(t, err, `invalid authorizer breaker decision "", it must be either Allow, Deny or Error`)
+}
+
+func Test_ValidateCEL_AuthorizerBreaker(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "authorizer.group('apps').resource('deployments').namespace('default').check('create').allowed()"},
+	}
+	authz := &flakyAuthorizer{err: errors.New("the server was unable to return a response in the time allotted")}
+	breaker := NewAuthorizerBreaker(2, time.Minute, AuthorizerBreakerAllow)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	handler, err := NewValidateCELHandler(nil, WithAuthorizerBreaker(breaker), WithAuthorizerFactory(func(engineapi.Client, schema.GroupVersionKind) authorizer.Authorizer {
+		return authz
+	}))
+	assert.NilError(t, err)
+	// the failed checks deny the resource until the breaker opens
+	for i := 0; i < 2; i++ {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+		assert.Equal(t, responses[0].AuthorizerCalls(), engineapi.AuthorizerCalls{Requests: 1})
+	}
+	assert.Equal(t, breaker.State(), AuthorizerBreakerOpen)
+
+	// the checks are answered with the decision of the breaker without being sent
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+	assert.Equal(t, responses[0].AuthorizerCalls(), engineapi.AuthorizerCalls{ShortCircuits: 1})
+	assert.Equal(t, authz.calls, 2)
+
+	// the checks are sent again once the cooldown is over
+	now = now.Add(time.Minute)
+	authz.err = nil
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+	assert.Equal(t, responses[0].AuthorizerCalls(), engineapi.AuthorizerCalls{Requests: 1})
+	assert.Equal(t, breaker.State(), AuthorizerBreakerClosed)
+}
@@ -0,0 +1,216 @@
+package validation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kyverno/kyverno/pkg/utils/jsonpointer"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// DefaultCompilationCacheSize is the default number of compiled rules kept by the compilation cache.
+	DefaultCompilationCacheSize = 1000
+	// DefaultParamCacheSize is the default number of lists of params kept by the param cache.
+	DefaultParamCacheSize = 1000
+	// DefaultParamCacheTTL is the default time the lists of params are kept by the param cache, it is short
+	// so that the updates of params are quickly taken into account.
+	DefaultParamCacheTTL = 3 * time.Second
+	// DefaultParamCacheMaxListSize is the default number of params of the largest list kept by the param cache, the
+	// larger lists are listed by pages on every evaluation.
+	DefaultParamCacheMaxListSize = 500
+)
+
+// the stages of the compilation of the expressions of a rule
+const (
+	compilationStageCompiler   = "compiler"
+	compilationStageVariables  = "compile-variables"
+	compilationStageValidate   = "validate"
+	compilationStageMessage    = "message"
+	compilationStageAudit      = "audit"
+	compilationStageMatch      = "match"
+	compilationStageSuggestion = "suggestion"
+)
+
+// compiledRule holds the compiled expressions of a rule. The filters are stateless and shared by concurrent evaluations.
+type compiledRule struct {
+	compiledAt            time.Time
+	filter                cel.Filter
+	messageFilter         cel.Filter
+	auditAnnotationFilter cel.Filter
+	matchConditionFilter  cel.Filter
+	// suggestionFilter evaluates the suggestion expression of denied resources, it has no expression without one
+	suggestionFilter cel.Filter
+	// failedStages are the compilation stages whose expressions failed to compile
+	failedStages []string
+	// compilationErrors are the errors of all the expressions failing to compile
+	compilationErrors []error
+	// validationErrors are the errors of the validation expressions failing to compile, see celutils.ExpressionError
+	validationErrors []error
+	// paramFieldSelector selects the params of the rule by their fields, nil when the rule has none
+	paramFieldSelector fields.Selector
+	// redactions point at the values redacted from the objects of the message and suggestion expressions
+	redactions []jsonpointer.Pointer
+}
+
+// CompilationCache keeps the compiled expressions of the most recently evaluated rules, so that repeated
+// evaluations of a rule skip its compilation. Entries of previous resource versions of a policy are never
+// reused and are eventually evicted.
+type CompilationCache struct {
+	cache *lru.Cache
+}
+
+// NewCompilationCache returns a cache of the given number of compiled rules.
+func NewCompilationCache(size int) *CompilationCache {
+	return &CompilationCache{
+		cache: lru.New(size),
+	}
+}
+
+func (c *CompilationCache) get(key string) (compiledRule, bool) {
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return compiledRule{}, false
+	}
+	return value.(compiledRule), true
+}
+
+func (c *CompilationCache) add(key string, compiled compiledRule) {
+	c.cache.Add(key, compiled)
+}
+
+// compilationInputs are the inputs of the compilation of a rule
+type compilationInputs struct {
+	Validations      []admissionregistrationv1alpha1.Validation      `json:"validations,omitempty"`
+	AuditAnnotations []admissionregistrationv1alpha1.AuditAnnotation `json:"auditAnnotations,omitempty"`
+	MatchConditions  []admissionregistrationv1.MatchCondition        `json:"matchConditions,omitempty"`
+	Variables        []admissionregistrationv1alpha1.Variable        `json:"variables,omitempty"`
+	HasParam         bool                                            `json:"hasParam,omitempty"`
+	HasAuthorizer    bool                                            `json:"hasAuthorizer,omitempty"`
+	Bindings         []string                                        `json:"bindings,omitempty"`
+	Suggestion       string                                          `json:"suggestion,omitempty"`
+	// ParamFieldSelector and Redactions are parsed along with the expressions, they don't change them
+	ParamFieldSelector string   `json:"paramFieldSelector,omitempty"`
+	Redactions         []string `json:"redactions,omitempty"`
+}
+
+// compilationKey returns the key of the compiled rule in the compilation cache. It identifies the resource version
+// of the policy and the rule along with the digest of its CEL blocks. The inputs only depend on the rule, the values
+// computed for each request are bound when the expressions are evaluated, so that all requests share the entry.
+func compilationKey(policyKey, resourceVersion, ruleName string, inputs compilationInputs) (string, error) {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return policyKey + "@" + resourceVersion + "/" + ruleName + "/" + hex.EncodeToString(sum[:]), nil
+}
+
+// ParamCache keeps the lists of params selected by label selector for a short time, so that the admissions of
+// resources evaluated against the same params in a row list them once. Each list is assembled from all of its pages
+// and is kept by api version, kind, namespace and selector, whatever the page size of the evaluations.
+// Params fetched by name are never cached.
+type ParamCache struct {
+	cache *lru.Cache
+	ttl   time.Duration
+	// maxListSize is the number of params of the largest list kept, the larger lists aren't cached
+	maxListSize int
+	// now returns the current time, it is used to expire the lists
+	now func() time.Time
+}
+
+type paramCacheEntry struct {
+	list    *unstructured.UnstructuredList
+	expires time.Time
+}
+
+// NewParamCache returns a cache of the given number of lists of params, each list is kept for ttl.
+func NewParamCache(size int, ttl time.Duration) *ParamCache {
+	return &ParamCache{
+		cache:       lru.New(size),
+		ttl:         ttl,
+		maxListSize: DefaultParamCacheMaxListSize,
+		now:         time.Now,
+	}
+}
+
+func (c *ParamCache) get(key string) (*unstructured.UnstructuredList, bool) {
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(paramCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.cache.Remove(key)
+		return nil, false
+	}
+	return entry.list.DeepCopy(), true
+}
+
+func (c *ParamCache) add(key string, list *unstructured.UnstructuredList) {
+	c.cache.Add(key, paramCacheEntry{list: list.DeepCopy(), expires: c.now().Add(c.ttl)})
+}
+
+// loader returns a ParamLoader listing the params through the cache
+func (c *ParamCache) loader(loader ParamLoader) ParamLoader {
+	return cachingParamLoader{
+		ParamLoader: loader,
+		cache:       c,
+	}
+}
+
+type cachingParamLoader struct {
+	ParamLoader
+	cache *ParamCache
+}
+
+// ListParams returns the whole list of params as a single page, it is assembled from the pages of the given size.
+// The lists larger than the maximum size of the cache are returned along with the continue token of their next
+// page, they are continued by pages without being cached.
+func (l cachingParamLoader) ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	if continueToken != "" {
+		return l.ParamLoader.ListParams(ctx, apiVersion, kind, namespace, selector, limit, continueToken)
+	}
+	key := paramListKey(apiVersion, kind, namespace, selector)
+	if list, ok := l.cache.get(key); ok {
+		return list, nil
+	}
+	var list *unstructured.UnstructuredList
+	for {
+		page, err := l.ParamLoader.ListParams(ctx, apiVersion, kind, namespace, selector, limit, continueToken)
+		if err != nil {
+			return nil, err
+		}
+		if list == nil {
+			list = page
+		} else {
+			list.Items = append(list.Items, page.Items...)
+		}
+		continueToken = page.GetContinue()
+		if continueToken == "" {
+			break
+		}
+		if len(list.Items) > l.cache.maxListSize {
+			list.SetContinue(continueToken)
+			return list, nil
+		}
+	}
+	list.SetContinue("")
+	l.cache.add(key, list)
+	return list, nil
+}
+
+// paramListKey returns the key of a list of params in the param cache
+func paramListKey(apiVersion, kind, namespace string, selector *metav1.LabelSelector) string {
+	return fmt.Sprintf("%s/%s/%s?selector=%s", apiVersion, kind, namespace, metav1.FormatLabelSelector(selector))
+}
@@ -0,0 +1,258 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/handlers"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_CompilationCache(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	policyContext.Policy().SetResourceVersion("1")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	cache := NewCompilationCache(10)
+
+	handler, err := NewValidateCELHandler(nil, WithCompilationCache(cache))
+	assert.NilError(t, err)
+	h := handler.(validateCELHandler)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	process := func() engineapi.RuleResponse {
+		_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		return responses[0]
+	}
+
+	first := process()
+	assert.Equal(t, first.Status(), engineapi.RuleStatusPass)
+	assert.Equal(t, cache.cache.Len(), 1)
+
+	// the compiled rule is reused, its compilation time is the one of the cache entry
+	second := process()
+	assert.Equal(t, second.Status(), engineapi.RuleStatusPass)
+	assert.Equal(t, second.CompiledAt(), first.CompiledAt())
+	assert.Equal(t, cache.cache.Len(), 1)
+
+	// a new resource version of the policy is compiled again
+	policyContext.Policy().SetResourceVersion("2")
+	third := process()
+	assert.Assert(t, third.CompiledAt().After(second.CompiledAt()))
+	assert.Equal(t, cache.cache.Len(), 2)
+
+	// so is a rule with different expressions
+	rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas <= 2"
+	fourth := process()
+	assert.Equal(t, fourth.Status(), engineapi.RuleStatusFail)
+	assert.Assert(t, fourth.CompiledAt().After(third.CompiledAt()))
+	assert.Equal(t, cache.cache.Len(), 3)
+}
+
+func Test_ValidateCEL_CompilationCache_Bindings(t *testing.T) {
+	cache := NewCompilationCache(10)
+	handler, err := NewValidateCELHandler(nil, WithCompilationCache(cache))
+	assert.NilError(t, err)
+	process := func(environment string) engineapi.RuleResponse {
+		deployment := fmt.Sprintf(`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default", "labels": {"Environment": %q}}, "spec": {"replicas": 1}}`, environment)
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, deployment, "")
+		policyContext.Policy().SetResourceVersion("1")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.LowercaseMetadata = &kyvernov1.LowercaseMetadata{Values: true}
+		rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: "lowercaseLabels['environment'] == 'production'"}}
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		return responses[0]
+	}
+
+	first := process("Production")
+	assert.Equal(t, first.Status(), engineapi.RuleStatusPass, first.Message())
+	assert.Equal(t, cache.cache.Len(), 1)
+	// the values computed for other objects are bound to the compiled rule
+	second := process("Staging")
+	assert.Equal(t, second.Status(), engineapi.RuleStatusFail, second.Message())
+	assert.Equal(t, second.CompiledAt(), first.CompiledAt())
+	assert.Equal(t, cache.cache.Len(), 1)
+}
+
+func Test_compilationKey(t *testing.T) {
+	inputs := compilationInputs{Bindings: []string{"clusterContext"}}
+	key, err := compilationKey("check-deployment", "1", "check-replicas", inputs)
+	assert.NilError(t, err)
+	same, err := compilationKey("check-deployment", "1", "check-replicas", inputs)
+	assert.NilError(t, err)
+	assert.Equal(t, key, same)
+
+	// the bound variables are declared when the expressions are compiled
+	inputs.Bindings = []string{"clusterContext", "images"}
+	other, err := compilationKey("check-deployment", "1", "check-replicas", inputs)
+	assert.NilError(t, err)
+	assert.Assert(t, key != other)
+}
+
+func BenchmarkValidateCEL_CompilationCache(b *testing.B) {
+	policyContext := buildContext(b, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	benchmarks := []struct {
+		name    string
+		options []ValidateCELOption
+	}{
+		{
+			name: "compiled on every evaluation",
+		},
+		{
+			name:    "compiled once",
+			options: []ValidateCELOption{WithCompilationCache(NewCompilationCache(DefaultCompilationCacheSize))},
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			handler, err := NewValidateCELHandler(nil, bm.options...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+				if len(responses) != 1 || responses[0].Status() != engineapi.RuleStatusPass {
+					b.Errorf("unexpected responses: %v", responses)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateCEL_CompilationCache_Bindings(b *testing.B) {
+	deployments := []string{
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default", "labels": {"Environment": "Production"}}, "spec": {"replicas": 1}}`,
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "redis", "namespace": "default", "labels": {"Environment": "PRODUCTION"}}, "spec": {"replicas": 1}}`,
+	}
+	var policyContexts []engineapi.PolicyContext
+	for _, deployment := range deployments {
+		policyContext := buildContext(b, kyvernov1.Create, celReplicasPolicy, deployment, "")
+		policyContext.Policy().SetResourceVersion("1")
+		policyContexts = append(policyContexts, policyContext)
+	}
+	rule := policyContexts[0].Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.LowercaseMetadata = &kyvernov1.LowercaseMetadata{Values: true}
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: "lowercaseLabels['environment'] == 'production'"}}
+	cache := NewCompilationCache(DefaultCompilationCacheSize)
+	handler, err := NewValidateCELHandler(nil, WithCompilationCache(cache))
+	if err != nil {
+		b.Fatal(err)
+	}
+	process := func(policyContext engineapi.PolicyContext) engineapi.RuleResponse {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		if len(responses) != 1 || responses[0].Status() != engineapi.RuleStatusPass {
+			b.Fatalf("unexpected responses: %v", responses)
+		}
+		return responses[0]
+	}
+	// the second request, evaluating another object, hits the entry of the first one
+	first, second := process(policyContexts[0]), process(policyContexts[1])
+	if cache.cache.Len() != 1 || !second.CompiledAt().Equal(first.CompiledAt()) {
+		b.Fatalf("the second request compiled the rule again, %d cache entries", cache.cache.Len())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		process(policyContexts[i%len(policyContexts)])
+	}
+	b.StopTimer()
+	if cache.cache.Len() != 1 {
+		b.Fatalf("the requests compiled the rule again, %d cache entries", cache.cache.Len())
+	}
+}
+
+func Test_ValidateCEL_ParamCache(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+		newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+		newConfigMapParam("default", "beta", map[string]string{"app": "other"}, map[string]interface{}{"maxReplicas": "2"}),
+	}}
+	cache := NewParamCache(1, 3*time.Second)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time {
+		return clock
+	}
+
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader), WithParamCache(cache))
+	assert.NilError(t, err)
+	process := func(app string) engineapi.RuleStatus {
+		rule.Validation.CEL.ParamRef.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		return responses[0].Status()
+	}
+
+	assert.Equal(t, process("params"), engineapi.RuleStatusPass)
+	assert.Equal(t, loader.pages, 1)
+
+	// the params are reused until the page expires
+	clock = clock.Add(2 * time.Second)
+	assert.Equal(t, process("params"), engineapi.RuleStatusPass)
+	assert.Equal(t, loader.pages, 1)
+	clock = clock.Add(time.Second)
+	assert.Equal(t, process("params"), engineapi.RuleStatusPass)
+	assert.Equal(t, loader.pages, 2)
+
+	// another selector is listed, its page evicts the previous one
+	assert.Equal(t, process("other"), engineapi.RuleStatusFail)
+	assert.Equal(t, loader.pages, 3)
+	assert.Equal(t, process("params"), engineapi.RuleStatusPass)
+	assert.Equal(t, loader.pages, 4)
+	assert.Equal(t, cache.cache.Len(), 1)
+}
+
+func Test_ValidateCEL_ParamCache_Pages(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	var params []unstructured.Unstructured
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		params = append(params, newConfigMapParam("default", name, map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}))
+	}
+	loader := &fakeParamLoader{namespaced: true, params: params}
+	cache := NewParamCache(10, time.Minute)
+
+	process := func(handler handlers.Handler) {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		assert.Equal(t, len(responses[0].ParamResourceVersions()), 3)
+	}
+
+	// the list is assembled from all of its pages once, whatever the page size of the evaluations
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader), WithParamCache(cache), WithParamLimits(1, DefaultMaxParams))
+	assert.NilError(t, err)
+	process(handler)
+	assert.Equal(t, loader.pages, 3)
+	process(handler)
+	assert.Equal(t, loader.pages, 3)
+	handler, err = NewValidateCELHandler(nil, WithParamLoader(loader), WithParamCache(cache), WithParamLimits(2, DefaultMaxParams))
+	assert.NilError(t, err)
+	process(handler)
+	assert.Equal(t, loader.pages, 3)
+	assert.Equal(t, cache.cache.Len(), 1)
+
+	// the lists larger than the maximum size are continued by pages without being cached
+	cache = NewParamCache(10, time.Minute)
+	cache.maxListSize = 1
+	handler, err = NewValidateCELHandler(nil, WithParamLoader(loader), WithParamCache(cache), WithParamLimits(1, DefaultMaxParams))
+	assert.NilError(t, err)
+	process(handler)
+	assert.Equal(t, loader.pages, 6)
+	process(handler)
+	assert.Equal(t, loader.pages, 9)
+	assert.Equal(t, cache.cache.Len(), 0)
+}
@@ -0,0 +1,52 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxClusterContextEntries is the maximum number of entries of the cluster context.
+	MaxClusterContextEntries = 32
+	// MaxClusterContextValueLength is the maximum length of the keys and values of the cluster context.
+	MaxClusterContextValueLength = 256
+)
+
+// ParseClusterContext parses a cluster context written as comma separated key=value pairs, e.g. `env=prod,region=eu-west-1`.
+func ParseClusterContext(value string) (map[string]string, error) {
+	clusterContext := map[string]string{}
+	if strings.TrimSpace(value) == "" {
+		return clusterContext, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid cluster context entry %q, expected key=value", pair)
+		}
+		if _, ok := clusterContext[key]; ok {
+			return nil, fmt.Errorf("duplicate cluster context key %q", key)
+		}
+		clusterContext[key] = strings.TrimSpace(val)
+	}
+	if err := checkClusterContext(clusterContext); err != nil {
+		return nil, err
+	}
+	return clusterContext, nil
+}
+
+// checkClusterContext checks the cluster context stays within its bounds.
+func checkClusterContext(clusterContext map[string]string) error {
+	if len(clusterContext) > MaxClusterContextEntries {
+		return fmt.Errorf("the cluster context has %d entries, the maximum is %d", len(clusterContext), MaxClusterContextEntries)
+	}
+	for key, value := range clusterContext {
+		if len(key) > MaxClusterContextValueLength {
+			return fmt.Errorf("the cluster context key %q is longer than %d characters", key, MaxClusterContextValueLength)
+		}
+		if len(value) > MaxClusterContextValueLength {
+			return fmt.Errorf("the value of the cluster context key %q is longer than %d characters", key, MaxClusterContextValueLength)
+		}
+	}
+	return nil
+}
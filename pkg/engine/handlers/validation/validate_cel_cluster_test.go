@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+func Test_ValidateCEL_ClusterContext(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterContext map[string]string
+		wantStatus     engineapi.RuleStatus
+	}{
+		{
+			name:       "no cluster context",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:           "development cluster",
+			clusterContext: map[string]string{"env": "dev"},
+			wantStatus:     engineapi.RuleStatusPass,
+		},
+		{
+			name:           "production cluster",
+			clusterContext: map[string]string{"env": "prod", "region": "eu-west-1"},
+			wantStatus:     engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{
+				Expression: "!('env' in clusterContext) || clusterContext.env != 'prod' || object.spec.replicas >= 5",
+				Message:    "production deployments need at least 5 replicas",
+			}}
+
+			handler, err := NewValidateCELHandler(nil, WithClusterContext(tc.clusterContext))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_ClusterContextBounds(t *testing.T) {
+	clusterContext := map[string]string{}
+	for i := 0; i <= MaxClusterContextEntries; i++ {
+		clusterContext[fmt.Sprintf("key-%d", i)] = "value"
+	}
+	_, err := NewValidateCELHandler(nil, WithClusterContext(clusterContext))
+	assert.ErrorContains(t, err, "the maximum is 32")
+
+	_, err = NewValidateCELHandler(nil, WithClusterContext(map[string]string{"env": strings.Repeat("x", MaxClusterContextValueLength+1)}))
+	assert.ErrorContains(t, err, "longer than 256 characters")
+}
+
+func Test_ParseClusterContext(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string]string{},
+		},
+		{
+			name:  "entries",
+			value: "env=prod, region=eu-west-1,provider=",
+			want:  map[string]string{"env": "prod", "region": "eu-west-1", "provider": ""},
+		},
+		{
+			name:    "missing value",
+			value:   "env",
+			wantErr: "expected key=value",
+		},
+		{
+			name:    "duplicate key",
+			value:   "env=prod,env=dev",
+			wantErr: "duplicate cluster context key",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterContext, err := ParseClusterContext(tc.value)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, clusterContext, tc.want)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+func Test_ValidateCEL_CompiledAt(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	h := handler.(validateCELHandler)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+	compiledAt := responses[0].CompiledAt()
+	assert.Equal(t, compiledAt, time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC))
+
+	// the updated policy is compiled again
+	rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas <= 2"
+	_, responses = h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.Assert(t, responses[0].CompiledAt().After(compiledAt))
+}
+
+func Test_ValidateCEL_CompilationFailures(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	counter, err := provider.Meter("test").Int64Counter("kyverno_cel_compilation_failures")
+	assert.NilError(t, err)
+
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Variables = []admissionregistrationv1alpha1.Variable{{Name: "broken", Expression: "object.spec.replicas <="}}
+	rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas < )"
+
+	handler, err := NewValidateCELHandler(nil, WithCompilationFailureCounter(counter))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+
+	var metrics metricdata.ResourceMetrics
+	assert.NilError(t, reader.Collect(context.TODO(), &metrics))
+	assert.Equal(t, len(metrics.ScopeMetrics), 1)
+	assert.Equal(t, len(metrics.ScopeMetrics[0].Metrics), 1)
+	sum, ok := metrics.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Assert(t, ok)
+	failures := map[string]int64{}
+	for _, point := range sum.DataPoints {
+		policyName, _ := point.Attributes.Value("policy_name")
+		assert.Equal(t, policyName.AsString(), "check-deployment")
+		ruleName, _ := point.Attributes.Value("rule_name")
+		assert.Equal(t, ruleName.AsString(), rule.Name)
+		stage, _ := point.Attributes.Value("stage")
+		failures[stage.AsString()] = point.Value
+	}
+	assert.DeepEqual(t, failures, map[string]int64{"compile-variables": 1, "validate": 1})
+}
+
+func Test_ValidateCEL_ExpressionCompilationErrors(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= 5"},
+		{Expression: "'a' + 1 > 0"},
+		{Expression: "object.spec.replicas < )"},
+	}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	message := responses[0].Message()
+	// the broken expressions are pointed at with their type check or syntax errors
+	assert.Assert(t, strings.HasPrefix(message, `failed to compile CEL expressions: expressions[1] "'a' + 1 > 0": `), message)
+	assert.Assert(t, strings.Contains(message, "found no matching overload for '_+_' applied to '(string, int)'"), message)
+	assert.Assert(t, strings.Contains(message, `expressions[2] "object.spec.replicas < )": `), message)
+	assert.Assert(t, strings.Contains(message, "Syntax error"), message)
+	assert.Assert(t, !strings.Contains(message, "expressions[0]"), message)
+}
+
+func Test_ValidateCEL_FailClosedCompilation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		failClosed bool
+		expression string
+		annotation string
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name:       "validation failing to compile",
+			expression: "object.spec.replicas < )",
+			annotation: "'ok'",
+			wantStatus: engineapi.RuleStatusError,
+		},
+		{
+			name:       "validation failing to compile in fail closed mode",
+			failClosed: true,
+			expression: "object.spec.replicas < )",
+			annotation: "'ok'",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "audit annotation failing to compile in fail closed mode",
+			failClosed: true,
+			expression: "object.spec.replicas <= 5",
+			annotation: "'a' +",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "expressions compiling in fail closed mode",
+			failClosed: true,
+			expression: "object.spec.replicas <= 5",
+			annotation: "'ok'",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+			rule.Validation.CEL.AuditAnnotations = []admissionregistrationv1alpha1.AuditAnnotation{
+				{Key: "check", ValueExpression: tc.annotation},
+			}
+			var options []ValidateCELOption
+			if tc.failClosed {
+				options = append(options, WithFailClosedCompilation())
+			}
+
+			handler, err := NewValidateCELHandler(nil, options...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantStatus != engineapi.RuleStatusPass {
+				assert.Assert(t, strings.HasPrefix(responses[0].Message(), "failed to compile CEL expressions: "), responses[0].Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// digestIgnoredMetadata are the metadata fields ignored by object digests, they are maintained by the API server and
+// change with every write of the same object. The UID and generation identify the object and its spec, they are kept.
+var digestIgnoredMetadata = []string{"managedFields", "resourceVersion"}
+
+// objectDigest returns the sha256 digest of the JSON representation of the object.
+// The metadata fields maintained by the API server are ignored as they change without affecting the evaluated content.
+func objectDigest(obj runtime.Object) (string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return "", nil
+	}
+	content := u.Object
+	if metadata, ok := u.Object["metadata"].(map[string]interface{}); ok && hasAnyKey(metadata, digestIgnoredMetadata) {
+		// shallow copies are enough to drop the ignored fields without touching the object
+		content = make(map[string]interface{}, len(u.Object))
+		for key, value := range u.Object {
+			content[key] = value
+		}
+		trimmed := make(map[string]interface{}, len(metadata))
+		for key, value := range metadata {
+			if !slices.Contains(digestIgnoredMetadata, key) {
+				trimmed[key] = value
+			}
+		}
+		content["metadata"] = trimmed
+	}
+	// map keys are sorted when encoded, the same content always gives the same digest
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func hasAnyKey(m map[string]interface{}, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// objectDigest returns the digest of the evaluated object, it is computed once for the rules of the batch evaluating
+// the same object.
+func (b *ruleBatch) objectDigest(obj runtime.Object) (string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return "", nil
+	}
+	if b.digested != nil && sameContent(b.digested, u.Object) {
+		return b.digest, nil
+	}
+	digest, err := objectDigest(u)
+	if err != nil {
+		return "", err
+	}
+	b.digest, b.digested = digest, u.Object
+	return digest, nil
+}
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_objectDigest(t *testing.T) {
+	newDeployment := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}}
+	}
+	digest, err := objectDigest(newDeployment(3))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(digest, "sha256:"))
+
+	// identical objects give identical digests
+	other, err := objectDigest(newDeployment(3))
+	assert.NilError(t, err)
+	assert.Equal(t, digest, other)
+
+	// a changed field changes the digest
+	other, err = objectDigest(newDeployment(4))
+	assert.NilError(t, err)
+	assert.Assert(t, digest != other)
+
+	// managed fields are ignored and left untouched
+	withManagedFields := newDeployment(3)
+	withManagedFields.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate}})
+	other, err = objectDigest(withManagedFields)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, other)
+	assert.Equal(t, len(withManagedFields.GetManagedFields()), 1)
+
+	// the resource version is ignored as well
+	withResourceVersion := newDeployment(3)
+	withResourceVersion.SetResourceVersion("42")
+	other, err = objectDigest(withResourceVersion)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, other)
+	assert.Equal(t, withResourceVersion.GetResourceVersion(), "42")
+
+	// objects of different UIDs give different digests
+	first := newDeployment(3)
+	first.SetUID("7c1e2a3b")
+	second := newDeployment(3)
+	second.SetUID("9f4d5e6a")
+	firstDigest, err := objectDigest(first)
+	assert.NilError(t, err)
+	secondDigest, err := objectDigest(second)
+	assert.NilError(t, err)
+	assert.Assert(t, firstDigest != secondDigest)
+	assert.Assert(t, firstDigest != digest)
+
+	// a changed generation changes the digest
+	withGeneration := newDeployment(3)
+	withGeneration.SetGeneration(2)
+	other, err = objectDigest(withGeneration)
+	assert.NilError(t, err)
+	assert.Assert(t, digest != other)
+
+	digest, err = objectDigest(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, "")
+}
+
+func Test_ruleBatch_objectDigest(t *testing.T) {
+	newDeployment := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}}
+	}
+	batch := &ruleBatch{}
+	deployment := newDeployment(3)
+	digest, err := batch.objectDigest(deployment)
+	assert.NilError(t, err)
+	expected, err := objectDigest(deployment)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, expected)
+
+	// the digest of the same object is reused, it isn't computed again
+	batch.digest = "sha256:cached"
+	digest, err = batch.objectDigest(deployment)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, "sha256:cached")
+
+	// another object is digested again
+	other := newDeployment(4)
+	digest, err = batch.objectDigest(other)
+	assert.NilError(t, err)
+	expected, err = objectDigest(other)
+	assert.NilError(t, err)
+	assert.Equal(t, digest, expected)
+}
+
+func Test_ValidateCEL_ObjectDigest(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.FieldProjection = []string{"spec.replicas", "metadata.name"}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+
+	// the digest is computed on the projected object
+	resource := policyContext.NewResource()
+	expected, err := objectDigest(projectObject(&resource, parseFieldProjection(rule.Validation.CEL.FieldProjection)))
+	assert.NilError(t, err)
+	assert.Equal(t, responses[0].ObjectDigest(), expected)
+}
@@ -0,0 +1,208 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/cel-go/common/types"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxEnvSources bounds the number of environment variables exposed to CEL expressions.
+const maxEnvSources = 1024
+
+// podSpecPaths are the locations of the pod spec in pods and pod controllers.
+var podSpecPaths = [][]string{
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+	{"spec"},
+}
+
+func podSpec(resource unstructured.Unstructured) map[string]interface{} {
+	for _, path := range podSpecPaths {
+		spec, found, err := unstructured.NestedMap(resource.Object, path...)
+		if err == nil && found {
+			if _, ok := spec["containers"]; ok {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+// envSourcesBinding resolves the sources of the container environment variables when the expressions first read them,
+// they aren't resolved for the resources the rule doesn't apply to, e.g. not matching its preconditions.
+type envSourcesBinding struct {
+	resolve  func() ([]interface{}, error)
+	resolved bool
+	sources  []interface{}
+	// err is the error resolving the sources, the expressions reading them fail with it
+	err error
+}
+
+// value is bound to `envSources`, it is called by the evaluation the first time the variable is read.
+func (b *envSourcesBinding) value() any {
+	if !b.resolved {
+		b.sources, b.err = b.resolve()
+		b.resolved = true
+	}
+	if b.err != nil {
+		return types.NewErr("failed to resolve container environment sources: %v", b.err)
+	}
+	return b.sources
+}
+
+// resolveEnvSources returns the source of every container environment variable of the resource, and of every
+// ConfigMap and Secret loaded as a whole through `envFrom`. ConfigMaps and Secrets loaded through `envFrom` follow the
+// same rules as the keys referenced from them, they are allowed along with ConfigMapKeyRef and SecretKeyRef.
+// Referenced ConfigMaps are looked up to check the key exists but their values are never returned, Secrets are
+// never read.
+func resolveEnvSources(ctx context.Context, client engineapi.Client, resource unstructured.Unstructured, namespace string, allowed []kyvernov1.EnvSource) ([]interface{}, error) {
+	spec := podSpec(resource)
+	if spec == nil {
+		return []interface{}{}, nil
+	}
+	allowedSources := make(map[kyvernov1.EnvSource]bool, len(allowed))
+	for _, source := range allowed {
+		allowedSources[source] = true
+	}
+	sources := []interface{}{}
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, _, _ := unstructured.NestedSlice(spec, field)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			containerName, _, _ := unstructured.NestedString(container, "name")
+			envs, _, _ := unstructured.NestedSlice(container, "env")
+			envFroms, _, _ := unstructured.NestedSlice(container, "envFrom")
+			for i, e := range append(envs, envFroms...) {
+				env, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if len(sources) == maxEnvSources {
+					return nil, fmt.Errorf("too many environment variables, at most %d are supported", maxEnvSources)
+				}
+				var source map[string]interface{}
+				var err error
+				if i < len(envs) {
+					source, err = resolveEnvSource(ctx, client, env, namespace)
+				} else {
+					source, err = resolveEnvFromSource(ctx, client, env, namespace)
+				}
+				if err != nil {
+					return nil, err
+				}
+				source["container"] = containerName
+				source["allowed"] = allowedSources[kyvernov1.EnvSource(source["source"].(string))]
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources, nil
+}
+
+func resolveEnvSource(ctx context.Context, client engineapi.Client, env map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	name, _, _ := unstructured.NestedString(env, "name")
+	source := map[string]interface{}{
+		"name":       name,
+		"source":     string(kyvernov1.EnvSourceValue),
+		"sourceName": "",
+		"key":        "",
+		"resolved":   true,
+		"envFrom":    false,
+	}
+	valueFrom, found, _ := unstructured.NestedMap(env, "valueFrom")
+	if !found {
+		return source, nil
+	}
+	if ref, found, _ := unstructured.NestedMap(valueFrom, "configMapKeyRef"); found {
+		return resolveKeyRef(ctx, client, source, kyvernov1.EnvSourceConfigMapKeyRef, "ConfigMap", ref, namespace)
+	}
+	if ref, found, _ := unstructured.NestedMap(valueFrom, "secretKeyRef"); found {
+		return resolveKeyRef(ctx, client, source, kyvernov1.EnvSourceSecretKeyRef, "Secret", ref, namespace)
+	}
+	if ref, found, _ := unstructured.NestedMap(valueFrom, "fieldRef"); found {
+		source["source"] = string(kyvernov1.EnvSourceFieldRef)
+		source["sourceName"], _, _ = unstructured.NestedString(ref, "fieldPath")
+		return source, nil
+	}
+	if ref, found, _ := unstructured.NestedMap(valueFrom, "resourceFieldRef"); found {
+		source["source"] = string(kyvernov1.EnvSourceResourceFieldRef)
+		source["sourceName"], _, _ = unstructured.NestedString(ref, "resource")
+		return source, nil
+	}
+	return source, nil
+}
+
+// resolveEnvFromSource returns the source of the environment variables loaded from a ConfigMap or a Secret as a whole,
+// they are named by their prefix.
+func resolveEnvFromSource(ctx context.Context, client engineapi.Client, envFrom map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	prefix, _, _ := unstructured.NestedString(envFrom, "prefix")
+	source := map[string]interface{}{
+		"name":       prefix,
+		"source":     "",
+		"sourceName": "",
+		"key":        "",
+		"resolved":   false,
+		"envFrom":    true,
+	}
+	if ref, found, _ := unstructured.NestedMap(envFrom, "configMapRef"); found {
+		return resolveRef(ctx, client, source, kyvernov1.EnvSourceConfigMapKeyRef, "ConfigMap", ref, namespace)
+	}
+	if ref, found, _ := unstructured.NestedMap(envFrom, "secretRef"); found {
+		return resolveRef(ctx, client, source, kyvernov1.EnvSourceSecretKeyRef, "Secret", ref, namespace)
+	}
+	return source, nil
+}
+
+func resolveKeyRef(ctx context.Context, client engineapi.Client, source map[string]interface{}, envSource kyvernov1.EnvSource, kind string, ref map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	source["key"], _, _ = unstructured.NestedString(ref, "key")
+	return resolveRef(ctx, client, source, envSource, kind, ref, namespace)
+}
+
+// resolveRef resolves the ConfigMap or Secret referenced by the source. A ConfigMap reference is resolved when the
+// ConfigMap exists and, unless loaded as a whole, when it holds the referenced key. A Secret reference is resolved by
+// name only, when it names the Secret and the key, Secrets are never read so that neither their values nor their
+// keys are disclosed to the expressions.
+func resolveRef(ctx context.Context, client engineapi.Client, source map[string]interface{}, envSource kyvernov1.EnvSource, kind string, ref map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	refName, _, _ := unstructured.NestedString(ref, "name")
+	source["source"] = string(envSource)
+	source["sourceName"] = refName
+	source["resolved"] = false
+	envFrom := source["envFrom"].(bool)
+	key := source["key"].(string)
+	if refName == "" || (!envFrom && key == "") {
+		return source, nil
+	}
+	if kind == "Secret" {
+		source["resolved"] = true
+		return source, nil
+	}
+	if client == nil || namespace == "" {
+		return source, nil
+	}
+	obj, err := client.GetResource(ctx, "v1", kind, namespace, refName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return source, nil
+		}
+		return nil, fmt.Errorf("failed to resolve %s %s/%s: %w", kind, namespace, refName, err)
+	}
+	if envFrom {
+		source["resolved"] = true
+		return source, nil
+	}
+	for _, field := range []string{"data", "binaryData"} {
+		if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, field, key); found {
+			source["resolved"] = true
+			break
+		}
+	}
+	return source, nil
+}
@@ -0,0 +1,214 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var celEnvSourcesPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-env-sources"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-env-sources",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Pod"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"allowedEnvSources": ["Value", "ConfigMapKeyRef"],
+						"expressions": [
+							{
+								"expression": "envSources.all(e, e.resolved)",
+								"message": "environment variables must be set from allowed sources"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_EnvSources(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           string
+		warn          bool
+		preconditions []admissionregistrationv1alpha1.MatchCondition
+		want          engineapi.RuleStatus
+		message       string
+	}{{
+		name:    "disallowed source",
+		env:     `"env": [{"name": "MODE", "value": "production"}, {"name": "PASSWORD", "valueFrom": {"secretKeyRef": {"name": "db", "key": "password"}}}]`,
+		want:    engineapi.RuleStatusFail,
+		message: "environment variable PASSWORD of container app is populated from SecretKeyRef which is not allowed",
+	}, {
+		name:    "allowed source",
+		env:     `"env": [{"name": "MODE", "value": "production"}, {"name": "PASSWORD", "valueFrom": {"configMapKeyRef": {"name": "db", "key": "password"}}}]`,
+		want:    engineapi.RuleStatusPass,
+		message: "Validation rule 'check-env-sources' passed.",
+	}, {
+		name:    "unresolved source",
+		env:     `"env": [{"name": "MODE", "value": "production"}, {"name": "PASSWORD", "valueFrom": {"configMapKeyRef": {"name": "db", "key": "username"}}}]`,
+		want:    engineapi.RuleStatusFail,
+		message: "environment variables must be set from allowed sources",
+	}, {
+		name:    "disallowed envFrom source",
+		env:     `"envFrom": [{"configMapRef": {"name": "db"}}, {"secretRef": {"name": "db"}}]`,
+		want:    engineapi.RuleStatusFail,
+		message: "environment variables of container app are loaded from SecretKeyRef db which is not allowed",
+	}, {
+		name:    "allowed envFrom source",
+		env:     `"envFrom": [{"prefix": "DB_", "configMapRef": {"name": "db"}}]`,
+		want:    engineapi.RuleStatusPass,
+		message: "Validation rule 'check-env-sources' passed.",
+	}, {
+		name:    "disallowed source in warn mode",
+		env:     `"env": [{"name": "PASSWORD", "valueFrom": {"secretKeyRef": {"name": "db", "key": "password"}}}]`,
+		warn:    true,
+		want:    engineapi.RuleStatusWarn,
+		message: "environment variable PASSWORD of container app is populated from SecretKeyRef which is not allowed",
+	}, {
+		name: "source not matching the preconditions",
+		env:  `"env": [{"name": "PASSWORD", "valueFrom": {"configMapKeyRef": {"name": "db", "key": "password"}}}]`,
+		preconditions: []admissionregistrationv1alpha1.MatchCondition{
+			{Name: "production", Expression: "object.metadata.namespace == 'production'"},
+		},
+		want: engineapi.RuleStatusSkip,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := `{
+				"apiVersion": "v1",
+				"kind": "Pod",
+				"metadata": {"name": "app", "namespace": "default"},
+				"spec": {
+					"containers": [{
+						"name": "app",
+						"image": "nginx",
+						` + tt.env + `
+					}]
+				}
+			}`
+			policyContext := buildContext(t, kyvernov1.Create, celEnvSourcesPolicy, pod, "")
+			client := &fakeClient{
+				resources: []unstructured.Unstructured{
+					newConfigMapParam("default", "db", nil, map[string]interface{}{"password": "secret"}),
+				},
+			}
+			handler, err := NewValidateCELHandler(client)
+			assert.NilError(t, err)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Warn = tt.warn
+			rule.CELPreconditions = tt.preconditions
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tt.want, responses[0].Message())
+			if tt.message != "" {
+				assert.Equal(t, responses[0].Message(), tt.message)
+			}
+			// the sources are only resolved once the rule is known to apply, and Secrets are never read
+			for _, call := range client.calls {
+				assert.Assert(t, tt.want != engineapi.RuleStatusSkip, call)
+				assert.Equal(t, call.Kind, "ConfigMap")
+			}
+		})
+	}
+}
+
+// forbiddenClient denies access to every resource.
+type forbiddenClient struct {
+	fakeClient
+}
+
+func (c *forbiddenClient) GetResource(ctx context.Context, apiVersion, kind, namespace, name string, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, apierrors.NewForbidden(schema.GroupResource{Resource: kind}, name, fmt.Errorf("access denied"))
+}
+
+func Test_resolveEnvSources_Forbidden(t *testing.T) {
+	pod := func(valueFrom map[string]interface{}) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "app",
+						"env": []interface{}{
+							map[string]interface{}{"name": "PASSWORD", "valueFrom": valueFrom},
+						},
+					},
+				},
+			},
+		}}
+	}
+	// Secrets are resolved by name without being read
+	secretRef := map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"}}
+	sources, err := resolveEnvSources(context.TODO(), &forbiddenClient{}, pod(secretRef), "default", []kyvernov1.EnvSource{kyvernov1.EnvSourceSecretKeyRef})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, sources, []interface{}{
+		map[string]interface{}{
+			"name":       "PASSWORD",
+			"source":     "SecretKeyRef",
+			"sourceName": "db",
+			"key":        "password",
+			"resolved":   true,
+			"envFrom":    false,
+			"container":  "app",
+			"allowed":    true,
+		},
+	})
+	// ConfigMaps which can't be read fail the resolution
+	configMapRef := map[string]interface{}{"configMapKeyRef": map[string]interface{}{"name": "db", "key": "password"}}
+	_, err = resolveEnvSources(context.TODO(), &forbiddenClient{}, pod(configMapRef), "default", []kyvernov1.EnvSource{kyvernov1.EnvSourceConfigMapKeyRef})
+	assert.ErrorContains(t, err, "failed to resolve ConfigMap default/db")
+}
+
+func Test_ValidateCEL_EnvSources_Error(t *testing.T) {
+	pod := `{
+		"apiVersion": "v1",
+		"kind": "Pod",
+		"metadata": {"name": "app", "namespace": "default"},
+		"spec": {
+			"containers": [{
+				"name": "app",
+				"image": "nginx",
+				"env": [{"name": "PASSWORD", "valueFrom": {"configMapKeyRef": {"name": "db", "key": "password"}}}]
+			}]
+		}
+	}`
+	policyContext := buildContext(t, kyvernov1.Create, celEnvSourcesPolicy, pod, "")
+	handler, err := NewValidateCELHandler(&forbiddenClient{})
+	assert.NilError(t, err)
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError, responses[0].Message())
+	assert.Assert(t, strings.HasPrefix(responses[0].Message(), "failed to resolve container environment sources"), responses[0].Message())
+}
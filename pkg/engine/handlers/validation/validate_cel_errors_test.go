@@ -0,0 +1,246 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/cel-go/common/types/ref"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	"gotest.tools/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+)
+
+func Test_ValidateCEL_PreconditionErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		conditions  []admissionregistrationv1alpha1.MatchCondition
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name: "unmet preconditions",
+			conditions: []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "production", Expression: "object.metadata.namespace == 'production'"},
+			},
+			wantStatus:  engineapi.RuleStatusSkip,
+			wantMessage: "cel preconditions not met",
+		},
+		{
+			name: "preconditions failing to evaluate",
+			conditions: []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "managed", Expression: "object.metadata.labels['managed'] == 'true'"},
+			},
+			wantStatus:  engineapi.RuleStatusError,
+			wantMessage: `precondition "managed" failed to evaluate: `,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.CELPreconditions = tc.conditions
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Assert(t, strings.HasPrefix(responses[0].Message(), tc.wantMessage), responses[0].Message())
+		})
+	}
+}
+
+type panickingValidator struct{}
+
+func (panickingValidator) Validate(ctx context.Context, matchedResource schema.GroupVersionResource, versionedAttr *admission.VersionedAttributes, versionedParams runtime.Object, namespace *corev1.Namespace, runtimeCELCostBudget int64, authz authorizer.Authorizer) validatingadmissionpolicy.ValidateResult {
+	panic("unexpected evaluation failure")
+}
+
+func Test_ValidateCEL_Panic(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	h := handler.(validateCELHandler)
+	h.newValidator = func(cel.Filter, matchconditions.Matcher, cel.Filter, cel.Filter, *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+		return panickingValidator{}
+	}
+	_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	assert.Equal(t, responses[0].Message(), "failed to evaluate CEL expressions: CEL evaluation panicked: unexpected evaluation failure")
+}
+
+// expensiveCallEstimator charges more than the cost budget of preconditions for the calls of a single function
+type expensiveCallEstimator struct {
+	function string
+}
+
+func (e expensiveCallEstimator) CallCost(function, overloadID string, args []ref.Val, result ref.Val) *uint64 {
+	if function != e.function {
+		return nil
+	}
+	cost := uint64(10 * 1000 * 1000)
+	return &cost
+}
+
+func Test_ValidateCEL_PreconditionCostExhaustion(t *testing.T) {
+	testCases := []struct {
+		name              string
+		expensiveFunction string
+		wantStatus        engineapi.RuleStatus
+	}{
+		{
+			name:              "expensive preconditions",
+			expensiveFunction: "startsWith",
+			wantStatus:        engineapi.RuleStatusError,
+		},
+		{
+			name:              "expensive validations",
+			expensiveFunction: "endsWith",
+			wantStatus:        engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.CELPreconditions = []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "nginx", Expression: "object.metadata.name.startsWith('ngi')"},
+			}
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: "object.metadata.name.endsWith('nx')"}}
+
+			handler, err := NewValidateCELHandler(nil, WithCostEstimator(expensiveCallEstimator{function: tc.expensiveFunction}))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			// only preconditions running out of cost budget are reported as too expensive
+			isPreconditionsErr := strings.HasPrefix(responses[0].Message(), "CEL preconditions are too expensive")
+			assert.Equal(t, isPreconditionsErr, tc.wantStatus == engineapi.RuleStatusError, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_CostBudget(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+
+	// the cost consumed with the default budget is reported
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+	cost := responses[0].Cost()
+	assert.Assert(t, cost > 0)
+
+	// the budget of the rule is used instead of the default one
+	costBudget := cost - 1
+	rule.Validation.CEL.CostBudget = &costBudget
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.Assert(t, strings.Contains(responses[0].Message(), "running out of cost budget"), responses[0].Message())
+	assert.Equal(t, responses[0].Cost(), costBudget)
+}
+
+func Test_ValidateCEL_EvaluationErrors(t *testing.T) {
+	lowBudget := int64(1)
+	testCases := []struct {
+		name        string
+		expression  string
+		costBudget  *int64
+		wantPrefix  string
+		wantMessage string
+	}{
+		{
+			name:        "runtime error",
+			expression:  "object.spec.missing == 1",
+			wantPrefix:  "failed to evaluate CEL expression: ",
+			wantMessage: "no such key: missing",
+		},
+		{
+			name:        "cost budget exceeded",
+			expression:  "object.spec.replicas <= 5",
+			costBudget:  &lowBudget,
+			wantPrefix:  "CEL expressions ran out of cost budget: ",
+			wantMessage: "running out of cost budget",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+			rule.Validation.CEL.CostBudget = tc.costBudget
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			// evaluation errors are admitted when they are ignored
+			ignore := admissionregistrationv1.Ignore
+			h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, _ *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+				return validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, &ignore)
+			}
+			_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError, responses[0].Message())
+			assert.Assert(t, strings.HasPrefix(responses[0].Message(), tc.wantPrefix), responses[0].Message())
+			assert.Assert(t, strings.Contains(responses[0].Message(), tc.wantMessage), responses[0].Message())
+		})
+	}
+}
+
+func Test_evaluation_cause(t *testing.T) {
+	rootCause := &apiservercel.Error{Type: apiservercel.ErrorTypeInvalid, Detail: "no such key: missing"}
+	e := &evaluation{results: []cel.EvaluationResult{{}, {Error: rootCause}}}
+	assert.Equal(t, e.cause(1, "no such key: missing"), error(rootCause))
+	assert.Equal(t, e.cause(0, "unexpected failure").Error(), "unexpected failure")
+
+	// the error of the validations as a whole is the cause of all the decisions
+	e.err = &apiservercel.Error{Type: apiservercel.ErrorTypeInvalid, Detail: costBudgetExhausted + ", no further validation rules will be run"}
+	assert.Equal(t, e.cause(1, "no such key: missing"), e.err)
+	assert.Assert(t, e.costExhausted(0))
+}
+
+// Test_isBudgetExhausted pins the error of the filters of the API server running out of cost budget
+func Test_isBudgetExhausted(t *testing.T) {
+	compiler, err := celutils.NewCompiler([]admissionregistrationv1alpha1.Validation{{Expression: "object.metadata.name == 'nginx'"}}, nil, nil, nil)
+	assert.NilError(t, err)
+	filter := compiler.CompileValidateExpressions(cel.OptionalVariableDeclarations{})
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+	}}
+	attr := admission.NewAttributesRecord(object, nil, gvk, "default", "nginx", gvr, "", admission.Create, nil, false, nil)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, gvk, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	assert.NilError(t, err)
+	request := cel.CreateAdmissionRequest(attr, metav1.GroupVersionResource(gvr), metav1.GroupVersionKind(gvk))
+
+	_, _, err = filter.ForInput(context.TODO(), versionedAttr, request, cel.OptionalVariableBindings{}, nil, 0)
+	assert.Assert(t, isBudgetExhausted(err), err)
+	_, _, err = filter.ForInput(context.TODO(), versionedAttr, request, cel.OptionalVariableBindings{}, nil, celconfig.RuntimeCELCostBudget)
+	assert.Assert(t, !isBudgetExhausted(err))
+	assert.Assert(t, !isBudgetExhausted(&apiservercel.Error{Type: apiservercel.ErrorTypeInternal, Detail: costBudgetExhausted}))
+}
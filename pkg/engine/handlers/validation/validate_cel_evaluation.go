@@ -0,0 +1,854 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/handlers"
+	"github.com/kyverno/kyverno/pkg/engine/internal"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	datautils "github.com/kyverno/kyverno/pkg/utils/data"
+	vaputils "github.com/kyverno/kyverno/pkg/validatingadmissionpolicy"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	authorizerapi "k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ruleEvaluation is the evaluation of a CEL rule against a resource. Its stages run in order, each of them may end
+// the evaluation with the responses of the rule: the bindings of the values computed for the request, the
+// compilation of the expressions, the namespace of the resource, the admission attributes, the collection of the
+// params, the evaluation of the expressions and the building of the responses.
+type ruleEvaluation struct {
+	h             validateCELHandler
+	logger        logr.Logger
+	policyContext engineapi.PolicyContext
+	rule          kyvernov1.Rule
+	batch         *ruleBatch
+
+	// ctx is the context of the request, ruleCtx bounds the evaluation of the rule and carries its bindings
+	ctx     context.Context
+	ruleCtx context.Context
+
+	resource    unstructured.Unstructured
+	oldResource unstructured.Unstructured
+	// object and oldObject are the objects the expressions are evaluated against, nil when not available
+	object    runtime.Object
+	oldObject runtime.Object
+	gvr       schema.GroupVersionResource
+	gvk       schema.GroupVersionKind
+	// subresource is the subresource of the request, empty for the resource itself
+	subresource string
+	ns          string
+	name        string
+	connect     bool
+	// validations are the expressions of the rule along with the validations compiled from its declarative fields
+	validations []admissionregistrationv1alpha1.Validation
+	digest      string
+
+	bindings   map[string]interface{}
+	envSources *envSourcesBinding
+
+	inputs   compilationInputs
+	compiled compiledRule
+	// duration is the time spent compiling and evaluating the expressions
+	duration         time.Duration
+	validator        validatingadmissionpolicy.Validator
+	suggestionFilter cel.Filter
+	recorder         *expressionRecorder
+	evaluations      *evaluationRecorder
+	costs            *costCounter
+
+	namespace     *corev1.Namespace
+	versionedAttr *admission.VersionedAttributes
+
+	params        []runtime.Object
+	paramVersions map[string]string
+
+	costBudget int64
+	// authorizer counts the authorization checks of the rule, authz is nil unless the expressions reference it
+	authorizer *countingAuthorizer
+	authz      authorizerapi.Authorizer
+	// validationResults are the results of the evaluations, validationParams the params they were evaluated with,
+	// nil for the evaluation without params
+	validationResults []validatingadmissionpolicy.ValidateResult
+	validationParams  []runtime.Object
+
+	gracePeriodEnd time.Time
+	inGracePeriod  bool
+	annotations    map[string]string
+}
+
+// newRuleEvaluation prepares the evaluation of the rule: the objects, the namespace and the name of the request, the
+// validations and the digest of the evaluated object. The rule is skipped when neither object is available.
+func (h validateCELHandler) newRuleEvaluation(
+	logger logr.Logger,
+	policyContext engineapi.PolicyContext,
+	resource unstructured.Unstructured,
+	rule kyvernov1.Rule,
+	batch *ruleBatch,
+) (*ruleEvaluation, []engineapi.RuleResponse) {
+	e := &ruleEvaluation{
+		h:             h,
+		logger:        logger,
+		policyContext: policyContext,
+		rule:          rule,
+		batch:         batch,
+		resource:      resource,
+		// in case of UPDATE requests, set the oldObject to the current resource before it gets updated
+		oldResource: policyContext.OldResource(),
+		// CONNECT requests carry the options of the request, e.g. PodExecOptions, instead of the connected resource
+		connect: policyContext.Operation() == kyvernov1.Connect,
+	}
+
+	// get resource's name, namespace, GroupVersionResource, and GroupVersionKind
+	e.gvr = schema.GroupVersionResource(policyContext.RequestResource())
+	e.gvk, e.subresource = policyContext.ResourceKind()
+
+	// the objects are not copied, the evaluation only reads them and the helpers altering them return copies
+	if e.oldResource.Object != nil {
+		e.oldObject = &e.oldResource
+	}
+	// in case of DELETE request, get the name and the namespace from the old object
+	if resource.Object == nil {
+		e.ns = e.oldResource.GetNamespace()
+		e.name = e.oldResource.GetName()
+	} else {
+		e.ns = resource.GetNamespace()
+		e.name = resource.GetName()
+		e.object = &e.resource
+	}
+	// there is nothing to evaluate when neither object is available
+	if e.object == nil && e.oldObject == nil {
+		logger.V(3).Info("skipping CEL validation as neither the object nor the old object is available")
+		return nil, handlers.WithSkip(rule, engineapi.Validation, "neither the object nor the old object is available")
+	}
+	// in case of CONNECT request, the options have no name, get it from the request
+	if e.connect {
+		if e.name == "" {
+			e.name = requestField(policyContext, "name")
+		}
+	}
+	e.ns = requestNamespace(policyContext, e.ns)
+	// resources lacking a namespace are evaluated in the default namespace, as they would be admitted in it
+	if e.ns == "" && h.defaultNamespace != "" && !e.connect {
+		if namespaced, known := h.scopeOf(e.gvk); !known {
+			logger.V(3).Info("the scope of the resource is unknown, it is evaluated without the default namespace", "gvk", e.gvk.String())
+		} else if namespaced {
+			e.ns = h.defaultNamespace
+			e.object = withNamespace(e.object, e.ns)
+			e.oldObject = withNamespace(e.oldObject, e.ns)
+		}
+	}
+
+	e.validations = ruleValidations(rule)
+
+	// merge the computed labels onto the object, they are only used for the evaluation and are never persisted
+	if computedLabels := rule.Validation.CEL.ComputedLabels; len(computedLabels) != 0 {
+		e.object = withComputedLabels(e.object, computedLabels)
+	}
+
+	// trim the objects to the declared field projection, expressions are checked against it when the policy is admitted
+	if fieldProjection := rule.Validation.CEL.FieldProjection; len(fieldProjection) != 0 {
+		paths := parseFieldProjection(fieldProjection)
+		e.object = projectObject(e.object, paths)
+		e.oldObject = projectObject(e.oldObject, paths)
+	}
+
+	// the digest of the evaluated object correlates the outcome of the rule with its input
+	digest, err := batch.objectDigest(e.evaluatedObject())
+	if err != nil {
+		logger.Error(err, "failed to compute the digest of the evaluated object")
+	}
+	e.digest = digest
+	return e, nil
+}
+
+// ruleValidations returns the validations of the rule: its expressions followed by the validations compiled from its
+// declarative fields, each of them falls back to the message of the rule.
+func ruleValidations(rule kyvernov1.Rule) []admissionregistrationv1alpha1.Validation {
+	validations := rule.Validation.CEL.Expressions
+	// compile the groups of mutually exclusive label and annotation keys to validations
+	if mutuallyExclusive := rule.Validation.CEL.MutuallyExclusive; len(mutuallyExclusive) != 0 {
+		validations = slices.Clip(validations)
+		for _, keys := range mutuallyExclusive {
+			validations = append(validations, celutils.MutuallyExclusiveValidation(keys.Labels, keys.Annotations))
+		}
+	}
+	// compile the approved registries to a validation
+	if allowedRegistries := rule.Validation.CEL.AllowedRegistries; allowedRegistries != nil {
+		validations = slices.Clip(validations)
+		validations = append(validations, celutils.AllowedRegistriesValidation(allowedRegistries.Registries, allowedRegistries.ParamField))
+	}
+	// compile the allow-lists of fields to validations
+	if allowedFields := rule.Validation.CEL.AllowedFields; len(allowedFields) != 0 {
+		validations = slices.Clip(validations)
+		for _, allowed := range allowedFields {
+			validations = append(validations, celutils.AllowedFieldsValidation(allowed.Path, allowed.Fields))
+		}
+	}
+	// compile the required owner kinds to a validation
+	if requiredOwners := rule.Validation.CEL.RequiredOwners; len(requiredOwners) != 0 {
+		owners := make([]schema.GroupKind, 0, len(requiredOwners))
+		for _, owner := range requiredOwners {
+			owners = append(owners, schema.GroupKind{Group: owner.APIGroup, Kind: owner.Kind})
+		}
+		validations = slices.Clip(validations)
+		validations = append(validations, celutils.RequiredOwnersValidation(owners))
+	}
+	// compile the allowed sources of container environment variables to a validation
+	if len(rule.Validation.CEL.AllowedEnvSources) != 0 {
+		validations = slices.Clip(validations)
+		validations = append(validations, celutils.AllowedEnvSourcesValidation())
+	}
+	return withFallbackMessages(validations, rule.Validation.Message)
+}
+
+// evaluatedObject returns the object the expressions are evaluated against, the old object for DELETE requests.
+func (e *ruleEvaluation) evaluatedObject() runtime.Object {
+	if e.object == nil {
+		return e.oldObject
+	}
+	return e.object
+}
+
+// timedOut tells whether the rule ran out of time, rather than the request being canceled.
+func (e *ruleEvaluation) timedOut() bool {
+	return errors.Is(e.ruleCtx.Err(), context.DeadlineExceeded) && e.ctx.Err() == nil
+}
+
+// timeout returns the response of a rule which ran out of time.
+func (e *ruleEvaluation) timeout() []engineapi.RuleResponse {
+	return handlers.WithResponses(
+		engineapi.RuleError(e.rule.Name, engineapi.Validation, fmt.Sprintf("CEL rule timed out after %s", e.h.ruleTimeout), e.ruleCtx.Err()),
+	)
+}
+
+// bind computes the values bound to the variables of the expressions. They are bound when the expressions are
+// evaluated, so that the compiled expressions only depend on the rule and are reused across requests. The cluster
+// context is always exposed, it is empty unless configured.
+func (e *ruleEvaluation) bind() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	clusterContext := h.clusterContext
+	if clusterContext == nil {
+		clusterContext = map[string]string{}
+	}
+	e.bindings = map[string]interface{}{"clusterContext": clusterContext}
+	// expose the sources of container environment variables when allowed sources are declared, the sources which
+	// are not allowed are denied by a validation. They are resolved when first read, once the rule is known to apply.
+	if allowedEnvSources := rule.Validation.CEL.AllowedEnvSources; len(allowedEnvSources) != 0 {
+		e.envSources = &envSourcesBinding{
+			resolve: func() ([]interface{}, error) {
+				return resolveEnvSources(e.ruleCtx, h.client, e.resource, e.ns, allowedEnvSources)
+			},
+		}
+		e.bindings["envSources"] = e.envSources.value
+	}
+	// expose the container images when approved registries are declared
+	if rule.Validation.CEL.AllowedRegistries != nil {
+		e.bindings["images"] = containerImages(e.policyContext.JSONContext().ImageInfo())
+	}
+	// expose the related resources of the declared kinds, they are only provided by offline evaluations
+	if relatedKinds := rule.Validation.CEL.RelatedResources; len(relatedKinds) != 0 {
+		related, err := relatedResourcesOf(h.relatedResources, relatedKinds, e.resource)
+		if err != nil {
+			return handlers.WithError(rule, engineapi.Validation, "failed to collect related resources", err)
+		}
+		e.bindings["relatedResources"] = related
+	}
+	// expose the document of the external data source
+	if externalData := rule.Validation.CEL.ExternalData; externalData != nil {
+		if h.externalData == nil {
+			return handlers.WithError(rule, engineapi.Validation, "external data sources are not enabled", nil)
+		}
+		data, err := h.externalData.Fetch(e.ruleCtx, externalData.URL, externalData.CacheTTL.Duration)
+		if err != nil {
+			if e.timedOut() {
+				return e.timeout()
+			}
+			return handlers.WithError(rule, engineapi.Validation, "failed to fetch external data", err)
+		}
+		e.bindings["externalData"] = data
+	}
+	// expose lowercased copies of the labels and annotations, the object itself is left intact
+	if lowercaseMetadata := rule.Validation.CEL.LowercaseMetadata; lowercaseMetadata != nil {
+		var labels, annotations map[string]string
+		if u, ok := e.evaluatedObject().(*unstructured.Unstructured); ok && u != nil {
+			labels, annotations = u.GetLabels(), u.GetAnnotations()
+		}
+		e.bindings["lowercaseLabels"] = lowercased(labels, lowercaseMetadata.Values)
+		e.bindings["lowercaseAnnotations"] = lowercased(annotations, lowercaseMetadata.Values)
+	}
+	// expose the values loaded by the context entries of the rule
+	if h.contextVariables && len(rule.Context) != 0 {
+		e.bindings["context"] = contextValues(e.logger, rule.Context, e.policyContext.JSONContext())
+	}
+	// the expressions read the values bound to their variables from the context of the rule
+	e.ruleCtx = celutils.WithBindings(e.ruleCtx, e.bindings)
+	return nil
+}
+
+// compileExpressions compiles the expressions of the rule, or reuses them when the rule was compiled with the same
+// inputs, and creates the validator evaluating them.
+func (e *ruleEvaluation) compileExpressions() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	policyKind := e.policyContext.Policy().GetKind()
+	policyName := e.policyContext.Policy().GetName()
+	e.inputs = compilationInputs{
+		Validations:      e.validations,
+		AuditAnnotations: rule.Validation.CEL.AuditAnnotations,
+		MatchConditions:  vaputils.ConvertMatchConditionsV1(rule.CELPreconditions),
+		Variables:        rule.Validation.CEL.Variables,
+		HasParam:         rule.Validation.CEL.HasParam(),
+		Bindings:         bindingNames(e.bindings),
+		Suggestion:       rule.Validation.CEL.SuggestionExpression,
+		Redactions:       rule.Validation.CEL.MessageRedactions,
+	}
+	if e.inputs.HasParam {
+		e.inputs.ParamFieldSelector = rule.Validation.CEL.ParamFieldSelector
+	}
+	e.inputs.HasAuthorizer = usesAuthorizer(e.inputs)
+	var cacheKey string
+	if h.compilationCache != nil {
+		policyKey, _ := cache.MetaNamespaceKeyFunc(e.policyContext.Policy())
+		var err error
+		cacheKey, err = compilationKey(policyKey, e.policyContext.Policy().GetResourceVersion(), rule.Name, e.inputs)
+		if err != nil {
+			e.logger.Error(err, "failed to compute the compilation cache key")
+		}
+	}
+	cached := false
+	if cacheKey != "" {
+		e.compiled, cached = h.compilationCache.get(cacheKey)
+	}
+	if !cached {
+		compileStart := time.Now()
+		compiled, err := h.compile(e.inputs)
+		e.duration += time.Since(compileStart)
+		if err != nil {
+			h.recordCompilationFailure(e.ctx, policyName, rule.Name, compilationStageCompiler)
+			return h.compilationFailure(rule, "Error while creating composited compiler", err)
+		}
+		// expressions failing to compile fail when evaluated, the failures are only counted
+		for _, stage := range compiled.failedStages {
+			h.recordCompilationFailure(e.ctx, policyName, rule.Name, stage)
+		}
+		if cacheKey != "" {
+			h.compilationCache.add(cacheKey, compiled)
+		}
+		e.compiled = compiled
+	}
+	// validation expressions failing to compile can't be evaluated, whatever the preconditions and params
+	if len(e.compiled.validationErrors) != 0 {
+		return h.compilationFailure(rule, "failed to compile CEL expressions", errors.Join(e.compiled.validationErrors...))
+	}
+	// in fail closed mode, any expression failing to compile fails the rule
+	if h.failClosedCompilation && len(e.compiled.compilationErrors) != 0 {
+		return h.compilationFailure(rule, "failed to compile CEL expressions", errors.Join(e.compiled.compilationErrors...))
+	}
+	filter := e.compiled.filter
+	messageExpressionfilter := e.compiled.messageFilter
+	auditAnnotationFilter := e.compiled.auditAnnotationFilter
+	matchConditionFilter := e.compiled.matchConditionFilter
+	suggestionFilter := e.compiled.suggestionFilter
+	// message expressions and suggestions are evaluated against the same copies of the objects with redacted values
+	if len(e.compiled.redactions) != 0 {
+		redactor := newObjectRedactor(e.compiled.redactions)
+		messageExpressionfilter = redactor.wrap(messageExpressionfilter)
+		suggestionFilter = redactor.wrap(suggestionFilter)
+	}
+	if h.explainPass {
+		e.recorder = &expressionRecorder{}
+		filter = e.recorder.wrap(filter, false)
+		matchConditionFilter = e.recorder.wrap(matchConditionFilter, true)
+	}
+	// the decisions of the validator only carry the messages of the errors, keep the errors of each evaluation,
+	// e.g. the names of the failed preconditions or the variables failing to evaluate
+	e.evaluations = &evaluationRecorder{}
+	matchConditionFilter = e.evaluations.wrapConditions(matchConditionFilter)
+
+	// track the runtime cost of the validations, of the messages and of the suggestions, they share the cost budget
+	e.costs = &costCounter{}
+	filter = e.costs.wrap(filter)
+	messageExpressionfilter = e.costs.wrap(messageExpressionfilter)
+	e.suggestionFilter = e.costs.wrap(suggestionFilter)
+
+	filter = e.evaluations.wrapValidations(filter)
+	// message expressions failing as a whole fall back to the static messages instead of failing the decisions
+	messageExpressionfilter = &messageFallbackFilter{Filter: messageExpressionfilter, logger: e.logger}
+
+	// newMatcher will be used to check if the incoming resource matches the CEL preconditions
+	newMatcher := matchconditions.NewMatcher(matchConditionFilter, nil, policyKind, "", policyName)
+	// newValidator will be used to validate CEL expressions against the incoming object
+	e.validator = h.newValidator(filter, newMatcher, auditAnnotationFilter, messageExpressionfilter, nil)
+	return nil
+}
+
+// selectNamespace resolves the namespace of the resource when the expressions or the namespace selector need it,
+// the rule is skipped when its namespace isn't selected.
+func (e *ruleEvaluation) selectNamespace() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	// definedNamespace tells whether the namespace was taken from its definition without a client
+	var definedNamespace bool
+	// Special case, the namespace object has the namespace of itself.
+	// unset it if the incoming object is a namespace, `namespaceObject` is null in this case
+	// and policies validating namespaces read their labels and annotations from `object`
+	isNamespace := e.gvk.Kind == "Namespace" && e.gvk.Version == "v1" && e.gvk.Group == ""
+	if isNamespace {
+		e.ns = ""
+	}
+	// the namespace is only fetched when the expressions reference it or the namespace selector needs its labels,
+	// `namespaceObject` is null otherwise
+	needsNamespace := usesNamespaceObject(e.inputs) || rule.Validation.CEL.NamespaceSelector != nil
+	if e.ns != "" && needsNamespace {
+		if h.client != nil {
+			// the namespace is fetched once per batch and reused by the other rules
+			namespace, err := e.batch.getNamespace(e.ruleCtx, h.client, e.ns)
+			// the namespace may be deleted along with its resources, there is nothing to evaluate them against
+			if apierrors.IsNotFound(err) {
+				e.logger.V(3).Info("skipping CEL validation, the resource's namespace was not found", "namespace", e.ns)
+				return handlers.WithResponses(
+					engineapi.RuleSkip(rule.Name, engineapi.Validation, fmt.Sprintf("the resource's namespace %s was not found", e.ns)),
+				)
+			}
+			if err != nil {
+				if e.timedOut() {
+					return e.timeout()
+				}
+				return handlers.WithResponses(
+					engineapi.RuleError(rule.Name, engineapi.Validation, "Error getting the resource's namespace", err),
+				)
+			}
+			e.namespace = namespace
+		} else if definition, ok := h.namespaces[e.ns]; ok {
+			e.namespace = definition.DeepCopy()
+			definedNamespace = true
+		} else {
+			e.namespace = &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: e.ns,
+				},
+			}
+		}
+	}
+
+	// only the resources of the selected namespaces are evaluated, cluster scoped resources are always evaluated
+	if selector := rule.Validation.CEL.NamespaceSelector; selector != nil && (e.ns != "" || isNamespace) {
+		namespaceName, namespaceLabels := e.ns, e.policyContext.NamespaceLabels()
+		if isNamespace {
+			// namespaces are selected by their own labels
+			namespaceName = e.name
+			if e.resource.Object == nil {
+				namespaceLabels = e.oldResource.GetLabels()
+			} else {
+				namespaceLabels = e.resource.GetLabels()
+			}
+		} else if h.client != nil || definedNamespace {
+			namespaceLabels = e.namespace.Labels
+		}
+		selected, err := selectsNamespace(selector, namespaceLabels)
+		if err != nil {
+			return handlers.WithResponses(
+				engineapi.RuleError(rule.Name, engineapi.Validation, "invalid namespace selector", err),
+			)
+		}
+		if !selected {
+			e.logger.V(3).Info("skipping CEL validation, the namespace is not selected", "namespace", namespaceName)
+			return handlers.WithResponses(
+				engineapi.RuleSkip(rule.Name, engineapi.Validation, fmt.Sprintf("the namespace %s is not selected by the namespace selector", namespaceName)),
+			)
+		}
+	}
+	return nil
+}
+
+// buildAttributes creates the admission attributes the expressions are evaluated against, they are shared by the
+// rules of the batch evaluating the same object unchanged.
+func (e *ruleEvaluation) buildAttributes() []engineapi.RuleResponse {
+	unchanged := len(e.rule.Validation.CEL.ComputedLabels) == 0 && len(e.rule.Validation.CEL.FieldProjection) == 0
+	e.versionedAttr = e.batch.versionedAttr
+	if e.versionedAttr != nil && unchanged && sameContent(e.batch.object, e.resource.Object) {
+		return nil
+	}
+	requestInfo := e.policyContext.AdmissionInfo()
+	userInfo := internal.NewUser(requestInfo.AdmissionUserInfo.Username, requestInfo.AdmissionUserInfo.UID, requestInfo.AdmissionUserInfo.Groups)
+	requestKind := requestKindOf(e.gvk, e.subresource, e.resource, e.oldResource)
+	attrObject, attrOldObject := e.object, e.oldObject
+	if e.h.typedObjects {
+		attrObject, attrOldObject = typedObject(e.object), typedObject(e.oldObject)
+	}
+	// the API server generates the names of the objects created with a generateName once they are admitted,
+	// as for the API server the rules are evaluated with an empty name: `request.name` is empty and
+	// `object.metadata.name` is unset, expressions use `has(object.metadata.name)` to tell them apart
+	attr := admission.NewAttributesRecord(attrObject, attrOldObject, requestKind, e.ns, e.name, e.gvr, e.subresource, admission.Operation(e.policyContext.Operation()), nil, isDryRun(e.policyContext), &userInfo)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, attr.GetKind(), objectInterfaces)
+	if err != nil {
+		return handlers.WithError(e.rule, engineapi.Validation, "error while creating versioned attributes", err)
+	}
+	e.versionedAttr = versionedAttr
+	if unchanged {
+		e.batch.versionedAttr = versionedAttr
+		e.batch.object = e.resource.Object
+	}
+	return nil
+}
+
+// collectParams collects the params the expressions are evaluated with, the rule is skipped when none are found
+// unless its expressions are also evaluated without params.
+func (e *ruleEvaluation) collectParams() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	if !e.inputs.HasParam {
+		return nil
+	}
+	paramRef := rule.Validation.CEL.ParamRef
+	// fetching the params is bounded independently of the evaluation
+	fetchCtx, cancel := context.WithTimeout(e.ruleCtx, h.paramFetchTimeout)
+	// the params loaded by the context entries of the rule aren't fetched again
+	paramLoader := h.paramLoader
+	if h.paramCache != nil {
+		paramLoader = h.paramCache.loader(paramLoader)
+	}
+	paramLoader = newContextParamLoader(paramLoader, rule.Validation.CEL, e.ns, rule.Context, e.policyContext.JSONContext())
+	// the params are filtered by their owners and exclude the resource itself, the old one for DELETE requests
+	self := e.resource
+	if self.Object == nil {
+		self = e.oldResource
+	}
+	// only the params owned by the owners of the resource are collected, e.g. by the ReplicaSet of a Pod
+	if rule.Validation.CEL.ParamsFromOwners {
+		paramLoader = newOwnedParamLoader(paramLoader, self)
+	}
+	params, err := collectAllParams(fetchCtx, paramLoader, rule.Validation.CEL, e.compiled.paramFieldSelector, e.ns, h.paramLimits)
+	timedOut := errors.Is(fetchCtx.Err(), context.DeadlineExceeded) && e.ruleCtx.Err() == nil
+	cancel()
+	if err != nil {
+		if e.timedOut() {
+			return e.timeout()
+		}
+		if timedOut {
+			return handlers.WithResponses(
+				engineapi.RuleError(rule.Name, engineapi.Validation, fmt.Sprintf("param fetch timed out after %s", h.paramFetchTimeout), err),
+			)
+		}
+		return handlers.WithResponses(
+			engineapi.RuleError(rule.Name, engineapi.Validation, "error in parameterized resource", err),
+		)
+	}
+	// the incoming resource is excluded from its own params, e.g. by self-referential policies
+	if rule.Validation.CEL.ExcludeSelfFromParams {
+		params = excludeResource(params, self)
+		if len(params) == 0 && h.paramLimits.denyNotFound(paramRef) {
+			return handlers.WithResponses(
+				engineapi.RuleError(rule.Name, engineapi.Validation, "error in parameterized resource", fmt.Errorf("no params found")),
+			)
+		}
+	}
+	// missing params are denied above, they skip the rule with the Allow action as well as without any action,
+	// unless the expressions are also evaluated without params
+	if len(params) == 0 && !rule.Validation.CEL.EvaluateWithoutParams {
+		e.logger.V(3).Info("skipping CEL validation, no parameter resources matched")
+		return handlers.WithResponses(
+			engineapi.RuleSkip(rule.Name, engineapi.Validation, "no parameter resources matched; skipping"),
+		)
+	}
+	e.params = params
+	e.paramVersions = paramResourceVersions(params)
+	return nil
+}
+
+// evaluate evaluates the expressions without params and with each param. Evaluations interrupted by the timeout of
+// the rule end it in error.
+func (e *ruleEvaluation) evaluate() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	// the cost budget of the rule applies to the evaluation against each param, it is bounded when the policy is admitted
+	e.costBudget = int64(celconfig.RuntimeCELCostBudget)
+	if budget := rule.Validation.CEL.CostBudget; budget != nil {
+		e.costBudget = min(*budget, celutils.MaxRuntimeCostBudget)
+	}
+	// the calls are counted per rule
+	e.authorizer = newCountingAuthorizer(nil, nil)
+	// the authorizer is only available to the rules referencing it, no access reviews are sent otherwise
+	if e.inputs.HasAuthorizer {
+		if e.batch.authorizer == nil {
+			e.batch.authorizer = h.newAuthorizer(h.client, e.gvk)
+		}
+		e.authorizer = newCountingAuthorizer(e.batch.authorizer, h.authorizerBreaker)
+		e.authz = e.authorizer
+	}
+	params := e.params
+	// the expressions are evaluated once without params when the rule has none or asks for it along with its params,
+	// the param-less result comes first and is merged with the results of the params as if it were one more param
+	if !e.inputs.HasParam || rule.Validation.CEL.EvaluateWithoutParams {
+		if responses := e.validateWith(nil); responses != nil {
+			return responses
+		}
+		// the params can't change the outcome of a rule denied without params
+		if rule.Validation.CEL.FailFast && isDenied(e.validationResults[0]) {
+			e.logger.V(3).Info("skipping the params after a denial without params")
+			params = nil
+		}
+	}
+	for _, param := range params {
+		if e.recorder != nil {
+			e.recorder.param, _ = cache.MetaNamespaceKeyFunc(param)
+		}
+		if responses := e.validateWith(param); responses != nil {
+			return responses
+		}
+		// the remaining params can't change the outcome of a denied rule
+		if rule.Validation.CEL.FailFast && isDenied(e.validationResults[len(e.validationResults)-1]) {
+			paramKey, _ := cache.MetaNamespaceKeyFunc(param)
+			e.logger.V(3).Info("skipping the remaining params after a denial", "param", paramKey)
+			break
+		}
+	}
+
+	// the expressions reading the sources of container environment variables fail when they can't be resolved,
+	// the rule ends in error instead
+	if e.envSources != nil && e.envSources.err != nil {
+		return handlers.WithError(rule, engineapi.Validation, "failed to resolve container environment sources", e.envSources.err)
+	}
+	return nil
+}
+
+// validateWith evaluates the expressions with the given param, nil for the evaluation without params.
+func (e *ruleEvaluation) validateWith(param runtime.Object) []engineapi.RuleResponse {
+	validateStart := time.Now()
+	validationResult, err := validateWithRecover(e.evaluations.start(e.ruleCtx), e.logger, e.validator, e.gvr, e.versionedAttr, param, e.namespace, e.costBudget, e.authz)
+	e.duration += time.Since(validateStart)
+	// interrupted evaluations fail their expressions, the rule is reported as timed out instead
+	if e.timedOut() {
+		return e.timeout()
+	}
+	if err != nil {
+		return handlers.WithError(e.rule, engineapi.Validation, "failed to evaluate CEL expressions", err)
+	}
+	e.validationResults = append(e.validationResults, validationResult)
+	e.validationParams = append(e.validationParams, param)
+	return nil
+}
+
+// responses merges the results of the evaluations into the responses of the rule.
+func (e *ruleEvaluation) responses() []engineapi.RuleResponse {
+	h, rule := e.h, e.rule
+	// failed validations are only reported as warnings during the grace period of new policies
+	if gracePeriod := rule.Validation.CEL.GracePeriod; gracePeriod != nil {
+		e.gracePeriodEnd = e.policyContext.Policy().GetCreationTimestamp().Add(gracePeriod.Duration)
+	}
+	e.inGracePeriod = h.now().Before(e.gracePeriodEnd)
+	annotations, dropped := publishedAuditAnnotations(e.validationResults, h.maxAuditAnnotationsLength)
+	if dropped != 0 {
+		e.logger.Info("dropped audit annotation values exceeding the maximum length", "dropped", dropped, "maxLength", h.maxAuditAnnotationsLength)
+	}
+	e.annotations = annotations
+
+	if e.evaluations.preconditionsExhausted() {
+		err := errors.New("the evaluation of the preconditions ran out of cost budget, simplify them")
+		return handlers.WithResponses(
+			e.withDetails(engineapi.RuleError(rule.Name, engineapi.Validation, "CEL preconditions are too expensive", err)),
+		)
+	}
+	passMessage := fmt.Sprintf("Validation rule '%s' passed.", rule.Name)
+	// every decision of every param is reported when all decisions are requested
+	var allDecisions []engineapi.RuleResponse
+	// denials of all params and expressions are only collected when aggregated, the first one is reported otherwise
+	var denials []string
+	// the reason of aggregated denials is the reason of the first one
+	var denialReason metav1.StatusReason
+	// the suggestion of aggregated denials is the suggestion of the first one
+	var denialSuggestion string
+	var deniedParams []engineapi.ParamReference
+	var deniedFieldPaths []string
+	reported := map[denialKey]bool{}
+	// the results without params and with each param are merged alike: the rule fails when any of them denies,
+	// the denials without params don't reference a param
+	for i, validationResult := range e.validationResults {
+		param := paramReference(e.validationParams[i])
+		// no validations are returned if preconditions aren't met
+		if datautils.DeepEqual(validationResult, validatingadmissionpolicy.ValidateResult{}) {
+			if h.allDecisions {
+				allDecisions = append(allDecisions, *e.withDetails(engineapi.RuleSkip(rule.Name, engineapi.Validation, "cel preconditions not met")))
+				continue
+			}
+			if len(denials) != 0 {
+				continue
+			}
+			resp := e.withDetails(engineapi.RuleSkip(rule.Name, engineapi.Validation, "cel preconditions not met"))
+			if e.recorder != nil {
+				resp = resp.WithExpressionResults(e.recorder.results)
+			}
+			return handlers.WithResponses(resp)
+		}
+
+		for j, decision := range validationResult.Decisions {
+			if resp, ok := e.decisionError(i, j, decision); ok {
+				if h.allDecisions {
+					allDecisions = append(allDecisions, *resp)
+					continue
+				}
+				return handlers.WithResponses(resp)
+			}
+			if h.allDecisions {
+				if decision.Action == validatingadmissionpolicy.ActionDeny {
+					allDecisions = append(allDecisions, e.deny(decision.Message, decision.Reason, paramReferences(param), e.deniedPaths(j), e.suggest(e.validationParams[i]))...)
+				} else {
+					allDecisions = append(allDecisions, *e.withDetails(engineapi.RulePass(rule.Name, engineapi.Validation, passMessage)))
+				}
+				continue
+			}
+			switch decision.Action {
+			case validatingadmissionpolicy.ActionDeny:
+				if param != nil {
+					e.logger.V(3).Info("denied with param", "param", *param)
+				}
+				if !h.aggregateDenials {
+					return e.deny(decision.Message, decision.Reason, paramReferences(param), e.deniedPaths(j), e.suggest(e.validationParams[i]))
+				}
+				if param != nil && !slices.Contains(deniedParams, *param) {
+					deniedParams = append(deniedParams, *param)
+				}
+				for _, path := range e.deniedPaths(j) {
+					if !slices.Contains(deniedFieldPaths, path) {
+						deniedFieldPaths = append(deniedFieldPaths, path)
+					}
+				}
+				key := denialKey{message: decision.Message, action: decision.Action}
+				if h.deduplicateDenials && reported[key] {
+					continue
+				}
+				reported[key] = true
+				if len(denials) == 0 {
+					denialReason = decision.Reason
+					denialSuggestion = e.suggest(e.validationParams[i])
+				}
+				denials = append(denials, decision.Message)
+			}
+		}
+	}
+	if len(denials) != 0 {
+		return e.deny(strings.Join(denials, "; "), denialReason, deniedParams, deniedFieldPaths, denialSuggestion)
+	}
+	if len(allDecisions) != 0 {
+		return allDecisions
+	}
+
+	resp := e.withDetails(engineapi.RulePass(rule.Name, engineapi.Validation, passMessage))
+	if e.recorder != nil {
+		resp = resp.WithExpressionResults(e.recorder.results)
+	}
+	return handlers.WithResponses(resp)
+}
+
+// withDetails attaches the details of the evaluation to the response.
+func (e *ruleEvaluation) withDetails(resp *engineapi.RuleResponse) *engineapi.RuleResponse {
+	// the outcome of the expressions is attached to all responses, it is only attached to passing and skipped ones otherwise
+	if e.recorder != nil && e.h.explainAll {
+		resp = resp.WithExpressionResults(e.recorder.results)
+	}
+	return resp.WithObjectDigest(e.digest).
+		WithAuditAnnotations(e.annotations).
+		WithCompiledAt(e.compiled.compiledAt).
+		WithParamResourceVersions(e.paramVersions).
+		WithAuthorizerCalls(e.authorizer.Calls()).
+		WithCost(e.costs.cost).
+		WithDuration(e.duration)
+}
+
+// suggest evaluates the suggestion expression against the param the resource was denied with, the suggestion is
+// advisory and failing to evaluate it doesn't change the outcome of the rule
+func (e *ruleEvaluation) suggest(param runtime.Object) string {
+	if e.rule.Validation.CEL.SuggestionExpression == "" {
+		return ""
+	}
+	request := cel.CreateAdmissionRequest(e.versionedAttr.Attributes, metav1.GroupVersionResource(e.gvr), metav1.GroupVersionKind(e.versionedAttr.VersionedKind))
+	optionalVars := cel.OptionalVariableBindings{VersionedParams: param}
+	results, _, err := e.suggestionFilter.ForInput(e.ruleCtx, e.versionedAttr, request, optionalVars, cel.CreateNamespaceObject(e.namespace), e.costBudget)
+	if err == nil && len(results) != 0 {
+		err = results[0].Error
+	}
+	if err != nil {
+		e.logger.V(2).Info("failed to evaluate the suggestion expression", "error", err.Error())
+		return ""
+	}
+	if len(results) == 0 || results[0].EvalResult == nil {
+		return ""
+	}
+	suggestion, _ := results[0].EvalResult.Value().(string)
+	return strings.TrimSpace(suggestion)
+}
+
+// deny returns the response of a denied resource, a warning when the rule only warns or is in its grace period.
+func (e *ruleEvaluation) deny(msg string, reason metav1.StatusReason, params []engineapi.ParamReference, paths []string, suggestion string) []engineapi.RuleResponse {
+	rule := e.rule
+	// warnings are returned to the client and don't deny the resource
+	if rule.Validation.CEL.Warn {
+		return handlers.WithResponses(e.withDetails(engineapi.RuleWarn(rule.Name, engineapi.Validation, msg)).WithDeniedParams(params).WithReason(reason).WithFieldPaths(paths).WithSuggestion(suggestion))
+	}
+	if e.inGracePeriod {
+		msg = fmt.Sprintf("%s (the rule is enforced once its grace period ends at %s)", msg, e.gracePeriodEnd.UTC().Format(time.RFC3339))
+		return handlers.WithResponses(e.withDetails(engineapi.RuleWarn(rule.Name, engineapi.Validation, msg)).WithDeniedParams(params).WithReason(reason).WithFieldPaths(paths).WithSuggestion(suggestion))
+	}
+	return handlers.WithResponses(e.withDetails(engineapi.RuleFail(rule.Name, engineapi.Validation, msg)).WithDeniedParams(params).WithReason(reason).WithFieldPaths(paths).WithSuggestion(suggestion))
+}
+
+// deniedPaths returns the paths of the object fields referenced by the denying expression, when requested
+func (e *ruleEvaluation) deniedPaths(i int) []string {
+	if !e.h.fieldPaths || i >= len(e.validations) {
+		return nil
+	}
+	paths, err := celutils.FieldPaths(e.validations[i].Expression, "object")
+	if err != nil {
+		e.logger.V(4).Info("failed to compute the field paths of the expression", "error", err.Error())
+		return nil
+	}
+	return paths
+}
+
+// decisionError returns the error of the decision at index j of the evaluation at index i failing to evaluate
+func (e *ruleEvaluation) decisionError(i, j int, decision validatingadmissionpolicy.PolicyDecision) (*engineapi.RuleResponse, bool) {
+	rule := e.rule
+	if decision.Evaluation != validatingadmissionpolicy.EvalError {
+		return nil, false
+	}
+	evaluation := e.evaluations.evaluations[i]
+	// preconditions failing to evaluate are errors, unmet ones skip the rule
+	if failure, ok := evaluation.failedCondition(); ok {
+		msg := fmt.Sprintf("precondition %q failed to evaluate", failure.name)
+		return e.withDetails(engineapi.RuleError(rule.Name, engineapi.Validation, msg, failure.err)), true
+	}
+	if decision.Action != validatingadmissionpolicy.ActionAdmit {
+		return nil, false
+	}
+	// point at the failed variable rather than at the expression using it
+	if j < len(e.validations) {
+		if failure, ok := failedVariable(e.validations[j].Expression, rule.Validation.CEL.Variables, e.compiled.compilationErrors, evaluation.failures.Variables); ok {
+			msg := fmt.Sprintf("variable %q failed to compile", failure.Name)
+			if !failure.Compilation {
+				msg = fmt.Sprintf("variable %q failed to evaluate", failure.Name)
+			}
+			return e.withDetails(engineapi.RuleError(rule.Name, engineapi.Validation, msg, failure.Err)), true
+		}
+	}
+	msg := "failed to evaluate CEL expression"
+	if evaluation.costExhausted(j) {
+		msg = "CEL expressions ran out of cost budget"
+	}
+	return e.withDetails(engineapi.RuleError(rule.Name, engineapi.Validation, msg, evaluation.cause(j, decision.Message))), true
+}
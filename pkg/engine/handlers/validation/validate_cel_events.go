@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"sync"
+	"time"
+
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/event"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultErrorEventInterval is the default minimum interval between two error events of a rule.
+const DefaultErrorEventInterval = time.Minute
+
+// ErrorEventRecorder emits a Warning event on the policy of the CEL rules ending in error, e.g. failing to compile
+// or to evaluate. At most one event is emitted per rule and interval, so that failing policies don't flood the
+// API server with events under load.
+type ErrorEventRecorder struct {
+	generator event.Interface
+	source    event.Source
+	interval  time.Duration
+	now       func() time.Time
+	lock      sync.Mutex
+	// emitted are the times of the last events indexed by policy and rule
+	emitted map[string]time.Time
+}
+
+// NewErrorEventRecorder returns a recorder emitting the error events of rules with the given generator,
+// at most once per interval for each rule.
+func NewErrorEventRecorder(generator event.Interface, source event.Source, interval time.Duration) *ErrorEventRecorder {
+	return &ErrorEventRecorder{
+		generator: generator,
+		source:    source,
+		interval:  interval,
+		now:       time.Now,
+		emitted:   map[string]time.Time{},
+	}
+}
+
+// Record emits the event of a rule response in error, unless an event was emitted for the rule during the interval.
+func (r *ErrorEventRecorder) Record(policy kyvernov1.PolicyInterface, response engineapi.RuleResponse) {
+	if response.Status() != engineapi.RuleStatusError {
+		return
+	}
+	policyKey, err := cache.MetaNamespaceKeyFunc(policy)
+	if err != nil {
+		return
+	}
+	if !r.allow(policyKey + "/" + response.Name()) {
+		return
+	}
+	r.generator.Add(event.NewRuleErrorEvent(policy, r.source, response))
+}
+
+// allow tells whether an event can be emitted for the given key and records its time when it can.
+func (r *ErrorEventRecorder) allow(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.now()
+	if last, ok := r.emitted[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+	// forget the rules whose interval is over, so that deleted policies aren't kept
+	for k, last := range r.emitted {
+		if now.Sub(last) >= r.interval {
+			delete(r.emitted, k)
+		}
+	}
+	r.emitted[key] = now
+	return true
+}
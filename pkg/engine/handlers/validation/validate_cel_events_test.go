@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/event"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+// recordingEventGenerator keeps the events it is given
+type recordingEventGenerator struct {
+	events []event.Info
+}
+
+func (g *recordingEventGenerator) Add(infos ...event.Info) {
+	g.events = append(g.events, infos...)
+}
+
+func TestErrorEventRecorder(t *testing.T) {
+	policy := &kyvernov1.ClusterPolicy{}
+	policy.SetName("check-deployment")
+	policy.SetUID("uid")
+	generator := &recordingEventGenerator{}
+	recorder := NewErrorEventRecorder(generator, event.AdmissionController, time.Minute)
+	now := time.Now()
+	recorder.now = func() time.Time { return now }
+
+	failed := *engineapi.RuleError("check-deployment", engineapi.Validation, "failed to evaluate CEL expression", errors.New("no such key: replicas"))
+	recorder.Record(policy, failed)
+	assert.Equal(t, len(generator.events), 1)
+	info := generator.events[0]
+	assert.Equal(t, info.Regarding.Kind, "ClusterPolicy")
+	assert.Equal(t, info.Regarding.Name, "check-deployment")
+	assert.Equal(t, info.Reason, event.PolicyError)
+	assert.Equal(t, info.Source, event.AdmissionController)
+	assert.Equal(t, info.Message, "rule check-deployment failed: failed to evaluate CEL expression: no such key: replicas")
+
+	// the errors of the rule are not reported again during the interval
+	now = now.Add(30 * time.Second)
+	recorder.Record(policy, failed)
+	assert.Equal(t, len(generator.events), 1)
+
+	// the errors of other rules are reported
+	recorder.Record(policy, *engineapi.RuleError("check-replicas", engineapi.Validation, "failed to compile CEL expressions", errors.New("undeclared reference")))
+	assert.Equal(t, len(generator.events), 2)
+
+	// the responses of rules not in error are ignored
+	recorder.Record(policy, *engineapi.RuleFail("check-labels", engineapi.Validation, "missing labels"))
+	assert.Equal(t, len(generator.events), 2)
+
+	// the errors of the rule are reported again once the interval is over
+	now = now.Add(time.Minute)
+	recorder.Record(policy, failed)
+	assert.Equal(t, len(generator.events), 3)
+}
+
+func Test_ValidateCEL_ErrorEvents(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.replicas <= unknown"}}
+	generator := &recordingEventGenerator{}
+
+	handler, err := NewValidateCELHandler(nil, WithErrorEvents(NewErrorEventRecorder(generator, event.AdmissionController, time.Minute)))
+	assert.NilError(t, err)
+	for i := 0; i < 3; i++ {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	}
+	// the repeated errors of the rule give a single event
+	assert.Equal(t, len(generator.events), 1)
+	assert.Equal(t, generator.events[0].Regarding.Name, "check-deployment")
+}
@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_ExpressionResults(t *testing.T) {
+	tooManyReplicas := []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= 5"},
+		{Expression: "object.spec.replicas <= 2", Message: "too many replicas"},
+	}
+	testCases := []struct {
+		name          string
+		policy        string
+		params        []unstructured.Unstructured
+		options       []ValidateCELOption
+		expressions   []admissionregistrationv1alpha1.Validation
+		preconditions []admissionregistrationv1alpha1.MatchCondition
+		wantStatus    engineapi.RuleStatus
+		wantResults   []engineapi.ExpressionResult
+	}{
+		{
+			name:       "passing rules are not explained by default",
+			policy:     celReplicasPolicy,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "passing rules are explained",
+			policy:     celReplicasPolicy,
+			options:    []ValidateCELOption{WithPassExplanation()},
+			wantStatus: engineapi.RuleStatusPass,
+			wantResults: []engineapi.ExpressionResult{
+				{Expression: "object.spec.replicas <= 5", Result: true},
+				{Expression: "object.metadata.name != 'forbidden'", Result: true},
+			},
+		},
+		{
+			name:   "passing rules are explained per param",
+			policy: celParamPolicy,
+			params: []unstructured.Unstructured{
+				newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+				newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "3"}),
+			},
+			options:    []ValidateCELOption{WithPassExplanation()},
+			wantStatus: engineapi.RuleStatusPass,
+			wantResults: []engineapi.ExpressionResult{
+				{Expression: "object.spec.replicas <= int(params.data.maxReplicas)", Result: true, Param: "default/alpha"},
+				{Expression: "object.spec.replicas <= int(params.data.maxReplicas)", Result: true, Param: "default/beta"},
+			},
+		},
+		{
+			name:   "skipped rules are explained by their preconditions",
+			policy: celReplicasPolicy,
+			preconditions: []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "production", Expression: "object.metadata.namespace == 'production'"},
+			},
+			options:    []ValidateCELOption{WithPassExplanation()},
+			wantStatus: engineapi.RuleStatusSkip,
+			wantResults: []engineapi.ExpressionResult{
+				{Expression: "object.metadata.namespace == 'production'", Result: false, Precondition: true},
+			},
+		},
+		{
+			// the results of failed rules are only attached in verbose mode
+			name:        "failed rules are not explained with the passing ones",
+			policy:      celReplicasPolicy,
+			expressions: tooManyReplicas,
+			options:     []ValidateCELOption{WithPassExplanation()},
+			wantStatus:  engineapi.RuleStatusFail,
+		},
+		{
+			name:        "failed rules are explained in verbose mode",
+			policy:      celReplicasPolicy,
+			expressions: tooManyReplicas,
+			options:     []ValidateCELOption{WithExpressionResults()},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantResults: []engineapi.ExpressionResult{
+				{Expression: "object.spec.replicas <= 5", Result: true},
+				{Expression: "object.spec.replicas <= 2", Result: false},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, tc.policy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			if tc.expressions != nil {
+				rule.Validation.CEL.Expressions = tc.expressions
+			}
+			rule.CELPreconditions = tc.preconditions
+			options := tc.options
+			if tc.params != nil {
+				options = append(options, WithParamLoader(&fakeParamLoader{namespaced: true, params: tc.params}))
+			}
+
+			handler, err := NewValidateCELHandler(nil, options...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.DeepEqual(t, responses[0].ExpressionResults(), tc.wantResults)
+		})
+	}
+
+	t.Run("the results point at the expressions failing to evaluate", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+			{Expression: "object.spec.replicas <= 5"},
+			{Expression: "object.spec.missing <= 2", Message: "too many replicas"},
+		}
+
+		handler, err := NewValidateCELHandler(nil, WithExpressionResults())
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Assert(t, responses[0].Status() != engineapi.RuleStatusPass)
+		results := responses[0].ExpressionResults()
+		assert.Equal(t, len(results), 2)
+		assert.Equal(t, results[0].Error, "")
+		assert.Assert(t, results[1].Error != "")
+	})
+}
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/kyverno/kyverno/pkg/tracing"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// DefaultMaxExternalDataLength is the default maximum length of the documents returned by external data sources.
+	DefaultMaxExternalDataLength = 1000 * 1000
+	// DefaultExternalDataCacheSize is the default number of documents of external data sources kept by the fetcher.
+	DefaultExternalDataCacheSize = 100
+)
+
+// ExternalDataFetcher fetches the JSON documents of the external data sources declared by validate.cel subrules.
+type ExternalDataFetcher interface {
+	// Fetch returns the decoded document of the given URL, documents fetched less than ttl ago may be reused.
+	Fetch(ctx context.Context, url string, ttl time.Duration) (interface{}, error)
+}
+
+type externalDataEntry struct {
+	data    interface{}
+	fetched time.Time
+}
+
+type httpExternalDataFetcher struct {
+	client            *http.Client
+	urls              celutils.ExternalDataURLs
+	timeout           time.Duration
+	maxResponseLength int64
+	now               func() time.Time
+	// cache keeps the most recently fetched documents, they are reused by the callers whose ttl hasn't elapsed
+	// since they were fetched
+	cache *lru.Cache
+}
+
+// NewExternalDataHTTPClient returns an HTTP client dedicated to external data sources, its connections aren't
+// shared with the other clients of the process. Connections to loopback and link-local addresses are refused
+// unless allowLocal is true, whatever the name the address was resolved from.
+func NewExternalDataHTTPClient(allowLocal bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowLocal {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip != nil && celutils.IsLocalIP(ip) {
+					return fmt.Errorf("connections to the loopback or link-local address %s are not allowed", host)
+				}
+				return nil
+			},
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	return &http.Client{
+		Transport: tracing.Transport(transport, otelhttp.WithFilter(tracing.RequestFilterIsInSpan)),
+	}
+}
+
+// NewHTTPExternalDataFetcher returns an ExternalDataFetcher using the given HTTP client, the URLs and the redirections
+// which are not allowed by urls are rejected. Requests taking longer than timeout and documents longer than
+// maxResponseLength are rejected. At most cacheSize documents are kept, a zero cacheSize disables the cache.
+func NewHTTPExternalDataFetcher(client *http.Client, urls celutils.ExternalDataURLs, timeout time.Duration, maxResponseLength int64, cacheSize int) ExternalDataFetcher {
+	// redirections are followed as long as they target allowed URLs
+	checked := *client
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return urls.Check(req.URL.String())
+	}
+	f := &httpExternalDataFetcher{
+		client:            &checked,
+		urls:              urls,
+		timeout:           timeout,
+		maxResponseLength: maxResponseLength,
+		now:               time.Now,
+	}
+	if cacheSize > 0 {
+		f.cache = lru.New(cacheSize)
+	}
+	return f
+}
+
+func (f *httpExternalDataFetcher) Fetch(ctx context.Context, url string, ttl time.Duration) (interface{}, error) {
+	if err := f.urls.Check(url); err != nil {
+		return nil, err
+	}
+	if data, ok := f.cached(url, ttl); ok {
+		return data, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	// read one more byte than allowed to detect documents that are too long
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxResponseLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.maxResponseLength {
+		return nil, fmt.Errorf("response length must be less than max allowed response length of %d", f.maxResponseLength)
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %w", err)
+	}
+	if f.cache != nil {
+		f.cache.Add(url, externalDataEntry{data: data, fetched: f.now()})
+	}
+	return data, nil
+}
+
+// cached returns the document of the URL when it was fetched less than ttl ago, the ttl of the caller applies
+// whatever the ttl of the caller which fetched the document.
+func (f *httpExternalDataFetcher) cached(url string, ttl time.Duration) (interface{}, bool) {
+	if f.cache == nil {
+		return nil, false
+	}
+	value, ok := f.cache.Get(url)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(externalDataEntry)
+	if f.now().Sub(entry.fetched) >= ttl {
+		return nil, false
+	}
+	return entry.data, true
+}
@@ -0,0 +1,244 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newExternalDataServer returns a server serving the given document and counting the requests it receives.
+func newExternalDataServer(t *testing.T, document string, requests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, document)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// allowedURLs returns an allow-list of the given URL prefixes, test servers listen on loopback addresses.
+func allowedURLs(t *testing.T, prefixes string) celutils.ExternalDataURLs {
+	urls, err := celutils.ParseExternalDataURLs(prefixes, true)
+	assert.NilError(t, err)
+	return urls
+}
+
+func Test_ValidateCEL_ExternalData(t *testing.T) {
+	testCases := []struct {
+		name        string
+		document    string
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:       "allowed by the external data",
+			document:   `{"allowed": ["nginx", "redis"]}`,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "denied by the external data",
+			document:    `{"allowed": ["redis"]}`,
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "deployment is not allowed",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			server := newExternalDataServer(t, tc.document, &requests)
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.ExternalData = &kyvernov1.ExternalData{URL: server.URL, CacheTTL: metav1.Duration{Duration: time.Minute}}
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.metadata.name in externalData.allowed", Message: "deployment is not allowed"},
+			}
+
+			fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+			handler, err := NewValidateCELHandler(nil, WithExternalDataFetcher(fetcher))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+			assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+		})
+	}
+}
+
+func Test_ValidateCEL_ExternalData_Disabled(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.ExternalData = &kyvernov1.ExternalData{URL: "https://registry.example.com/allowed", CacheTTL: metav1.Duration{Duration: time.Minute}}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	assert.Equal(t, responses[0].Message(), "external data sources are not enabled")
+}
+
+func TestHTTPExternalDataFetcher_Cache(t *testing.T) {
+	var requests int32
+	server := newExternalDataServer(t, `{"allowed": ["nginx"]}`, &requests)
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize).(*httpExternalDataFetcher)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetcher.now = func() time.Time { return clock }
+
+	data, err := fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, map[string]interface{}{"allowed": []interface{}{"nginx"}})
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// the ttl of each caller applies to the cached document
+	clock = clock.Add(30 * time.Second)
+	_, ok := fetcher.cached(server.URL, 10*time.Second)
+	assert.Assert(t, !ok)
+	_, ok = fetcher.cached(server.URL, time.Minute)
+	assert.Assert(t, ok)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, 10*time.Second)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+
+	// the document is fetched again once expired
+	clock = clock.Add(time.Minute)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(3))
+}
+
+func TestHTTPExternalDataFetcher_CacheSize(t *testing.T) {
+	var requests int32
+	server := newExternalDataServer(t, `{"allowed": ["nginx"]}`, &requests)
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, 1).(*httpExternalDataFetcher)
+
+	// the least recently fetched document is evicted
+	_, err := fetcher.Fetch(context.TODO(), server.URL+"/a", time.Minute)
+	assert.NilError(t, err)
+	_, err = fetcher.Fetch(context.TODO(), server.URL+"/b", time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, fetcher.cache.Len(), 1)
+	_, err = fetcher.Fetch(context.TODO(), server.URL+"/a", time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(3))
+
+	// a zero size disables the cache
+	fetcher = NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, 0).(*httpExternalDataFetcher)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(5))
+}
+
+func TestHTTPExternalDataFetcher_MaxResponseLength(t *testing.T) {
+	var requests int32
+	server := newExternalDataServer(t, `{"allowed": ["nginx", "redis"]}`, &requests)
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, 10, DefaultExternalDataCacheSize)
+	_, err := fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.Error(t, err, "response length must be less than max allowed response length of 10")
+}
+
+func TestHTTPExternalDataFetcher_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), 10*time.Millisecond, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err := fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+func TestHTTPExternalDataFetcher_AllowedURLs(t *testing.T) {
+	var requests int32
+	server := newExternalDataServer(t, `{"allowed": ["nginx"]}`, &requests)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/allowed", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	// the URLs outside of the allow-list aren't fetched
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL+"/allowed/"), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err := fetcher.Fetch(context.TODO(), server.URL+"/allowed/registries", time.Minute)
+	assert.NilError(t, err)
+	_, err = fetcher.Fetch(context.TODO(), server.URL+"/allowed/../secret", time.Minute)
+	assert.ErrorContains(t, err, "isn't allowed for external data sources")
+	_, err = fetcher.Fetch(context.TODO(), server.URL+"/secret", time.Minute)
+	assert.ErrorContains(t, err, "isn't allowed for external data sources")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// the redirections are checked against the allow-list
+	fetcher = NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, redirect.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err = fetcher.Fetch(context.TODO(), redirect.URL, time.Minute)
+	assert.ErrorContains(t, err, "isn't allowed for external data sources")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// loopback addresses are rejected unless allowed
+	urls, err := celutils.ParseExternalDataURLs(server.URL, false)
+	assert.NilError(t, err)
+	fetcher = NewHTTPExternalDataFetcher(server.Client(), urls, time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.ErrorContains(t, err, "targets a loopback or link-local address")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+}
+
+func TestNewExternalDataHTTPClient(t *testing.T) {
+	var requests int32
+	server := newExternalDataServer(t, `{"allowed": ["nginx"]}`, &requests)
+	// the addresses are checked once resolved, whatever the allow-list
+	fetcher := NewHTTPExternalDataFetcher(NewExternalDataHTTPClient(false), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err := fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.ErrorContains(t, err, "connections to the loopback or link-local address")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(0))
+
+	fetcher = NewHTTPExternalDataFetcher(NewExternalDataHTTPClient(true), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	_, err = fetcher.Fetch(context.TODO(), server.URL, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+}
+
+func Test_ValidateCEL_ExternalData_RuleTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.ExternalData = &kyvernov1.ExternalData{URL: server.URL, CacheTTL: metav1.Duration{Duration: time.Minute}}
+
+	// the fetch is bounded by the rule timeout
+	fetcher := NewHTTPExternalDataFetcher(server.Client(), allowedURLs(t, server.URL), time.Minute, DefaultMaxExternalDataLength, DefaultExternalDataCacheSize)
+	handler, err := NewValidateCELHandler(nil, WithExternalDataFetcher(fetcher), WithRuleTimeout(10*time.Millisecond))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	assert.Equal(t, responses[0].Message(), "CEL rule timed out after 10ms: "+context.DeadlineExceeded.Error())
+}
@@ -0,0 +1,92 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimitReached is returned when no evaluation slot became available before the queue timeout.
+// Callers should retry the request later.
+var ErrConcurrencyLimitReached = errors.New("too many concurrent evaluations of the policy, retry later")
+
+// ConcurrencyLimiter bounds the number of concurrent evaluations of each policy.
+type ConcurrencyLimiter struct {
+	limit        int
+	queueTimeout time.Duration
+	lock         sync.Mutex
+	// semaphores are the slots of the policies being evaluated, the slots of a policy are dropped once idle so that
+	// deleted and renamed policies aren't kept
+	semaphores map[string]*policySlots
+}
+
+// policySlots are the evaluation slots of a policy along with the number of evaluations holding or waiting for one.
+type policySlots struct {
+	slots chan struct{}
+	users int
+}
+
+// NewConcurrencyLimiter returns a limiter allowing at most limit concurrent evaluations of each policy.
+// Excess evaluations wait up to queueTimeout for a slot, a zero queueTimeout rejects them immediately.
+func NewConcurrencyLimiter(limit int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limit:        limit,
+		queueTimeout: queueTimeout,
+		semaphores:   map[string]*policySlots{},
+	}
+}
+
+// enter returns the slots of the policy, they are kept until the evaluation leaves.
+func (l *ConcurrencyLimiter) enter(policy string) chan struct{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	semaphore, ok := l.semaphores[policy]
+	if !ok {
+		semaphore = &policySlots{slots: make(chan struct{}, l.limit)}
+		l.semaphores[policy] = semaphore
+	}
+	semaphore.users++
+	return semaphore.slots
+}
+
+// leave drops the slots of the policy once no evaluation holds or waits for one.
+func (l *ConcurrencyLimiter) leave(policy string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	semaphore := l.semaphores[policy]
+	semaphore.users--
+	if semaphore.users == 0 {
+		delete(l.semaphores, policy)
+	}
+}
+
+// Acquire waits for an evaluation slot of the policy and returns the function releasing it.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, policy string) (func(), error) {
+	semaphore := l.enter(policy)
+	release := func() {
+		<-semaphore
+		l.leave(policy)
+	}
+	select {
+	case semaphore <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		l.leave(policy)
+		return nil, ErrConcurrencyLimitReached
+	}
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case semaphore <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		l.leave(policy)
+		return nil, ErrConcurrencyLimitReached
+	case <-ctx.Done():
+		l.leave(policy)
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+)
+
+func TestConcurrencyLimiter_Bound(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2, time.Minute)
+	var active, maxActive int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.TODO(), "check-replicas")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			current := atomic.AddInt32(&active, 1)
+			for {
+				observed := atomic.LoadInt32(&maxActive)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxActive, observed, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+	assert.Assert(t, maxActive <= 2)
+	assert.Assert(t, maxActive > 0)
+}
+
+func TestConcurrencyLimiter_Reject(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 0)
+	release, err := limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	_, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.Equal(t, err, ErrConcurrencyLimitReached)
+	// other policies have their own slots
+	other, err := limiter.Acquire(context.TODO(), "check-labels")
+	assert.NilError(t, err)
+	other()
+	release()
+	release, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	release()
+}
+
+func TestConcurrencyLimiter_Queue(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 10*time.Millisecond)
+	release, err := limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	_, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.Equal(t, err, ErrConcurrencyLimitReached)
+	release()
+
+	// a queued evaluation gets the slot once it is released
+	limiter = NewConcurrencyLimiter(1, time.Minute)
+	release, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	time.AfterFunc(10*time.Millisecond, release)
+	queued, err := limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	queued()
+
+	// a cancelled context stops waiting
+	release, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	defer release()
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = limiter.Acquire(ctx, "check-replicas")
+	assert.Equal(t, err, context.Canceled)
+}
+
+func TestConcurrencyLimiter_Idle(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 10*time.Millisecond)
+	release, err := limiter.Acquire(context.TODO(), "check-replicas")
+	assert.NilError(t, err)
+	// the slots are kept while an evaluation holds or waits for one
+	_, err = limiter.Acquire(context.TODO(), "check-replicas")
+	assert.Equal(t, err, ErrConcurrencyLimitReached)
+	assert.Equal(t, len(limiter.semaphores), 1)
+	// the slots of an idle policy are dropped
+	release()
+	assert.Equal(t, len(limiter.semaphores), 0)
+}
+
+func Test_ValidateCEL_ConcurrencyLimiter(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	limiter := NewConcurrencyLimiter(1, 0)
+
+	handler, err := NewValidateCELHandler(nil, WithConcurrencyLimiter(limiter))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+
+	// the slot of the policy is taken
+	release, err := limiter.Acquire(context.TODO(), "check-deployment")
+	assert.NilError(t, err)
+	defer release()
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	assert.Equal(t, responses[0].Message(), "failed to acquire a CEL evaluation slot: "+ErrConcurrencyLimitReached.Error())
+}
+
+func BenchmarkValidateCEL_ConcurrencyLimiter(b *testing.B) {
+	policyContext := buildContext(b, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	handler, err := NewValidateCELHandler(nil, WithConcurrencyLimiter(NewConcurrencyLimiter(4, time.Minute)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			if len(responses) != 1 || responses[0].Status() != engineapi.RuleStatusPass {
+				b.Errorf("unexpected responses: %v", responses)
+			}
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_MessageFallback(t *testing.T) {
+	testCases := []struct {
+		name              string
+		messageExpression string
+		message           string
+		ruleMessage       string
+		wantMessage       string
+	}{
+		{
+			name:              "message expression",
+			messageExpression: "'replicas ' + string(object.spec.replicas) + ' exceed 2'",
+			message:           "too many replicas",
+			ruleMessage:       "invalid deployment",
+			wantMessage:       "replicas 3 exceed 2",
+		},
+		{
+			name:              "message when the message expression is empty",
+			messageExpression: "''",
+			message:           "too many replicas",
+			ruleMessage:       "invalid deployment",
+			wantMessage:       "too many replicas",
+		},
+		{
+			name:              "message when the message expression fails to evaluate",
+			messageExpression: "'replicas of ' + object.metadata.labels['app'] + ' exceed 2'",
+			message:           "too many replicas",
+			ruleMessage:       "invalid deployment",
+			wantMessage:       "too many replicas",
+		},
+		{
+			name:              "rule message when the message expression fails to evaluate",
+			messageExpression: "'replicas of ' + object.metadata.labels['app'] + ' exceed 2'",
+			ruleMessage:       "invalid deployment",
+			wantMessage:       "invalid deployment",
+		},
+		{
+			name:        "message",
+			message:     "too many replicas",
+			ruleMessage: "invalid deployment",
+			wantMessage: "too many replicas",
+		},
+		{
+			name:        "rule message",
+			ruleMessage: "invalid deployment",
+			wantMessage: "invalid deployment",
+		},
+		{
+			name:        "default message",
+			wantMessage: "failed expression: object.spec.replicas <= 2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.Message = tc.ruleMessage
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= 2", Message: tc.message, MessageExpression: tc.messageExpression},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			// the policy is left untouched
+			assert.Equal(t, rule.Validation.CEL.Expressions[0].Message, tc.message)
+		})
+	}
+}
+
+func Test_ValidateCEL_MessageExpressionBudget(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= 2", Message: "too many replicas"},
+	}
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+
+	// the cost of the validation alone
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	costBudget := responses[0].Cost() + 1
+
+	// the message expression runs out of the remaining budget, the static message is reported
+	rule.Validation.CEL.CostBudget = &costBudget
+	rule.Validation.CEL.Expressions[0].MessageExpression = "[1, 2, 3, 4, 5, 6, 7, 8].map(x, [1, 2, 3, 4, 5, 6, 7, 8].map(y, x * y)).size() > 0 ? 'replicas exceed 2' : ''"
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+	assert.Equal(t, responses[0].Message(), "too many replicas")
+}
+
+func Test_ValidateCEL_Reason(t *testing.T) {
+	forbidden := metav1.StatusReasonForbidden
+	testCases := []struct {
+		name       string
+		reason     *metav1.StatusReason
+		wantReason metav1.StatusReason
+	}{
+		{
+			name:       "defaults to invalid",
+			wantReason: metav1.StatusReasonInvalid,
+		},
+		{
+			name:       "the reason of the expression",
+			reason:     &forbidden,
+			wantReason: metav1.StatusReasonForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= 2", Message: "too many replicas", Reason: tc.reason},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, responses[0].Reason(), tc.wantReason)
+		})
+	}
+
+	t.Run("aggregated denials have the reason of the first one", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+			{Expression: "object.spec.replicas <= 2", Message: "too many replicas", Reason: &forbidden},
+			{Expression: "has(object.metadata.labels)", Message: "labels are required"},
+		}
+
+		handler, err := NewValidateCELHandler(nil, WithDenialAggregation(false))
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Message(), "too many replicas; labels are required")
+		assert.Equal(t, responses[0].Reason(), metav1.StatusReasonForbidden)
+	})
+}
+
+func Test_ValidateCEL_DenialAggregation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		options     []ValidateCELOption
+		wantMessage string
+	}{
+		{
+			name:        "first denial",
+			wantMessage: "too many replicas",
+		},
+		{
+			name:        "aggregated denials",
+			options:     []ValidateCELOption{WithDenialAggregation(false)},
+			wantMessage: "too many replicas; too many replicas; too many replicas",
+		},
+		{
+			name:        "deduplicated denials",
+			options:     []ValidateCELOption{WithDenialAggregation(true)},
+			wantMessage: "too many replicas",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+					newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+					newConfigMapParam("default", "gamma", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+				},
+			}
+
+			handler, err := NewValidateCELHandler(nil, append(tc.options, WithParamLoader(loader))...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, responses[0].Message(), tc.wantMessage)
+		})
+	}
+}
+
+func Test_ValidateCEL_Suggestion(t *testing.T) {
+	testCases := []struct {
+		name           string
+		maxReplicas    string
+		suggestion     string
+		wantStatus     engineapi.RuleStatus
+		wantSuggestion string
+	}{
+		{
+			name:           "denied resource",
+			maxReplicas:    "2",
+			suggestion:     "'set spec.replicas to at most ' + string(params.data.maxReplicas)",
+			wantStatus:     engineapi.RuleStatusFail,
+			wantSuggestion: "set spec.replicas to at most 2",
+		},
+		{
+			name:        "admitted resource",
+			maxReplicas: "5",
+			suggestion:  "'set spec.replicas to at most ' + string(params.data.maxReplicas)",
+			wantStatus:  engineapi.RuleStatusPass,
+		},
+		{
+			name:        "suggestion failing to evaluate",
+			maxReplicas: "2",
+			suggestion:  "'set spec.replicas to at most ' + string(params.data.minReplicas)",
+			wantStatus:  engineapi.RuleStatusFail,
+		},
+		{
+			name:        "suggestion not returning a string",
+			maxReplicas: "2",
+			suggestion:  "int(params.data.maxReplicas)",
+			wantStatus:  engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.ParamKind = &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+			rule.Validation.CEL.ParamRef = &admissionregistrationv1alpha1.ParamRef{Name: "limits"}
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= int(params.data.maxReplicas)", Message: "too many replicas"},
+			}
+			rule.Validation.CEL.SuggestionExpression = tc.suggestion
+			loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+				newConfigMapParam("default", "limits", nil, map[string]interface{}{"maxReplicas": tc.maxReplicas}),
+			}}
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			// the suggestion doesn't change the message of the denial
+			if tc.wantStatus == engineapi.RuleStatusFail {
+				assert.Equal(t, responses[0].Message(), "too many replicas")
+			}
+			assert.Equal(t, responses[0].Suggestion(), tc.wantSuggestion)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"context"
+
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// scopeOf tells whether resources of the given kind are namespaced, known is false when their scope can't be told.
+// The scope is discovered with the client when available, it is told by the REST mapper otherwise.
+func (h validateCELHandler) scopeOf(gvk schema.GroupVersionKind) (namespaced bool, known bool) {
+	if h.client != nil {
+		namespaced, err := h.client.IsNamespaced(gvk.Group, gvk.Version, gvk.Kind)
+		return namespaced, err == nil
+	}
+	if h.restMapper != nil {
+		mapping, err := h.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return false, false
+		}
+		return mapping.Scope.Name() == meta.RESTScopeNameNamespace, true
+	}
+	return false, false
+}
+
+// getNamespace returns the namespace with the given name, it is fetched with the client the first time.
+func (b *ruleBatch) getNamespace(ctx context.Context, client engineapi.Client, name string) (*corev1.Namespace, error) {
+	if namespace, ok := b.namespaces[name]; ok {
+		return namespace, nil
+	}
+	namespace, err := client.GetNamespace(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if b.namespaces == nil {
+		b.namespaces = map[string]*corev1.Namespace{}
+	}
+	b.namespaces[name] = namespace
+	return namespace, nil
+}
+
+// withNamespace returns a copy of the object in the given namespace.
+func withNamespace(obj runtime.Object, namespace string) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return obj
+	}
+	u = u.DeepCopy()
+	u.SetNamespace(namespace)
+	return u
+}
+
+// usesNamespaceObject tells whether the expressions of a rule, including its message expressions, reference the namespace object.
+func usesNamespaceObject(inputs compilationInputs) bool {
+	var expressions []string
+	for _, validation := range inputs.Validations {
+		expressions = append(expressions, validation.Expression, validation.MessageExpression)
+	}
+	if inputs.Suggestion != "" {
+		expressions = append(expressions, inputs.Suggestion)
+	}
+	for _, auditAnnotation := range inputs.AuditAnnotations {
+		expressions = append(expressions, auditAnnotation.ValueExpression)
+	}
+	for _, matchCondition := range inputs.MatchConditions {
+		expressions = append(expressions, matchCondition.Expression)
+	}
+	for _, variable := range inputs.Variables {
+		expressions = append(expressions, variable.Expression)
+	}
+	return referencesVariable(expressions, "namespaceObject")
+}
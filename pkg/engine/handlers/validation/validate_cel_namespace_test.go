@@ -0,0 +1,483 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/policycontext"
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var celNamespacePolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-namespace"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-namespace",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Namespace"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"expressions": [
+							{
+								"expression": "namespaceObject == null",
+								"message": "namespaceObject is set"
+							},
+							{
+								"expression": "has(object.metadata.labels) && 'environment' in object.metadata.labels",
+								"message": "the environment label is required"
+							},
+							{
+								"expression": "!has(object.metadata.labels) || !('environment' in object.metadata.labels) || object.metadata.labels['environment'] in ['production', 'staging']",
+								"messageExpression": "'unknown environment ' + object.metadata.labels['environment'] + ' of namespace ' + object.metadata.name"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_Namespace(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labels      string
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:       "namespace with a known environment",
+			labels:     `{"environment": "production"}`,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "namespace with an unknown environment",
+			labels:      `{"environment": "sandbox"}`,
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "unknown environment sandbox of namespace payments",
+		},
+		{
+			name:        "namespace without labels",
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "the environment label is required",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata := `"name": "payments"`
+			if tc.labels != "" {
+				metadata += `, "labels": ` + tc.labels
+			}
+			namespace := fmt.Sprintf(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {%s}}`, metadata)
+			policyContext := buildContext(t, kyvernov1.Create, celNamespacePolicy, namespace, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_ValidateCEL_Namespaces(t *testing.T) {
+	testCases := []struct {
+		name       string
+		namespaces []corev1.Namespace
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name: "the definition of the namespace",
+			namespaces: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}, Annotations: map[string]string{"owner": "team-a"}}},
+			},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name: "the namespace isn't defined",
+			namespaces: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"env": "prod"}, Annotations: map[string]string{"owner": "team-a"}}},
+			},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "has(namespaceObject.metadata.labels) && namespaceObject.metadata.labels['env'] == 'prod'", Message: "production namespaces only"},
+				{Expression: "has(namespaceObject.metadata.annotations) && namespaceObject.metadata.annotations['owner'] == 'team-a'", Message: "owned namespaces only"},
+			}
+
+			handler, err := NewValidateCELHandler(nil, WithNamespaces(tc.namespaces))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_DefaultNamespace(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	testCases := []struct {
+		name       string
+		resource   string
+		client     engineapi.Client
+		mapper     meta.RESTMapper
+		expression string
+	}{
+		{
+			name:       "namespaced resource without namespace",
+			resource:   `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx"}, "spec": {"replicas": 1}}`,
+			mapper:     mapper,
+			expression: "object.metadata.namespace == 'team-a' && namespaceObject.metadata.name == 'team-a'",
+		},
+		{
+			name:       "namespaced resource with a namespace",
+			resource:   `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "team-b"}, "spec": {"replicas": 1}}`,
+			mapper:     mapper,
+			expression: "object.metadata.namespace == 'team-b' && namespaceObject.metadata.name == 'team-b'",
+		},
+		{
+			name:       "cluster scoped resource",
+			resource:   `{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": {"name": "view"}}`,
+			mapper:     mapper,
+			expression: "!has(object.metadata.namespace) && namespaceObject == null",
+		},
+		{
+			name:       "resource of an unknown scope",
+			resource:   `{"apiVersion": "example.com/v1", "kind": "Tenant", "metadata": {"name": "acme"}}`,
+			mapper:     mapper,
+			expression: "!has(object.metadata.namespace) && namespaceObject == null",
+		},
+		{
+			name:       "resource without a REST mapper",
+			resource:   `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx"}, "spec": {"replicas": 1}}`,
+			expression: "!has(object.metadata.namespace) && namespaceObject == null",
+		},
+		{
+			name:       "cluster scoped resource discovered with the client",
+			resource:   `{"apiVersion": "example.com/v1", "kind": "Tenant", "metadata": {"name": "acme"}}`,
+			client:     &fakeClient{namespaced: false},
+			expression: "!has(object.metadata.namespace) && namespaceObject == null",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, tc.resource, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+
+			handler, err := NewValidateCELHandler(tc.client, WithDefaultNamespace("team-a"), WithRESTMapper(tc.mapper))
+			assert.NilError(t, err)
+			resource, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+			// the resource itself is left untouched
+			assert.DeepEqual(t, resource.Object, policyContext.NewResource().Object)
+		})
+	}
+}
+
+func Test_ValidateCEL_RequestNamespace(t *testing.T) {
+	testCases := []struct {
+		name             string
+		resource         string
+		requestNamespace string
+		expression       string
+	}{
+		{
+			name:             "namespace of the object disagrees with the request",
+			resource:         `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "team-b"}, "spec": {"replicas": 1}}`,
+			requestNamespace: "team-a",
+			expression:       "namespaceObject.metadata.name == 'team-a'",
+		},
+		{
+			name:             "object without namespace",
+			resource:         `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx"}, "spec": {"replicas": 1}}`,
+			requestNamespace: "team-a",
+			expression:       "namespaceObject.metadata.name == 'team-a'",
+		},
+		{
+			name:       "request without namespace",
+			resource:   `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "team-b"}, "spec": {"replicas": 1}}`,
+			expression: "namespaceObject.metadata.name == 'team-b'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, tc.resource, "")
+			err := policyContext.JSONContext().AddRequest(admissionv1.AdmissionRequest{
+				Name:      "nginx",
+				Namespace: tc.requestNamespace,
+				Operation: admissionv1.Create,
+			})
+			assert.NilError(t, err)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_NamespaceCache(t *testing.T) {
+	t.Run("the namespace is fetched once for the rules of a policy", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		first := policyContext.Policy().GetSpec().Rules[0]
+		first.Validation.CEL.Expressions = append(first.Validation.CEL.Expressions, celNamespaceValidation)
+		second := *first.DeepCopy()
+		second.Name = "check-deployment-again"
+
+		client := &fakeClient{}
+		handler, err := NewValidateCELBatchHandler(client)
+		assert.NilError(t, err)
+		for _, rule := range []kyvernov1.Rule{first, second} {
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		}
+		assert.Equal(t, client.namespaceCalls, 1)
+
+		// the handler of a single rule doesn't keep it
+		single, err := NewValidateCELHandler(client)
+		assert.NilError(t, err)
+		for _, rule := range []kyvernov1.Rule{first, second} {
+			_, responses := single.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+		}
+		assert.Equal(t, client.namespaceCalls, 3)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = append(rule.Validation.CEL.Expressions, celNamespaceValidation)
+
+		client := &fakeClient{namespaceErr: fmt.Errorf("connection refused")}
+		handler, err := NewValidateCELHandler(client)
+		assert.NilError(t, err)
+		for i := 0; i < 2; i++ {
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+			assert.Equal(t, responses[0].Message(), "Error getting the resource's namespace: connection refused")
+		}
+		assert.Equal(t, client.namespaceCalls, 2)
+	})
+
+	t.Run("rules are skipped when the namespace is not found", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Delete, celReplicasPolicy, celDeployment, celDeployment)
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = append(rule.Validation.CEL.Expressions, celNamespaceValidation)
+
+		client := &fakeClient{namespaceErr: apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "default")}
+		handler, err := NewValidateCELHandler(client)
+		assert.NilError(t, err)
+		// the deleted resource is the old one
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, unstructured.Unstructured{}, rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusSkip)
+		assert.Equal(t, responses[0].Message(), "the resource's namespace default was not found")
+	})
+
+	t.Run("the namespace is not fetched unless referenced", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+
+		client := &fakeClient{}
+		handler, err := NewValidateCELHandler(client)
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+		assert.Equal(t, client.namespaceCalls, 0)
+
+		// message expressions may reference it as well
+		rule.Validation.CEL.Expressions[0].MessageExpression = "'too many replicas in ' + namespaceObject.metadata.name"
+		_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, client.namespaceCalls, 1)
+	})
+}
+
+// celNamespaceValidation references the namespace of the evaluated resource
+var celNamespaceValidation = admissionregistrationv1alpha1.Validation{Expression: "namespaceObject.metadata.name == object.metadata.namespace"}
+
+func Test_ValidateCEL_NamespaceSelector(t *testing.T) {
+	production := &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "production"}}
+	testCases := []struct {
+		name            string
+		policy          string
+		resource        string
+		client          *fakeClient
+		namespaceLabels map[string]string
+		selector        *metav1.LabelSelector
+		wantStatus      engineapi.RuleStatus
+		wantMessage     string
+	}{
+		{
+			name:       "without selector",
+			policy:     celReplicasPolicy,
+			resource:   celDeployment,
+			client:     &fakeClient{},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "selected namespace",
+			policy:     celReplicasPolicy,
+			resource:   celDeployment,
+			client:     &fakeClient{namespaceLabels: map[string]string{"environment": "production"}},
+			selector:   production,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "namespace not selected",
+			policy:      celReplicasPolicy,
+			resource:    celDeployment,
+			client:      &fakeClient{namespaceLabels: map[string]string{"environment": "sandbox"}},
+			selector:    production,
+			wantStatus:  engineapi.RuleStatusSkip,
+			wantMessage: "the namespace default is not selected by the namespace selector",
+		},
+		{
+			name:            "namespace labels of the policy context without client",
+			policy:          celReplicasPolicy,
+			resource:        celDeployment,
+			namespaceLabels: map[string]string{"environment": "sandbox"},
+			selector:        production,
+			wantStatus:      engineapi.RuleStatusSkip,
+			wantMessage:     "the namespace default is not selected by the namespace selector",
+		},
+		{
+			name:       "namespaces are selected by their own labels",
+			policy:     celNamespacePolicy,
+			resource:   `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "payments", "labels": {"environment": "production"}}}`,
+			client:     &fakeClient{},
+			selector:   production,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "namespace not selected by its own labels",
+			policy:      celNamespacePolicy,
+			resource:    `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "payments", "labels": {"environment": "sandbox"}}}`,
+			client:      &fakeClient{namespaceLabels: map[string]string{"environment": "production"}},
+			selector:    production,
+			wantStatus:  engineapi.RuleStatusSkip,
+			wantMessage: "the namespace payments is not selected by the namespace selector",
+		},
+		{
+			name:     "invalid selector",
+			policy:   celReplicasPolicy,
+			resource: celDeployment,
+			client:   &fakeClient{},
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "environment", Operator: "Matches"},
+			}},
+			wantStatus: engineapi.RuleStatusError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, tc.policy, tc.resource, "")
+			if tc.namespaceLabels != nil {
+				policyContext = policyContext.(*policycontext.PolicyContext).WithNamespaceLabels(tc.namespaceLabels)
+			}
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.NamespaceSelector = tc.selector
+			var client engineapi.Client
+			if tc.client != nil {
+				client = tc.client
+			}
+			handler, err := NewValidateCELHandler(client)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_usesNamespaceObject(t *testing.T) {
+	testCases := []struct {
+		name   string
+		inputs compilationInputs
+		want   bool
+	}{
+		{
+			name:   "no reference",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.replicas <= 2"}}},
+		},
+		{
+			name:   "referenced by a validation",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "namespaceObject.metadata.name != 'production'"}}},
+			want:   true,
+		},
+		{
+			name:   "referenced by a message expression",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.replicas <= 2", MessageExpression: "'too many replicas in ' + namespaceObject.metadata.name"}}},
+			want:   true,
+		},
+		{
+			name:   "referenced by the suggestion",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.spec.replicas <= 2"}}, Suggestion: "namespaceObject.metadata.name"},
+			want:   true,
+		},
+		{
+			name:   "string literal",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "object.metadata.annotations['source'] != 'namespaceObject'"}}},
+		},
+		{
+			name:   "field named like the namespace object",
+			inputs: compilationInputs{Validations: []admissionregistrationv1alpha1.Validation{{Expression: "!has(object.spec.namespaceObject)"}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, usesNamespaceObject(tc.inputs), tc.want)
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	enginecontext "github.com/kyverno/kyverno/pkg/engine/context"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ParamLoader resolves the parameter resources referenced by validate.cel subrules.
+type ParamLoader interface {
+	// IsNamespaced returns true if the given parameter kind is namespace-scoped.
+	IsNamespaced(group, version, kind string) (bool, error)
+	// GetParam returns the parameter resource with the given name.
+	GetParam(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error)
+	// ListParams returns a page of at most limit parameter resources matching the given label selector.
+	// The list is continued with the continue token of the previous page, it is empty for the first page.
+	ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error)
+}
+
+// paramLimits bounds the listing of the parameter resources of a rule and sets the action taken when none are found.
+type paramLimits struct {
+	// pageSize is the number of params listed at once
+	pageSize int64
+	// max is the maximum number of params evaluated by a rule
+	max int
+	// namespaces is the maximum number of namespaces selected by the paramNamespaceSelector of a rule
+	namespaces int
+	// notFoundAction is the action taken when no params are found for the references lacking one, Allow when empty
+	notFoundAction admissionregistrationv1alpha1.ParameterNotFoundActionType
+}
+
+// ParseParameterNotFoundAction parses the default action taken when no params are found, it is either Allow or Deny.
+// An empty value leaves the default unset.
+func ParseParameterNotFoundAction(value string) (admissionregistrationv1alpha1.ParameterNotFoundActionType, error) {
+	switch action := admissionregistrationv1alpha1.ParameterNotFoundActionType(value); action {
+	case "", admissionregistrationv1alpha1.AllowAction, admissionregistrationv1alpha1.DenyAction:
+		return action, nil
+	default:
+		return "", fmt.Errorf("invalid parameter not found action %q, it must be either Allow or Deny", value)
+	}
+}
+
+// denyNotFound tells whether a reference finding no params is denied. The action of the reference takes precedence
+// over the default action, params are allowed to be missing when neither is set.
+func (l paramLimits) denyNotFound(paramRef *admissionregistrationv1alpha1.ParamRef) bool {
+	if paramRef.ParameterNotFoundAction != nil {
+		return *paramRef.ParameterNotFoundAction == admissionregistrationv1alpha1.DenyAction
+	}
+	return l.notFoundAction == admissionregistrationv1alpha1.DenyAction
+}
+
+// tooManyParams returns the error of rules selecting more params than the maximum.
+func (l paramLimits) tooManyParams() error {
+	return fmt.Errorf("more than %d params are selected, the maximum number of params evaluated by a rule is set by the celMaxParams flag", l.max)
+}
+
+type clientParamLoader struct {
+	client engineapi.Client
+}
+
+// NewClientParamLoader returns a ParamLoader that fetches parameter resources using the engine client.
+func NewClientParamLoader(client engineapi.Client) ParamLoader {
+	return clientParamLoader{
+		client: client,
+	}
+}
+
+func (l clientParamLoader) IsNamespaced(group, version, kind string) (bool, error) {
+	return l.client.IsNamespaced(group, version, kind)
+}
+
+func (l clientParamLoader) GetParam(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	return l.client.GetResource(ctx, apiVersion, kind, namespace, name, "")
+}
+
+func (l clientParamLoader) ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	return l.client.ListResourcePage(ctx, apiVersion, kind, namespace, selector, limit, continueToken)
+}
+
+// contextParamLoader resolves the params referenced by name from the resources loaded by the context entries of a rule,
+// e.g. a ConfigMap loaded by a configMap entry, the other params are resolved by the wrapped loader.
+type contextParamLoader struct {
+	ParamLoader
+	resources []unstructured.Unstructured
+}
+
+// newContextParamLoader returns a ParamLoader consulting the resources loaded by the given context entries first,
+// the loader is returned as is when the entries load no resources.
+// The identity of the loaded resources isn't verified, e.g. api calls may return anything, so only the resources of the
+// param kinds declared by the rule, in the namespaces its params are resolved in, are used as params.
+func newContextParamLoader(loader ParamLoader, rule *kyvernov1.CEL, namespace string, entries []kyvernov1.ContextEntry, jsonContext enginecontext.Interface) ParamLoader {
+	var resources []unstructured.Unstructured
+	for _, entry := range entries {
+		if entry.ConfigMap == nil && entry.APICall == nil {
+			continue
+		}
+		value, err := jsonContext.Query(entry.Name)
+		if err != nil {
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resource := unstructured.Unstructured{Object: runtime.DeepCopyJSON(object)}
+		// configMap entries only load the data and metadata of the config map
+		if entry.ConfigMap != nil {
+			resource.SetAPIVersion("v1")
+			resource.SetKind("ConfigMap")
+		}
+		// api calls may load anything, only single resources are params
+		if resource.GetAPIVersion() == "" || resource.GetKind() == "" || resource.GetName() == "" {
+			continue
+		}
+		if !declaresParam(rule, namespace, &resource) {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	if len(resources) == 0 {
+		return loader
+	}
+	return contextParamLoader{
+		ParamLoader: loader,
+		resources:   resources,
+	}
+}
+
+// declaresParam tells whether the resource may be a param of the rule: its kind is the param kind of paramRef or of an
+// additional param, and it lives in the namespace set by the reference. Without one, params are resolved in the given
+// namespace, the namespace of the evaluation, or are cluster-scoped.
+func declaresParam(rule *kyvernov1.CEL, namespace string, resource *unstructured.Unstructured) bool {
+	matches := func(paramKind *admissionregistrationv1alpha1.ParamKind, paramRef *admissionregistrationv1alpha1.ParamRef) bool {
+		if paramKind == nil || paramKind.APIVersion != resource.GetAPIVersion() || paramKind.Kind != resource.GetKind() {
+			return false
+		}
+		if paramRef != nil && paramRef.Namespace != "" {
+			return resource.GetNamespace() == paramRef.Namespace
+		}
+		return resource.GetNamespace() == "" || resource.GetNamespace() == namespace
+	}
+	if matches(rule.ParamKind, rule.ParamRef) {
+		return true
+	}
+	for i := range rule.AdditionalParams {
+		if matches(&rule.AdditionalParams[i].ParamKind, &rule.AdditionalParams[i].ParamRef) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l contextParamLoader) IsNamespaced(group, version, kind string) (bool, error) {
+	apiVersion := schema.GroupVersion{Group: group, Version: version}.String()
+	for i := range l.resources {
+		if resource := &l.resources[i]; resource.GetAPIVersion() == apiVersion && resource.GetKind() == kind {
+			return resource.GetNamespace() != "", nil
+		}
+	}
+	return l.ParamLoader.IsNamespaced(group, version, kind)
+}
+
+func (l contextParamLoader) GetParam(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	for i := range l.resources {
+		resource := &l.resources[i]
+		if resource.GetAPIVersion() == apiVersion && resource.GetKind() == kind && resource.GetNamespace() == namespace && resource.GetName() == name {
+			return resource.DeepCopy(), nil
+		}
+	}
+	return l.ParamLoader.GetParam(ctx, apiVersion, kind, namespace, name)
+}
+
+// collectParams returns the params referenced by paramRef, when a field selector is given only the params matching it are returned.
+// Params are listed by pages, an error is returned as soon as more params than the maximum are selected.
+func collectParams(ctx context.Context, loader ParamLoader, paramKind *admissionregistrationv1alpha1.ParamKind, paramRef *admissionregistrationv1alpha1.ParamRef, fieldSelector fields.Selector, namespace string, limits paramLimits) ([]runtime.Object, error) {
+	var params []runtime.Object
+
+	apiVersion := paramKind.APIVersion
+	kind := paramKind.Kind
+	// the group version is checked when the policy is admitted, it is checked again for the policies admitted before
+	gv, err := celutils.ParseParamKind(paramKind)
+	if err != nil {
+		return nil, err
+	}
+
+	// If `paramKind` is cluster-scoped, then paramRef.namespace MUST be unset.
+	// If `paramKind` is namespace-scoped, the namespace of the object being evaluated for admission will be used
+	// when paramRef.namespace is left unset.
+	var paramsNamespace string
+	isNamespaced, err := loader.IsNamespaced(gv.Group, gv.Version, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if resource is namespaced or not (%w)", err)
+	}
+
+	// check if `paramKind` is namespace-scoped
+	if isNamespaced {
+		// set params namespace to the incoming object's namespace by default.
+		paramsNamespace = namespace
+		if paramRef.Namespace != "" {
+			paramsNamespace = paramRef.Namespace
+		} else if paramsNamespace == "" {
+			return nil, fmt.Errorf("can't use namespaced paramRef to match cluster-scoped resources")
+		}
+	} else {
+		// It isn't allowed to set namespace for cluster-scoped params
+		if paramRef.Namespace != "" {
+			return nil, fmt.Errorf("paramRef.namespace must not be provided for a cluster-scoped `paramKind`")
+		}
+	}
+
+	if paramRef.Name != "" {
+		param, err := loader.GetParam(ctx, apiVersion, kind, paramsNamespace, paramRef.Name)
+		// a missing param is handled as the params missing from a selection, according to paramRef.parameterNotFoundAction
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil && (fieldSelector == nil || matchesFieldSelector(param, fieldSelector)) {
+			params = append(params, param)
+		}
+	} else if paramRef.Selector != nil || fieldSelector != nil {
+		// without a label selector, params are selected by their fields only
+		selector := paramRef.Selector
+		if selector == nil {
+			selector = &metav1.LabelSelector{}
+		}
+		var selected []*unstructured.Unstructured
+		continueToken := ""
+		for {
+			paramList, err := loader.ListParams(ctx, apiVersion, kind, paramsNamespace, selector, limits.pageSize, continueToken)
+			if err != nil {
+				return nil, err
+			}
+			for i := range paramList.Items {
+				if fieldSelector == nil || matchesFieldSelector(&paramList.Items[i], fieldSelector) {
+					selected = append(selected, &paramList.Items[i])
+				}
+			}
+			if limits.max > 0 && len(selected) > limits.max {
+				return nil, limits.tooManyParams()
+			}
+			continueToken = paramList.GetContinue()
+			if continueToken == "" {
+				break
+			}
+		}
+		// sort params so that results are returned in a stable order
+		sort.SliceStable(selected, func(i, j int) bool {
+			if selected[i].GetNamespace() != selected[j].GetNamespace() {
+				return selected[i].GetNamespace() < selected[j].GetNamespace()
+			}
+			return selected[i].GetName() < selected[j].GetName()
+		})
+		for _, param := range selected {
+			params = append(params, param)
+		}
+	}
+
+	if len(params) == 0 && limits.denyNotFound(paramRef) {
+		return nil, fmt.Errorf("no params found")
+	}
+
+	return params, nil
+}
+
+// collectNamespacesParams returns the union of the params referenced by paramRef in the namespaces selected by the
+// namespace selector. The params of each namespace are collected separately, with the permissions of the loader in
+// that namespace, and are returned in a stable order.
+func collectNamespacesParams(ctx context.Context, loader ParamLoader, paramKind *admissionregistrationv1alpha1.ParamKind, paramRef *admissionregistrationv1alpha1.ParamRef, namespaceSelector *metav1.LabelSelector, fieldSelector fields.Selector, limits paramLimits) ([]runtime.Object, error) {
+	gv, err := celutils.ParseParamKind(paramKind)
+	if err != nil {
+		return nil, err
+	}
+	isNamespaced, err := loader.IsNamespaced(gv.Group, gv.Version, paramKind.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if resource is namespaced or not (%w)", err)
+	}
+	// cluster-scoped params have no namespace to select
+	if !isNamespaced {
+		return nil, fmt.Errorf("paramNamespaceSelector must not be provided for a cluster-scoped `paramKind`")
+	}
+	if paramRef.Namespace != "" {
+		return nil, fmt.Errorf("paramRef.namespace must not be provided along with paramNamespaceSelector")
+	}
+	namespaces, err := selectParamNamespaces(ctx, loader, namespaceSelector, limits)
+	if err != nil {
+		return nil, err
+	}
+	// namespaces lacking params are fine, paramRef.parameterNotFoundAction applies to the union of the params
+	ref := *paramRef
+	allow := admissionregistrationv1alpha1.AllowAction
+	ref.ParameterNotFoundAction = &allow
+	var params []runtime.Object
+	for _, namespace := range namespaces {
+		ref.Namespace = namespace
+		selected, err := collectParams(ctx, loader, paramKind, &ref, fieldSelector, namespace, limits)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		params = append(params, selected...)
+		if limits.max > 0 && len(params) > limits.max {
+			return nil, limits.tooManyParams()
+		}
+	}
+	if len(params) == 0 && limits.denyNotFound(paramRef) {
+		return nil, fmt.Errorf("no params found")
+	}
+	return params, nil
+}
+
+// selectParamNamespaces returns the sorted names of the namespaces selected by the selector. An error is returned as
+// soon as more namespaces than the maximum are listed, the first page is limited accordingly so that the namespaces
+// of the whole cluster aren't listed.
+func selectParamNamespaces(ctx context.Context, loader ParamLoader, selector *metav1.LabelSelector, limits paramLimits) ([]string, error) {
+	pageSize := limits.pageSize
+	if limits.namespaces > 0 && (pageSize <= 0 || pageSize > int64(limits.namespaces)) {
+		pageSize = int64(limits.namespaces) + 1
+	}
+	var namespaces []string
+	continueToken := ""
+	for {
+		namespaceList, err := loader.ListParams(ctx, "v1", "Namespace", "", selector, pageSize, continueToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the namespaces selected by paramNamespaceSelector (%w)", err)
+		}
+		for i := range namespaceList.Items {
+			namespaces = append(namespaces, namespaceList.Items[i].GetName())
+		}
+		if limits.namespaces > 0 && len(namespaces) > limits.namespaces {
+			return nil, fmt.Errorf("more than %d namespaces are selected by paramNamespaceSelector, the maximum number of namespaces is set by the celMaxParamNamespaces flag", limits.namespaces)
+		}
+		continueToken = namespaceList.GetContinue()
+		if continueToken == "" {
+			break
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// collectAllParams returns the union of the params referenced by paramRef and by the additional params of the rule,
+// all of them must match the field selector when not nil. The scope of each reference is resolved independently,
+// params referenced more than once are returned once.
+func collectAllParams(ctx context.Context, loader ParamLoader, rule *kyvernov1.CEL, fieldSelector fields.Selector, namespace string, limits paramLimits) ([]runtime.Object, error) {
+	var params []runtime.Object
+	var err error
+	if rule.ParamNamespaceSelector != nil {
+		params, err = collectNamespacesParams(ctx, loader, rule.ParamKind, rule.ParamRef, rule.ParamNamespaceSelector, fieldSelector, limits)
+	} else {
+		params, err = collectParams(ctx, loader, rule.ParamKind, rule.ParamRef, fieldSelector, namespace, limits)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rule.AdditionalParams) == 0 {
+		return params, nil
+	}
+	seen := map[engineapi.ParamReference]bool{}
+	var result []runtime.Object
+	add := func(params []runtime.Object) {
+		for _, param := range params {
+			if ref := paramReference(param); ref != nil {
+				if seen[*ref] {
+					continue
+				}
+				seen[*ref] = true
+			}
+			result = append(result, param)
+		}
+	}
+	add(params)
+	for i := range rule.AdditionalParams {
+		additional := &rule.AdditionalParams[i]
+		params, err := collectParams(ctx, loader, &additional.ParamKind, &additional.ParamRef, fieldSelector, namespace, limits)
+		if err != nil {
+			return nil, fmt.Errorf("additionalParams[%d]: %w", i, err)
+		}
+		add(params)
+		if limits.max > 0 && len(result) > limits.max {
+			return nil, limits.tooManyParams()
+		}
+	}
+	return result, nil
+}
+
+// matchesFieldSelector tells whether the param matches the field selector, fields are dot separated paths of the param.
+// Missing fields are matched as absent, so that `!=` requirements hold for them.
+func matchesFieldSelector(param *unstructured.Unstructured, selector fields.Selector) bool {
+	set := fields.Set{}
+	for _, requirement := range selector.Requirements() {
+		value, found, err := unstructured.NestedFieldNoCopy(param.Object, strings.Split(requirement.Field, ".")...)
+		if err == nil && found && value != nil {
+			set[requirement.Field] = fmt.Sprint(value)
+		}
+	}
+	return selector.Matches(set)
+}
+
+// paramResourceVersions returns the resource versions of the params indexed by their namespace/name keys.
+func paramResourceVersions(params []runtime.Object) map[string]string {
+	versions := make(map[string]string, len(params))
+	for _, param := range params {
+		accessor, err := meta.Accessor(param)
+		if err != nil {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(param)
+		if err != nil {
+			continue
+		}
+		versions[key] = accessor.GetResourceVersion()
+	}
+	return versions
+}
+
+// paramReference returns the reference of the given param, it is nil when evaluating without params.
+func paramReference(param runtime.Object) *engineapi.ParamReference {
+	if param == nil {
+		return nil
+	}
+	accessor, err := meta.Accessor(param)
+	if err != nil {
+		return nil
+	}
+	apiVersion, kind := param.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	return &engineapi.ParamReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  accessor.GetNamespace(),
+		Name:       accessor.GetName(),
+	}
+}
+
+// paramReferences returns the given reference as a list, it is empty for nil references.
+func paramReferences(param *engineapi.ParamReference) []engineapi.ParamReference {
+	if param == nil {
+		return nil
+	}
+	return []engineapi.ParamReference{*param}
+}
+
+// excludeResource returns the params other than the given resource. Params are compared by UID when both have one,
+// by api version, kind, namespace and name otherwise.
+func excludeResource(params []runtime.Object, resource unstructured.Unstructured) []runtime.Object {
+	var result []runtime.Object
+	for _, param := range params {
+		u, ok := param.(*unstructured.Unstructured)
+		if ok && isSelf(u, &resource) {
+			continue
+		}
+		result = append(result, param)
+	}
+	return result
+}
+
+func isSelf(param, resource *unstructured.Unstructured) bool {
+	if param.GetUID() != "" && resource.GetUID() != "" {
+		return param.GetUID() == resource.GetUID()
+	}
+	return isSameResource(param, resource)
+}
+
+// ownedParamLoader only returns the params owned by one of the owners of a resource.
+type ownedParamLoader struct {
+	ParamLoader
+	resource unstructured.Unstructured
+}
+
+// newOwnedParamLoader returns a ParamLoader only returning the params owned by one of the owners of the resource, the
+// params of other owners are filtered out while listing so that they don't count toward the maximum number of params.
+// Nothing is loaded for resources without owners.
+func newOwnedParamLoader(loader ParamLoader, resource unstructured.Unstructured) ParamLoader {
+	return ownedParamLoader{
+		ParamLoader: loader,
+		resource:    resource,
+	}
+}
+
+func (l ownedParamLoader) GetParam(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: kind}, name)
+	if len(l.resource.GetOwnerReferences()) == 0 {
+		return nil, notFound
+	}
+	param, err := l.ParamLoader.GetParam(ctx, apiVersion, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if !isOwnedBy(param, &l.resource) {
+		return nil, notFound
+	}
+	return param, nil
+}
+
+func (l ownedParamLoader) ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	if len(l.resource.GetOwnerReferences()) == 0 {
+		return &unstructured.UnstructuredList{}, nil
+	}
+	paramList, err := l.ParamLoader.ListParams(ctx, apiVersion, kind, namespace, selector, limit, continueToken)
+	if err != nil {
+		return nil, err
+	}
+	// the list may be shared, e.g. by the param cache, it is copied rather than filtered in place
+	owned := &unstructured.UnstructuredList{Object: paramList.Object}
+	for i := range paramList.Items {
+		if isOwnedBy(&paramList.Items[i], &l.resource) {
+			owned.Items = append(owned.Items, paramList.Items[i])
+		}
+	}
+	return owned, nil
+}
+
+// isOwnedBy returns true when the param is owned by one of the owners of the resource. Owners and their dependents
+// share their namespace, the params of other namespaces are never owned.
+func isOwnedBy(param, resource *unstructured.Unstructured) bool {
+	if param.GetNamespace() != resource.GetNamespace() {
+		return false
+	}
+	owners := resource.GetOwnerReferences()
+	return slices.ContainsFunc(param.GetOwnerReferences(), func(ref metav1.OwnerReference) bool {
+		return slices.ContainsFunc(owners, func(owner metav1.OwnerReference) bool {
+			return isSameOwner(ref, owner)
+		})
+	})
+}
+
+// isSameOwner compares owner references by UID when both have one, by api version, kind and name otherwise.
+func isSameOwner(ref, owner metav1.OwnerReference) bool {
+	if ref.UID != "" && owner.UID != "" {
+		return ref.UID == owner.UID
+	}
+	return ref.APIVersion == owner.APIVersion && ref.Kind == owner.Kind && ref.Name == owner.Name
+}
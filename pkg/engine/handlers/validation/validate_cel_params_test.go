@@ -0,0 +1,1069 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+)
+
+func Test_collectParams(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	deny := admissionregistrationv1alpha1.DenyAction
+	loader := &fakeParamLoader{
+		namespaced: true,
+		params: []unstructured.Unstructured{
+			newConfigMapParam("default", "first", map[string]string{"app": "params"}, nil),
+			newConfigMapParam("default", "second", map[string]string{"app": "params"}, nil),
+			newConfigMapParam("other", "third", map[string]string{"app": "params"}, nil),
+		},
+	}
+	tests := []struct {
+		name      string
+		paramRef  *admissionregistrationv1alpha1.ParamRef
+		namespace string
+		want      []string
+		wantErr   bool
+	}{{
+		name:      "by name",
+		paramRef:  &admissionregistrationv1alpha1.ParamRef{Name: "second"},
+		namespace: "default",
+		want:      []string{"second"},
+	}, {
+		name:      "by selector",
+		paramRef:  &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+		namespace: "default",
+		want:      []string{"first", "second"},
+	}, {
+		name:      "by selector with namespace",
+		paramRef:  &admissionregistrationv1alpha1.ParamRef{Namespace: "other", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+		namespace: "default",
+		want:      []string{"third"},
+	}, {
+		name:      "cluster-scoped resource",
+		paramRef:  &admissionregistrationv1alpha1.ParamRef{Name: "first"},
+		namespace: "",
+		wantErr:   true,
+	}, {
+		name:      "no params found with deny action",
+		paramRef:  &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}}, ParameterNotFoundAction: &deny},
+		namespace: "default",
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := collectParams(context.TODO(), loader, paramKind, tt.paramRef, nil, tt.namespace, defaultParamLimits)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			var names []string
+			for _, param := range params {
+				names = append(names, param.(*unstructured.Unstructured).GetName())
+			}
+			assert.DeepEqual(t, tt.want, names)
+		})
+	}
+}
+
+func Test_collectParams_DefaultNotFoundAction(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	loader := &fakeParamLoader{namespaced: true}
+	allow := admissionregistrationv1alpha1.AllowAction
+	deny := admissionregistrationv1alpha1.DenyAction
+	tests := []struct {
+		name          string
+		defaultAction admissionregistrationv1alpha1.ParameterNotFoundActionType
+		action        *admissionregistrationv1alpha1.ParameterNotFoundActionType
+		wantErr       bool
+	}{{
+		name: "no action",
+	}, {
+		name:          "default allow action",
+		defaultAction: allow,
+	}, {
+		name:          "default deny action",
+		defaultAction: deny,
+		wantErr:       true,
+	}, {
+		name:          "allow action over the default deny action",
+		defaultAction: deny,
+		action:        &allow,
+	}, {
+		name:          "deny action over the default allow action",
+		defaultAction: allow,
+		action:        &deny,
+		wantErr:       true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := defaultParamLimits
+			limits.notFoundAction = tt.defaultAction
+			for _, paramRef := range []*admissionregistrationv1alpha1.ParamRef{
+				{Name: "missing", ParameterNotFoundAction: tt.action},
+				{Selector: &metav1.LabelSelector{}, ParameterNotFoundAction: tt.action},
+			} {
+				params, err := collectParams(context.TODO(), loader, paramKind, paramRef, nil, "default", limits)
+				if tt.wantErr {
+					assert.Error(t, err, "no params found")
+					continue
+				}
+				assert.NilError(t, err)
+				assert.Equal(t, len(params), 0)
+			}
+		})
+	}
+}
+
+func Test_ParseParameterNotFoundAction(t *testing.T) {
+	for _, value := range []string{"", "Allow", "Deny"} {
+		action, err := ParseParameterNotFoundAction(value)
+		assert.NilError(t, err)
+		assert.Equal(t, string(action), value)
+	}
+	_, err := ParseParameterNotFoundAction("deny")
+	assert.Error(t, err, `invalid parameter not found action "deny", it must be either Allow or Deny`)
+	_, err = NewValidateCELHandler(nil, WithDefaultParameterNotFoundAction("Fail"))
+	assert.Error(t, err, `invalid parameter not found action "Fail", it must be either Allow or Deny`)
+}
+
+func Test_collectParams_FieldSelector(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	deny := admissionregistrationv1alpha1.DenyAction
+	loader := &fakeParamLoader{
+		namespaced: true,
+		params: []unstructured.Unstructured{
+			newConfigMapParam("default", "first", map[string]string{"app": "params"}, map[string]interface{}{"tier": "gold"}),
+			newConfigMapParam("default", "second", map[string]string{"app": "params"}, map[string]interface{}{"tier": "silver"}),
+			newConfigMapParam("default", "third", map[string]string{"app": "other"}, map[string]interface{}{"tier": "gold"}),
+			newConfigMapParam("default", "fourth", map[string]string{"app": "params"}, nil),
+		},
+	}
+	tests := []struct {
+		name          string
+		paramRef      *admissionregistrationv1alpha1.ParamRef
+		fieldSelector string
+		want          []string
+		wantErr       bool
+	}{{
+		name:          "label and field selectors are combined",
+		paramRef:      &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+		fieldSelector: "data.tier=gold",
+		want:          []string{"first"},
+	}, {
+		name:          "missing fields are absent",
+		paramRef:      &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+		fieldSelector: "data.tier!=silver",
+		want:          []string{"first", "fourth"},
+	}, {
+		name:          "field selector only",
+		paramRef:      &admissionregistrationv1alpha1.ParamRef{},
+		fieldSelector: "data.tier=gold,metadata.name!=first",
+		want:          []string{"third"},
+	}, {
+		name:          "by name",
+		paramRef:      &admissionregistrationv1alpha1.ParamRef{Name: "second"},
+		fieldSelector: "data.tier=gold",
+	}, {
+		name:          "no params found with deny action",
+		paramRef:      &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}, ParameterNotFoundAction: &deny},
+		fieldSelector: "data.tier=bronze",
+		wantErr:       true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldSelector, err := fields.ParseSelector(tt.fieldSelector)
+			assert.NilError(t, err)
+			params, err := collectParams(context.TODO(), loader, paramKind, tt.paramRef, fieldSelector, "default", defaultParamLimits)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			var names []string
+			for _, param := range params {
+				names = append(names, param.(*unstructured.Unstructured).GetName())
+			}
+			assert.DeepEqual(t, tt.want, names)
+		})
+	}
+}
+
+func Test_collectAllParams(t *testing.T) {
+	deny := admissionregistrationv1alpha1.DenyAction
+	defaults := newConfigMapParam("", "defaults", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"})
+	defaults.SetKind("ClusterConfig")
+	loader := &fakeParamLoader{
+		namespaced:         true,
+		clusterScopedKinds: []string{"ClusterConfig"},
+		params: []unstructured.Unstructured{
+			defaults,
+			newConfigMapParam("default", "override", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+			newConfigMapParam("other", "override", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+		},
+	}
+	rule := &kyvernov1.CEL{
+		ParamKind: &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ClusterConfig"},
+		ParamRef:  &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{}},
+	}
+	overrides := kyvernov1.CELParams{
+		ParamKind: admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+		ParamRef:  admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+	}
+	names := func(params []runtime.Object) []string {
+		var names []string
+		for _, param := range params {
+			u := param.(*unstructured.Unstructured)
+			names = append(names, fmt.Sprintf("%s:%s/%s", u.GetKind(), u.GetNamespace(), u.GetName()))
+		}
+		return names
+	}
+
+	t.Run("the params of all references are collected", func(t *testing.T) {
+		rule := rule.DeepCopy()
+		rule.AdditionalParams = []kyvernov1.CELParams{overrides}
+		params, err := collectAllParams(context.TODO(), loader, rule, nil, "default", defaultParamLimits)
+		assert.NilError(t, err)
+		// the cluster scoped defaults are collected along with the overrides of the namespace
+		assert.DeepEqual(t, names(params), []string{"ClusterConfig:/defaults", "ConfigMap:default/override"})
+	})
+
+	t.Run("params referenced more than once are collected once", func(t *testing.T) {
+		byName := kyvernov1.CELParams{
+			ParamKind: admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:  admissionregistrationv1alpha1.ParamRef{Name: "override"},
+		}
+		rule := rule.DeepCopy()
+		rule.AdditionalParams = []kyvernov1.CELParams{overrides, byName}
+		params, err := collectAllParams(context.TODO(), loader, rule, nil, "default", defaultParamLimits)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(params), []string{"ClusterConfig:/defaults", "ConfigMap:default/override"})
+	})
+
+	t.Run("the field selector applies to the params of all references", func(t *testing.T) {
+		rule := rule.DeepCopy()
+		rule.AdditionalParams = []kyvernov1.CELParams{overrides}
+		fieldSelector, err := fields.ParseSelector("metadata.name!=override")
+		assert.NilError(t, err)
+		params, err := collectAllParams(context.TODO(), loader, rule, fieldSelector, "default", defaultParamLimits)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(params), []string{"ClusterConfig:/defaults"})
+	})
+
+	t.Run("missing additional params are denied per reference", func(t *testing.T) {
+		missing := kyvernov1.CELParams{
+			ParamKind: admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef: admissionregistrationv1alpha1.ParamRef{
+				Selector:                &metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}},
+				ParameterNotFoundAction: &deny,
+			},
+		}
+		rule := rule.DeepCopy()
+		rule.AdditionalParams = []kyvernov1.CELParams{overrides, missing}
+		_, err := collectAllParams(context.TODO(), loader, rule, nil, "default", defaultParamLimits)
+		assert.Error(t, err, "additionalParams[1]: no params found")
+	})
+}
+
+func Test_collectNamespacesParams(t *testing.T) {
+	deny := admissionregistrationv1alpha1.DenyAction
+	newNamespace := func(name string, labels map[string]string) unstructured.Unstructured {
+		namespace := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Namespace"}}
+		namespace.SetName(name)
+		namespace.SetLabels(labels)
+		return namespace
+	}
+	org := map[string]string{"params": "org"}
+	loader := &fakeParamLoader{
+		namespaced:         true,
+		clusterScopedKinds: []string{"ClusterConfig"},
+		params: []unstructured.Unstructured{
+			newNamespace("team-b", org),
+			newNamespace("team-a", org),
+			newNamespace("team-c", org),
+			newNamespace("other", nil),
+			newConfigMapParam("team-b", "limits", map[string]string{"app": "params"}, nil),
+			newConfigMapParam("team-a", "limits", map[string]string{"app": "params"}, nil),
+			newConfigMapParam("other", "limits", map[string]string{"app": "params"}, nil),
+		},
+	}
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	selector := &metav1.LabelSelector{MatchLabels: org}
+	names := func(params []runtime.Object) []string {
+		var names []string
+		for _, param := range params {
+			u := param.(*unstructured.Unstructured)
+			names = append(names, u.GetNamespace()+"/"+u.GetName())
+		}
+		return names
+	}
+
+	t.Run("the params of the selected namespaces are collected", func(t *testing.T) {
+		paramRef := &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}, ParameterNotFoundAction: &deny}
+		params, err := collectNamespacesParams(context.TODO(), loader, paramKind, paramRef, selector, nil, defaultParamLimits)
+		assert.NilError(t, err)
+		// team-c lacks params, it doesn't deny the request
+		assert.DeepEqual(t, names(params), []string{"team-a/limits", "team-b/limits"})
+	})
+
+	t.Run("params referenced by name", func(t *testing.T) {
+		paramRef := &admissionregistrationv1alpha1.ParamRef{Name: "limits"}
+		params, err := collectNamespacesParams(context.TODO(), loader, paramKind, paramRef, selector, nil, defaultParamLimits)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(params), []string{"team-a/limits", "team-b/limits"})
+	})
+
+	t.Run("missing params are denied for the union", func(t *testing.T) {
+		paramRef := &admissionregistrationv1alpha1.ParamRef{Name: "missing", ParameterNotFoundAction: &deny}
+		_, err := collectNamespacesParams(context.TODO(), loader, paramKind, paramRef, selector, nil, defaultParamLimits)
+		assert.Error(t, err, "no params found")
+	})
+
+	t.Run("cluster-scoped param kinds", func(t *testing.T) {
+		paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ClusterConfig"}
+		paramRef := &admissionregistrationv1alpha1.ParamRef{Name: "limits"}
+		_, err := collectNamespacesParams(context.TODO(), loader, paramKind, paramRef, selector, nil, defaultParamLimits)
+		assert.Error(t, err, "paramNamespaceSelector must not be provided for a cluster-scoped `paramKind`")
+	})
+
+	t.Run("the number of namespaces is bounded", func(t *testing.T) {
+		loader.pages = 0
+		paramRef := &admissionregistrationv1alpha1.ParamRef{Name: "limits"}
+		limits := defaultParamLimits
+		limits.namespaces = 2
+		_, err := collectNamespacesParams(context.TODO(), loader, paramKind, paramRef, selector, nil, limits)
+		assert.ErrorContains(t, err, "more than 2 namespaces are selected by paramNamespaceSelector")
+		// a single page of namespaces is listed
+		assert.Equal(t, loader.pages, 1)
+	})
+}
+
+func Test_collectParams_Pages(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	paramRef := &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}}
+	var params []unstructured.Unstructured
+	for i := 0; i < 5; i++ {
+		tier := "gold"
+		if i%2 == 1 {
+			tier = "silver"
+		}
+		params = append(params, newConfigMapParam("default", fmt.Sprintf("param-%d", 4-i), map[string]string{"app": "params"}, map[string]interface{}{"tier": tier}))
+	}
+
+	t.Run("params are listed by pages", func(t *testing.T) {
+		loader := &fakeParamLoader{namespaced: true, params: params}
+		collected, err := collectParams(context.TODO(), loader, paramKind, paramRef, nil, "default", paramLimits{pageSize: 2, max: 10})
+		assert.NilError(t, err)
+		assert.Equal(t, len(collected), 5)
+		assert.Equal(t, loader.pages, 3)
+		// params are sorted across pages
+		assert.Equal(t, collected[0].(*unstructured.Unstructured).GetName(), "param-0")
+		assert.Equal(t, collected[4].(*unstructured.Unstructured).GetName(), "param-4")
+	})
+
+	t.Run("listing stops once more params than the maximum are selected", func(t *testing.T) {
+		loader := &fakeParamLoader{namespaced: true, params: params}
+		_, err := collectParams(context.TODO(), loader, paramKind, paramRef, nil, "default", paramLimits{pageSize: 2, max: 3})
+		assert.Error(t, err, "more than 3 params are selected, the maximum number of params evaluated by a rule is set by the celMaxParams flag")
+		assert.Equal(t, loader.pages, 2)
+	})
+
+	t.Run("only the params matching the field selector count", func(t *testing.T) {
+		loader := &fakeParamLoader{namespaced: true, params: params}
+		fieldSelector, err := fields.ParseSelector("data.tier=gold")
+		assert.NilError(t, err)
+		collected, err := collectParams(context.TODO(), loader, paramKind, paramRef, fieldSelector, "default", paramLimits{pageSize: 2, max: 3})
+		assert.NilError(t, err)
+		assert.Equal(t, len(collected), 3)
+	})
+
+	t.Run("the maximum bounds the union of all references", func(t *testing.T) {
+		defaults := newConfigMapParam("default", "defaults", nil, nil)
+		defaults.SetKind("Defaults")
+		loader := &fakeParamLoader{namespaced: true, params: append([]unstructured.Unstructured{defaults}, params...)}
+		rule := &kyvernov1.CEL{
+			ParamKind: &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "Defaults"},
+			ParamRef:  &admissionregistrationv1alpha1.ParamRef{Name: "defaults"},
+			AdditionalParams: []kyvernov1.CELParams{
+				{ParamKind: *paramKind, ParamRef: *paramRef},
+			},
+		}
+		// each reference selects at most 5 params, their union selects 6
+		_, err := collectAllParams(context.TODO(), loader, rule, nil, "default", paramLimits{pageSize: 10, max: 5})
+		assert.Error(t, err, "more than 5 params are selected, the maximum number of params evaluated by a rule is set by the celMaxParams flag")
+	})
+
+	t.Run("zero disables the maximum", func(t *testing.T) {
+		loader := &fakeParamLoader{namespaced: true, params: params}
+		collected, err := collectParams(context.TODO(), loader, paramKind, paramRef, nil, "default", paramLimits{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(collected), 5)
+		assert.Equal(t, loader.pages, 1)
+	})
+}
+
+func Test_clientParamLoader(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}
+	tests := []struct {
+		name     string
+		paramRef *admissionregistrationv1alpha1.ParamRef
+		want     []unstructured.Unstructured
+		wantCall fakeClientCall
+	}{{
+		name:     "by name",
+		paramRef: &admissionregistrationv1alpha1.ParamRef{Name: "second"},
+		want: []unstructured.Unstructured{
+			newConfigMapParam("default", "second", map[string]string{"app": "other"}, nil),
+		},
+		wantCall: fakeClientCall{Method: "GetResource", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "second", Subresources: []string{""}},
+	}, {
+		name:     "by selector",
+		paramRef: &admissionregistrationv1alpha1.ParamRef{Selector: selector},
+		want: []unstructured.Unstructured{
+			newConfigMapParam("default", "first", map[string]string{"app": "params"}, nil),
+			newConfigMapParam("default", "third", map[string]string{"app": "params"}, nil),
+		},
+		wantCall: fakeClientCall{Method: "ListResourcePage", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Selector: selector, Limit: DefaultParamsPageSize},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{
+				namespaced: true,
+				resources: []unstructured.Unstructured{
+					newConfigMapParam("default", "third", map[string]string{"app": "params"}, nil),
+					newConfigMapParam("default", "second", map[string]string{"app": "other"}, nil),
+					newConfigMapParam("default", "first", map[string]string{"app": "params"}, nil),
+					newConfigMapParam("other", "fourth", map[string]string{"app": "params"}, nil),
+				},
+			}
+			params, err := collectParams(context.TODO(), NewClientParamLoader(client), paramKind, tt.paramRef, nil, "default", defaultParamLimits)
+			assert.NilError(t, err)
+			var got []unstructured.Unstructured
+			for _, param := range params {
+				got = append(got, *param.(*unstructured.Unstructured))
+			}
+			assert.DeepEqual(t, got, tt.want)
+			assert.DeepEqual(t, client.calls, []fakeClientCall{tt.wantCall})
+		})
+	}
+}
+
+func Test_clientParamLoader_Pages(t *testing.T) {
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}
+	paramRef := &admissionregistrationv1alpha1.ParamRef{Selector: selector}
+	var resources []unstructured.Unstructured
+	for i := 0; i < 5; i++ {
+		resources = append(resources, newConfigMapParam("default", fmt.Sprintf("param-%d", i), map[string]string{"app": "params"}, nil))
+	}
+	listCall := func(continueToken string) fakeClientCall {
+		return fakeClientCall{Method: "ListResourcePage", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Selector: selector, Limit: 2, Continue: continueToken}
+	}
+
+	t.Run("the pages are fetched with the continue token of the previous one", func(t *testing.T) {
+		client := &fakeClient{namespaced: true, resources: resources}
+		params, err := collectParams(context.TODO(), NewClientParamLoader(client), paramKind, paramRef, nil, "default", paramLimits{pageSize: 2, max: 10})
+		assert.NilError(t, err)
+		assert.Equal(t, len(params), 5)
+		assert.DeepEqual(t, client.calls, []fakeClientCall{listCall(""), listCall("2"), listCall("4")})
+	})
+
+	t.Run("the pages are not fetched beyond the maximum", func(t *testing.T) {
+		client := &fakeClient{namespaced: true, resources: resources}
+		_, err := collectParams(context.TODO(), NewClientParamLoader(client), paramKind, paramRef, nil, "default", paramLimits{pageSize: 2, max: 3})
+		assert.Error(t, err, paramLimits{max: 3}.tooManyParams().Error())
+		assert.DeepEqual(t, client.calls, []fakeClientCall{listCall(""), listCall("2")})
+	})
+}
+
+func Test_ValidateCEL_ParamLoader(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxReplica string
+		want       engineapi.RuleStatus
+	}{{
+		name:       "pass",
+		maxReplica: "5",
+		want:       engineapi.RuleStatusPass,
+	}, {
+		name:       "fail",
+		maxReplica: "2",
+		want:       engineapi.RuleStatusFail,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newConfigMapParam("default", "params", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": tt.maxReplica}),
+				},
+			}
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tt.want)
+		})
+	}
+}
+
+func Test_ValidateCEL_ParamsNotFound(t *testing.T) {
+	allow := admissionregistrationv1alpha1.AllowAction
+	deny := admissionregistrationv1alpha1.DenyAction
+	tests := []struct {
+		name        string
+		action      *admissionregistrationv1alpha1.ParameterNotFoundActionType
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{{
+		name:        "allow",
+		action:      &allow,
+		wantStatus:  engineapi.RuleStatusSkip,
+		wantMessage: "no parameter resources matched; skipping",
+	}, {
+		name:        "no action",
+		wantStatus:  engineapi.RuleStatusSkip,
+		wantMessage: "no parameter resources matched; skipping",
+	}, {
+		name:        "deny",
+		action:      &deny,
+		wantStatus:  engineapi.RuleStatusError,
+		wantMessage: "error in parameterized resource: no params found",
+	}}
+	paramRefs := map[string]admissionregistrationv1alpha1.ParamRef{
+		"by selector": {Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "params"}}},
+		"by name":     {Name: "missing"},
+	}
+	for _, tt := range tests {
+		for by, paramRef := range paramRefs {
+			t.Run(tt.name+" "+by, func(t *testing.T) {
+				policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+				rule := policyContext.Policy().GetSpec().Rules[0]
+				rule.Validation.CEL.ParamRef = &paramRef
+				rule.Validation.CEL.ParamRef.ParameterNotFoundAction = tt.action
+				handler, err := NewValidateCELHandler(nil, WithParamLoader(&fakeParamLoader{namespaced: true}))
+				assert.NilError(t, err)
+				_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+				assert.Equal(t, len(responses), 1)
+				assert.Equal(t, responses[0].Status(), tt.wantStatus)
+				assert.Equal(t, responses[0].Message(), tt.wantMessage)
+			})
+		}
+	}
+}
+
+func Test_ValidateCEL_ParamsOrder(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions[0].MessageExpression = "'too many replicas for ' + params.metadata.name"
+	loader := &fakeParamLoader{
+		namespaced: true,
+		params: []unstructured.Unstructured{
+			newConfigMapParam("default", "zeta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+			newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+			newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+		},
+	}
+	params, err := collectParams(context.TODO(), loader, rule.Validation.CEL.ParamKind, rule.Validation.CEL.ParamRef, nil, "default", defaultParamLimits)
+	assert.NilError(t, err)
+	var names []string
+	for _, param := range params {
+		names = append(names, param.(*unstructured.Unstructured).GetName())
+	}
+	assert.DeepEqual(t, names, []string{"alpha", "beta", "zeta"})
+
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.Equal(t, responses[0].Message(), "too many replicas for alpha")
+}
+
+// slowParamLoader waits for the given delay or for the context to be done before listing the params.
+type slowParamLoader struct {
+	fakeParamLoader
+	delay time.Duration
+}
+
+func (l *slowParamLoader) ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	select {
+	case <-time.After(l.delay):
+		return l.fakeParamLoader.ListParams(ctx, apiVersion, kind, namespace, selector, limit, continueToken)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_ValidateCEL_ParamFetchTimeout(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	loader := &slowParamLoader{
+		fakeParamLoader: fakeParamLoader{
+			namespaced: true,
+			params: []unstructured.Unstructured{
+				newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+			},
+		},
+		delay: time.Minute,
+	}
+
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader), WithParamFetchTimeout(10*time.Millisecond))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	assert.Equal(t, responses[0].Message(), "param fetch timed out after 10ms: "+context.DeadlineExceeded.Error())
+
+	// params fetched within the timeout are evaluated
+	loader.delay = time.Millisecond
+	handler, err = NewValidateCELHandler(nil, WithParamLoader(loader), WithParamFetchTimeout(time.Minute))
+	assert.NilError(t, err)
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+}
+
+func Test_ValidateCEL_ParamResourceVersions(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	alpha := newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"})
+	alpha.SetResourceVersion("1234")
+	beta := newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"})
+	beta.SetResourceVersion("5678")
+
+	loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{alpha}}
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+	assert.DeepEqual(t, responses[0].ParamResourceVersions(), map[string]string{"default/alpha": "1234"})
+
+	// the versions of all params are attached to failures
+	loader.params = append(loader.params, beta)
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.DeepEqual(t, responses[0].ParamResourceVersions(), map[string]string{"default/alpha": "1234", "default/beta": "5678"})
+
+	// rules without params have no versions
+	policyContext = buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule = policyContext.Policy().GetSpec().Rules[0]
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Assert(t, responses[0].ParamResourceVersions() == nil)
+}
+
+func Test_ValidateCEL_ContextParams(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Context = []kyvernov1.ContextEntry{
+		{Name: "limits", ConfigMap: &kyvernov1.ConfigMapReference{Name: "limits", Namespace: "default"}},
+	}
+	rule.Validation.CEL.ParamKind = &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	rule.Validation.CEL.ParamRef = &admissionregistrationv1alpha1.ParamRef{Name: "limits"}
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= int(params.data.maxReplicas)", Message: "too many replicas"},
+	}
+	// the config map loaded by the context entry overrides the one of the cluster
+	err := policyContext.JSONContext().AddContextEntry("limits", []byte(`{"data": {"maxReplicas": "2"}, "metadata": {"name": "limits", "namespace": "default"}}`))
+	assert.NilError(t, err)
+	loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+		newConfigMapParam("default", "limits", nil, map[string]interface{}{"maxReplicas": "5"}),
+	}}
+
+	handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+	assert.Equal(t, responses[0].Message(), "too many replicas")
+
+	// the other params are fetched
+	rule.Validation.CEL.ParamRef = &admissionregistrationv1alpha1.ParamRef{Name: "other"}
+	loader.params = append(loader.params, newConfigMapParam("default", "other", nil, map[string]interface{}{"maxReplicas": "5"}))
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+}
+
+func Test_declaresParam(t *testing.T) {
+	configMaps := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	testCases := []struct {
+		name     string
+		rule     kyvernov1.CEL
+		resource unstructured.Unstructured
+		want     bool
+	}{
+		{
+			name:     "param of the evaluation namespace",
+			rule:     kyvernov1.CEL{ParamKind: configMaps, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits"}},
+			resource: newConfigMapParam("default", "limits", nil, nil),
+			want:     true,
+		},
+		{
+			name:     "param of another namespace",
+			rule:     kyvernov1.CEL{ParamKind: configMaps, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits"}},
+			resource: newConfigMapParam("kube-system", "limits", nil, nil),
+		},
+		{
+			name:     "param of the namespace of the reference",
+			rule:     kyvernov1.CEL{ParamKind: configMaps, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits", Namespace: "kube-system"}},
+			resource: newConfigMapParam("kube-system", "limits", nil, nil),
+			want:     true,
+		},
+		{
+			name:     "param outside of the namespace of the reference",
+			rule:     kyvernov1.CEL{ParamKind: configMaps, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits", Namespace: "kube-system"}},
+			resource: newConfigMapParam("default", "limits", nil, nil),
+		},
+		{
+			name:     "cluster-scoped param",
+			rule:     kyvernov1.CEL{ParamKind: configMaps, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits"}},
+			resource: newConfigMapParam("", "limits", nil, nil),
+			want:     true,
+		},
+		{
+			name:     "resource of another kind",
+			rule:     kyvernov1.CEL{ParamKind: &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "Secret"}, ParamRef: &admissionregistrationv1alpha1.ParamRef{Name: "limits"}},
+			resource: newConfigMapParam("default", "limits", nil, nil),
+		},
+		{
+			name: "resource of the kind of an additional param",
+			rule: kyvernov1.CEL{
+				ParamKind: &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "Secret"},
+				ParamRef:  &admissionregistrationv1alpha1.ParamRef{Name: "limits"},
+				AdditionalParams: []kyvernov1.CELParams{
+					{ParamKind: *configMaps, ParamRef: admissionregistrationv1alpha1.ParamRef{Name: "limits"}},
+				},
+			},
+			resource: newConfigMapParam("default", "limits", nil, nil),
+			want:     true,
+		},
+		{
+			name:     "rule without params",
+			resource: newConfigMapParam("default", "limits", nil, nil),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, declaresParam(&tc.rule, "default", &tc.resource), tc.want)
+		})
+	}
+}
+
+func Test_ValidateCEL_ExcludeSelfFromParams(t *testing.T) {
+	configMap := `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "alpha", "namespace": "default", "labels": {"app": "params"}}, "data": {"port": "8080"}}`
+	testCases := []struct {
+		name        string
+		excludeSelf bool
+		wantStatus  engineapi.RuleStatus
+	}{
+		{
+			name:       "the selector matches the resource",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:        "the resource is excluded from its params",
+			excludeSelf: true,
+			wantStatus:  engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, configMap, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.ExcludeSelfFromParams = tc.excludeSelf
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.data.port != params.data.port", Message: "the port is already used"},
+			}
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"port": "8080"}),
+					newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"port": "9090"}),
+				},
+			}
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.excludeSelf {
+				assert.DeepEqual(t, responses[0].ParamResourceVersions(), map[string]string{"default/beta": ""})
+			}
+		})
+	}
+}
+
+func Test_excludeResource(t *testing.T) {
+	alpha := newConfigMapParam("default", "alpha", nil, nil)
+	beta := newConfigMapParam("default", "beta", nil, nil)
+	params := []runtime.Object{&alpha, &beta}
+
+	// compared by name without UIDs
+	resource := newConfigMapParam("default", "alpha", nil, nil)
+	assert.DeepEqual(t, excludeResource(params, resource), []runtime.Object{&beta})
+
+	// compared by UID when both have one
+	alpha.SetUID("1234")
+	resource.SetUID("5678")
+	assert.DeepEqual(t, excludeResource(params, resource), []runtime.Object{&alpha, &beta})
+	resource.SetUID("1234")
+	assert.DeepEqual(t, excludeResource(params, resource), []runtime.Object{&beta})
+}
+
+func Test_ValidateCEL_ParamsFromOwners(t *testing.T) {
+	first := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-1", UID: "1111"}
+	second := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-2", UID: "2222"}
+	other := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "other", UID: "3333"}
+	newParam := func(name, maxReplicas string, owner metav1.OwnerReference) unstructured.Unstructured {
+		param := newConfigMapParam("default", name, map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": maxReplicas})
+		param.SetOwnerReferences([]metav1.OwnerReference{owner})
+		return param
+	}
+	testCases := []struct {
+		name       string
+		owners     []metav1.OwnerReference
+		action     admissionregistrationv1alpha1.ParameterNotFoundActionType
+		maxParams  int
+		wantStatus engineapi.RuleStatus
+		wantParams map[string]string
+	}{
+		{
+			name:       "params of the owner",
+			owners:     []metav1.OwnerReference{first},
+			action:     admissionregistrationv1alpha1.DenyAction,
+			wantStatus: engineapi.RuleStatusPass,
+			wantParams: map[string]string{"default/first": ""},
+		},
+		{
+			name:       "params of several owners",
+			owners:     []metav1.OwnerReference{first, second},
+			action:     admissionregistrationv1alpha1.DenyAction,
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "params of other owners don't count toward the maximum",
+			owners:     []metav1.OwnerReference{first},
+			action:     admissionregistrationv1alpha1.DenyAction,
+			maxParams:  1,
+			wantStatus: engineapi.RuleStatusPass,
+			wantParams: map[string]string{"default/first": ""},
+		},
+		{
+			name:       "params of several owners above the maximum",
+			owners:     []metav1.OwnerReference{first, second},
+			action:     admissionregistrationv1alpha1.DenyAction,
+			maxParams:  1,
+			wantStatus: engineapi.RuleStatusError,
+		},
+		{
+			name:       "no owner with the Deny action",
+			action:     admissionregistrationv1alpha1.DenyAction,
+			wantStatus: engineapi.RuleStatusError,
+		},
+		{
+			name:       "no owner with the Allow action",
+			action:     admissionregistrationv1alpha1.AllowAction,
+			wantStatus: engineapi.RuleStatusSkip,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.ParamsFromOwners = true
+			rule.Validation.CEL.ParamRef.ParameterNotFoundAction = &tc.action
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newParam("first", "5", first),
+					newParam("second", "2", second),
+					newParam("other", "1", other),
+				},
+			}
+
+			options := []ValidateCELOption{WithParamLoader(loader)}
+			if tc.maxParams > 0 {
+				options = append(options, WithParamLimits(DefaultParamsPageSize, tc.maxParams))
+			}
+			handler, err := NewValidateCELHandler(nil, options...)
+			assert.NilError(t, err)
+			resource := policyContext.NewResource()
+			resource.SetOwnerReferences(tc.owners)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, resource, rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantParams != nil {
+				assert.DeepEqual(t, responses[0].ParamResourceVersions(), tc.wantParams)
+			}
+			// params aren't listed for resources without owners
+			if len(tc.owners) == 0 {
+				assert.Equal(t, loader.pages, 0)
+			}
+		})
+	}
+}
+
+func Test_isOwnedBy(t *testing.T) {
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-1", UID: "1111"}
+	owned := newConfigMapParam("default", "owned", nil, nil)
+	owned.SetOwnerReferences([]metav1.OwnerReference{owner})
+	// owners and their dependents share their namespace
+	elsewhere := newConfigMapParam("other", "elsewhere", nil, nil)
+	elsewhere.SetOwnerReferences([]metav1.OwnerReference{owner})
+	unowned := newConfigMapParam("default", "unowned", nil, nil)
+
+	resource := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Pod"}}
+	resource.SetNamespace("default")
+	assert.Assert(t, !isOwnedBy(&owned, &resource))
+
+	// compared by UID when both have one
+	resource.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-1", UID: "2222"}})
+	assert.Assert(t, !isOwnedBy(&owned, &resource))
+	resource.SetOwnerReferences([]metav1.OwnerReference{owner})
+	assert.Assert(t, isOwnedBy(&owned, &resource))
+	assert.Assert(t, !isOwnedBy(&elsewhere, &resource))
+	assert.Assert(t, !isOwnedBy(&unowned, &resource))
+
+	// compared by api version, kind and name otherwise
+	resource.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-1"}})
+	assert.Assert(t, isOwnedBy(&owned, &resource))
+}
+
+func Test_ValidateCEL_EvaluateWithoutParams(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		evaluateWithoutParams bool
+		params                []unstructured.Unstructured
+		wantStatus            engineapi.RuleStatus
+		wantMessage           string
+		wantEvaluations       int
+	}{{
+		name:            "only params are evaluated by default",
+		params:          []unstructured.Unstructured{newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"})},
+		wantStatus:      engineapi.RuleStatusPass,
+		wantEvaluations: 1,
+	}, {
+		name:            "missing params skip the rule by default",
+		wantStatus:      engineapi.RuleStatusSkip,
+		wantMessage:     "no parameter resources matched; skipping",
+		wantEvaluations: 0,
+	}, {
+		name:                  "evaluated without params as well",
+		evaluateWithoutParams: true,
+		params:                []unstructured.Unstructured{newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"})},
+		wantStatus:            engineapi.RuleStatusFail,
+		wantMessage:           "too many replicas without params",
+		wantEvaluations:       2,
+	}, {
+		name:                  "evaluated without params when params are missing",
+		evaluateWithoutParams: true,
+		wantStatus:            engineapi.RuleStatusFail,
+		wantMessage:           "too many replicas without params",
+		wantEvaluations:       1,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.EvaluateWithoutParams = tc.evaluateWithoutParams
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "params == null || object.spec.replicas <= int(params.data.maxReplicas)", Message: "too many replicas"},
+				{Expression: "params != null || object.spec.replicas <= 2", Message: "too many replicas without params"},
+			}
+			loader := &fakeParamLoader{namespaced: true, params: tc.params}
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			evaluations := 0
+			h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+				validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+				return countingValidator{Validator: validator, evaluations: &evaluations}
+			}
+			_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+			assert.Equal(t, evaluations, tc.wantEvaluations)
+			// the denials without params don't reference a param
+			assert.Equal(t, len(responses[0].DeniedParams()), 0)
+		})
+	}
+}
+
+func Test_ValidateCEL_DeniedParams(t *testing.T) {
+	alpha := engineapi.ParamReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "alpha"}
+	gamma := engineapi.ParamReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "gamma"}
+	testCases := []struct {
+		name             string
+		options          []ValidateCELOption
+		wantDeniedParams []engineapi.ParamReference
+	}{
+		{
+			name:             "first denial",
+			wantDeniedParams: []engineapi.ParamReference{alpha},
+		},
+		{
+			name:             "aggregated denials",
+			options:          []ValidateCELOption{WithDenialAggregation(true)},
+			wantDeniedParams: []engineapi.ParamReference{alpha, gamma},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+					newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+					newConfigMapParam("default", "gamma", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+				},
+			}
+
+			handler, err := NewValidateCELHandler(nil, append(tc.options, WithParamLoader(loader))...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.DeepEqual(t, responses[0].DeniedParams(), tc.wantDeniedParams)
+		})
+	}
+
+	// rules without params have no denied params
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas <= 2"
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.Assert(t, responses[0].DeniedParams() == nil)
+}
@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// projectedFields are always kept so that the projected object can still be identified.
+var projectedFields = [][]string{{"apiVersion"}, {"kind"}}
+
+func parseFieldProjection(projection []string) [][]string {
+	paths := make([][]string, 0, len(projectedFields)+len(projection))
+	paths = append(paths, projectedFields...)
+	for _, path := range projection {
+		paths = append(paths, strings.Split(path, "."))
+	}
+	return paths
+}
+
+// projectObject returns a copy of the object containing only the given field paths.
+func projectObject(obj runtime.Object, paths [][]string) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return obj
+	}
+	projected := map[string]interface{}{}
+	for _, path := range paths {
+		value, found, err := unstructured.NestedFieldNoCopy(u.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+		if err := unstructured.SetNestedField(projected, runtime.DeepCopyJSONValue(value), path...); err != nil {
+			continue
+		}
+	}
+	return &unstructured.Unstructured{Object: projected}
+}
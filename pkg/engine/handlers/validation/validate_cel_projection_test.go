@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_FieldProjection(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.FieldProjection = []string{"spec.replicas", "metadata.name"}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+}
+
+func Test_projectObject(t *testing.T) {
+	spec := map[string]interface{}{"replicas": int64(3)}
+	for i := 0; i < 1000; i++ {
+		spec[fmt.Sprintf("field%d", i)] = "value"
+	}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+		"spec":       spec,
+	}}
+	projected := projectObject(object, parseFieldProjection([]string{"spec.replicas"}))
+	assert.DeepEqual(t, projected.(*unstructured.Unstructured).Object, map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec":       map[string]interface{}{"replicas": int64(3)},
+	})
+	// the original object is left untouched
+	assert.Equal(t, len(object.Object["spec"].(map[string]interface{})), 1001)
+
+	// the evaluated object is a fraction of the original one
+	full, err := object.MarshalJSON()
+	assert.NilError(t, err)
+	trimmed, err := projected.(*unstructured.Unstructured).MarshalJSON()
+	assert.NilError(t, err)
+	assert.Assert(t, len(trimmed)*100 < len(full))
+
+	// missing fields and nil objects are ignored
+	projected = projectObject(object, parseFieldProjection([]string{"spec.template.spec"}))
+	assert.DeepEqual(t, projected.(*unstructured.Unstructured).Object, map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+	})
+	assert.Assert(t, projectObject(nil, parseFieldProjection([]string{"spec.replicas"})) == nil)
+}
@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/kyverno/kyverno/pkg/utils/jsonpointer"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+)
+
+// parseRedactions parses the JSON pointers of the redacted values, it returns nil without redactions.
+func parseRedactions(redactions []string) []jsonpointer.Pointer {
+	if len(redactions) == 0 {
+		return nil
+	}
+	pointers := make([]jsonpointer.Pointer, 0, len(redactions))
+	for _, redaction := range redactions {
+		pointers = append(pointers, jsonpointer.Parse(redaction))
+	}
+	return pointers
+}
+
+// redactObject returns a copy of the object with the values at the given pointers set to null.
+// Pointers to missing values are ignored.
+func redactObject(obj runtime.Object, pointers []jsonpointer.Pointer) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return obj
+	}
+	redacted := u.DeepCopy()
+	for _, pointer := range pointers {
+		redactValue(redacted.Object, pointer)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}, pointer jsonpointer.Pointer) {
+	if len(pointer) == 0 {
+		return
+	}
+	token, last := pointer[0], len(pointer) == 1
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		child, ok := typed[token]
+		if !ok {
+			return
+		}
+		if last {
+			typed[token] = nil
+			return
+		}
+		redactValue(child, pointer[1:])
+	case []interface{}:
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(typed) {
+			return
+		}
+		if last {
+			typed[index] = nil
+			return
+		}
+		redactValue(typed[index], pointer[1:])
+	}
+}
+
+// objectRedactor redacts the objects the message and suggestion expressions of a rule are evaluated against, each
+// object is copied and redacted once for all the filters it wraps. It is used by a single evaluation of a rule.
+type objectRedactor struct {
+	pointers []jsonpointer.Pointer
+	redacted map[runtime.Object]runtime.Object
+}
+
+func newObjectRedactor(pointers []jsonpointer.Pointer) *objectRedactor {
+	return &objectRedactor{
+		pointers: pointers,
+		redacted: map[runtime.Object]runtime.Object{},
+	}
+}
+
+// redact returns the redacted copy of the object, it is only copied the first time.
+func (r *objectRedactor) redact(obj runtime.Object) runtime.Object {
+	if obj == nil {
+		return nil
+	}
+	if redacted, ok := r.redacted[obj]; ok {
+		return redacted
+	}
+	redacted := redactObject(obj, r.pointers)
+	r.redacted[obj] = redacted
+	return redacted
+}
+
+// wrap returns a filter evaluating the given filter against the redacted copies of the objects.
+func (r *objectRedactor) wrap(filter cel.Filter) cel.Filter {
+	return &redactingFilter{
+		Filter:   filter,
+		redactor: r,
+	}
+}
+
+// redactingFilter evaluates the wrapped filter against copies of the objects with redacted values
+type redactingFilter struct {
+	cel.Filter
+	redactor *objectRedactor
+}
+
+func (f *redactingFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	redacted := *versionedAttr
+	redacted.VersionedObject = f.redactor.redact(versionedAttr.VersionedObject)
+	redacted.VersionedOldObject = f.redactor.redact(versionedAttr.VersionedOldObject)
+	return f.Filter.ForInput(ctx, &redacted, request, optionalVars, namespace, runtimeCELCostBudget)
+}
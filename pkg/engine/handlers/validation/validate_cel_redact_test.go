@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_MessageRedactions(t *testing.T) {
+	deployment := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default", "annotations": {"token": "s3cr3t"}}, "spec": {"replicas": 3}}`
+	testCases := []struct {
+		name        string
+		redactions  []string
+		wantMessage string
+	}{
+		{
+			name:        "without redactions",
+			wantMessage: "token of nginx is null: false",
+		},
+		{
+			name:        "redacted token",
+			redactions:  []string{"/metadata/annotations/token"},
+			wantMessage: "token of nginx is null: true",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, deployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.MessageRedactions = tc.redactions
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{
+					// the validation sees the real value
+					Expression:        "object.metadata.annotations.token != 's3cr3t'",
+					MessageExpression: "'token of ' + object.metadata.name + ' is null: ' + string(object.metadata.annotations.token == null)",
+				},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, responses[0].Message(), tc.wantMessage)
+		})
+	}
+}
+
+func Test_ValidateCEL_SuggestionRedactions(t *testing.T) {
+	deployment := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default", "annotations": {"token": "s3cr3t"}}, "spec": {"replicas": 3}}`
+	process := func(suggestion string) engineapi.RuleResponse {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, deployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.MessageRedactions = []string{"/metadata/annotations/token"}
+		rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+			{Expression: "object.metadata.annotations.token != 's3cr3t'", Message: "the token is not allowed"},
+		}
+		rule.Validation.CEL.SuggestionExpression = suggestion
+		handler, err := NewValidateCELHandler(nil)
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+		return responses[0]
+	}
+	// suggestions see the redacted values and their cost is counted along with the cost of the validations
+	withoutSuggestion := process("")
+	withSuggestion := process("'token is null: ' + string(object.metadata.annotations.token == null)")
+	assert.Equal(t, withSuggestion.Suggestion(), "token is null: true")
+	assert.Assert(t, withSuggestion.Cost() > withoutSuggestion.Cost())
+}
+
+func Test_objectRedactor(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "nginx", "annotations": map[string]interface{}{"token": "s3cr3t"}},
+	}}
+	redactor := newObjectRedactor(parseRedactions([]string{"/metadata/annotations/token"}))
+	redacted := redactor.redact(obj)
+	token, found, err := unstructured.NestedFieldNoCopy(redacted.(*unstructured.Unstructured).Object, "metadata", "annotations", "token")
+	assert.NilError(t, err)
+	assert.Assert(t, found && token == nil)
+	// each object is copied once
+	assert.Assert(t, redactor.redact(obj) == redacted)
+	assert.Assert(t, redactor.redact(nil) == nil)
+	assert.Equal(t, len(redactor.redacted), 1)
+}
+
+func Test_redactObject(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "nginx",
+			"annotations": map[string]interface{}{"a/b": "secret"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "nginx", "env": []interface{}{"secret"}},
+			},
+		},
+	}}
+	pointers := parseRedactions([]string{"/metadata/annotations/a~1b", "/spec/containers/0/env", "/spec/missing/field", "/spec/containers/1"})
+	redacted := redactObject(obj, pointers).(*unstructured.Unstructured)
+	assert.DeepEqual(t, redacted.Object, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "nginx",
+			"annotations": map[string]interface{}{"a/b": nil},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "nginx", "env": nil},
+			},
+		},
+	})
+	// the object itself is left intact
+	assert.Equal(t, obj.GetAnnotations()["a/b"], "secret")
+}
@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"sort"
+
+	apiutils "github.com/kyverno/kyverno/pkg/utils/api"
+)
+
+// containerImages returns the images of the containers of the resource, sorted by container type and name.
+// Every image gives its container, its registry, its repository (`registry/path`) and its reference.
+func containerImages(infos map[string]map[string]apiutils.ImageInfo) []interface{} {
+	types := make([]string, 0, len(infos))
+	for containerType := range infos {
+		types = append(types, containerType)
+	}
+	sort.Strings(types)
+	images := []interface{}{}
+	for _, containerType := range types {
+		names := make([]string, 0, len(infos[containerType]))
+		for name := range infos[containerType] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			info := infos[containerType][name]
+			image := info.Path
+			if info.Registry != "" {
+				image = info.Registry + "/" + info.Path
+			}
+			images = append(images, map[string]interface{}{
+				"container": name,
+				"registry":  info.Registry,
+				"image":     image,
+				"reference": info.String(),
+			})
+		}
+	}
+	return images
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var celRegistriesPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-registries"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-registries",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Pod"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"allowedRegistries": {
+							"registries": [
+								"ghcr.io"
+							]
+						}
+					}
+				}
+			}
+		]
+	}
+}`
+
+var celRegistriesPod = `{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {
+		"name": "nginx",
+		"namespace": "default"
+	},
+	"spec": {
+		"containers": [
+			{
+				"name": "nginx",
+				"image": "ghcr.io/acme/nginx:1.25"
+			},
+			{
+				"name": "sidecar",
+				"image": "docker.io/library/busybox:1.36"
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_AllowedRegistries(t *testing.T) {
+	testCases := []struct {
+		name        string
+		registries  []string
+		params      []string
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:        "inline registries with a disallowed registry",
+			registries:  []string{"ghcr.io"},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "images not pulled from an approved registry: docker.io/library/busybox:1.36",
+		},
+		{
+			name:       "inline registries with approved registries",
+			registries: []string{"ghcr.io", "docker.io/library"},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "approved registry prefix is a path boundary",
+			registries:  []string{"ghcr.io", "docker.io/lib"},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "images not pulled from an approved registry: docker.io/library/busybox:1.36",
+		},
+		{
+			name:        "registries from params with a disallowed registry",
+			params:      []string{"ghcr.io"},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "images not pulled from an approved registry: docker.io/library/busybox:1.36",
+		},
+		{
+			name:       "registries from params with approved registries",
+			params:     []string{"ghcr.io, docker.io"},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "inline registries and registries from params",
+			registries: []string{"docker.io"},
+			params:     []string{"ghcr.io"},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celRegistriesPolicy, celRegistriesPod, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.AllowedRegistries = &kyvernov1.AllowedRegistries{Registries: tc.registries}
+			loader := &fakeParamLoader{namespaced: true}
+			if tc.params != nil {
+				rule.Validation.CEL.AllowedRegistries.ParamField = "data.registries"
+				rule.Validation.CEL.ParamKind = &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+				rule.Validation.CEL.ParamRef = &admissionregistrationv1alpha1.ParamRef{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "registries"}}}
+				for i, registries := range tc.params {
+					loader.params = append(loader.params, newConfigMapParam("default", fmt.Sprintf("registries-%d", i), map[string]string{"app": "registries"}, map[string]interface{}{"registries": registries}))
+				}
+			}
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
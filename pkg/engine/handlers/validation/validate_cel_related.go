@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"fmt"
+
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MaxRelatedResources is the maximum number of related resources exposed to the expressions of a rule.
+const MaxRelatedResources = 100
+
+// relatedResourcesOf returns the contents of the related resources of the declared kinds, the evaluated object excluded.
+func relatedResourcesOf(resources []unstructured.Unstructured, kinds []kyvernov1.RelatedResourceKind, object unstructured.Unstructured) ([]interface{}, error) {
+	related := []interface{}{}
+	for i := range resources {
+		resource := &resources[i]
+		if !isRelatedKind(resource, kinds) || isSameResource(resource, &object) {
+			continue
+		}
+		if len(related) == MaxRelatedResources {
+			return nil, fmt.Errorf("more than %d related resources", MaxRelatedResources)
+		}
+		related = append(related, resource.UnstructuredContent())
+	}
+	return related, nil
+}
+
+func isRelatedKind(resource *unstructured.Unstructured, kinds []kyvernov1.RelatedResourceKind) bool {
+	for _, kind := range kinds {
+		if resource.GetAPIVersion() == kind.APIVersion && resource.GetKind() == kind.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func isSameResource(resource, object *unstructured.Unstructured) bool {
+	return resource.GetAPIVersion() == object.GetAPIVersion() &&
+		resource.GetKind() == object.GetKind() &&
+		resource.GetNamespace() == object.GetNamespace() &&
+		resource.GetName() == object.GetName()
+}
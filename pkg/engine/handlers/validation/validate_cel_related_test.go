@@ -0,0 +1,272 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	kubeutils "github.com/kyverno/kyverno/pkg/utils/kube"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var celOwnersPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-owners"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-owners",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Pod"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"requiredOwners": [
+							{
+								"apiGroup": "apps",
+								"kind": "ReplicaSet"
+							},
+							{
+								"apiGroup": "batch",
+								"kind": "Job"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_RequiredOwners(t *testing.T) {
+	testCases := []struct {
+		name            string
+		ownerReferences string
+		wantStatus      engineapi.RuleStatus
+		wantMessage     string
+	}{
+		{
+			name:            "owned by a replica set",
+			ownerReferences: `[{"apiVersion": "apps/v1", "kind": "ReplicaSet", "name": "nginx-5d4f8", "uid": "d9607e19-f88f-11e6-a518-42010a800195"}]`,
+			wantStatus:      engineapi.RuleStatusPass,
+		},
+		{
+			name:            "owned by a job",
+			ownerReferences: `[{"apiVersion": "v1", "kind": "Node", "name": "node-1", "uid": "5d9d2b8a-f88f-11e6-a518-42010a800195"}, {"apiVersion": "batch/v1", "kind": "Job", "name": "backup", "uid": "c1a0e1a4-f88f-11e6-a518-42010a800195"}]`,
+			wantStatus:      engineapi.RuleStatusPass,
+		},
+		{
+			name:        "without owner references",
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "object has no owner reference, it must be owned by one of: ReplicaSet.apps, Job.batch",
+		},
+		{
+			name:            "with empty owner references",
+			ownerReferences: `[]`,
+			wantStatus:      engineapi.RuleStatusFail,
+			wantMessage:     "object has no owner reference, it must be owned by one of: ReplicaSet.apps, Job.batch",
+		},
+		{
+			name:            "owned by another kind",
+			ownerReferences: `[{"apiVersion": "v1", "kind": "Node", "name": "node-1", "uid": "5d9d2b8a-f88f-11e6-a518-42010a800195"}]`,
+			wantStatus:      engineapi.RuleStatusFail,
+			wantMessage:     "object is not owned by one of: ReplicaSet.apps, Job.batch",
+		},
+		{
+			name:            "owned by a kind of another group",
+			ownerReferences: `[{"apiVersion": "extensions/v1beta1", "kind": "ReplicaSet", "name": "nginx-5d4f8", "uid": "d9607e19-f88f-11e6-a518-42010a800195"}]`,
+			wantStatus:      engineapi.RuleStatusFail,
+			wantMessage:     "object is not owned by one of: ReplicaSet.apps, Job.batch",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata := `"name": "nginx", "namespace": "default"`
+			if tc.ownerReferences != "" {
+				metadata += `, "ownerReferences": ` + tc.ownerReferences
+			}
+			pod := fmt.Sprintf(`{"apiVersion": "v1", "kind": "Pod", "metadata": {%s}, "spec": {"containers": [{"name": "nginx", "image": "nginx"}]}}`, metadata)
+			policyContext := buildContext(t, kyvernov1.Create, celOwnersPolicy, pod, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+var celServicePolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-service-backend"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-service-backend",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Service"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"relatedResources": [
+							{
+								"apiVersion": "apps/v1",
+								"kind": "Deployment"
+							}
+						],
+						"expressions": [
+							{
+								"expression": "relatedResources.exists(r, r.metadata.namespace == object.metadata.namespace && r.metadata.name == object.metadata.name)",
+								"message": "service must have a matching deployment"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+var celService = `{
+	"apiVersion": "v1",
+	"kind": "Service",
+	"metadata": {
+		"name": "nginx",
+		"namespace": "default"
+	},
+	"spec": {
+		"selector": {
+			"app": "nginx"
+		},
+		"ports": [
+			{
+				"port": 80
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_RelatedResources(t *testing.T) {
+	newResource := func(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+		var resource unstructured.Unstructured
+		resource.SetAPIVersion(apiVersion)
+		resource.SetKind(kind)
+		resource.SetNamespace(namespace)
+		resource.SetName(name)
+		return resource
+	}
+	service, err := kubeutils.BytesToUnstructured([]byte(celService))
+	assert.NilError(t, err)
+	testCases := []struct {
+		name       string
+		related    []unstructured.Unstructured
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name: "service with a matching deployment",
+			related: []unstructured.Unstructured{
+				*service,
+				newResource("apps/v1", "Deployment", "default", "nginx"),
+			},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "lone service",
+			related:    []unstructured.Unstructured{*service},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name: "service with a deployment in another namespace",
+			related: []unstructured.Unstructured{
+				*service,
+				newResource("apps/v1", "Deployment", "production", "nginx"),
+			},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name: "service with a matching resource of an undeclared kind",
+			related: []unstructured.Unstructured{
+				*service,
+				newResource("apps/v1", "StatefulSet", "default", "nginx"),
+			},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celServicePolicy, celService, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+
+			handler, err := NewValidateCELHandler(nil, WithRelatedResources(tc.related))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantStatus == engineapi.RuleStatusFail {
+				assert.Equal(t, responses[0].Message(), "service must have a matching deployment")
+			}
+		})
+	}
+}
+
+func Test_relatedResourcesOf(t *testing.T) {
+	kinds := []kyvernov1.RelatedResourceKind{{APIVersion: "apps/v1", Kind: "Deployment"}}
+	var object unstructured.Unstructured
+	object.SetAPIVersion("apps/v1")
+	object.SetKind("Deployment")
+	object.SetNamespace("default")
+	object.SetName("nginx")
+
+	// the evaluated object is not related to itself
+	related, err := relatedResourcesOf([]unstructured.Unstructured{object}, kinds, object)
+	assert.NilError(t, err)
+	assert.Equal(t, len(related), 0)
+
+	resources := make([]unstructured.Unstructured, MaxRelatedResources+1)
+	for i := range resources {
+		resources[i].SetAPIVersion("apps/v1")
+		resources[i].SetKind("Deployment")
+		resources[i].SetNamespace("default")
+		resources[i].SetName(fmt.Sprintf("nginx-%d", i))
+	}
+	related, err = relatedResourcesOf(resources[:MaxRelatedResources], kinds, object)
+	assert.NilError(t, err)
+	assert.Equal(t, len(related), MaxRelatedResources)
+	_, err = relatedResourcesOf(resources, kinds, object)
+	assert.Error(t, err, fmt.Sprintf("more than %d related resources", MaxRelatedResources))
+}
@@ -0,0 +1,341 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/policycontext"
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var celScalePolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-scale"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-scale",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Deployment/scale"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"expressions": [
+							{
+								"expression": "request.kind.group == 'autoscaling' && request.kind.version == 'v1' && request.kind.kind == 'Scale'",
+								"message": "unexpected kind"
+							},
+							{
+								"expression": "request.requestKind.group == 'autoscaling' && request.requestKind.version == 'v1' && request.requestKind.kind == 'Scale'",
+								"message": "unexpected request kind"
+							},
+							{
+								"expression": "request.resource.group == 'apps' && request.resource.version == 'v1' && request.resource.resource == 'deployments'",
+								"message": "unexpected resource"
+							},
+							{
+								"expression": "request.subResource == 'scale'",
+								"message": "unexpected subresource"
+							},
+							{
+								"expression": "object.spec.replicas <= 5",
+								"message": "too many replicas"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+var celScale = `{
+	"apiVersion": "autoscaling/v1",
+	"kind": "Scale",
+	"metadata": {
+		"name": "nginx",
+		"namespace": "default"
+	},
+	"spec": {
+		"replicas": 10
+	}
+}`
+
+func Test_ValidateCEL_ScaleSubresource(t *testing.T) {
+	testCases := []struct {
+		name         string
+		precondition string
+		wantStatus   engineapi.RuleStatus
+		wantMessage  string
+	}{
+		{
+			name:        "scale requests",
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "too many replicas",
+		},
+		// preconditions are evaluated against the subresource of the request as well
+		{
+			name:         "preconditions selecting scale requests",
+			precondition: "request.subResource == 'scale'",
+			wantStatus:   engineapi.RuleStatusFail,
+			wantMessage:  "too many replicas",
+		},
+		{
+			name:         "preconditions selecting requests of the main resource",
+			precondition: "request.subResource == ''",
+			wantStatus:   engineapi.RuleStatusSkip,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Update, celScalePolicy, celScale, celScale).(*policycontext.PolicyContext).
+				WithResourceKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "scale").
+				WithRequestResource(metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"})
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			if tc.precondition != "" {
+				rule.CELPreconditions = []admissionregistrationv1alpha1.MatchCondition{
+					{Name: "subresource", Expression: tc.precondition},
+				}
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_ValidateCEL_StatusSubresource(t *testing.T) {
+	widget := func(phase string) string {
+		return `{
+			"apiVersion": "example.com/v1",
+			"kind": "Widget",
+			"metadata": {"name": "widget", "namespace": "default"},
+			"spec": {"size": 1},
+			"status": {"phase": "` + phase + `"}
+		}`
+	}
+	testCases := []struct {
+		name       string
+		oldPhase   string
+		phase      string
+		wantStatus engineapi.RuleStatus
+	}{{
+		name:       "allowed transition",
+		oldPhase:   "Pending",
+		phase:      "Running",
+		wantStatus: engineapi.RuleStatusPass,
+	}, {
+		name:       "denied transition",
+		oldPhase:   "Running",
+		phase:      "Pending",
+		wantStatus: engineapi.RuleStatusFail,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Update, celReplicasPolicy, widget(tc.phase), widget(tc.oldPhase)).(*policycontext.PolicyContext).
+				WithResourceKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "status").
+				WithRequestResource(metav1.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"})
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "request.subResource == 'status'", Message: "unexpected subresource"},
+				{Expression: "request.kind.group == 'example.com' && request.kind.kind == 'Widget'", Message: "unexpected kind"},
+				{Expression: "request.resource.resource == 'widgets'", Message: "unexpected resource"},
+				// status updates carry the whole custom resource, the old status is available to transition rules
+				{Expression: "!(oldObject.status.phase == 'Running' && object.status.phase == 'Pending')", Message: "running widgets can't go back to pending"},
+				{Expression: "object.spec == oldObject.spec", Message: "unexpected spec change"},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantStatus == engineapi.RuleStatusFail {
+				assert.Equal(t, responses[0].Message(), "running widgets can't go back to pending")
+			}
+		})
+	}
+}
+
+func Test_requestKindOf(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	scale := unstructured.Unstructured{}
+	scale.SetGroupVersionKind(schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"})
+
+	assert.Equal(t, requestKindOf(deployment, "", scale, unstructured.Unstructured{}), deployment)
+	assert.Equal(t, requestKindOf(deployment, "scale", scale, unstructured.Unstructured{}), scale.GroupVersionKind())
+	assert.Equal(t, requestKindOf(deployment, "scale", unstructured.Unstructured{}, scale), scale.GroupVersionKind())
+	assert.Equal(t, requestKindOf(deployment, "scale", unstructured.Unstructured{}, unstructured.Unstructured{}), deployment)
+}
+
+var celExecPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "deny-shell"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "deny-shell",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Pod/exec"
+								],
+								"operations": [
+									"CONNECT"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"expressions": [
+							{
+								"expression": "request.operation == 'CONNECT' && request.subResource == 'exec' && request.name == 'nginx' && oldObject == null",
+								"message": "unexpected request"
+							},
+							{
+								"expression": "object.kind == 'PodExecOptions' && !object.command.exists(c, c == 'sh')",
+								"message": "shell is not allowed"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_Connect(t *testing.T) {
+	testCases := []struct {
+		name        string
+		connect     kyvernov1.ConnectAction
+		command     string
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:        "skipped by default",
+			command:     "sh",
+			wantStatus:  engineapi.RuleStatusSkip,
+			wantMessage: "rule skipped for CONNECT requests",
+		},
+		{
+			name:        "skipped",
+			connect:     kyvernov1.ConnectSkip,
+			command:     "sh",
+			wantStatus:  engineapi.RuleStatusSkip,
+			wantMessage: "rule skipped for CONNECT requests",
+		},
+		{
+			name:        "evaluated and denied",
+			connect:     kyvernov1.ConnectEvaluate,
+			command:     "sh",
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "shell is not allowed",
+		},
+		{
+			name:       "evaluated and allowed",
+			connect:    kyvernov1.ConnectEvaluate,
+			command:    "ls",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options := fmt.Sprintf(`{"apiVersion": "v1", "kind": "PodExecOptions", "command": [%q], "container": "nginx", "stdin": true, "tty": true}`, tc.command)
+			policyContext := buildContext(t, kyvernov1.Connect, celExecPolicy, options, "").(*policycontext.PolicyContext).
+				WithResourceKind(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, "exec").
+				WithRequestResource(metav1.GroupVersionResource{Version: "v1", Resource: "pods"})
+			err := policyContext.JSONContext().AddRequest(admissionv1.AdmissionRequest{
+				Name:        "nginx",
+				Namespace:   "default",
+				Operation:   admissionv1.Connect,
+				SubResource: "exec",
+			})
+			assert.NilError(t, err)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Connect = tc.connect
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus)
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_ValidateCEL_ConnectNamespace(t *testing.T) {
+	for _, namespace := range []string{"production", "staging"} {
+		t.Run(namespace, func(t *testing.T) {
+			options := `{"apiVersion": "v1", "kind": "PodExecOptions", "command": ["ls"], "container": "nginx"}`
+			policyContext := buildContext(t, kyvernov1.Connect, celExecPolicy, options, "").(*policycontext.PolicyContext).
+				WithResourceKind(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, "exec").
+				WithRequestResource(metav1.GroupVersionResource{Version: "v1", Resource: "pods"})
+			err := policyContext.JSONContext().AddRequest(admissionv1.AdmissionRequest{
+				Name:        "nginx",
+				Namespace:   namespace,
+				Operation:   admissionv1.Connect,
+				SubResource: "exec",
+			})
+			assert.NilError(t, err)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Connect = kyvernov1.ConnectEvaluate
+			// the namespace of the connected pod is the namespace of the request
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "request.namespace == namespaceObject.metadata.name", Message: "unexpected namespace"},
+				{Expression: "namespaceObject.metadata.name != 'production'", Message: "exec into production pods is not allowed"},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			if namespace == "production" {
+				assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+				assert.Equal(t, responses[0].Message(), "exec into production pods is not allowed")
+			} else {
+				assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,1399 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/kyverno/kyverno/pkg/engine/policycontext"
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+type fakeParamLoader struct {
+	namespaced bool
+	params     []unstructured.Unstructured
+	// clusterScopedKinds are cluster scoped regardless of namespaced
+	clusterScopedKinds []string
+	// pages counts the pages listed
+	pages int
+}
+
+// defaultParamLimits are the param limits of handlers created without options
+var defaultParamLimits = paramLimits{pageSize: DefaultParamsPageSize, max: DefaultMaxParams, namespaces: DefaultMaxParamNamespaces}
+
+func (l *fakeParamLoader) IsNamespaced(group, version, kind string) (bool, error) {
+	if slices.Contains(l.clusterScopedKinds, kind) {
+		return false, nil
+	}
+	return l.namespaced, nil
+}
+
+func (l *fakeParamLoader) GetParam(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	for i := range l.params {
+		if l.params[i].GetKind() == kind && l.params[i].GetNamespace() == namespace && l.params[i].GetName() == name {
+			return &l.params[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: kind}, name)
+}
+
+// ListParams lists the matching params by pages, the continue token is the index of the first param of the next page.
+func (l *fakeParamLoader) ListParams(ctx context.Context, apiVersion, kind, namespace string, selector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	l.pages++
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var matching []unstructured.Unstructured
+	for _, param := range l.params {
+		if param.GetKind() == kind && param.GetNamespace() == namespace && s.Matches(labels.Set(param.GetLabels())) {
+			matching = append(matching, param)
+		}
+	}
+	start := 0
+	if continueToken != "" {
+		start, err = strconv.Atoi(continueToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	end := len(matching)
+	if limit > 0 && start+int(limit) < end {
+		end = start + int(limit)
+	}
+	list := &unstructured.UnstructuredList{Items: matching[start:end]}
+	if end < len(matching) {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+// fakeClientCall records the arguments of a call made to fakeClient.
+type fakeClientCall struct {
+	Method       string
+	APIVersion   string
+	Kind         string
+	Namespace    string
+	Name         string
+	Selector     *metav1.LabelSelector
+	Limit        int64
+	Continue     string
+	Subresources []string
+}
+
+// fakeClient implements engineapi.Client, only the methods overridden below can be called.
+type fakeClient struct {
+	engineapi.Client
+	namespaced bool
+	resources  []unstructured.Unstructured
+	calls      []fakeClientCall
+	// namespaceCalls counts the namespace reads, they are failed with namespaceErr when set
+	namespaceCalls int
+	namespaceErr   error
+	// namespaceLabels are the labels of the namespaces read
+	namespaceLabels map[string]string
+}
+
+func (c *fakeClient) IsNamespaced(group, version, kind string) (bool, error) {
+	return c.namespaced, nil
+}
+
+func (c *fakeClient) GetResource(ctx context.Context, apiVersion, kind, namespace, name string, subresources ...string) (*unstructured.Unstructured, error) {
+	c.calls = append(c.calls, fakeClientCall{Method: "GetResource", APIVersion: apiVersion, Kind: kind, Namespace: namespace, Name: name, Subresources: subresources})
+	for i := range c.resources {
+		if c.resources[i].GetKind() == kind && c.resources[i].GetNamespace() == namespace && c.resources[i].GetName() == name {
+			return &c.resources[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: kind}, name)
+}
+
+func (c *fakeClient) GetNamespace(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Namespace, error) {
+	c.namespaceCalls++
+	if c.namespaceErr != nil {
+		return nil, c.namespaceErr
+	}
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: c.namespaceLabels}}, nil
+}
+
+func (c *fakeClient) CanI(ctx context.Context, kind, namespace, verb, subresource, user string) (bool, string, error) {
+	c.calls = append(c.calls, fakeClientCall{Method: "CanI", Kind: kind, Namespace: namespace})
+	return true, "", nil
+}
+
+func (c *fakeClient) ListResourcePage(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	c.calls = append(c.calls, fakeClientCall{Method: "ListResourcePage", APIVersion: apiVersion, Kind: kind, Namespace: namespace, Selector: lselector, Limit: limit, Continue: continueToken})
+	selector, err := metav1.LabelSelectorAsSelector(lselector)
+	if err != nil {
+		return nil, err
+	}
+	var matching []unstructured.Unstructured
+	for _, resource := range c.resources {
+		if resource.GetKind() == kind && resource.GetNamespace() == namespace && selector.Matches(labels.Set(resource.GetLabels())) {
+			matching = append(matching, resource)
+		}
+	}
+	// the continue token is the index of the first resource of the next page
+	start := 0
+	if continueToken != "" {
+		start, err = strconv.Atoi(continueToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	end := len(matching)
+	if limit > 0 && start+int(limit) < end {
+		end = start + int(limit)
+	}
+	list := &unstructured.UnstructuredList{Items: matching[start:end]}
+	if end < len(matching) {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+func newConfigMapParam(namespace, name string, labels map[string]string, data map[string]interface{}) unstructured.Unstructured {
+	param := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"data":       data,
+	}}
+	param.SetNamespace(namespace)
+	param.SetName(name)
+	param.SetLabels(labels)
+	return param
+}
+
+var celParamPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-replicas"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-replicas",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Deployment"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"paramKind": {
+							"apiVersion": "v1",
+							"kind": "ConfigMap"
+						},
+						"paramRef": {
+							"selector": {
+								"matchLabels": {
+									"app": "params"
+								}
+							}
+						},
+						"expressions": [
+							{
+								"expression": "object.spec.replicas <= int(params.data.maxReplicas)",
+								"message": "too many replicas"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+var celDeployment = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {
+		"name": "nginx",
+		"namespace": "default"
+	},
+	"spec": {
+		"replicas": 3
+	}
+}`
+
+func Test_ValidateCEL_ResponsesOrder(t *testing.T) {
+	params := []unstructured.Unstructured{
+		newConfigMapParam("default", "zeta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+		newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+		newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+	}
+	process := func(params []unstructured.Unstructured, options ...ValidateCELOption) []string {
+		policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions[0].MessageExpression = "'too many replicas for ' + params.metadata.name"
+		options = append(options, WithParamLoader(&fakeParamLoader{namespaced: true, params: params}))
+		handler, err := NewValidateCELHandler(nil, options...)
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		var messages []string
+		for _, response := range responses {
+			messages = append(messages, response.Message())
+		}
+		return messages
+	}
+	reversed := slices.Clone(params)
+	slices.Reverse(reversed)
+
+	// the responses follow the order of the params sorted by namespace and name, whatever the order they are listed in
+	for _, params := range [][]unstructured.Unstructured{params, reversed} {
+		assert.DeepEqual(t, process(params, WithAllDecisions()), []string{
+			"Validation rule 'check-replicas' passed.",
+			"too many replicas for beta",
+			"too many replicas for zeta",
+		})
+		assert.DeepEqual(t, process(params, WithDenialAggregation(true)), []string{
+			"too many replicas for beta; too many replicas for zeta",
+		})
+	}
+}
+
+var celReplicasPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {
+		"name": "check-deployment"
+	},
+	"spec": {
+		"validationFailureAction": "Enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-deployment",
+				"match": {
+					"any": [
+						{
+							"resources": {
+								"kinds": [
+									"Deployment"
+								]
+							}
+						}
+					]
+				},
+				"validate": {
+					"cel": {
+						"expressions": [
+							{
+								"expression": "object.spec.replicas <= 5",
+								"message": "too many replicas"
+							},
+							{
+								"expression": "object.metadata.name != 'forbidden'",
+								"message": "forbidden name"
+							}
+						]
+					}
+				}
+			}
+		]
+	}
+}`
+
+func Test_ValidateCEL_ComputedLabels(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.CELPreconditions = []admissionregistrationv1alpha1.MatchCondition{
+		{Name: "payments", Expression: "has(object.metadata.labels) && 'team' in object.metadata.labels && object.metadata.labels['team'] == 'payments'"},
+	}
+	rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas <= 2"
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusSkip)
+
+	// the computed label makes the precondition match
+	rule.Validation.CEL.ComputedLabels = map[string]string{"team": "payments"}
+	resource, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	assert.Equal(t, responses[0].Message(), "too many replicas")
+	// the computed labels are not persisted
+	assert.Assert(t, resource.GetLabels() == nil)
+	assert.Assert(t, policyContext.NewResource().GetLabels() == nil)
+}
+
+func Test_withComputedLabels(t *testing.T) {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	object.SetLabels(map[string]string{"app": "nginx", "team": "core"})
+	merged := withComputedLabels(object, map[string]string{"team": "payments", "tier": "backend"})
+	assert.DeepEqual(t, merged.(*unstructured.Unstructured).GetLabels(), map[string]string{"app": "nginx", "team": "payments", "tier": "backend"})
+	// the object itself is left intact
+	assert.DeepEqual(t, object.GetLabels(), map[string]string{"app": "nginx", "team": "core"})
+	assert.Assert(t, withComputedLabels(nil, map[string]string{"team": "payments"}) == nil)
+}
+
+func Test_ValidateCEL_MutuallyExclusive(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:       "no key",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "one key",
+			labels:     map[string]string{"team-a": "true", "app": "nginx"},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "both labels",
+			labels:      map[string]string{"team-a": "true", "team-b": "true"},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "mutually exclusive keys are set: label team-a, label team-b",
+		},
+		{
+			name:        "label and annotation",
+			labels:      map[string]string{"team-b": "true"},
+			annotations: map[string]string{"example.com/team": "c"},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "mutually exclusive keys are set: label team-b, annotation example.com/team",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			resource := policyContext.NewResource()
+			resource.SetLabels(tc.labels)
+			resource.SetAnnotations(tc.annotations)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.MutuallyExclusive = []kyvernov1.MutuallyExclusiveKeys{
+				{Labels: []string{"team-a", "team-b"}, Annotations: []string{"example.com/team"}},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, resource, rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus)
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_ValidateCEL_Clock(t *testing.T) {
+	testCases := []struct {
+		name       string
+		now        time.Time
+		wantStatus engineapi.RuleStatus
+	}{{
+		name:       "before the deadline",
+		now:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		wantStatus: engineapi.RuleStatusPass,
+	}, {
+		name:       "after the deadline",
+		now:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		wantStatus: engineapi.RuleStatusFail,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= 2 || now() < timestamp('2024-03-01T00:00:00Z')", Message: "too many replicas"},
+			}
+
+			handler, err := NewValidateCELHandler(nil, WithClock(func() time.Time { return tc.now }), WithClockLibrary())
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Equal(t, responses[0].CompiledAt(), tc.now)
+		})
+	}
+
+	t.Run("the time functions are not declared unless enabled", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+			{Expression: "now() < timestamp('2024-03-01T00:00:00Z')"},
+		}
+
+		handler, err := NewValidateCELHandler(nil)
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError, responses[0].Message())
+		assert.Assert(t, strings.Contains(responses[0].Message(), "undeclared reference to 'now'"), responses[0].Message())
+	})
+}
+
+func Test_ValidateCEL_EvaluationID(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	var lines []string
+	logger := funcr.New(
+		func(prefix, args string) {
+			lines = append(lines, args)
+		},
+		funcr.Options{Verbosity: 4},
+	)
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logger, policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass)
+	evaluationID := responses[0].EvaluationID()
+	assert.Assert(t, evaluationID != "")
+	assert.Assert(t, len(lines) != 0)
+	for _, line := range lines {
+		assert.Assert(t, strings.Contains(line, fmt.Sprintf(`"evaluationId"=%q`, evaluationID)), line)
+	}
+
+	// every evaluation gets its own id
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Assert(t, responses[0].EvaluationID() != evaluationID)
+}
+
+func Test_ValidateCEL_ValidationFailureAction(t *testing.T) {
+	overrides := []kyvernov1.ValidationFailureActionOverride{
+		{Action: kyvernov1.Audit, Namespaces: []string{"staging-*"}},
+		{Action: kyvernov1.Audit, NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "dev"}}},
+	}
+	testCases := []struct {
+		name            string
+		namespace       string
+		namespaceLabels map[string]string
+		overrides       []kyvernov1.ValidationFailureActionOverride
+		wantAction      kyvernov1.ValidationFailureAction
+	}{
+		{
+			name:       "without overrides",
+			namespace:  "staging-1",
+			wantAction: kyvernov1.Enforce,
+		},
+		{
+			name:       "namespace not overridden",
+			namespace:  "default",
+			overrides:  overrides,
+			wantAction: kyvernov1.Enforce,
+		},
+		{
+			name:       "namespace overridden by name",
+			namespace:  "staging-1",
+			overrides:  overrides,
+			wantAction: kyvernov1.Audit,
+		},
+		{
+			name:            "namespace overridden by selector",
+			namespace:       "team-a",
+			namespaceLabels: map[string]string{"environment": "dev"},
+			overrides:       overrides,
+			wantAction:      kyvernov1.Audit,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deployment := strings.Replace(celDeployment, `"namespace": "default"`, fmt.Sprintf("%q: %q", "namespace", tc.namespace), 1)
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, deployment, "")
+			policyContext = policyContext.(*policycontext.PolicyContext).WithNamespaceLabels(tc.namespaceLabels)
+			policyContext.Policy().GetSpec().ValidationFailureActionOverrides = tc.overrides
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions[0].Expression = "object.spec.replicas <= 2"
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, responses[0].ValidationFailureAction(), tc.wantAction)
+		})
+	}
+}
+
+func Test_ValidateCEL_AllowedFields(t *testing.T) {
+	testCases := []struct {
+		name        string
+		deployment  string
+		allowed     []kyvernov1.AllowedFields
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:       "only allowed fields",
+			deployment: celDeployment,
+			allowed:    []kyvernov1.AllowedFields{{Path: "spec", Fields: []string{"replicas", "selector", "template"}}},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "unexpected field",
+			deployment:  `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default"}, "spec": {"replicas": 3, "paused": true}}`,
+			allowed:     []kyvernov1.AllowedFields{{Path: "spec", Fields: []string{"replicas", "selector", "template"}}},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "unexpected fields in spec: paused",
+		},
+		{
+			name:        "unexpected field of the root",
+			deployment:  `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default"}, "spec": {"replicas": 3}, "extra": {}}`,
+			allowed:     []kyvernov1.AllowedFields{{Fields: []string{"apiVersion", "kind", "metadata", "spec"}}},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "unexpected fields in the object: extra",
+		},
+		{
+			name:       "missing field",
+			deployment: celDeployment,
+			allowed:    []kyvernov1.AllowedFields{{Path: "spec.template.spec", Fields: []string{"containers"}}},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, tc.deployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.AllowedFields = tc.allowed
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			if tc.wantMessage != "" {
+				assert.Equal(t, responses[0].Message(), tc.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_ValidateCEL_LowercaseMetadata(t *testing.T) {
+	deployment := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default", "labels": {"Environment": "Production"}, "annotations": {"Team": "Payments"}}, "spec": {"replicas": 1}}`
+	testCases := []struct {
+		name       string
+		expression string
+		metadata   *kyvernov1.LowercaseMetadata
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name:       "lowercased keys",
+			expression: "lowercaseLabels['environment'] == 'Production' && lowercaseAnnotations['team'] == 'Payments'",
+			metadata:   &kyvernov1.LowercaseMetadata{},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "case insensitive comparison with lowercased values",
+			expression: "lowercaseLabels['environment'] == 'production'",
+			metadata:   &kyvernov1.LowercaseMetadata{Values: true},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "case sensitive comparison without lowercased values",
+			expression: "lowercaseLabels['environment'] == 'production'",
+			metadata:   &kyvernov1.LowercaseMetadata{},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "original labels are left intact",
+			expression: "object.metadata.labels['Environment'] == 'Production' && !('environment' in object.metadata.labels)",
+			metadata:   &kyvernov1.LowercaseMetadata{Values: true},
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, deployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.LowercaseMetadata = tc.metadata
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_lowercased(t *testing.T) {
+	values := map[string]string{"Environment": "Production", "environment": "staging", "Team": "Payments"}
+	// colliding keys are resolved in lexical order
+	assert.DeepEqual(t, lowercased(values, false), map[string]string{"environment": "Production", "team": "Payments"})
+	assert.DeepEqual(t, lowercased(values, true), map[string]string{"environment": "production", "team": "payments"})
+	assert.DeepEqual(t, lowercased(nil, true), map[string]string{})
+}
+
+func Test_ValidateCEL_GracePeriod(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name        string
+		now         time.Time
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:        "within the grace period",
+			now:         created.Add(time.Hour),
+			wantStatus:  engineapi.RuleStatusWarn,
+			wantMessage: "too many replicas (the rule is enforced once its grace period ends at 2024-01-02T00:00:00Z)",
+		},
+		{
+			name:        "past the grace period",
+			now:         created.Add(25 * time.Hour),
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "too many replicas",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			policy := policyContext.Policy()
+			policy.SetCreationTimestamp(metav1.NewTime(created))
+			rule := policy.GetSpec().Rules[0]
+			rule.Validation.CEL.GracePeriod = &metav1.Duration{Duration: 24 * time.Hour}
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= 2", Message: "too many replicas"},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			h.now = func() time.Time { return tc.now }
+			_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus)
+			assert.Equal(t, responses[0].Message(), tc.wantMessage)
+		})
+	}
+}
+
+func Test_ValidateCEL_Warn(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Warn = true
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= 2", Message: "too many replicas"},
+	}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusWarn)
+	assert.Equal(t, responses[0].Message(), "too many replicas")
+	assert.Assert(t, responses[0].EmitWarning())
+
+	// the resource is admitted
+	response := engineapi.NewEngineResponseFromPolicyContext(policyContext).WithPolicyResponse(engineapi.PolicyResponse{Rules: responses})
+	assert.Assert(t, response.IsSuccessful())
+}
+
+func Test_ValidateCEL_GeneratedValidatingAdmissionPolicy(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	policy := policyContext.Policy().(*kyvernov1.ClusterPolicy)
+	policy.Status.ValidatingAdmissionPolicy.Generated = true
+	rule := policy.GetSpec().Rules[0]
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusSkip)
+	assert.Equal(t, responses[0].Message(), "handled by generated ValidatingAdmissionPolicy")
+}
+
+func Test_ValidateCEL_TransitionRules(t *testing.T) {
+	fewerReplicas := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default"}, "spec": {"replicas": 2}}`
+	testCases := []struct {
+		name        string
+		operation   kyvernov1.AdmissionOperation
+		oldResource string
+		wantStatus  engineapi.RuleStatus
+	}{
+		{
+			name:       "the replicas of created resources aren't compared",
+			operation:  kyvernov1.Create,
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:        "the replicas may increase",
+			operation:   kyvernov1.Update,
+			oldResource: fewerReplicas,
+			wantStatus:  engineapi.RuleStatusPass,
+		},
+		{
+			name:        "the replicas may not decrease",
+			operation:   kyvernov1.Update,
+			oldResource: `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "nginx", "namespace": "default"}, "spec": {"replicas": 5}}`,
+			wantStatus:  engineapi.RuleStatusFail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, tc.operation, celReplicasPolicy, celDeployment, tc.oldResource)
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "oldObject == null || object.spec.replicas >= oldObject.spec.replicas", Message: "replicas may only increase"},
+			}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_GenerateName(t *testing.T) {
+	resource := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"generateName": "nginx-", "namespace": "default"}, "spec": {"replicas": 1}}`
+	testCases := []struct {
+		name       string
+		expression string
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name:       "expression requiring the name of the object",
+			expression: "has(object.metadata.name)",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "expression reading the name of the object when set",
+			expression: "!has(object.metadata.name) || object.metadata.name.startsWith('nginx-')",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "expression reading the name of the object through a string key",
+			expression: "!('name' in object.metadata) && object.metadata[?'name'].orValue('') == ''",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "expression reading the generate name of the object",
+			expression: "object.metadata.generateName.startsWith('nginx-')",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+		{
+			name:       "expression reading the name of the request",
+			expression: "request.name == ''",
+			wantStatus: engineapi.RuleStatusPass,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, resource, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_AllDecisions(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= int(params.data.maxReplicas)", Message: "too many replicas"},
+		{Expression: "object.spec.replicas >= 1", Message: "at least one replica"},
+	}
+	loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+		newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+		newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+	}}
+
+	t.Run("the first failure", func(t *testing.T) {
+		handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+	})
+
+	t.Run("all decisions", func(t *testing.T) {
+		handler, err := NewValidateCELHandler(nil, WithParamLoader(loader), WithAllDecisions())
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		var statuses []engineapi.RuleStatus
+		for _, response := range responses {
+			assert.Equal(t, response.Name(), rule.Name)
+			statuses = append(statuses, response.Status())
+		}
+		assert.DeepEqual(t, statuses, []engineapi.RuleStatus{
+			engineapi.RuleStatusFail, engineapi.RuleStatusPass,
+			engineapi.RuleStatusPass, engineapi.RuleStatusPass,
+		})
+		assert.Equal(t, responses[0].Message(), "too many replicas")
+		assert.DeepEqual(t, responses[0].DeniedParams(), []engineapi.ParamReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "alpha"}})
+	})
+}
+
+// countingValidator counts the evaluations of the wrapped validator
+type countingValidator struct {
+	validatingadmissionpolicy.Validator
+	evaluations *int
+}
+
+func (v countingValidator) Validate(ctx context.Context, matchedResource schema.GroupVersionResource, versionedAttr *admission.VersionedAttributes, versionedParams runtime.Object, namespace *corev1.Namespace, runtimeCELCostBudget int64, authz authorizer.Authorizer) validatingadmissionpolicy.ValidateResult {
+	*v.evaluations++
+	return v.Validator.Validate(ctx, matchedResource, versionedAttr, versionedParams, namespace, runtimeCELCostBudget, authz)
+}
+
+func Test_ValidateCEL_FailFast(t *testing.T) {
+	testCases := []struct {
+		name            string
+		failFast        bool
+		wantEvaluations int
+	}{
+		{
+			name:            "all params are evaluated",
+			wantEvaluations: 3,
+		},
+		{
+			name:            "the remaining params are skipped after a denial",
+			failFast:        true,
+			wantEvaluations: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.FailFast = tc.failFast
+			loader := &fakeParamLoader{
+				namespaced: true,
+				params: []unstructured.Unstructured{
+					newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+					newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "2"}),
+					newConfigMapParam("default", "gamma", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+				},
+			}
+			var lines []string
+			logger := funcr.New(
+				func(prefix, args string) {
+					lines = append(lines, args)
+				},
+				funcr.Options{Verbosity: 4},
+			)
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			evaluations := 0
+			h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+				validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+				return countingValidator{Validator: validator, evaluations: &evaluations}
+			}
+			_, responses := h.Process(context.TODO(), logger, policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail)
+			assert.Equal(t, evaluations, tc.wantEvaluations)
+			// the param that triggered the denial is logged
+			logged := false
+			for _, line := range lines {
+				if strings.Contains(line, `"msg"="skipping the remaining params after a denial"`) && strings.Contains(line, `"param"="default/alpha"`) {
+					logged = true
+				}
+			}
+			assert.Equal(t, logged, tc.failFast)
+		})
+	}
+}
+
+func Test_ValidateCEL_BatchHandler(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	first := policyContext.Policy().GetSpec().Rules[0]
+	first.Validation.CEL.Expressions = append(first.Validation.CEL.Expressions,
+		celNamespaceValidation,
+		admissionregistrationv1alpha1.Validation{Expression: "authorizer.group('apps').resource('deployments').namespace('default').check('create').allowed()"},
+	)
+	second := *first.DeepCopy()
+	second.Name = "check-deployment-again"
+
+	client := &fakeClient{}
+	authorizers := 0
+	handler, err := NewValidateCELBatchHandler(client, WithAuthorizerFactory(func(engineapi.Client, schema.GroupVersionKind) authorizer.Authorizer {
+		authorizers++
+		return authorizer.AuthorizerFunc(func(context.Context, authorizer.Attributes) (authorizer.Decision, string, error) {
+			return authorizer.DecisionAllow, "", nil
+		})
+	}))
+	assert.NilError(t, err)
+	var responses []engineapi.RuleResponse
+	for _, rule := range []kyvernov1.Rule{first, second} {
+		_, ruleResponses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		responses = append(responses, ruleResponses...)
+	}
+	assert.Equal(t, len(responses), 2)
+	assert.Equal(t, responses[0].Name(), first.Name)
+	assert.Equal(t, responses[1].Name(), second.Name)
+	for _, response := range responses {
+		assert.Equal(t, response.Status(), engineapi.RuleStatusPass, response.Message())
+	}
+	assert.Assert(t, responses[0].EvaluationID() != responses[1].EvaluationID())
+	// the rules share the authorizer and the namespace
+	assert.Equal(t, authorizers, 1)
+	assert.Equal(t, client.namespaceCalls, 1)
+
+	// the attributes are created again for another object
+	resource := policyContext.NewResource()
+	other := *resource.DeepCopy()
+	assert.NilError(t, unstructured.SetNestedField(other.Object, int64(10), "spec", "replicas"))
+	_, ruleResponses := handler.Process(context.TODO(), logr.Discard(), policyContext, other, first, nil, nil)
+	assert.Equal(t, len(ruleResponses), 1)
+	assert.Equal(t, ruleResponses[0].Status(), engineapi.RuleStatusFail, ruleResponses[0].Message())
+}
+
+func BenchmarkValidateCEL_Process(b *testing.B) {
+	policyContext := buildContext(b, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	handler, err := NewValidateCELHandler(nil, WithCompilationCache(NewCompilationCache(DefaultCompilationCacheSize)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		if len(responses) != 1 || responses[0].Status() != engineapi.RuleStatusPass {
+			b.Errorf("unexpected responses: %v", responses)
+		}
+	}
+}
+
+// BenchmarkValidateCEL_RepresentativePolicy evaluates a rule with several expressions, a param and an authorization check.
+func BenchmarkValidateCEL_RepresentativePolicy(b *testing.B) {
+	policyContext := buildContext(b, kyvernov1.Create, celParamPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Expressions = append(rule.Validation.CEL.Expressions,
+		admissionregistrationv1alpha1.Validation{Expression: "object.metadata.name != 'forbidden'"},
+		admissionregistrationv1alpha1.Validation{Expression: "!has(object.spec.template) || object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))"},
+		admissionregistrationv1alpha1.Validation{Expression: "authorizer.group('apps').resource('deployments').namespace('default').check('create').allowed()"},
+	)
+	loader := &fakeParamLoader{
+		namespaced: true,
+		params: []unstructured.Unstructured{
+			newConfigMapParam("default", "params", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+		},
+	}
+	allow := authorizer.AuthorizerFunc(func(context.Context, authorizer.Attributes) (authorizer.Decision, string, error) {
+		return authorizer.DecisionAllow, "", nil
+	})
+	handler, err := NewValidateCELHandler(nil,
+		WithParamLoader(loader),
+		WithCompilationCache(NewCompilationCache(DefaultCompilationCacheSize)),
+		WithAuthorizerFactory(func(engineapi.Client, schema.GroupVersionKind) authorizer.Authorizer {
+			return allow
+		}),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		if len(responses) != 1 || responses[0].Status() != engineapi.RuleStatusPass {
+			b.Errorf("unexpected responses: %v", responses)
+		}
+	}
+}
+
+func Test_ValidateCEL_TypedObjects(t *testing.T) {
+	pod := `{
+		"apiVersion": "v1",
+		"kind": "Pod",
+		"metadata": {"name": "nginx", "namespace": "default"},
+		"spec": {"containers": [{"name": "nginx", "image": "nginx", "resources": {"limits": {"cpu": "1000m"}}}]}
+	}`
+	custom := `{
+		"apiVersion": "example.com/v1",
+		"kind": "Widget",
+		"metadata": {"name": "widget", "namespace": "default"},
+		"spec": {"containers": [{"name": "nginx", "image": "nginx", "resources": {"limits": {"cpu": "1000m"}}}]}
+	}`
+	testCases := []struct {
+		name       string
+		resource   string
+		typed      bool
+		wantStatus engineapi.RuleStatus
+	}{{
+		name:       "built-in kind",
+		resource:   pod,
+		wantStatus: engineapi.RuleStatusFail,
+	}, {
+		name:       "typed built-in kind",
+		resource:   pod,
+		typed:      true,
+		wantStatus: engineapi.RuleStatusPass,
+	}, {
+		name:       "typed custom resource",
+		resource:   custom,
+		typed:      true,
+		wantStatus: engineapi.RuleStatusFail,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, tc.resource, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			// quantities are canonical in their typed form
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.containers[0].resources.limits.cpu == '1'", Message: "cpu limit must be 1"},
+			}
+			var options []ValidateCELOption
+			if tc.typed {
+				options = append(options, WithTypedObjects())
+			}
+			handler, err := NewValidateCELHandler(nil, options...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_NoObjects(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Delete, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	h := handler.(validateCELHandler)
+	evaluations := 0
+	h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+		validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+		return countingValidator{Validator: validator, evaluations: &evaluations}
+	}
+	// neither the deleted resource nor its old version are available
+	_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, unstructured.Unstructured{}, rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusSkip)
+	assert.Equal(t, responses[0].Message(), "neither the object nor the old object is available")
+	assert.Equal(t, evaluations, 0)
+}
+
+func Test_ValidateCEL_ReinvocationPolicy(t *testing.T) {
+	ifNeeded := admissionregistrationv1.IfNeededReinvocationPolicy
+	never := admissionregistrationv1.NeverReinvocationPolicy
+	testCases := []struct {
+		name            string
+		policy          *admissionregistrationv1.ReinvocationPolicyType
+		replicas        int64
+		wantStatus      engineapi.RuleStatus
+		wantEvaluations int
+	}{{
+		name:            "not reinvoked by default",
+		replicas:        7,
+		wantStatus:      engineapi.RuleStatusFail,
+		wantEvaluations: 1,
+	}, {
+		name:            "never reinvoked",
+		policy:          &never,
+		replicas:        7,
+		wantStatus:      engineapi.RuleStatusFail,
+		wantEvaluations: 1,
+	}, {
+		name:            "reinvoked against the mutated resource",
+		policy:          &ifNeeded,
+		replicas:        7,
+		wantStatus:      engineapi.RuleStatusPass,
+		wantEvaluations: 2,
+	}, {
+		name:            "not reinvoked when the resource is unchanged",
+		policy:          &ifNeeded,
+		replicas:        3,
+		wantStatus:      engineapi.RuleStatusPass,
+		wantEvaluations: 1,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the policy context holds the mutated resource, with 3 replicas
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.ReinvocationPolicy = tc.policy
+
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			evaluations := 0
+			h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+				validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+				return countingValidator{Validator: validator, evaluations: &evaluations}
+			}
+			resource := policyContext.NewResource()
+			resource = *resource.DeepCopy()
+			assert.NilError(t, unstructured.SetNestedField(resource.Object, tc.replicas, "spec", "replicas"))
+			_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, resource, rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Equal(t, evaluations, tc.wantEvaluations)
+		})
+	}
+}
+
+func Test_ValidateCEL_FieldPaths(t *testing.T) {
+	testCases := []struct {
+		name      string
+		options   []ValidateCELOption
+		wantPaths []string
+	}{{
+		name: "not computed by default",
+	}, {
+		name:      "paths of the denying expression",
+		options:   []ValidateCELOption{WithFieldPaths()},
+		wantPaths: []string{"spec.replicas", "metadata.namespace"},
+	}, {
+		name:      "paths of the aggregated denials",
+		options:   []ValidateCELOption{WithFieldPaths(), WithDenialAggregation(false)},
+		wantPaths: []string{"spec.replicas", "metadata.namespace", "metadata.name"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.metadata.name != ''", Message: "missing name"},
+				{Expression: "object.spec.replicas <= 2 && object.metadata.namespace != ''", Message: "too many replicas"},
+				{Expression: "object.metadata.name == 'other'", Message: "unexpected name"},
+			}
+			handler, err := NewValidateCELHandler(nil, tc.options...)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+			assert.DeepEqual(t, responses[0].FieldPaths(), tc.wantPaths)
+		})
+	}
+}
+
+func Test_ValidateCEL_ValidationType(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression string
+		wantStatus engineapi.RuleStatus
+	}{{
+		name:       "pass",
+		expression: "object.spec.replicas <= 5",
+		wantStatus: engineapi.RuleStatusPass,
+	}, {
+		name:       "fail",
+		expression: "object.spec.replicas <= 2",
+		wantStatus: engineapi.RuleStatusFail,
+	}, {
+		name:       "error",
+		expression: "object.spec.replicas <= unknown",
+		wantStatus: engineapi.RuleStatusError,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: tc.expression}}
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Equal(t, responses[0].ValidationType(), engineapi.CELValidation)
+		})
+	}
+}
+
+// slowValidator delays the evaluations of the wrapped validator
+type slowValidator struct {
+	validatingadmissionpolicy.Validator
+	delay time.Duration
+}
+
+func (v slowValidator) Validate(ctx context.Context, matchedResource schema.GroupVersionResource, versionedAttr *admission.VersionedAttributes, versionedParams runtime.Object, namespace *corev1.Namespace, runtimeCELCostBudget int64, authz authorizer.Authorizer) validatingadmissionpolicy.ValidateResult {
+	time.Sleep(v.delay)
+	return v.Validator.Validate(ctx, matchedResource, versionedAttr, versionedParams, namespace, runtimeCELCostBudget, authz)
+}
+
+func Test_ValidateCEL_Duration(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	testCases := []struct {
+		name          string
+		policy        string
+		preconditions []admissionregistrationv1alpha1.MatchCondition
+		params        []unstructured.Unstructured
+		wantStatus    engineapi.RuleStatus
+		wantDuration  time.Duration
+	}{
+		{
+			name:         "pass",
+			policy:       celReplicasPolicy,
+			wantStatus:   engineapi.RuleStatusPass,
+			wantDuration: delay,
+		},
+		{
+			name:   "skip",
+			policy: celReplicasPolicy,
+			preconditions: []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "production", Expression: "object.metadata.namespace == 'production'"},
+			},
+			wantStatus:   engineapi.RuleStatusSkip,
+			wantDuration: delay,
+		},
+		{
+			name:   "fail, the evaluations against each param are summed",
+			policy: celParamPolicy,
+			params: []unstructured.Unstructured{
+				newConfigMapParam("default", "alpha", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "5"}),
+				newConfigMapParam("default", "beta", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": "1"}),
+			},
+			wantStatus:   engineapi.RuleStatusFail,
+			wantDuration: 2 * delay,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, tc.policy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.CELPreconditions = tc.preconditions
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(&fakeParamLoader{namespaced: true, params: tc.params}))
+			assert.NilError(t, err)
+			h := handler.(validateCELHandler)
+			h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+				validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+				return slowValidator{Validator: validator, delay: delay}
+			}
+			_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Assert(t, responses[0].Duration() >= tc.wantDuration, "duration %s", responses[0].Duration())
+		})
+	}
+}
+
+func Test_ValidateCEL_DryRun(t *testing.T) {
+	dryRun := true
+	testCases := []struct {
+		name       string
+		request    *admissionv1.AdmissionRequest
+		wantStatus engineapi.RuleStatus
+	}{
+		{
+			name:       "outside of an admission request",
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "admission request",
+			request:    &admissionv1.AdmissionRequest{Operation: admissionv1.Create},
+			wantStatus: engineapi.RuleStatusFail,
+		},
+		{
+			name:       "dry run admission request",
+			request:    &admissionv1.AdmissionRequest{Operation: admissionv1.Create, DryRun: &dryRun},
+			wantStatus: engineapi.RuleStatusSkip,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+			if tc.request != nil {
+				assert.NilError(t, policyContext.JSONContext().AddRequest(*tc.request))
+			}
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			// dry runs are not validated
+			rule.CELPreconditions = []admissionregistrationv1alpha1.MatchCondition{
+				{Name: "not-dry-run", Expression: "!request.dryRun"},
+			}
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "object.spec.replicas <= 2", Message: "too many replicas"},
+			}
+			handler, err := NewValidateCELHandler(nil)
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+		})
+	}
+}
+
+func Test_ValidateCEL_RuleTimeout(t *testing.T) {
+	t.Run("slow evaluations time out", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		handler, err := NewValidateCELHandler(nil, WithRuleTimeout(10*time.Millisecond))
+		assert.NilError(t, err)
+		h := handler.(validateCELHandler)
+		h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+			validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+			return slowValidator{Validator: validator, delay: 50 * time.Millisecond}
+		}
+		_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+		assert.Equal(t, responses[0].Message(), "CEL rule timed out after 10ms: "+context.DeadlineExceeded.Error())
+	})
+
+	t.Run("the param fetch is bounded by the rule timeout", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		loader := &slowParamLoader{
+			fakeParamLoader: fakeParamLoader{namespaced: true},
+			delay:           time.Minute,
+		}
+		handler, err := NewValidateCELHandler(nil, WithParamLoader(loader), WithParamFetchTimeout(time.Minute), WithRuleTimeout(10*time.Millisecond))
+		assert.NilError(t, err)
+		_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+		assert.Equal(t, responses[0].Message(), "CEL rule timed out after 10ms: "+context.DeadlineExceeded.Error())
+	})
+
+	t.Run("zero disables the timeout", func(t *testing.T) {
+		policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+		rule := policyContext.Policy().GetSpec().Rules[0]
+		handler, err := NewValidateCELHandler(nil, WithRuleTimeout(0))
+		assert.NilError(t, err)
+		h := handler.(validateCELHandler)
+		h.newValidator = func(validationFilter cel.Filter, celMatcher matchconditions.Matcher, auditAnnotationFilter, messageFilter cel.Filter, failPolicy *admissionregistrationv1.FailurePolicyType) validatingadmissionpolicy.Validator {
+			validator := validatingadmissionpolicy.NewValidator(validationFilter, celMatcher, auditAnnotationFilter, messageFilter, failPolicy)
+			return slowValidator{Validator: validator, delay: 10 * time.Millisecond}
+		}
+		_, responses := h.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+		assert.Equal(t, len(responses), 1)
+		assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+	})
+}
@@ -0,0 +1,27 @@
+package validation
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// typedObject decodes an object of a built-in kind, e.g. a Pod or a Deployment, to its typed form so that its fields
+// are normalized as by the API server, e.g. quantities are canonical. Objects of other kinds, e.g. custom resources,
+// and objects failing to decode are returned as is. Fields unknown to the typed form are dropped.
+func typedObject(obj runtime.Object) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u == nil {
+		return obj
+	}
+	gvk := u.GroupVersionKind()
+	typed, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return obj
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return obj
+	}
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	return typed
+}
@@ -0,0 +1,179 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ValidateCEL_FailedVariable(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Validation.CEL.Variables = []admissionregistrationv1alpha1.Variable{
+		{Name: "maxReplicas", Expression: "int(object.metadata.annotations['max-replicas'])"},
+		{Name: "allowed", Expression: "object.spec.replicas <= variables.maxReplicas"},
+	}
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{{Expression: "variables.allowed"}}
+
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError)
+	// the innermost failed variable is reported
+	assert.Assert(t, strings.HasPrefix(responses[0].Message(), `variable "maxReplicas" failed to evaluate: `), responses[0].Message())
+}
+
+func Test_ValidateCEL_ContextVariables(t *testing.T) {
+	policyContext := buildContext(t, kyvernov1.Create, celReplicasPolicy, celDeployment, "")
+	rule := policyContext.Policy().GetSpec().Rules[0]
+	rule.Context = []kyvernov1.ContextEntry{
+		{Name: "limits", ConfigMap: &kyvernov1.ConfigMapReference{Name: "limits", Namespace: "default"}},
+	}
+	rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.spec.replicas <= int(context.limits.data.maxReplicas)", Message: "too many replicas"},
+	}
+	err := policyContext.JSONContext().AddContextEntry("limits", []byte(`{"data": {"maxReplicas": "5"}, "metadata": {"name": "limits", "namespace": "default"}}`))
+	assert.NilError(t, err)
+
+	// the context entries aren't exposed by default
+	handler, err := NewValidateCELHandler(nil)
+	assert.NilError(t, err)
+	_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusError, responses[0].Message())
+
+	handler, err = NewValidateCELHandler(nil, WithContextVariables())
+	assert.NilError(t, err)
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusPass, responses[0].Message())
+
+	// the expressions see the values loaded by the entries
+	err = policyContext.JSONContext().AddContextEntry("limits", []byte(`{"data": {"maxReplicas": "2"}, "metadata": {"name": "limits", "namespace": "default"}}`))
+	assert.NilError(t, err)
+	_, responses = handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].Status(), engineapi.RuleStatusFail, responses[0].Message())
+	assert.Equal(t, responses[0].Message(), "too many replicas")
+}
+
+func Test_ValidateCEL_ChainedVariables(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxReplicas string
+		variables   []admissionregistrationv1alpha1.Variable
+		wantStatus  engineapi.RuleStatus
+		wantMessage string
+	}{
+		{
+			name:        "allowed by the param",
+			maxReplicas: "5",
+			variables: []admissionregistrationv1alpha1.Variable{
+				{Name: "maxReplicas", Expression: "int(params.data.maxReplicas)"},
+				{Name: "allowed", Expression: "object.spec.replicas <= variables.maxReplicas"},
+			},
+			wantStatus:  engineapi.RuleStatusPass,
+			wantMessage: "Validation rule 'check-replicas' passed.",
+		},
+		{
+			name:        "denied by the param",
+			maxReplicas: "2",
+			variables: []admissionregistrationv1alpha1.Variable{
+				{Name: "maxReplicas", Expression: "int(params.data.maxReplicas)"},
+				{Name: "allowed", Expression: "object.spec.replicas <= variables.maxReplicas"},
+			},
+			wantStatus:  engineapi.RuleStatusFail,
+			wantMessage: "too many replicas",
+		},
+		{
+			name:        "variables declared later can't be referenced",
+			maxReplicas: "5",
+			variables: []admissionregistrationv1alpha1.Variable{
+				{Name: "allowed", Expression: "object.spec.replicas <= variables.maxReplicas"},
+				{Name: "maxReplicas", Expression: "int(params.data.maxReplicas)"},
+			},
+			wantStatus:  engineapi.RuleStatusError,
+			wantMessage: `variable "allowed" failed to compile: `,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyContext := buildContext(t, kyvernov1.Create, celParamPolicy, celDeployment, "")
+			rule := policyContext.Policy().GetSpec().Rules[0]
+			rule.Validation.CEL.Variables = tc.variables
+			rule.Validation.CEL.Expressions = []admissionregistrationv1alpha1.Validation{
+				{Expression: "variables.allowed", Message: "too many replicas"},
+			}
+			loader := &fakeParamLoader{namespaced: true, params: []unstructured.Unstructured{
+				newConfigMapParam("default", "limits", map[string]string{"app": "params"}, map[string]interface{}{"maxReplicas": tc.maxReplicas}),
+			}}
+
+			handler, err := NewValidateCELHandler(nil, WithParamLoader(loader))
+			assert.NilError(t, err)
+			_, responses := handler.Process(context.TODO(), logr.Discard(), policyContext, policyContext.NewResource(), rule, nil, nil)
+			assert.Equal(t, len(responses), 1)
+			assert.Equal(t, responses[0].Status(), tc.wantStatus, responses[0].Message())
+			assert.Assert(t, strings.HasPrefix(responses[0].Message(), tc.wantMessage), responses[0].Message())
+		})
+	}
+}
+
+func Test_failedVariable(t *testing.T) {
+	variables := []admissionregistrationv1alpha1.Variable{
+		{Name: "a", Expression: "variables.b == 1"},
+		{Name: "b", Expression: "undeclared + 1"},
+		{Name: "c", Expression: "object.spec.missing"},
+		{Name: "d", Expression: `variables["c"] == 1`},
+	}
+	compilationErrors := []error{celutils.VariableError{Name: "b", Compilation: true, Err: errors.New("undeclared reference")}}
+	testCases := []struct {
+		name        string
+		expression  string
+		failures    []celutils.VariableError
+		wantName    string
+		wantCompile bool
+	}{
+		{
+			name:        "variable using a variable failing to compile",
+			expression:  "variables.a",
+			failures:    []celutils.VariableError{{Name: "a", Err: errors.New("no such overload")}},
+			wantName:    "b",
+			wantCompile: true,
+		},
+		{
+			name:        "variable failing to compile",
+			expression:  "variables.b > 1",
+			wantName:    "b",
+			wantCompile: true,
+		},
+		{
+			name:       "variable using a variable failing to evaluate",
+			expression: "variables.d",
+			failures:   []celutils.VariableError{{Name: "c", Err: errors.New("no such key: missing")}, {Name: "d", Err: errors.New("no such key: missing")}},
+			wantName:   "c",
+		},
+		{
+			name:       "failures of the variables the expression doesn't use",
+			expression: "object.spec.replicas <= 2",
+			failures:   []celutils.VariableError{{Name: "c", Err: errors.New("no such key: missing")}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failure, ok := failedVariable(tc.expression, variables, compilationErrors, tc.failures)
+			assert.Equal(t, ok, tc.wantName != "")
+			assert.Equal(t, failure.Name, tc.wantName)
+			assert.Equal(t, failure.Compilation, tc.wantCompile)
+		})
+	}
+}
@@ -780,7 +780,7 @@ func Test_VerifyManifest_AtLeastOne(t *testing.T) {
 	assert.Equal(t, verified, true)
 }
 
-func buildContext(t *testing.T, operation kyvernov1.AdmissionOperation, policy, resource string, oldResource string) engineapi.PolicyContext {
+func buildContext(t testing.TB, operation kyvernov1.AdmissionOperation, policy, resource string, oldResource string) engineapi.PolicyContext {
 	var cpol kyvernov1.ClusterPolicy
 	err := json.Unmarshal([]byte(policy), &cpol)
 	assert.NilError(t, err)
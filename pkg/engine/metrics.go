@@ -19,7 +19,7 @@ func (e *engine) reportMetrics(
 	admissionOperation bool,
 	response engineapi.EngineResponse,
 ) {
-	if e.resultCounter == nil && e.durationHistogram == nil {
+	if e.resultCounter == nil && e.durationHistogram == nil && e.authorizerCallsCounter == nil && e.authorizerShortCircuitsCounter == nil {
 		return
 	}
 	policy := response.Policy().AsKyvernoPolicy()
@@ -91,6 +91,36 @@ func (e *engine) reportMetrics(
 				}
 				e.durationHistogram.Record(ctx, rule.Stats().ProcessingTime().Seconds(), metric.WithAttributes(commonLabels...))
 			}
+			if e.authorizerCallsCounter != nil {
+				calls := rule.AuthorizerCalls()
+				commonLabels := []attribute.KeyValue{
+					attribute.String("policy_type", string(policyType)),
+					attribute.String("policy_namespace", namespace),
+					attribute.String("policy_name", name),
+					attribute.String("resource_kind", resourceKind),
+					attribute.String("resource_namespace", resourceNamespace),
+					attribute.String("rule_name", ruleName),
+					attribute.String("rule_execution_cause", string(executionCause)),
+				}
+				if calls.Requests != 0 {
+					labels := append(commonLabels, attribute.Bool("cached", false))
+					e.authorizerCallsCounter.Add(ctx, int64(calls.Requests), metric.WithAttributes(labels...))
+				}
+				if calls.CacheHits != 0 {
+					labels := append(commonLabels, attribute.Bool("cached", true))
+					e.authorizerCallsCounter.Add(ctx, int64(calls.CacheHits), metric.WithAttributes(labels...))
+				}
+			}
+			if e.authorizerShortCircuitsCounter != nil {
+				if calls := rule.AuthorizerCalls(); calls.ShortCircuits != 0 {
+					e.authorizerShortCircuitsCounter.Add(ctx, int64(calls.ShortCircuits), metric.WithAttributes(
+						attribute.String("policy_type", string(policyType)),
+						attribute.String("policy_namespace", namespace),
+						attribute.String("policy_name", name),
+						attribute.String("rule_name", ruleName),
+					))
+				}
+			}
 		}
 	}
 }
@@ -27,6 +27,8 @@ func (e *engine) validate(
 	defer policyContext.JSONContext().Restore()
 
 	gvk, _ := policyContext.ResourceKind()
+	// the CEL rules of the policy are evaluated by the same handler, they share the evaluation of the resource
+	var celHandler handlers.Handler
 	for _, rule := range autogen.ComputeRules(policy, gvk.Kind) {
 		startTime := time.Now()
 		logger := internal.LoggerWithRule(logger, rule)
@@ -48,7 +50,14 @@ func (e *engine) validate(
 				} else if hasValidatePss {
 					return validation.NewValidatePssHandler()
 				} else if hasValidateCEL {
-					return validation.NewValidateCELHandler(e.client)
+					if celHandler == nil {
+						handler, err := validation.NewValidateCELBatchHandler(e.client, e.validateCELOptions...)
+						if err != nil {
+							return nil, err
+						}
+						celHandler = handler
+					}
+					return celHandler, nil
 				} else {
 					return validation.NewValidateResourceHandler()
 				}
@@ -13,6 +13,7 @@ import (
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
 	enginecontext "github.com/kyverno/kyverno/pkg/engine/context"
 	"github.com/kyverno/kyverno/pkg/engine/factories"
+	"github.com/kyverno/kyverno/pkg/engine/handlers/validation"
 	"github.com/kyverno/kyverno/pkg/imageverifycache"
 	"github.com/kyverno/kyverno/pkg/registryclient"
 	admissionutils "github.com/kyverno/kyverno/pkg/utils/admission"
@@ -28,6 +29,7 @@ func testValidate(
 	pContext *PolicyContext,
 	cfg config.Configuration,
 	contextLoader engineapi.ContextLoaderFactory,
+	options ...Option,
 ) engineapi.EngineResponse {
 	if contextLoader == nil {
 		contextLoader = factories.DefaultContextLoaderFactory(nil)
@@ -41,6 +43,7 @@ func testValidate(
 		imageverifycache.DisabledImageVerifyCache(),
 		contextLoader,
 		nil,
+		options...,
 	)
 	return e.Validate(
 		ctx,
@@ -3310,3 +3313,67 @@ func Test_ValidatePattern_anyPattern(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_CEL_PassExplanation(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {
+			"name": "check-replicas"
+		},
+		"spec": {
+			"rules": [
+				{
+					"name": "check-replicas",
+					"match": {
+						"any": [
+							{
+								"resources": {
+									"kinds": [
+										"Deployment"
+									]
+								}
+							}
+						]
+					},
+					"validate": {
+						"cel": {
+							"expressions": [
+								{
+									"expression": "object.spec.replicas <= 5"
+								}
+							]
+						}
+					}
+				}
+			]
+		}
+	}`)
+	rawResource := []byte(`{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {
+			"name": "nginx"
+		},
+		"spec": {
+			"replicas": 3
+		}
+	}`)
+
+	var policy kyvernov1.ClusterPolicy
+	err := json.Unmarshal(rawPolicy, &policy)
+	assert.NilError(t, err)
+	resourceUnstructured, err := kubeutils.BytesToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	er := testValidate(context.TODO(), registryclient.NewOrDie(), newPolicyContext(t, *resourceUnstructured, kyvernov1.Create, nil).WithPolicy(&policy), cfg, nil)
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.PolicyResponse.Rules[0].ExpressionResults() == nil)
+
+	er = testValidate(context.TODO(), registryclient.NewOrDie(), newPolicyContext(t, *resourceUnstructured, kyvernov1.Create, nil).WithPolicy(&policy), cfg, nil, WithValidateCELOptions(validation.WithPassExplanation()))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Status(), engineapi.RuleStatusPass)
+	assert.DeepEqual(t, er.PolicyResponse.Rules[0].ExpressionResults(), []engineapi.ExpressionResult{
+		{Expression: "object.spec.replicas <= 5", Result: true},
+	})
+}
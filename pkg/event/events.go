@@ -351,6 +351,22 @@ func NewValidatingAdmissionPolicyEvent(policy kyvernov1.PolicyInterface, vapName
 	return []Info{vapEvent, vapBindingEvent}
 }
 
+func NewRuleErrorEvent(policy kyvernov1.PolicyInterface, source Source, ruleResp engineapi.RuleResponse) Info {
+	return Info{
+		Regarding: corev1.ObjectReference{
+			APIVersion: "kyverno.io/v1",
+			Kind:       policy.GetKind(),
+			Name:       policy.GetName(),
+			Namespace:  policy.GetNamespace(),
+			UID:        policy.GetUID(),
+		},
+		Source:  source,
+		Reason:  PolicyError,
+		Message: fmt.Sprintf("rule %s failed: %s", ruleResp.Name(), ruleResp.Message()),
+		Action:  None,
+	}
+}
+
 func NewFailedEvent(err error, policy, rule string, source Source, resource kyvernov1.ResourceSpec) Info {
 	return Info{
 		Regarding: corev1.ObjectReference{
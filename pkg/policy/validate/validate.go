@@ -3,22 +3,58 @@ package validate
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/engine/anchor"
 	"github.com/kyverno/kyverno/pkg/policy/common"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 )
 
+// DefaultMaxAuditAnnotations is the default maximum number of audit annotations of a CEL rule.
+const DefaultMaxAuditAnnotations = 50
+
+// Options are the settings of the checks depending on the configuration of Kyverno.
+type Options struct {
+	// MaxAuditAnnotations is the maximum number of audit annotations of a CEL rule, unbounded when zero
+	MaxAuditAnnotations int
+	// ExternalDataURLs is the allow-list of the URLs of external data sources, they aren't checked when nil,
+	// e.g. by the CLI which doesn't fetch them
+	ExternalDataURLs *celutils.ExternalDataURLs
+}
+
+// DefaultOptions returns the options matching the defaults of the admission controller flags.
+func DefaultOptions() Options {
+	return Options{
+		MaxAuditAnnotations: DefaultMaxAuditAnnotations,
+	}
+}
+
+// fieldPathRegex matches dot separated field paths made of CEL identifiers, e.g. `data.registries`
+var fieldPathRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+
 // Validate validates a 'validate' rule
 type Validate struct {
 	// rule to hold 'validate' rule specifications
 	rule *kyvernov1.Validation
+	// celPreconditions are the CEL preconditions of the rule, they are evaluated against the same objects as the CEL expressions
+	celPreconditions []admissionregistrationv1alpha1.MatchCondition
+	// options are the settings of the checks depending on the configuration of Kyverno
+	options Options
 }
 
 // NewValidateFactory returns a new instance of Mutate validation checker
-func NewValidateFactory(rule *kyvernov1.Validation) *Validate {
+func NewValidateFactory(rule *kyvernov1.Validation, celPreconditions []admissionregistrationv1alpha1.MatchCondition, options Options) *Validate {
 	m := Validate{
-		rule: rule,
+		rule:             rule,
+		celPreconditions: celPreconditions,
+		options:          options,
 	}
 
 	return &m
@@ -84,6 +120,10 @@ func (v *Validate) Validate(ctx context.Context) (string, error) {
 				return "", fmt.Errorf("cel.paramKind.kind is required")
 			}
 
+			if _, err := celutils.ParseParamKind(v.rule.CEL.ParamKind); err != nil {
+				return "cel.paramKind.apiVersion", err
+			}
+
 			if v.rule.CEL.ParamRef == nil {
 				return "", fmt.Errorf("cel.paramRef is required")
 			}
@@ -107,6 +147,10 @@ func (v *Validate) Validate(ctx context.Context) (string, error) {
 			}
 		}
 
+		if max := v.options.MaxAuditAnnotations; max > 0 && len(v.rule.CEL.AuditAnnotations) > max {
+			return "cel.auditAnnotations", fmt.Errorf("the rule declares %d audit annotations, the maximum is %d", len(v.rule.CEL.AuditAnnotations), max)
+		}
+
 		if v.rule.CEL.AuditAnnotations != nil {
 			for _, auditAnnotation := range v.rule.CEL.AuditAnnotations {
 				if auditAnnotation.Key == "" {
@@ -118,11 +162,241 @@ func (v *Validate) Validate(ctx context.Context) (string, error) {
 				}
 			}
 		}
+
+		for i, keys := range v.rule.CEL.MutuallyExclusive {
+			if len(keys.Labels)+len(keys.Annotations) < 2 {
+				return fmt.Sprintf("cel.mutuallyExclusive[%d]", i), fmt.Errorf("at least two label or annotation keys are required")
+			}
+		}
+
+		if allowedRegistries := v.rule.CEL.AllowedRegistries; allowedRegistries != nil {
+			if len(allowedRegistries.Registries) == 0 && allowedRegistries.ParamField == "" {
+				return "cel.allowedRegistries", fmt.Errorf("one of registries or paramField must be set")
+			}
+			if allowedRegistries.ParamField != "" {
+				if v.rule.CEL.ParamKind == nil {
+					return "cel.allowedRegistries.paramField", fmt.Errorf("cel.paramKind is required")
+				}
+				if !fieldPathRegex.MatchString(allowedRegistries.ParamField) {
+					return "cel.allowedRegistries.paramField", fmt.Errorf("invalid field path %q", allowedRegistries.ParamField)
+				}
+			}
+		}
+
+		for i, owner := range v.rule.CEL.RequiredOwners {
+			if owner.Kind == "" {
+				return fmt.Sprintf("cel.requiredOwners[%d].kind", i), fmt.Errorf("kind is required")
+			}
+		}
+
+		for i, kind := range v.rule.CEL.RelatedResources {
+			if kind.APIVersion == "" {
+				return fmt.Sprintf("cel.relatedResources[%d].apiVersion", i), fmt.Errorf("apiVersion is required")
+			}
+			if kind.Kind == "" {
+				return fmt.Sprintf("cel.relatedResources[%d].kind", i), fmt.Errorf("kind is required")
+			}
+		}
+
+		if externalData := v.rule.CEL.ExternalData; externalData != nil {
+			if externalData.URL == "" {
+				return "cel.externalData.url", fmt.Errorf("url is required")
+			}
+			if u, err := url.Parse(externalData.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "cel.externalData.url", fmt.Errorf("invalid HTTP or HTTPS URL %q", externalData.URL)
+			}
+			if urls := v.options.ExternalDataURLs; urls != nil {
+				if err := urls.Check(externalData.URL); err != nil {
+					return "cel.externalData.url", err
+				}
+			}
+			if externalData.CacheTTL.Duration <= 0 {
+				return "cel.externalData.cacheTTL", fmt.Errorf("a positive cacheTTL is required")
+			}
+		}
+
+		for i, allowed := range v.rule.CEL.AllowedFields {
+			if allowed.Path != "" && !fieldPathRegex.MatchString(allowed.Path) {
+				return fmt.Sprintf("cel.allowedFields[%d].path", i), fmt.Errorf("invalid field path %q", allowed.Path)
+			}
+			if len(allowed.Fields) == 0 {
+				return fmt.Sprintf("cel.allowedFields[%d].fields", i), fmt.Errorf("at least one field is required")
+			}
+		}
+
+		if gracePeriod := v.rule.CEL.GracePeriod; gracePeriod != nil && gracePeriod.Duration <= 0 {
+			return "cel.gracePeriod", fmt.Errorf("a positive gracePeriod is required")
+		}
+
+		if costBudget := v.rule.CEL.CostBudget; costBudget != nil && (*costBudget <= 0 || *costBudget > celutils.MaxRuntimeCostBudget) {
+			return "cel.costBudget", fmt.Errorf("the costBudget must be positive and at most %d", celutils.MaxRuntimeCostBudget)
+		}
+
+		if v.rule.CEL.ExcludeSelfFromParams && !v.rule.CEL.HasParam() {
+			return "cel.excludeSelfFromParams", fmt.Errorf("paramKind and paramRef are required to exclude the resource from its params")
+		}
+
+		if v.rule.CEL.FailFast && !v.rule.CEL.HasParam() {
+			return "cel.failFast", fmt.Errorf("paramKind and paramRef are required to fail fast")
+		}
+
+		if v.rule.CEL.EvaluateWithoutParams && !v.rule.CEL.HasParam() {
+			return "cel.evaluateWithoutParams", fmt.Errorf("paramKind and paramRef are required to evaluate the expressions without params as well")
+		}
+
+		if v.rule.CEL.ParamsFromOwners {
+			if !v.rule.CEL.HasParam() {
+				return "cel.paramsFromOwners", fmt.Errorf("paramKind and paramRef are required to select params by owner")
+			}
+			// the params of the owners are filtered out of a selection, a single param isn't owned by every owner
+			if v.rule.CEL.ParamRef.Selector == nil {
+				return "cel.paramsFromOwners", fmt.Errorf("paramRef.selector is required to select params by owner")
+			}
+			// owners and their dependents share their namespace
+			if v.rule.CEL.ParamRef.Namespace != "" || v.rule.CEL.ParamNamespaceSelector != nil {
+				return "cel.paramsFromOwners", fmt.Errorf("params selected by owner can't be collected from other namespaces, paramRef.namespace and paramNamespaceSelector must not be set")
+			}
+		}
+
+		if v.rule.CEL.ParamFieldSelector != "" {
+			if !v.rule.CEL.HasParam() {
+				return "cel.paramFieldSelector", fmt.Errorf("paramKind and paramRef are required to select params by their fields")
+			}
+			if _, err := fields.ParseSelector(v.rule.CEL.ParamFieldSelector); err != nil {
+				return "cel.paramFieldSelector", err
+			}
+		}
+
+		if len(v.rule.CEL.AdditionalParams) != 0 && !v.rule.CEL.HasParam() {
+			return "cel.additionalParams", fmt.Errorf("paramKind and paramRef are required to reference additional params")
+		}
+		for i, additional := range v.rule.CEL.AdditionalParams {
+			path := fmt.Sprintf("cel.additionalParams[%d]", i)
+			if additional.ParamKind.APIVersion == "" || additional.ParamKind.Kind == "" {
+				return path + ".paramKind", fmt.Errorf("apiVersion and kind are required")
+			}
+			if _, err := celutils.ParseParamKind(&additional.ParamKind); err != nil {
+				return path + ".paramKind.apiVersion", err
+			}
+			if (additional.ParamRef.Name == "") == (additional.ParamRef.Selector == nil) {
+				return path + ".paramRef", fmt.Errorf("one of name or selector must be set")
+			}
+			if additional.ParamRef.ParameterNotFoundAction == nil {
+				return path + ".paramRef", fmt.Errorf("parameterNotFoundAction is required")
+			}
+		}
+
+		if v.rule.CEL.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(v.rule.CEL.NamespaceSelector); err != nil {
+				return "cel.namespaceSelector", err
+			}
+		}
+
+		if v.rule.CEL.ParamNamespaceSelector != nil {
+			if v.rule.CEL.ParamRef == nil {
+				return "", fmt.Errorf("cel.paramNamespaceSelector requires cel.paramRef")
+			}
+			if v.rule.CEL.ParamRef.Namespace != "" {
+				return "", fmt.Errorf("only one of cel.paramRef.namespace or cel.paramNamespaceSelector can be set")
+			}
+			if _, err := metav1.LabelSelectorAsSelector(v.rule.CEL.ParamNamespaceSelector); err != nil {
+				return "cel.paramNamespaceSelector", err
+			}
+		}
+
+		for i, redaction := range v.rule.CEL.MessageRedactions {
+			if !strings.HasPrefix(redaction, "/") || redaction == "/" {
+				return fmt.Sprintf("cel.messageRedactions[%d]", i), fmt.Errorf("invalid JSON pointer %q", redaction)
+			}
+		}
+
+		if len(v.rule.CEL.FieldProjection) != 0 {
+			if err := v.validateFieldProjection(); err != nil {
+				return "cel.fieldProjection", err
+			}
+		}
 	}
 
 	return "", nil
 }
 
+// validateFieldProjection checks that the CEL expressions only reference fields of the object and the old object
+// that are kept by the field projection
+func (v *Validate) validateFieldProjection() error {
+	// apiVersion and kind are always kept
+	paths := [][]string{{"apiVersion"}, {"kind"}}
+	for _, path := range v.rule.CEL.FieldProjection {
+		fields := strings.Split(path, ".")
+		for _, field := range fields {
+			if field == "" {
+				return fmt.Errorf("invalid field path %q", path)
+			}
+		}
+		paths = append(paths, fields)
+	}
+	var expressions []string
+	for _, e := range v.rule.CEL.Expressions {
+		expressions = append(expressions, e.Expression, e.MessageExpression)
+	}
+	for _, a := range v.rule.CEL.AuditAnnotations {
+		expressions = append(expressions, a.ValueExpression)
+	}
+	for _, variable := range v.rule.CEL.Variables {
+		expressions = append(expressions, variable.Expression)
+	}
+	for _, precondition := range v.celPreconditions {
+		expressions = append(expressions, precondition.Expression)
+	}
+	expressions = append(expressions, v.rule.CEL.SuggestionExpression)
+	// mutually exclusive keys are looked up in the labels and annotations of the object
+	for _, keys := range v.rule.CEL.MutuallyExclusive {
+		if len(keys.Labels) != 0 && !isProjected([]string{"metadata", "labels"}, paths) {
+			return fmt.Errorf("mutuallyExclusive references metadata.labels which is not part of the field projection")
+		}
+		if len(keys.Annotations) != 0 && !isProjected([]string{"metadata", "annotations"}, paths) {
+			return fmt.Errorf("mutuallyExclusive references metadata.annotations which is not part of the field projection")
+		}
+	}
+	// the fields of allow-lists must be kept entirely
+	for _, allowed := range v.rule.CEL.AllowedFields {
+		if allowed.Path == "" {
+			return fmt.Errorf("allowedFields references the root of the object which is not part of the field projection")
+		}
+		if !isProjected(strings.Split(allowed.Path, "."), paths) {
+			return fmt.Errorf("allowedFields references %s which is not part of the field projection", allowed.Path)
+		}
+	}
+	// required owners are looked up in the owner references of the object
+	if len(v.rule.CEL.RequiredOwners) != 0 && !isProjected([]string{"metadata", "ownerReferences"}, paths) {
+		return fmt.Errorf("requiredOwners references metadata.ownerReferences which is not part of the field projection")
+	}
+	for _, expression := range expressions {
+		if expression == "" {
+			continue
+		}
+		references, err := celutils.FieldReferences(expression, "object", "oldObject")
+		if err != nil {
+			return err
+		}
+		for _, reference := range references {
+			if !isProjected(reference[1:], paths) {
+				return fmt.Errorf("expression %q references %s which is not part of the field projection", expression, strings.Join(reference, "."))
+			}
+		}
+	}
+	return nil
+}
+
+// isProjected returns true if the field is one of the projected paths or a descendant of one
+func isProjected(field []string, paths [][]string) bool {
+	for _, path := range paths {
+		if len(field) >= len(path) && slices.Equal(field[:len(path)], path) {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *Validate) validateElements() error {
 	count := validationElemCount(v.rule)
 	if count == 0 {
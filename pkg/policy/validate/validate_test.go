@@ -3,10 +3,15 @@ package validate
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	kyverno "github.com/kyverno/kyverno/api/kyverno/v1"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
 	"gotest.tools/assert"
+	"k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func Test_Validate_OverlayPattern_Empty(t *testing.T) {
@@ -17,7 +22,7 @@ func Test_Validate_OverlayPattern_Empty(t *testing.T) {
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
 
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -31,7 +36,7 @@ func Test_Validate_OverlayPattern_Nil_PatternAnypattern(t *testing.T) {
 	var validation kyverno.Validation
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -69,7 +74,7 @@ func Test_Validate_OverlayPattern_Exist_PatternAnypattern(t *testing.T) {
 	var validation kyverno.Validation
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -107,7 +112,7 @@ func Test_Validate_OverlayPattern_Valid(t *testing.T) {
 	var validation kyverno.Validation
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.NilError(t, err)
 	}
@@ -140,7 +145,7 @@ func Test_Validate_ExistingAnchor_AnchorOnMap(t *testing.T) {
 	var validation kyverno.Validation
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -170,7 +175,7 @@ func Test_Validate_ExistingAnchor_AnchorOnString(t *testing.T) {
 	var validation kyverno.Validation
 	err := json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -203,7 +208,7 @@ func Test_Validate_ExistingAnchor_Valid(t *testing.T) {
 
 	err = json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validation)
+	checker := NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -228,7 +233,7 @@ func Test_Validate_ExistingAnchor_Valid(t *testing.T) {
 	 }	`)
 	err = json.Unmarshal(rawValidation, &validation)
 	assert.NilError(t, err)
-	checker = NewValidateFactory(&validation)
+	checker = NewValidateFactory(&validation, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -269,7 +274,7 @@ func Test_Validate_Validate_ValidAnchor(t *testing.T) {
 	err = json.Unmarshal(rawValidate, &validate)
 	assert.NilError(t, err)
 
-	checker := NewValidateFactory(&validate)
+	checker := NewValidateFactory(&validate, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.NilError(t, err)
 	}
@@ -291,7 +296,7 @@ func Test_Validate_Validate_ValidAnchor(t *testing.T) {
 	err = json.Unmarshal(rawValidate, &validate)
 	assert.NilError(t, err)
 
-	checker = NewValidateFactory(&validate)
+	checker = NewValidateFactory(&validate, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.NilError(t, err)
 	}
@@ -318,7 +323,7 @@ func Test_Validate_Validate_Mismatched(t *testing.T) {
 	var validate kyverno.Validation
 	err := json.Unmarshal(rawValidate, &validate)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validate)
+	checker := NewValidateFactory(&validate, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -348,7 +353,7 @@ func Test_Validate_Validate_Unsupported(t *testing.T) {
 
 	err = json.Unmarshal(rawValidate, &validate)
 	assert.NilError(t, err)
-	checker := NewValidateFactory(&validate)
+	checker := NewValidateFactory(&validate, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
@@ -374,9 +379,966 @@ func Test_Validate_Validate_Unsupported(t *testing.T) {
 	err = json.Unmarshal(rawValidate, &validate)
 	assert.NilError(t, err)
 
-	checker = NewValidateFactory(&validate)
+	checker = NewValidateFactory(&validate, nil, DefaultOptions())
 	if _, err := checker.Validate(context.TODO()); err != nil {
 		assert.Assert(t, err != nil)
 	}
 
 }
+
+func Test_Validate_CEL_FieldProjection(t *testing.T) {
+	testCases := []struct {
+		name         string
+		projection   []string
+		expression   string
+		precondition string
+		suggestion   string
+		wantErr      bool
+	}{
+		{
+			name:       "projected field",
+			projection: []string{"spec.replicas"},
+			expression: "object.spec.replicas <= 5",
+		},
+		{
+			name:       "field below a projected path",
+			projection: []string{"metadata.labels"},
+			expression: "object.metadata.labels.app == 'nginx' && oldObject.metadata.labels['app'] == 'nginx'",
+		},
+		{
+			name:       "always projected fields",
+			projection: []string{"spec.replicas"},
+			expression: "object.kind == 'Deployment' && object.apiVersion == 'apps/v1'",
+		},
+		{
+			name:       "field outside of the projection",
+			projection: []string{"spec.replicas"},
+			expression: "object.metadata.name != 'forbidden'",
+			wantErr:    true,
+		},
+		{
+			name:       "field outside of the projection using index",
+			projection: []string{"spec.replicas"},
+			expression: "oldObject['metadata']['name'] != 'forbidden'",
+			wantErr:    true,
+		},
+		{
+			name:       "parent of a projected path",
+			projection: []string{"spec.replicas"},
+			expression: "object.spec.all(k, k != 'forbidden')",
+			wantErr:    true,
+		},
+		{
+			name:       "whole object",
+			projection: []string{"spec.replicas"},
+			expression: "size(object) > 0",
+			wantErr:    true,
+		},
+		{
+			name:         "projected field in a precondition",
+			projection:   []string{"spec.replicas", "metadata.labels"},
+			expression:   "object.spec.replicas <= 5",
+			precondition: "object.metadata.labels['team'] == 'payments'",
+		},
+		{
+			name:         "field outside of the projection in a precondition",
+			projection:   []string{"spec.replicas"},
+			expression:   "object.spec.replicas <= 5",
+			precondition: "object.metadata.namespace == 'production'",
+			wantErr:      true,
+		},
+		{
+			name:       "projected field in the suggestion",
+			projection: []string{"spec.replicas"},
+			expression: "object.spec.replicas <= 5",
+			suggestion: "'replicas: ' + string(object.spec.replicas)",
+		},
+		{
+			name:       "field outside of the projection in the suggestion",
+			projection: []string{"spec.replicas"},
+			expression: "object.spec.replicas <= 5",
+			suggestion: "'name: ' + object.metadata.name",
+			wantErr:    true,
+		},
+		{
+			name:       "empty path segment",
+			projection: []string{"spec..replicas"},
+			expression: "object.spec.replicas <= 5",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					Expressions:          []v1alpha1.Validation{{Expression: tc.expression}},
+					FieldProjection:      tc.projection,
+					SuggestionExpression: tc.suggestion,
+				},
+			}
+			var preconditions []v1alpha1.MatchCondition
+			if tc.precondition != "" {
+				preconditions = append(preconditions, v1alpha1.MatchCondition{Name: "precondition", Expression: tc.precondition})
+			}
+			_, err := NewValidateFactory(&validation, preconditions, DefaultOptions()).Validate(context.TODO())
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_MutuallyExclusive(t *testing.T) {
+	testCases := []struct {
+		name       string
+		keys       kyverno.MutuallyExclusiveKeys
+		projection []string
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name: "labels and annotations",
+			keys: kyverno.MutuallyExclusiveKeys{Labels: []string{"team-a"}, Annotations: []string{"team-b"}},
+		},
+		{
+			name:     "single key",
+			keys:     kyverno.MutuallyExclusiveKeys{Labels: []string{"team-a"}},
+			wantPath: "cel.mutuallyExclusive[0]",
+			wantErr:  true,
+		},
+		{
+			name:       "projected labels",
+			keys:       kyverno.MutuallyExclusiveKeys{Labels: []string{"team-a", "team-b"}},
+			projection: []string{"metadata.labels"},
+		},
+		{
+			name:       "labels outside of the projection",
+			keys:       kyverno.MutuallyExclusiveKeys{Labels: []string{"team-a", "team-b"}},
+			projection: []string{"metadata.annotations"},
+			wantPath:   "cel.fieldProjection",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					MutuallyExclusive: []kyverno.MutuallyExclusiveKeys{tc.keys},
+					FieldProjection:   tc.projection,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_AllowedRegistries(t *testing.T) {
+	paramKind := &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	testCases := []struct {
+		name       string
+		registries kyverno.AllowedRegistries
+		paramKind  *v1alpha1.ParamKind
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:       "inline registries",
+			registries: kyverno.AllowedRegistries{Registries: []string{"ghcr.io"}},
+		},
+		{
+			name:     "no registries",
+			wantPath: "cel.allowedRegistries",
+			wantErr:  true,
+		},
+		{
+			name:       "param field",
+			registries: kyverno.AllowedRegistries{ParamField: "data.registries"},
+			paramKind:  paramKind,
+		},
+		{
+			name:       "param field without param kind",
+			registries: kyverno.AllowedRegistries{ParamField: "data.registries"},
+			wantPath:   "cel.allowedRegistries.paramField",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid param field",
+			registries: kyverno.AllowedRegistries{ParamField: "data..registries"},
+			paramKind:  paramKind,
+			wantPath:   "cel.allowedRegistries.paramField",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					AllowedRegistries: &tc.registries,
+				},
+			}
+			if tc.paramKind != nil {
+				notFoundAction := v1alpha1.DenyAction
+				validation.CEL.ParamKind = tc.paramKind
+				validation.CEL.ParamRef = &v1alpha1.ParamRef{Name: "registries", ParameterNotFoundAction: &notFoundAction}
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_RequiredOwners(t *testing.T) {
+	testCases := []struct {
+		name       string
+		owners     []kyverno.RequiredOwner
+		projection []string
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:   "owner kinds",
+			owners: []kyverno.RequiredOwner{{APIGroup: "apps", Kind: "ReplicaSet"}, {Kind: "Node"}},
+		},
+		{
+			name:     "missing kind",
+			owners:   []kyverno.RequiredOwner{{APIGroup: "apps", Kind: "ReplicaSet"}, {APIGroup: "batch"}},
+			wantPath: "cel.requiredOwners[1].kind",
+			wantErr:  true,
+		},
+		{
+			name:       "projected owner references",
+			owners:     []kyverno.RequiredOwner{{APIGroup: "apps", Kind: "ReplicaSet"}},
+			projection: []string{"metadata.ownerReferences"},
+		},
+		{
+			name:       "owner references outside of the projection",
+			owners:     []kyverno.RequiredOwner{{APIGroup: "apps", Kind: "ReplicaSet"}},
+			projection: []string{"metadata.labels"},
+			wantPath:   "cel.fieldProjection",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					RequiredOwners:  tc.owners,
+					FieldProjection: tc.projection,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_RelatedResources(t *testing.T) {
+	testCases := []struct {
+		name     string
+		kinds    []kyverno.RelatedResourceKind
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:  "related kinds",
+			kinds: []kyverno.RelatedResourceKind{{APIVersion: "apps/v1", Kind: "Deployment"}, {APIVersion: "v1", Kind: "Service"}},
+		},
+		{
+			name:     "missing api version",
+			kinds:    []kyverno.RelatedResourceKind{{Kind: "Deployment"}},
+			wantPath: "cel.relatedResources[0].apiVersion",
+			wantErr:  true,
+		},
+		{
+			name:     "missing kind",
+			kinds:    []kyverno.RelatedResourceKind{{APIVersion: "apps/v1", Kind: "Deployment"}, {APIVersion: "v1"}},
+			wantPath: "cel.relatedResources[1].kind",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					RelatedResources: tc.kinds,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_ExternalData(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     kyverno.ExternalData
+		urls     string
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name: "external data",
+			data: kyverno.ExternalData{URL: "https://registry.example.com/allowed", CacheTTL: metav1.Duration{Duration: time.Minute}},
+		},
+		{
+			name:     "missing url",
+			data:     kyverno.ExternalData{CacheTTL: metav1.Duration{Duration: time.Minute}},
+			wantPath: "cel.externalData.url",
+			wantErr:  true,
+		},
+		{
+			name:     "url without http scheme",
+			data:     kyverno.ExternalData{URL: "file:///etc/allowed.json", CacheTTL: metav1.Duration{Duration: time.Minute}},
+			wantPath: "cel.externalData.url",
+			wantErr:  true,
+		},
+		{
+			name:     "missing cache ttl",
+			data:     kyverno.ExternalData{URL: "https://registry.example.com/allowed"},
+			wantPath: "cel.externalData.cacheTTL",
+			wantErr:  true,
+		},
+		{
+			name: "allowed url",
+			data: kyverno.ExternalData{URL: "https://registry.example.com/allowed", CacheTTL: metav1.Duration{Duration: time.Minute}},
+			urls: "registry.example.com",
+		},
+		{
+			name:     "url outside of the allow-list",
+			data:     kyverno.ExternalData{URL: "https://registry.example.org/allowed", CacheTTL: metav1.Duration{Duration: time.Minute}},
+			urls:     "registry.example.com",
+			wantPath: "cel.externalData.url",
+			wantErr:  true,
+		},
+		{
+			name:     "link-local url",
+			data:     kyverno.ExternalData{URL: "http://169.254.169.254/latest/meta-data", CacheTTL: metav1.Duration{Duration: time.Minute}},
+			urls:     "169.254.169.254",
+			wantPath: "cel.externalData.url",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					ExternalData: &tc.data,
+				},
+			}
+			options := DefaultOptions()
+			if tc.urls != "" {
+				urls, err := celutils.ParseExternalDataURLs(tc.urls, false)
+				assert.NilError(t, err)
+				options.ExternalDataURLs = &urls
+			}
+			path, err := NewValidateFactory(&validation, nil, options).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_AllowedFields(t *testing.T) {
+	testCases := []struct {
+		name       string
+		allowed    kyverno.AllowedFields
+		projection []string
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:    "allowed fields",
+			allowed: kyverno.AllowedFields{Path: "spec.template.spec", Fields: []string{"containers", "volumes"}},
+		},
+		{
+			name:    "allowed fields of the root",
+			allowed: kyverno.AllowedFields{Fields: []string{"apiVersion", "kind", "metadata", "spec"}},
+		},
+		{
+			name:     "invalid path",
+			allowed:  kyverno.AllowedFields{Path: "spec..template", Fields: []string{"containers"}},
+			wantPath: "cel.allowedFields[0].path",
+			wantErr:  true,
+		},
+		{
+			name:     "missing fields",
+			allowed:  kyverno.AllowedFields{Path: "spec"},
+			wantPath: "cel.allowedFields[0].fields",
+			wantErr:  true,
+		},
+		{
+			name:       "projected path",
+			allowed:    kyverno.AllowedFields{Path: "spec.template", Fields: []string{"metadata", "spec"}},
+			projection: []string{"spec"},
+		},
+		{
+			name:       "path partially projected",
+			allowed:    kyverno.AllowedFields{Path: "spec", Fields: []string{"replicas", "template"}},
+			projection: []string{"spec.replicas"},
+			wantPath:   "cel.fieldProjection",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					AllowedFields:   []kyverno.AllowedFields{tc.allowed},
+					FieldProjection: tc.projection,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_GracePeriod(t *testing.T) {
+	testCases := []struct {
+		name        string
+		gracePeriod time.Duration
+		wantPath    string
+		wantErr     bool
+	}{
+		{
+			name:        "grace period",
+			gracePeriod: 72 * time.Hour,
+		},
+		{
+			name:        "empty grace period",
+			gracePeriod: 0,
+			wantPath:    "cel.gracePeriod",
+			wantErr:     true,
+		},
+		{
+			name:        "negative grace period",
+			gracePeriod: -time.Hour,
+			wantPath:    "cel.gracePeriod",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					GracePeriod: &metav1.Duration{Duration: tc.gracePeriod},
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_MessageRedactions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		redactions []string
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:       "json pointers",
+			redactions: []string{"/metadata/annotations/token", "/spec/template/spec/containers/0/env"},
+		},
+		{
+			name:       "relative pointer",
+			redactions: []string{"/metadata/annotations/token", "spec/replicas"},
+			wantPath:   "cel.messageRedactions[1]",
+			wantErr:    true,
+		},
+		{
+			name:       "root pointer",
+			redactions: []string{"/"},
+			wantPath:   "cel.messageRedactions[0]",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					MessageRedactions: tc.redactions,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_ExcludeSelfFromParams(t *testing.T) {
+	validation := kyverno.Validation{
+		CEL: &kyverno.CEL{
+			ExcludeSelfFromParams: true,
+		},
+	}
+	path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+	assert.Equal(t, path, "cel.excludeSelfFromParams")
+	assert.Assert(t, err != nil)
+}
+
+func Test_Validate_CEL_CostBudget(t *testing.T) {
+	testCases := []struct {
+		name       string
+		costBudget int64
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:       "cost budget",
+			costBudget: 50000000,
+		},
+		{
+			name:       "empty cost budget",
+			costBudget: 0,
+			wantPath:   "cel.costBudget",
+			wantErr:    true,
+		},
+		{
+			name:       "cost budget above the maximum",
+			costBudget: 200000000,
+			wantPath:   "cel.costBudget",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			costBudget := tc.costBudget
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{
+					CostBudget: &costBudget,
+				},
+			}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tc.wantPath)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_MaxAuditAnnotations(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations int
+		max         int
+		wantErr     string
+	}{
+		{
+			name:        "within the default limit",
+			annotations: DefaultMaxAuditAnnotations,
+		},
+		{
+			name:        "over the default limit",
+			annotations: DefaultMaxAuditAnnotations + 1,
+			wantErr:     fmt.Sprintf("the rule declares %d audit annotations, the maximum is %d", DefaultMaxAuditAnnotations+1, DefaultMaxAuditAnnotations),
+		},
+		{
+			name:        "within a configured limit",
+			annotations: 2,
+			max:         2,
+		},
+		{
+			name:        "over a configured limit",
+			annotations: 3,
+			max:         2,
+			wantErr:     "the rule declares 3 audit annotations, the maximum is 2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := kyverno.Validation{
+				CEL: &kyverno.CEL{},
+			}
+			for i := 0; i < tc.annotations; i++ {
+				validation.CEL.AuditAnnotations = append(validation.CEL.AuditAnnotations, v1alpha1.AuditAnnotation{
+					Key:             fmt.Sprintf("replicas-%d", i),
+					ValueExpression: "string(object.spec.replicas)",
+				})
+			}
+			options := DefaultOptions()
+			if tc.max > 0 {
+				options.MaxAuditAnnotations = tc.max
+			}
+			checker := NewValidateFactory(&validation, nil, options)
+			path, err := checker.Validate(context.TODO())
+			if tc.wantErr != "" {
+				assert.Equal(t, path, "cel.auditAnnotations")
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_FailFast(t *testing.T) {
+	validation := kyverno.Validation{
+		CEL: &kyverno.CEL{
+			FailFast: true,
+		},
+	}
+	path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+	assert.Equal(t, path, "cel.failFast")
+	assert.Assert(t, err != nil)
+}
+
+func Test_Validate_CEL_EvaluateWithoutParams(t *testing.T) {
+	validation := kyverno.Validation{
+		CEL: &kyverno.CEL{
+			EvaluateWithoutParams: true,
+		},
+	}
+	path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+	assert.Equal(t, path, "cel.evaluateWithoutParams")
+	assert.Assert(t, err != nil)
+}
+
+func Test_Validate_CEL_ParamsFromOwners(t *testing.T) {
+	deny := v1alpha1.DenyAction
+	tests := []struct {
+		name    string
+		cel     kyverno.CEL
+		wantErr bool
+	}{{
+		name: "without params",
+		cel: kyverno.CEL{
+			ParamsFromOwners: true,
+		},
+		wantErr: true,
+	}, {
+		name: "without selector",
+		cel: kyverno.CEL{
+			ParamKind:        &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:         &v1alpha1.ParamRef{Name: "params", ParameterNotFoundAction: &deny},
+			ParamsFromOwners: true,
+		},
+		wantErr: true,
+	}, {
+		name: "params of another namespace",
+		cel: kyverno.CEL{
+			ParamKind:        &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:         &v1alpha1.ParamRef{Selector: &metav1.LabelSelector{}, Namespace: "other", ParameterNotFoundAction: &deny},
+			ParamsFromOwners: true,
+		},
+		wantErr: true,
+	}, {
+		name: "params of the selected namespaces",
+		cel: kyverno.CEL{
+			ParamKind:              &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:               &v1alpha1.ParamRef{Selector: &metav1.LabelSelector{}, ParameterNotFoundAction: &deny},
+			ParamNamespaceSelector: &metav1.LabelSelector{},
+			ParamsFromOwners:       true,
+		},
+		wantErr: true,
+	}, {
+		name: "params of the namespace of the resource",
+		cel: kyverno.CEL{
+			ParamKind:        &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:         &v1alpha1.ParamRef{Selector: &metav1.LabelSelector{}, ParameterNotFoundAction: &deny},
+			ParamsFromOwners: true,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &tt.cel}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			if tt.wantErr {
+				assert.Equal(t, path, "cel.paramsFromOwners")
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func Test_Validate_CEL_ParamFieldSelector(t *testing.T) {
+	deny := v1alpha1.DenyAction
+	tests := []struct {
+		name    string
+		cel     kyverno.CEL
+		wantErr bool
+	}{{
+		name: "without params",
+		cel: kyverno.CEL{
+			ParamFieldSelector: "data.tier=gold",
+		},
+		wantErr: true,
+	}, {
+		name: "invalid selector",
+		cel: kyverno.CEL{
+			ParamKind:          &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:           &v1alpha1.ParamRef{Name: "params", ParameterNotFoundAction: &deny},
+			ParamFieldSelector: "data.tier",
+		},
+		wantErr: true,
+	}, {
+		name: "valid selector",
+		cel: kyverno.CEL{
+			ParamKind:          &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:           &v1alpha1.ParamRef{Name: "params", ParameterNotFoundAction: &deny},
+			ParamFieldSelector: "data.tier=gold,metadata.name!=default",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &tt.cel}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			if tt.wantErr {
+				assert.Equal(t, path, "cel.paramFieldSelector")
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func Test_Validate_CEL_AdditionalParams(t *testing.T) {
+	deny := v1alpha1.DenyAction
+	overrides := kyverno.CELParams{
+		ParamKind: v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+		ParamRef:  v1alpha1.ParamRef{Name: "overrides", ParameterNotFoundAction: &deny},
+	}
+	tests := []struct {
+		name     string
+		cel      kyverno.CEL
+		wantPath string
+		wantErr  bool
+	}{{
+		name: "without params",
+		cel: kyverno.CEL{
+			AdditionalParams: []kyverno.CELParams{overrides},
+		},
+		wantPath: "cel.additionalParams",
+		wantErr:  true,
+	}, {
+		name: "without kind",
+		cel: kyverno.CEL{
+			ParamKind: &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:  &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+			AdditionalParams: []kyverno.CELParams{overrides, {
+				ParamKind: v1alpha1.ParamKind{APIVersion: "v1"},
+				ParamRef:  v1alpha1.ParamRef{Name: "overrides", ParameterNotFoundAction: &deny},
+			}},
+		},
+		wantPath: "cel.additionalParams[1].paramKind",
+		wantErr:  true,
+	}, {
+		name: "with both name and selector",
+		cel: kyverno.CEL{
+			ParamKind: &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:  &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+			AdditionalParams: []kyverno.CELParams{{
+				ParamKind: v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+				ParamRef:  v1alpha1.ParamRef{Name: "overrides", Selector: &metav1.LabelSelector{}, ParameterNotFoundAction: &deny},
+			}},
+		},
+		wantPath: "cel.additionalParams[0].paramRef",
+		wantErr:  true,
+	}, {
+		name: "valid",
+		cel: kyverno.CEL{
+			ParamKind:        &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:         &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+			AdditionalParams: []kyverno.CELParams{overrides},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &tt.cel}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			assert.Equal(t, path, tt.wantPath)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Validate_CEL_NamespaceSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector *metav1.LabelSelector
+		wantErr  bool
+	}{{
+		name:     "labels",
+		selector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+	}, {
+		name: "expressions",
+		selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "staging"}},
+		}},
+	}, {
+		name: "invalid operator",
+		selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: "Matches", Values: []string{"prod"}},
+		}},
+		wantErr: true,
+	}, {
+		name: "values with exists",
+		selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpExists, Values: []string{"prod"}},
+		}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &kyverno.CEL{NamespaceSelector: tt.selector}}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			if tt.wantErr {
+				assert.Equal(t, path, "cel.namespaceSelector")
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func Test_Validate_CEL_ParamNamespaceSelector(t *testing.T) {
+	deny := v1alpha1.DenyAction
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"params": "org"}}
+	tests := []struct {
+		name     string
+		paramRef *v1alpha1.ParamRef
+		selector *metav1.LabelSelector
+		wantPath string
+		wantErr  bool
+	}{{
+		name:     "selected namespaces",
+		paramRef: &v1alpha1.ParamRef{Name: "limits", ParameterNotFoundAction: &deny},
+		selector: selector,
+	}, {
+		name:     "without paramRef",
+		selector: selector,
+		wantErr:  true,
+	}, {
+		name:     "along with the namespace of paramRef",
+		paramRef: &v1alpha1.ParamRef{Name: "limits", Namespace: "default", ParameterNotFoundAction: &deny},
+		selector: selector,
+		wantErr:  true,
+	}, {
+		name:     "invalid selector",
+		paramRef: &v1alpha1.ParamRef{Name: "limits", ParameterNotFoundAction: &deny},
+		selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "params", Operator: "Matches", Values: []string{"org"}},
+		}},
+		wantPath: "cel.paramNamespaceSelector",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &kyverno.CEL{
+				ParamKind:              &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+				ParamRef:               tt.paramRef,
+				ParamNamespaceSelector: tt.selector,
+			}}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			if tt.wantErr {
+				assert.Equal(t, path, tt.wantPath)
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func Test_Validate_CEL_ParamKind(t *testing.T) {
+	deny := v1alpha1.DenyAction
+	tests := []struct {
+		name     string
+		cel      kyverno.CEL
+		wantPath string
+		wantErr  bool
+	}{{
+		name: "valid group version",
+		cel: kyverno.CEL{
+			ParamKind: &v1alpha1.ParamKind{APIVersion: "apps/v1", Kind: "Deployment"},
+			ParamRef:  &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+		},
+	}, {
+		name: "malformed group version",
+		cel: kyverno.CEL{
+			ParamKind: &v1alpha1.ParamKind{APIVersion: "apps/v1/deployments", Kind: "Deployment"},
+			ParamRef:  &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+		},
+		wantPath: "cel.paramKind.apiVersion",
+		wantErr:  true,
+	}, {
+		name: "malformed group version of additional params",
+		cel: kyverno.CEL{
+			ParamKind: &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			ParamRef:  &v1alpha1.ParamRef{Name: "defaults", ParameterNotFoundAction: &deny},
+			AdditionalParams: []kyverno.CELParams{{
+				ParamKind: v1alpha1.ParamKind{APIVersion: "v1/configmaps/", Kind: "ConfigMap"},
+				ParamRef:  v1alpha1.ParamRef{Name: "overrides", ParameterNotFoundAction: &deny},
+			}},
+		},
+		wantPath: "cel.additionalParams[0].paramKind.apiVersion",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := kyverno.Validation{CEL: &tt.cel}
+			path, err := NewValidateFactory(&validation, nil, DefaultOptions()).Validate(context.TODO())
+			if tt.wantErr {
+				assert.Equal(t, path, tt.wantPath)
+				assert.ErrorContains(t, err, "can't parse the parameter resource group version")
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
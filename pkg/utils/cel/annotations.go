@@ -0,0 +1,118 @@
+package cel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+)
+
+// structuredAuditAnnotationCondition is an audit annotation whose value expression may return any type.
+type structuredAuditAnnotationCondition struct {
+	Key             string
+	ValueExpression string
+}
+
+func (v *structuredAuditAnnotationCondition) GetExpression() string {
+	return v.ValueExpression
+}
+
+func (v *structuredAuditAnnotationCondition) ReturnTypes() []*celgo.Type {
+	return []*celgo.Type{celgo.AnyType}
+}
+
+// structuredAuditAnnotationFilter is a cel.Filter serializing the structured values of audit annotations, e.g. maps
+// or lists, to JSON. The validator only publishes string values, strings and nulls are kept as they are.
+type structuredAuditAnnotationFilter struct {
+	filter cel.Filter
+}
+
+func (f *structuredAuditAnnotationFilter) ForInput(ctx context.Context, versionedAttr *admission.VersionedAttributes, request *admissionv1.AdmissionRequest, optionalVars cel.OptionalVariableBindings, namespace *corev1.Namespace, runtimeCELCostBudget int64) ([]cel.EvaluationResult, int64, error) {
+	evalResults, remainingBudget, err := f.filter.ForInput(ctx, versionedAttr, request, optionalVars, namespace, runtimeCELCostBudget)
+	for i := range evalResults {
+		evalResult := &evalResults[i]
+		// the validator expects the audit annotations of ValidatingAdmissionPolicies
+		if condition, ok := evalResult.ExpressionAccessor.(*structuredAuditAnnotationCondition); ok {
+			evalResult.ExpressionAccessor = &validatingadmissionpolicy.AuditAnnotationCondition{
+				Key:             condition.Key,
+				ValueExpression: condition.ValueExpression,
+			}
+		}
+		if evalResult.Error != nil || evalResult.EvalResult == nil {
+			continue
+		}
+		switch evalResult.EvalResult.Type() {
+		case types.StringType, types.NullType:
+			continue
+		}
+		value, marshalErr := marshalValue(evalResult.EvalResult)
+		if marshalErr != nil {
+			evalResult.Error = fmt.Errorf("failed to serialize the audit annotation value to JSON: %w", marshalErr)
+			continue
+		}
+		evalResult.EvalResult = types.String(value)
+	}
+	return evalResults, remainingBudget, err
+}
+
+func (f *structuredAuditAnnotationFilter) CompilationErrors() []error {
+	return f.filter.CompilationErrors()
+}
+
+// marshalValue serializes a CEL value to JSON, the keys of maps are sorted.
+func marshalValue(val ref.Val) (string, error) {
+	native, err := nativeValue(val)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(native)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// nativeValue converts a CEL value to its JSON counterpart, maps and lists are converted element by element
+// as they may be backed by the unstructured content of objects.
+func nativeValue(val ref.Val) (interface{}, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case traits.Mapper:
+		values := map[string]interface{}{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			name, ok := key.Value().(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported map key type %s, keys must be strings", key.Type().TypeName())
+			}
+			value, err := nativeValue(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		return values, nil
+	case traits.Lister:
+		values := []interface{}{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			value, err := nativeValue(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	default:
+		return val.Value(), nil
+	}
+}
@@ -0,0 +1,92 @@
+package cel
+
+import (
+	"context"
+	"sort"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// Bindings returns the environment options declaring the given variables, their values are bound when the
+// expressions are evaluated with the context returned by WithBindings, e.g. values computed for each request.
+// The compiled expressions don't depend on the values and are reused across evaluations.
+func Bindings(names ...string) environment.VersionedOptions {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	envOptions := make([]celgo.EnvOption, 0, len(names))
+	for _, name := range names {
+		envOptions = append(envOptions, celgo.Variable(name, celgo.DynType))
+	}
+	return environment.VersionedOptions{
+		IntroducedVersion: version.MajorMinor(1, 0),
+		EnvOptions:        envOptions,
+	}
+}
+
+type bindingsKey struct{}
+
+// WithBindings returns a context binding the given values to the variables declared with Bindings, the expressions
+// evaluated with it read them. The values are converted by the type adapter of the environment, e.g. maps and lists.
+// A value of type func() any is computed when an expression first reads it and replaces the function in values.
+func WithBindings(ctx context.Context, values map[string]interface{}) context.Context {
+	return context.WithValue(ctx, bindingsKey{}, values)
+}
+
+// bindingCompiler is a cel.Compiler whose programs read the values bound by WithBindings and report their
+// failures to the context returned by WithFailures.
+type bindingCompiler struct {
+	compiler cel.Compiler
+}
+
+func (c *bindingCompiler) CompileCELExpression(expressionAccessor cel.ExpressionAccessor, options cel.OptionalVariableDeclarations, mode environment.Type) cel.CompilationResult {
+	result := c.compiler.CompileCELExpression(expressionAccessor, options, mode)
+	if result.Program != nil {
+		program := &bindingProgram{Program: result.Program, expression: expressionAccessor.GetExpression()}
+		// the composited variables are the only named expressions
+		if variable, ok := expressionAccessor.(cel.NamedExpressionAccessor); ok {
+			program.variable = variable.GetName()
+		}
+		result.Program = program
+	}
+	return result
+}
+
+// bindingProgram layers the values bound to the evaluation context over the variables of the filters,
+// e.g. object or params, which can't be shadowed as their names are reserved.
+type bindingProgram struct {
+	celgo.Program
+	expression string
+	// variable is the name of the variable computed by the program, if any
+	variable string
+}
+
+func (p *bindingProgram) ContextEval(ctx context.Context, input any) (ref.Val, *celgo.EvalDetails, error) {
+	val, details, err := p.eval(ctx, input)
+	if err != nil {
+		if failures, ok := ctx.Value(failuresKey{}).(*Failures); ok {
+			failures.record(p.expression, p.variable, err)
+		}
+	}
+	return val, details, err
+}
+
+func (p *bindingProgram) eval(ctx context.Context, input any) (ref.Val, *celgo.EvalDetails, error) {
+	values, _ := ctx.Value(bindingsKey{}).(map[string]interface{})
+	if len(values) == 0 {
+		return p.Program.ContextEval(ctx, input)
+	}
+	activation, err := interpreter.NewActivation(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	bindings, err := interpreter.NewActivation(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Program.ContextEval(ctx, interpreter.NewHierarchicalActivation(activation, bindings))
+}
@@ -1,14 +1,23 @@
 package cel
 
 import (
+	"fmt"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/interpreter"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/apiserver/pkg/admission/plugin/cel"
 	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
 	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
 	"k8s.io/apiserver/pkg/cel/environment"
 )
 
+// MaxRuntimeCostBudget is the maximum runtime cost budget of a rule, ten times the default budget.
+const MaxRuntimeCostBudget = 10 * celconfig.RuntimeCELCostBudget
+
 type Compiler struct {
 	compositedCompiler cel.CompositedCompiler
 	// CEL expressions
@@ -23,11 +32,22 @@ func NewCompiler(
 	auditAnnotations []admissionregistrationv1alpha1.AuditAnnotation,
 	matchConditions []admissionregistrationv1.MatchCondition,
 	variables []admissionregistrationv1alpha1.Variable,
+	options ...environment.VersionedOptions,
 ) (*Compiler, error) {
-	compositedCompiler, err := cel.NewCompositedCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()))
+	envSet := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion())
+	if len(options) != 0 {
+		extended, err := envSet.Extend(options...)
+		if err != nil {
+			return nil, err
+		}
+		envSet = extended
+	}
+	compositedCompiler, err := cel.NewCompositedCompiler(envSet)
 	if err != nil {
 		return nil, err
 	}
+	compositedCompiler.Compiler = &bindingCompiler{compiler: compositedCompiler.Compiler}
+	compositedCompiler.FilterCompiler = &dedupingFilterCompiler{compiler: compositedCompiler.Compiler}
 	return &Compiler{
 		compositedCompiler:         *compositedCompiler,
 		validateExpressions:        validations,
@@ -37,12 +57,19 @@ func NewCompiler(
 	}, nil
 }
 
-func (c Compiler) CompileVariables(optionalVars cel.OptionalVariableDeclarations) {
-	c.compositedCompiler.CompileAndStoreVariables(
-		c.convertVariables(),
-		optionalVars,
-		environment.StoredExpressions,
-	)
+// CompileVariables compiles the variables and makes them available to the expressions compiled next.
+// Variables are compiled in the order they are declared, a variable may reference params and the variables
+// declared before it, references to the variables declared after it fail to compile.
+// It returns a VariableError for each variable failing to compile, their evaluation fails.
+func (c Compiler) CompileVariables(optionalVars cel.OptionalVariableDeclarations) []error {
+	var errs []error
+	for _, variable := range c.convertVariables() {
+		result := c.compositedCompiler.CompileAndStoreVariable(variable, optionalVars, environment.StoredExpressions)
+		if result.Error != nil {
+			errs = append(errs, VariableError{Name: variable.GetName(), Compilation: true, Err: result.Error})
+		}
+	}
+	return errs
 }
 
 func (c Compiler) CompileValidateExpressions(optionalVars cel.OptionalVariableDeclarations) cel.Filter {
@@ -53,6 +80,37 @@ func (c Compiler) CompileValidateExpressions(optionalVars cel.OptionalVariableDe
 	)
 }
 
+// ExpressionError is the failure to compile a validation expression of a rule.
+type ExpressionError struct {
+	// Index is the index of the expression in the validations of the rule
+	Index int
+	// Expression is the text of the expression
+	Expression string
+	// Err is the failure reported by the compiler, e.g. a syntax or type check error
+	Err error
+}
+
+func (e ExpressionError) Error() string {
+	return fmt.Sprintf("expressions[%d] %q: %v", e.Index, e.Expression, e.Err)
+}
+
+func (e ExpressionError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors compiles the validation expressions one by one and returns an ExpressionError for each
+// expression failing to compile. The variables must be compiled first.
+func (c Compiler) ValidationErrors(optionalVars cel.OptionalVariableDeclarations) []error {
+	var errs []error
+	for i, accessor := range c.convertValidations() {
+		result := c.compositedCompiler.CompileCELExpression(accessor, optionalVars, environment.StoredExpressions)
+		if result.Error != nil {
+			errs = append(errs, ExpressionError{Index: i, Expression: accessor.GetExpression(), Err: result.Error})
+		}
+	}
+	return errs
+}
+
 func (c Compiler) CompileMessageExpressions(optionalVars cel.OptionalVariableDeclarations) cel.Filter {
 	return c.compositedCompiler.Compile(
 		c.convertMessageExpressions(),
@@ -61,14 +119,35 @@ func (c Compiler) CompileMessageExpressions(optionalVars cel.OptionalVariableDec
 	)
 }
 
-func (c Compiler) CompileAuditAnnotationsExpressions(optionalVars cel.OptionalVariableDeclarations) cel.Filter {
+// CompileSuggestionExpression compiles an expression suggesting the value that would pass the validations.
+// As message expressions, it must return a string. The filter has no expression when the expression is empty.
+func (c Compiler) CompileSuggestionExpression(expression string, optionalVars cel.OptionalVariableDeclarations) cel.Filter {
+	var celExpressionAccessor []cel.ExpressionAccessor
+	if expression != "" {
+		celExpressionAccessor = append(celExpressionAccessor, &validatingadmissionpolicy.MessageExpressionCondition{
+			MessageExpression: expression,
+		})
+	}
 	return c.compositedCompiler.Compile(
-		c.convertAuditAnnotations(),
+		celExpressionAccessor,
 		optionalVars,
 		environment.StoredExpressions,
 	)
 }
 
+// CompileAuditAnnotationsExpressions compiles the value expressions of the audit annotations.
+// Besides strings and nulls, value expressions may return structured values, e.g. maps or lists, they are
+// serialized to JSON when evaluated.
+func (c Compiler) CompileAuditAnnotationsExpressions(optionalVars cel.OptionalVariableDeclarations) cel.Filter {
+	return &structuredAuditAnnotationFilter{
+		filter: c.compositedCompiler.Compile(
+			c.convertAuditAnnotations(),
+			optionalVars,
+			environment.StoredExpressions,
+		),
+	}
+}
+
 func (c Compiler) CompileMatchExpressions(optionalVars cel.OptionalVariableDeclarations) cel.Filter {
 	return c.compositedCompiler.Compile(
 		c.convertMatchExpressions(),
@@ -106,7 +185,7 @@ func (c Compiler) convertMessageExpressions() []cel.ExpressionAccessor {
 func (c Compiler) convertAuditAnnotations() []cel.ExpressionAccessor {
 	celExpressionAccessor := make([]cel.ExpressionAccessor, len(c.auditAnnotationExpressions))
 	for i, validation := range c.auditAnnotationExpressions {
-		validation := validatingadmissionpolicy.AuditAnnotationCondition{
+		validation := structuredAuditAnnotationCondition{
 			Key:             validation.Key,
 			ValueExpression: validation.ValueExpression,
 		}
@@ -137,3 +216,12 @@ func (c Compiler) convertVariables() []cel.NamedExpressionAccessor {
 	}
 	return namedExpressions
 }
+
+// CostEstimator returns the environment options tracking the runtime cost of expressions with the given estimator.
+// The estimator prices function calls, calls it returns no cost for keep the cost of the standard Kubernetes model.
+func CostEstimator(estimator interpreter.ActualCostEstimator) environment.VersionedOptions {
+	return environment.VersionedOptions{
+		IntroducedVersion: version.MajorMinor(1, 0),
+		ProgramOptions:    []celgo.ProgramOption{celgo.CostTracking(estimator)},
+	}
+}
@@ -0,0 +1,453 @@
+package cel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"gotest.tools/assert"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// fixedCostEstimator charges a fixed cost for the calls of a single function.
+type fixedCostEstimator struct {
+	function string
+	cost     uint64
+}
+
+func (e fixedCostEstimator) CallCost(function, overloadID string, args []ref.Val, result ref.Val) *uint64 {
+	if function != e.function {
+		return nil
+	}
+	cost := e.cost
+	return &cost
+}
+
+// evaluationCost returns the runtime cost of the given expression evaluated against a deployment.
+func evaluationCost(t *testing.T, expression string, options ...environment.VersionedOptions) int64 {
+	validations := []admissionregistrationv1alpha1.Validation{{Expression: expression}}
+	compiler, err := NewCompiler(validations, nil, nil, nil, options...)
+	assert.NilError(t, err)
+	optionalVars := cel.OptionalVariableDeclarations{}
+	compiler.CompileVariables(optionalVars)
+	filter := compiler.CompileValidateExpressions(optionalVars)
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+	}}
+	attr := admission.NewAttributesRecord(object, nil, gvk, "default", "nginx", gvr, "", admission.Create, nil, false, nil)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, gvk, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	assert.NilError(t, err)
+	request := cel.CreateAdmissionRequest(attr, metav1.GroupVersionResource(gvr), metav1.GroupVersionKind(gvk))
+
+	const budget = int64(1000000)
+	results, remaining, err := filter.ForInput(context.TODO(), versionedAttr, request, cel.OptionalVariableBindings{}, nil, budget)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.NilError(t, results[0].Error)
+	return budget - remaining
+}
+
+func TestCostEstimator(t *testing.T) {
+	expression := "object.metadata.name.startsWith('ngi')"
+	defaultCost := evaluationCost(t, expression)
+	customCost := evaluationCost(t, expression, CostEstimator(fixedCostEstimator{function: "startsWith", cost: 100}))
+	assert.Assert(t, defaultCost < 100, "default cost %d", defaultCost)
+	assert.Assert(t, customCost >= 100, "custom cost %d", customCost)
+
+	// calls the estimator doesn't price keep their default cost
+	otherCost := evaluationCost(t, expression, CostEstimator(fixedCostEstimator{function: "endsWith", cost: 100}))
+	assert.Equal(t, otherCost, defaultCost)
+}
+
+func TestSemverLibrary(t *testing.T) {
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Extend(SemverLibrary())
+	assert.NilError(t, err)
+	env, err := envSet.Env(environment.StoredExpressions)
+	assert.NilError(t, err)
+	evaluate := func(expression string) (ref.Val, error) {
+		ast, issues := env.Compile(expression)
+		if issues.Err() != nil {
+			return nil, issues.Err()
+		}
+		program, err := env.Program(ast)
+		assert.NilError(t, err)
+		result, _, err := program.Eval(map[string]interface{}{})
+		return result, err
+	}
+
+	for _, expression := range []string{
+		"semver('1.25.3').satisfies('>=1.25.0 <2.0.0')",
+		"!semver('1.24.0').satisfies('>=1.25.0')",
+		"semver('v1.29').satisfies('>=1.25.0')",
+		"semver('1.25.3').isGreaterThan(semver('1.25.0'))",
+		"semver('1.25.0-rc.1').isLessThan(semver('1.25.0'))",
+		"semver('1.25.0').compareTo(semver('1.25.0')) == 0",
+		"semver('1.25.0') == semver('v1.25.0')",
+		"semver('1.25.3').major() == 1 && semver('1.25.3').minor() == 25 && semver('1.25.3').patch() == 3",
+		"isSemver('1.25.0') && !isSemver('latest')",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			result, err := evaluate(expression)
+			assert.NilError(t, err)
+			assert.Equal(t, result, types.True)
+		})
+	}
+
+	// arguments are type checked at compile time
+	_, err = evaluate("semver('1.25.0').satisfies(1)")
+	assert.ErrorContains(t, err, "found no matching overload for 'satisfies'")
+	// invalid versions and ranges fail the evaluation
+	_, err = evaluate("semver('latest').major() == 1")
+	assert.ErrorContains(t, err, "invalid semantic version")
+	_, err = evaluate("semver('1.25.0').satisfies('>=latest')")
+	assert.ErrorContains(t, err, "invalid semantic version range")
+
+	// the functions are only declared when the library is
+	baseEnv, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Env(environment.StoredExpressions)
+	assert.NilError(t, err)
+	_, issues := baseEnv.Compile("semver('1.25.0').major() == 1")
+	assert.ErrorContains(t, issues.Err(), "undeclared reference to 'semver'")
+}
+
+func TestContainersLibrary(t *testing.T) {
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Extend(ContainersLibrary())
+	assert.NilError(t, err)
+	env, err := envSet.Env(environment.StoredExpressions)
+	assert.NilError(t, err)
+	env, err = env.Extend(celgo.Variable("object", celgo.DynType))
+	assert.NilError(t, err)
+	evaluate := func(expression string, object map[string]interface{}) (ref.Val, error) {
+		ast, issues := env.Compile(expression)
+		if issues.Err() != nil {
+			return nil, issues.Err()
+		}
+		program, err := env.Program(ast)
+		assert.NilError(t, err)
+		result, _, err := program.Eval(map[string]interface{}{"object": object})
+		return result, err
+	}
+	containers := map[string]interface{}{
+		"initContainers":      []interface{}{map[string]interface{}{"name": "init"}},
+		"containers":          []interface{}{map[string]interface{}{"name": "nginx", "resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}}}},
+		"ephemeralContainers": []interface{}{map[string]interface{}{"name": "debug"}},
+	}
+
+	testCases := []struct {
+		name   string
+		object map[string]interface{}
+	}{{
+		name:   "pod",
+		object: map[string]interface{}{"kind": "Pod", "spec": containers},
+	}, {
+		name:   "deployment",
+		object: map[string]interface{}{"kind": "Deployment", "spec": map[string]interface{}{"template": map[string]interface{}{"spec": containers}}},
+	}, {
+		name: "cron job",
+		object: map[string]interface{}{"kind": "CronJob", "spec": map[string]interface{}{"jobTemplate": map[string]interface{}{
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": containers}},
+		}}},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := evaluate("allContainers(object).map(c, c.name) == ['init', 'nginx', 'debug']", tc.object)
+			assert.NilError(t, err)
+			assert.Equal(t, result, types.True)
+			result, err = evaluate("allContainers(object).all(c, has(c.resources))", tc.object)
+			assert.NilError(t, err)
+			assert.Equal(t, result, types.False)
+			result, err = evaluate("allContainers(object).exists(c, has(c.resources) && has(c.resources.limits))", tc.object)
+			assert.NilError(t, err)
+			assert.Equal(t, result, types.True)
+		})
+	}
+
+	// objects without containers have none
+	result, err := evaluate("allContainers(object).size() == 0", map[string]interface{}{"kind": "ConfigMap", "data": map[string]interface{}{}})
+	assert.NilError(t, err)
+	assert.Equal(t, result, types.True)
+	// the result is a list
+	_, err = evaluate("allContainers(object) == 'nginx'", map[string]interface{}{"kind": "Pod"})
+	assert.ErrorContains(t, err, "found no matching overload for '_==_'")
+
+	// the functions are only declared when the library is
+	baseEnv, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Env(environment.StoredExpressions)
+	assert.NilError(t, err)
+	_, issues := baseEnv.Compile("allContainers(object).size() == 0")
+	assert.ErrorContains(t, issues.Err(), "undeclared reference to 'allContainers'")
+}
+
+// countingCompiler counts the expressions it compiles.
+type countingCompiler struct {
+	cel.Compiler
+	expressions []string
+}
+
+func (c *countingCompiler) CompileCELExpression(expressionAccessor cel.ExpressionAccessor, options cel.OptionalVariableDeclarations, mode environment.Type) cel.CompilationResult {
+	c.expressions = append(c.expressions, expressionAccessor.GetExpression())
+	return c.Compiler.CompileCELExpression(expressionAccessor, options, mode)
+}
+
+func TestClockLibrary(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).Extend(ClockLibrary(func() time.Time { return now }))
+	assert.NilError(t, err)
+	env, err := envSet.Env(environment.StoredExpressions)
+	assert.NilError(t, err)
+	ast, issues := env.Compile("now() < timestamp('2024-06-01T00:00:00Z')")
+	assert.NilError(t, issues.Err())
+	program, err := env.Program(ast)
+	assert.NilError(t, err)
+
+	result, _, err := program.Eval(map[string]interface{}{})
+	assert.NilError(t, err)
+	assert.Equal(t, result, types.True)
+	// the clock is read on every evaluation
+	now = now.AddDate(1, 0, 0)
+	result, _, err = program.Eval(map[string]interface{}{})
+	assert.NilError(t, err)
+	assert.Equal(t, result, types.False)
+}
+
+func TestBindings(t *testing.T) {
+	validations := []admissionregistrationv1alpha1.Validation{
+		{Expression: "variables.team == 'payments'"},
+		{Expression: "object.metadata.name in images"},
+	}
+	variables := []admissionregistrationv1alpha1.Variable{
+		{Name: "team", Expression: "labels.team"},
+	}
+	compiler, err := NewCompiler(validations, nil, nil, variables, Bindings("labels", "images"))
+	assert.NilError(t, err)
+	optionalVars := cel.OptionalVariableDeclarations{}
+	assert.Equal(t, len(compiler.CompileVariables(optionalVars)), 0)
+	filter := compiler.CompileValidateExpressions(optionalVars)
+	assert.Equal(t, len(filter.CompilationErrors()), 0)
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+	}}
+	attr := admission.NewAttributesRecord(object, nil, gvk, "default", "nginx", gvr, "", admission.Create, nil, false, nil)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, gvk, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	assert.NilError(t, err)
+	request := cel.CreateAdmissionRequest(attr, metav1.GroupVersionResource(gvr), metav1.GroupVersionKind(gvk))
+	evaluate := func(values map[string]interface{}) []cel.EvaluationResult {
+		results, _, err := filter.ForInput(WithBindings(context.TODO(), values), versionedAttr, request, cel.OptionalVariableBindings{}, nil, 1000000)
+		assert.NilError(t, err)
+		assert.Equal(t, len(results), 2)
+		return results
+	}
+
+	// the same compiled expressions read the values bound to each evaluation
+	results := evaluate(map[string]interface{}{"labels": map[string]string{"team": "payments"}, "images": []string{"nginx"}})
+	assert.Equal(t, results[0].EvalResult, types.True)
+	assert.Equal(t, results[1].EvalResult, types.True)
+	results = evaluate(map[string]interface{}{"labels": map[string]string{"team": "platform"}, "images": []string{}})
+	assert.Equal(t, results[0].EvalResult, types.False)
+	assert.Equal(t, results[1].EvalResult, types.False)
+	// unbound variables fail to evaluate
+	results = evaluate(nil)
+	assert.Assert(t, results[1].Error != nil)
+}
+
+func TestFailures(t *testing.T) {
+	validations := []admissionregistrationv1alpha1.Validation{
+		{Expression: "variables.a"},
+		{Expression: "variables.c == 1"},
+	}
+	variables := []admissionregistrationv1alpha1.Variable{
+		{Name: "b", Expression: "object.spec.replicas"},
+		{Name: "a", Expression: "variables.b == 1"},
+		{Name: "c", Expression: "undeclared + 1"},
+	}
+	compiler, err := NewCompiler(validations, nil, nil, variables)
+	assert.NilError(t, err)
+	optionalVars := cel.OptionalVariableDeclarations{}
+	errs := compiler.CompileVariables(optionalVars)
+	assert.Equal(t, len(errs), 1)
+	var variableErr VariableError
+	assert.Assert(t, errors.As(errs[0], &variableErr))
+	assert.Equal(t, variableErr.Name, "c")
+	assert.Assert(t, variableErr.Compilation)
+	filter := compiler.CompileValidateExpressions(optionalVars)
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+	}}
+	attr := admission.NewAttributesRecord(object, nil, gvk, "default", "nginx", gvr, "", admission.Create, nil, false, nil)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, gvk, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	assert.NilError(t, err)
+	request := cel.CreateAdmissionRequest(attr, metav1.GroupVersionResource(gvr), metav1.GroupVersionKind(gvk))
+	failures := &Failures{}
+	results, _, err := filter.ForInput(WithFailures(context.TODO(), failures), versionedAttr, request, cel.OptionalVariableBindings{}, nil, 1000000)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 2)
+	assert.Assert(t, results[0].Error != nil)
+	assert.Assert(t, results[1].Error != nil)
+	// the variables failing because of the variables they use come after them, variables failing to compile
+	// are not evaluated
+	assert.Equal(t, len(failures.Variables), 2)
+	assert.Equal(t, failures.Variables[0].Name, "b")
+	assert.Equal(t, failures.Variables[1].Name, "a")
+	assert.Assert(t, !failures.Variables[0].Compilation)
+	assert.ErrorContains(t, failures.Variables[0], "no such key: spec")
+	assert.Equal(t, len(failures.CostLimitExceeded), 0)
+}
+
+func TestCompileValidateExpressions_Duplicates(t *testing.T) {
+	validations := []admissionregistrationv1alpha1.Validation{
+		{Expression: "object.metadata.name == 'nginx'", Message: "first"},
+		{Expression: "object.metadata.name == 'nginx'", Message: "second"},
+		{Expression: "object.metadata.namespace == 'default'"},
+	}
+	compiler, err := NewCompiler(validations, nil, nil, nil)
+	assert.NilError(t, err)
+	counting := &countingCompiler{Compiler: compiler.compositedCompiler.Compiler}
+	compiler.compositedCompiler.FilterCompiler = &dedupingFilterCompiler{compiler: counting}
+	optionalVars := cel.OptionalVariableDeclarations{}
+	compiler.CompileVariables(optionalVars)
+	filter := compiler.CompileValidateExpressions(optionalVars)
+	assert.DeepEqual(t, counting.expressions, []string{"object.metadata.name == 'nginx'", "object.metadata.namespace == 'default'"})
+	assert.Equal(t, len(filter.CompilationErrors()), 0)
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+	}}
+	attr := admission.NewAttributesRecord(object, nil, gvk, "default", "nginx", gvr, "", admission.Create, nil, false, nil)
+	versionedAttr, err := admission.NewVersionedAttributes(attr, gvk, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	assert.NilError(t, err)
+	request := cel.CreateAdmissionRequest(attr, metav1.GroupVersionResource(gvr), metav1.GroupVersionKind(gvk))
+	results, _, err := filter.ForInput(context.TODO(), versionedAttr, request, cel.OptionalVariableBindings{}, nil, 1000000)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 3)
+	// the shared program still reports the accessor of each validation
+	for i, result := range results {
+		assert.NilError(t, result.Error)
+		assert.Equal(t, result.EvalResult, types.True)
+		assert.Equal(t, result.ExpressionAccessor.(*validatingadmissionpolicy.ValidationCondition).Message, validations[i].Message)
+	}
+}
+
+func TestFunctionCalls(t *testing.T) {
+	testCases := []struct {
+		expression string
+		want       []string
+	}{{
+		expression: "now() < timestamp(object.metadata.annotations.expires)",
+		want:       []string{"now", "timestamp"},
+	}, {
+		expression: "object.metadata.name.startsWith('nginx') && has(object.spec.replicas)",
+		want:       []string{"startsWith"},
+	}, {
+		expression: "object.spec.containers.all(c, c.image != 'now()') && object.spec.replicas in [1, 2]",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			functions, err := FunctionCalls(tc.expression)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, functions, tc.want)
+		})
+	}
+	_, err := FunctionCalls("now(")
+	assert.ErrorContains(t, err, "failed to parse expression")
+}
+
+func TestFieldPaths(t *testing.T) {
+	testCases := []struct {
+		expression string
+		want       []string
+	}{{
+		expression: "object.spec.replicas <= 5",
+		want:       []string{"spec.replicas"},
+	}, {
+		expression: "object.spec.containers[0].image.startsWith('registry.io/')",
+		want:       []string{"spec.containers[0].image"},
+	}, {
+		expression: "object.metadata.labels['app'] == 'nginx' && has(object.spec.replicas)",
+		want:       []string{"metadata.labels.app", "spec.replicas"},
+	}, {
+		expression: "object.spec.containers.all(c, c.image != '') && object.spec.replicas == oldObject.spec.replicas",
+		want:       []string{"spec.containers", "spec.replicas"},
+	}, {
+		expression: "object != null",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			paths, err := FieldPaths(tc.expression, "object")
+			assert.NilError(t, err)
+			assert.DeepEqual(t, paths, tc.want)
+		})
+	}
+	_, err := FieldPaths("object.spec.", "object")
+	assert.ErrorContains(t, err, "failed to parse expression")
+}
+
+func TestExternalDataURLs(t *testing.T) {
+	urls, err := ParseExternalDataURLs("registry.example.com, https://data.example.com/allowed/, https://files.example.com/policies", false)
+	assert.NilError(t, err)
+	testCases := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://registry.example.com/anything", allowed: true},
+		{url: "http://registry.example.com:8080/anything", allowed: true},
+		{url: "https://registry.example.com.evil.com/anything"},
+		{url: "https://registry.example.com@evil.com/anything"},
+		{url: "https://data.example.com/allowed/registries", allowed: true},
+		{url: "http://data.example.com/allowed/registries"},
+		{url: "https://data.example.com/allowed/../secret"},
+		{url: "https://data.example.com/secret"},
+		{url: "https://files.example.com/policies", allowed: true},
+		{url: "https://files.example.com/policies/registries", allowed: true},
+		{url: "https://files.example.com/policies-secret"},
+		{url: "file:///etc/passwd"},
+	}
+	for _, tc := range testCases {
+		err := urls.Check(tc.url)
+		assert.Equal(t, err == nil, tc.allowed, tc.url)
+	}
+
+	// loopback and link-local addresses are rejected unless allowed, even when listed
+	urls, err = ParseExternalDataURLs("localhost,127.0.0.1,169.254.169.254,[::1]", false)
+	assert.NilError(t, err)
+	for _, url := range []string{"http://localhost/", "http://127.0.0.1/", "http://169.254.169.254/latest", "http://[::1]/"} {
+		assert.ErrorContains(t, urls.Check(url), "targets a loopback or link-local address")
+	}
+	urls, err = ParseExternalDataURLs("localhost", true)
+	assert.NilError(t, err)
+	assert.NilError(t, urls.Check("http://localhost:8080/"))
+
+	// no URL is allowed by an empty allow-list
+	assert.ErrorContains(t, ExternalDataURLs{}.Check("https://registry.example.com/"), "isn't allowed for external data sources")
+
+	_, err = ParseExternalDataURLs("ftp://registry.example.com/", false)
+	assert.ErrorContains(t, err, "invalid HTTP or HTTPS URL prefix")
+}
@@ -0,0 +1,46 @@
+package cel
+
+import (
+	"time"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// ClockLibrary returns the environment options declaring the time functions reading the given clock:
+//
+//	now() <timestamp>    returns the current time of the clock
+//
+// For example `now() < timestamp(object.metadata.annotations.expires)`.
+// The clock is read when the expressions are evaluated, a fixed clock makes their outcome deterministic.
+func ClockLibrary(now func() time.Time) environment.VersionedOptions {
+	return environment.VersionedOptions{
+		IntroducedVersion: version.MajorMinor(1, 0),
+		EnvOptions:        []celgo.EnvOption{celgo.Lib(&clockLibrary{now: now})},
+	}
+}
+
+type clockLibrary struct {
+	now func() time.Time
+}
+
+func (*clockLibrary) LibraryName() string {
+	return "kyverno.clock"
+}
+
+func (l *clockLibrary) CompileOptions() []celgo.EnvOption {
+	return []celgo.EnvOption{
+		celgo.Function("now",
+			celgo.Overload("now", []*celgo.Type{}, celgo.TimestampType, celgo.FunctionBinding(func(...ref.Val) ref.Val {
+				return types.Timestamp{Time: l.now()}
+			})),
+		),
+	}
+}
+
+func (*clockLibrary) ProgramOptions() []celgo.ProgramOption {
+	return []celgo.ProgramOption{}
+}
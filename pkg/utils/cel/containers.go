@@ -0,0 +1,98 @@
+package cel
+
+import (
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// containerFields are the fields of a pod spec holding containers, in the order their containers are returned
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// ContainersLibrary returns the environment options declaring the container functions:
+//
+//	allContainers(<object>) <list>    returns the init, regular and ephemeral containers of a Pod, or of the pod template
+//	                                  of a pod controller, e.g. a Deployment or a CronJob
+//
+// For example `allContainers(object).all(c, has(c.resources.limits))`.
+func ContainersLibrary() environment.VersionedOptions {
+	return environment.VersionedOptions{
+		IntroducedVersion: version.MajorMinor(1, 0),
+		EnvOptions:        []celgo.EnvOption{celgo.Lib(containersLib)},
+	}
+}
+
+var containersLib = &containersLibrary{}
+
+type containersLibrary struct{}
+
+func (*containersLibrary) LibraryName() string {
+	return "kyverno.containers"
+}
+
+func (*containersLibrary) CompileOptions() []celgo.EnvOption {
+	return []celgo.EnvOption{
+		celgo.Function("allContainers",
+			celgo.Overload("all_containers_dyn", []*celgo.Type{celgo.DynType}, celgo.ListType(celgo.DynType), celgo.UnaryBinding(allContainers)),
+		),
+	}
+}
+
+func (*containersLibrary) ProgramOptions() []celgo.ProgramOption {
+	return []celgo.ProgramOption{}
+}
+
+func allContainers(arg ref.Val) ref.Val {
+	object, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	podSpec := findMap(object, "spec")
+	// pod controllers declare their pods with a template, cron jobs with the template of their jobs
+	if jobTemplate := findMap(podSpec, "jobTemplate"); jobTemplate != nil {
+		podSpec = findMap(findMap(findMap(jobTemplate, "spec"), "template"), "spec")
+	} else if template := findMap(podSpec, "template"); template != nil {
+		podSpec = findMap(template, "spec")
+	}
+	var containers []ref.Val
+	for _, field := range containerFields {
+		value, found := findValue(podSpec, field)
+		if !found {
+			continue
+		}
+		list, ok := value.(traits.Lister)
+		if !ok {
+			return types.NewErr("%s must be a list, found %s", field, value.Type())
+		}
+		size := list.Size().(types.Int)
+		for i := types.Int(0); i < size; i++ {
+			containers = append(containers, list.Get(i))
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, containers)
+}
+
+// findValue returns the value of the given key of a map, it is not found when the map is nil
+func findValue(m traits.Mapper, key string) (ref.Val, bool) {
+	if m == nil {
+		return nil, false
+	}
+	value, found := m.Find(types.String(key))
+	if !found || value == nil || value == types.NullValue {
+		return nil, false
+	}
+	return value, true
+}
+
+// findMap returns the map of the given key of a map, or nil
+func findMap(m traits.Mapper, key string) traits.Mapper {
+	value, found := findValue(m, key)
+	if !found {
+		return nil
+	}
+	mapper, _ := value.(traits.Mapper)
+	return mapper
+}
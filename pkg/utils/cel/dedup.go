@@ -0,0 +1,46 @@
+package cel
+
+import (
+	"strings"
+
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// dedupingFilterCompiler is a cel.FilterCompiler compiling identical expressions once.
+// Generated policies often repeat the same expression, the program compiled for the first
+// occurrence is reused by the next ones, each keeping its own expression accessor.
+type dedupingFilterCompiler struct {
+	compiler cel.Compiler
+}
+
+func (c *dedupingFilterCompiler) Compile(expressionAccessors []cel.ExpressionAccessor, options cel.OptionalVariableDeclarations, mode environment.Type) cel.Filter {
+	compilationResults := make([]cel.CompilationResult, len(expressionAccessors))
+	compiled := map[string]cel.CompilationResult{}
+	for i, expressionAccessor := range expressionAccessors {
+		if expressionAccessor == nil {
+			continue
+		}
+		key := expressionKey(expressionAccessor)
+		result, ok := compiled[key]
+		if !ok {
+			result = c.compiler.CompileCELExpression(expressionAccessor, options, mode)
+			compiled[key] = result
+		}
+		result.ExpressionAccessor = expressionAccessor
+		compilationResults[i] = result
+	}
+	return cel.NewFilter(compilationResults)
+}
+
+// expressionKey identifies an expression by its source and the types it is expected to return.
+func expressionKey(expressionAccessor cel.ExpressionAccessor) string {
+	var key strings.Builder
+	for _, returnType := range expressionAccessor.ReturnTypes() {
+		key.WriteString(returnType.String())
+		key.WriteByte(',')
+	}
+	key.WriteByte('|')
+	key.WriteString(expressionAccessor.GetExpression())
+	return key.String()
+}
@@ -0,0 +1,18 @@
+package cel
+
+import (
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+// AllowedEnvSourcesValidation returns a validation denying the container environment variables listed under
+// `envSources` which are not populated from an allowed source. Its message names the offending variables, and the
+// ConfigMaps and Secrets loaded as a whole through `envFrom`.
+func AllowedEnvSourcesValidation() admissionregistrationv1alpha1.Validation {
+	describe := "s.envFrom" +
+		" ? 'environment variables of container ' + s.container + ' are loaded from ' + s.source + ' ' + s.sourceName + ' which is not allowed'" +
+		" : 'environment variable ' + s.name + ' of container ' + s.container + ' is populated from ' + s.source + ' which is not allowed'"
+	return admissionregistrationv1alpha1.Validation{
+		Expression:        "envSources.all(s, s.allowed)",
+		MessageExpression: "envSources.filter(s, !s.allowed).map(s, " + describe + ").join(', ')",
+	}
+}
@@ -0,0 +1,39 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+// MutuallyExclusiveValidation returns a validation allowing at most one of the given label and annotation keys
+// to be set on the object. Its message names the conflicting keys.
+func MutuallyExclusiveValidation(labels, annotations []string) admissionregistrationv1alpha1.Validation {
+	keys := make([]string, 0, len(labels)+len(annotations))
+	for _, key := range labels {
+		keys = append(keys, presentKey("labels", "label", key))
+	}
+	for _, key := range annotations {
+		keys = append(keys, presentKey("annotations", "annotation", key))
+	}
+	// the list of the keys set on the object
+	present := fmt.Sprintf("[%s].filter(k, k != '')", strings.Join(keys, ", "))
+	return admissionregistrationv1alpha1.Validation{
+		Expression:        present + ".size() <= 1",
+		MessageExpression: "'mutually exclusive keys are set: ' + " + present + ".join(', ')",
+	}
+}
+
+// presentKey returns an expression evaluating to the description of the key when it is set on the object
+// and to an empty string otherwise.
+func presentKey(field, kind, key string) string {
+	return fmt.Sprintf(
+		"object != null && has(object.metadata.%s) && %s in object.metadata.%s ? %s : ''",
+		field,
+		strconv.Quote(key),
+		field,
+		strconv.Quote(kind+" "+key),
+	)
+}
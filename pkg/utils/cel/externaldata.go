@@ -0,0 +1,88 @@
+package cel
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ExternalDataURLs is the allow-list of the URLs fetched by external data sources, no URL is allowed when it is empty.
+type ExternalDataURLs struct {
+	// allowed are hosts, e.g. `registry.example.com`, or URL prefixes, e.g. `https://registry.example.com/allowed/`
+	allowed []*url.URL
+	// allowLocal allows the loopback and link-local addresses
+	allowLocal bool
+}
+
+// ParseExternalDataURLs parses a comma separated list of hosts and URL prefixes, the URLs of their hosts and the
+// URLs starting with their prefixes are allowed. Loopback and link-local addresses are rejected unless allowLocal is true.
+func ParseExternalDataURLs(value string, allowLocal bool) (ExternalDataURLs, error) {
+	urls := ExternalDataURLs{allowLocal: allowLocal}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "://") {
+			urls.allowed = append(urls.allowed, &url.URL{Host: strings.ToLower(entry)})
+			continue
+		}
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			return ExternalDataURLs{}, fmt.Errorf("invalid HTTP or HTTPS URL prefix %q", entry)
+		}
+		u.Host = strings.ToLower(u.Host)
+		urls.allowed = append(urls.allowed, u)
+	}
+	return urls, nil
+}
+
+// Check returns an error when the given URL isn't allowed.
+func (urls ExternalDataURLs) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid HTTP or HTTPS URL %q", rawURL)
+	}
+	if !urls.allowLocal && IsLocalHost(u.Hostname()) {
+		return fmt.Errorf("the URL %q targets a loopback or link-local address", rawURL)
+	}
+	for _, allowed := range urls.allowed {
+		if allowed.Scheme == "" {
+			if strings.EqualFold(u.Host, allowed.Host) || strings.EqualFold(u.Hostname(), allowed.Host) {
+				return nil
+			}
+		} else if allowed.Scheme == u.Scheme && strings.EqualFold(u.Host, allowed.Host) && hasPathPrefix(u.Path, allowed.Path) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the URL %q isn't allowed for external data sources", rawURL)
+}
+
+// hasPathPrefix returns true when the cleaned path is the prefix or one of its sub-paths.
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+	p = path.Clean("/" + p)
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(p+"/", prefix)
+	}
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// IsLocalHost returns true when the host is a loopback or link-local address, or is named localhost.
+func IsLocalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && IsLocalIP(ip)
+}
+
+// IsLocalIP returns true when the IP is a loopback, link-local or unspecified address.
+func IsLocalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
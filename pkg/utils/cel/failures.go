@@ -0,0 +1,59 @@
+package cel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/interpreter"
+)
+
+// VariableError is the failure of a composited variable, either to compile or to evaluate.
+type VariableError struct {
+	// Name is the name of the variable
+	Name string
+	// Compilation tells whether the variable failed to compile, it failed to evaluate otherwise
+	Compilation bool
+	// Err is the failure of the variable
+	Err error
+}
+
+func (e VariableError) Error() string {
+	if e.Compilation {
+		return fmt.Sprintf("variable %q failed to compile: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("variable %q failed to evaluate: %v", e.Name, e.Err)
+}
+
+func (e VariableError) Unwrap() error {
+	return e.Err
+}
+
+// Failures collects the failures of the programs evaluated with the context returned by WithFailures.
+// The filters only report the errors of the expressions as messages, e.g. the errors of the variables they use.
+type Failures struct {
+	// Variables are the variables failing to evaluate in the order they failed, a variable failing because
+	// of a variable it uses comes after it
+	Variables []VariableError
+	// CostLimitExceeded are the expressions cancelled for exceeding the cost limit of a single expression
+	CostLimitExceeded []string
+}
+
+type failuresKey struct{}
+
+// WithFailures returns a context collecting the failures of the programs evaluated with it in the given failures.
+func WithFailures(ctx context.Context, failures *Failures) context.Context {
+	return context.WithValue(ctx, failuresKey{}, failures)
+}
+
+// record adds the error of a program evaluating the given expression, variable is the name of the variable
+// the program computes, if any.
+func (f *Failures) record(expression, variable string, err error) {
+	var cancelled interpreter.EvalCancelledError
+	if errors.As(err, &cancelled) && cancelled.Cause == interpreter.CostLimitExceeded {
+		f.CostLimitExceeded = append(f.CostLimitExceeded, expression)
+	}
+	if variable != "" {
+		f.Variables = append(f.Variables, VariableError{Name: variable, Err: err})
+	}
+}
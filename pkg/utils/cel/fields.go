@@ -0,0 +1,41 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+// AllowedFieldsValidation returns a validation denying objects whose field at the given dot separated path
+// has fields outside of the given ones. The root of the object is checked when the path is empty.
+// Objects without the field are not checked. Its message names the unexpected fields.
+func AllowedFieldsValidation(path string, fields []string) admissionregistrationv1alpha1.Validation {
+	field := "object"
+	guards := []string{"object != null"}
+	if path != "" {
+		for _, segment := range strings.Split(path, ".") {
+			field += "." + segment
+			guards = append(guards, fmt.Sprintf("has(%s)", field))
+		}
+	}
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		quoted = append(quoted, strconv.Quote(f))
+	}
+	allowed := fmt.Sprintf("[%s]", strings.Join(quoted, ", "))
+	name := path
+	if name == "" {
+		name = "the object"
+	}
+	return admissionregistrationv1alpha1.Validation{
+		Expression: fmt.Sprintf("!(%s) || %s.all(f, f in %s)", strings.Join(guards, " && "), field, allowed),
+		MessageExpression: fmt.Sprintf(
+			"'unexpected fields in ' + %s + ': ' + %s.filter(f, !(f in %s)).join(', ')",
+			strconv.Quote(name),
+			field,
+			allowed,
+		),
+	}
+}
@@ -0,0 +1,37 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RequiredOwnersValidation returns a validation denying objects without an owner reference
+// of one of the given kinds. Its message tells apart objects without owner references
+// from objects owned by other kinds. Deleted objects are not checked.
+func RequiredOwnersValidation(owners []schema.GroupKind) admissionregistrationv1alpha1.Validation {
+	matches := make([]string, 0, len(owners))
+	names := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		// the api version of the core group has no group prefix
+		group := "!o.apiVersion.contains('/')"
+		if owner.Group != "" {
+			group = fmt.Sprintf("o.apiVersion.startsWith(%s)", strconv.Quote(owner.Group+"/"))
+		}
+		matches = append(matches, fmt.Sprintf("(o.kind == %s && %s)", strconv.Quote(owner.Kind), group))
+		names = append(names, owner.String())
+	}
+	hasOwners := "has(object.metadata.ownerReferences) && size(object.metadata.ownerReferences) != 0"
+	kinds := strconv.Quote(strings.Join(names, ", "))
+	return admissionregistrationv1alpha1.Validation{
+		Expression: fmt.Sprintf("object == null || (%s && object.metadata.ownerReferences.exists(o, %s))", hasOwners, strings.Join(matches, " || ")),
+		MessageExpression: fmt.Sprintf(
+			"(%s ? 'object is not owned by one of: ' : 'object has no owner reference, it must be owned by one of: ') + %s",
+			hasOwners,
+			kinds,
+		),
+	}
+}
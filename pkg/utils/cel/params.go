@@ -0,0 +1,18 @@
+package cel
+
+import (
+	"fmt"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ParseParamKind returns the group version of the params of the given kind.
+// It is checked when policies are admitted and when the params are fetched.
+func ParseParamKind(paramKind *admissionregistrationv1alpha1.ParamKind) (schema.GroupVersion, error) {
+	gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+	if err != nil {
+		return schema.GroupVersion{}, fmt.Errorf("can't parse the parameter resource group version: %w", err)
+	}
+	return gv, nil
+}
@@ -0,0 +1,146 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	celgo "github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// FieldReferences returns the field paths the expression accesses on the given root variables.
+// Every path starts with the name of the root variable, e.g. `object.spec.replicas` gives [object spec replicas].
+// Only the longest statically known path is returned, a root variable used as a whole gives a single element path.
+func FieldReferences(expression string, roots ...string) ([][]string, error) {
+	env := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).StoredExpressionsEnv()
+	parsed, issues := env.Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to parse expression %q: %w", expression, issues.Err())
+	}
+	isRoot := make(map[string]bool, len(roots))
+	for _, root := range roots {
+		isRoot[root] = true
+	}
+	return references(parsed, isRoot, false), nil
+}
+
+// FunctionCalls returns the names of the functions the expression calls, global and member functions alike,
+// e.g. `now() < timestamp(object.metadata.annotations.expires)` gives [now timestamp]. Operators are not returned.
+func FunctionCalls(expression string) ([]string, error) {
+	env := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).StoredExpressionsEnv()
+	parsed, issues := env.Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to parse expression %q: %w", expression, issues.Err())
+	}
+	var functions []string
+	var walk func(e celast.NavigableExpr)
+	walk = func(e celast.NavigableExpr) {
+		if e.Kind() == celast.CallKind {
+			function := e.AsCall().FunctionName()
+			_, operator := operators.FindReverse(function)
+			if !operator && !strings.HasPrefix(function, "@") && !slices.Contains(functions, function) {
+				functions = append(functions, function)
+			}
+		}
+		for _, child := range e.Children() {
+			walk(child)
+		}
+	}
+	walk(celast.NavigateCheckedAST(&celast.CheckedAST{Expr: parsed.Expr(), SourceInfo: parsed.SourceInfo()}))
+	return functions, nil
+}
+
+// FieldPaths returns the paths of the fields of the root variable the expression accesses, relative to the root,
+// e.g. `object.spec.containers[0].image` gives spec.containers[0].image. Constant list indexes are kept, the paths
+// are best-effort for expressions accessing fields through variables or comprehensions.
+func FieldPaths(expression string, root string) ([]string, error) {
+	env := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).StoredExpressionsEnv()
+	parsed, issues := env.Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to parse expression %q: %w", expression, issues.Err())
+	}
+	var paths []string
+	for _, reference := range references(parsed, map[string]bool{root: true}, true) {
+		var path strings.Builder
+		for _, element := range reference[1:] {
+			if path.Len() != 0 && !strings.HasPrefix(element, "[") {
+				path.WriteString(".")
+			}
+			path.WriteString(element)
+		}
+		// the root used as a whole references no field
+		if path.Len() != 0 && !slices.Contains(paths, path.String()) {
+			paths = append(paths, path.String())
+		}
+	}
+	return paths, nil
+}
+
+// references returns the longest field paths starting from the root variables accessed by the parsed expression.
+func references(parsed *celgo.Ast, isRoot map[string]bool, indexes bool) [][]string {
+	var references [][]string
+	var walk func(e celast.NavigableExpr)
+	walk = func(e celast.NavigableExpr) {
+		if path, ok := fieldPath(e, isRoot, indexes); ok {
+			references = append(references, path)
+			return
+		}
+		for _, child := range e.Children() {
+			walk(child)
+		}
+	}
+	walk(celast.NavigateCheckedAST(&celast.CheckedAST{Expr: parsed.Expr(), SourceInfo: parsed.SourceInfo()}))
+	return references
+}
+
+// fieldPath returns the path of a chain of field selections starting from a root variable.
+// Constant list indexes are part of the path as `[i]` elements when indexes is true.
+func fieldPath(e celast.NavigableExpr, isRoot map[string]bool, indexes bool) ([]string, bool) {
+	switch e.Kind() {
+	case celast.IdentKind:
+		name := e.AsIdent()
+		return []string{name}, isRoot[name]
+	case celast.SelectKind:
+		selectExpr := e.AsSelect()
+		path, ok := fieldPath(selectExpr.Operand(), isRoot, indexes)
+		if !ok {
+			return nil, false
+		}
+		return append(path, selectExpr.FieldName()), true
+	case celast.CallKind:
+		// index and optional select operations with a constant string key, e.g. `object["spec"]` or `object.?spec`
+		call := e.AsCall()
+		if call.Target() != nil || len(call.Args()) != 2 {
+			return nil, false
+		}
+		if call.FunctionName() != operators.Index && call.FunctionName() != operators.OptSelect {
+			return nil, false
+		}
+		key := call.Args()[1]
+		if key.Kind() != celast.LiteralKind {
+			return nil, false
+		}
+		var element string
+		switch literal := key.AsLiteral().(type) {
+		case types.String:
+			element = string(literal)
+		case types.Int:
+			if !indexes || call.FunctionName() != operators.Index {
+				return nil, false
+			}
+			element = fmt.Sprintf("[%d]", literal)
+		default:
+			return nil, false
+		}
+		path, ok := fieldPath(call.Args()[0], isRoot, indexes)
+		if !ok {
+			return nil, false
+		}
+		return append(path, element), true
+	}
+	return nil, false
+}
@@ -0,0 +1,30 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+)
+
+// AllowedRegistriesValidation returns a validation denying the container images listed under `images`
+// which are not pulled from one of the given registries or from one of the registries read from the given field of `params`.
+// The field holds a list or a comma separated string. Its message names the offending images.
+func AllowedRegistriesValidation(registries []string, paramField string) admissionregistrationv1alpha1.Validation {
+	quoted := make([]string, 0, len(registries))
+	for _, registry := range registries {
+		quoted = append(quoted, strconv.Quote(registry))
+	}
+	approved := fmt.Sprintf("[%s]", strings.Join(quoted, ", "))
+	if paramField != "" {
+		field := "params." + paramField
+		approved = fmt.Sprintf("(%s + (type(%s) == string ? %s.split(',').map(r, r.trim()) : %s))", approved, field, field, field)
+	}
+	// an image is approved when pulled from an approved registry or from a repository below it
+	isApproved := fmt.Sprintf("%s.exists(r, i.registry == r || i.image.startsWith(r + '/'))", approved)
+	return admissionregistrationv1alpha1.Validation{
+		Expression:        fmt.Sprintf("images.all(i, %s)", isApproved),
+		MessageExpression: fmt.Sprintf("'images not pulled from an approved registry: ' + images.filter(i, !(%s)).map(i, i.reference).join(', ')", isApproved),
+	}
+}
@@ -0,0 +1,217 @@
+package cel
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/blang/semver/v4"
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// SemverType is the CEL type of semantic versions.
+var SemverType = celgo.ObjectType("kyverno.Semver")
+
+// Semver is the CEL representation of a semantic version.
+type Semver struct {
+	semver.Version
+}
+
+func (v Semver) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	if reflect.TypeOf(v.Version).AssignableTo(typeDesc) {
+		return v.Version, nil
+	}
+	if reflect.TypeOf("").AssignableTo(typeDesc) {
+		return v.Version.String(), nil
+	}
+	return nil, fmt.Errorf("type conversion error from 'Semver' to '%v'", typeDesc)
+}
+
+func (v Semver) ConvertToType(typeVal ref.Type) ref.Val {
+	switch typeVal {
+	case SemverType:
+		return v
+	case types.StringType:
+		return types.String(v.Version.String())
+	case types.TypeType:
+		return SemverType
+	default:
+		return types.NewErr("type conversion error from '%s' to '%s'", SemverType, typeVal)
+	}
+}
+
+func (v Semver) Equal(other ref.Val) ref.Val {
+	otherVersion, ok := other.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(other)
+	}
+	return types.Bool(v.Version.Equals(otherVersion.Version))
+}
+
+func (v Semver) Type() ref.Type {
+	return SemverType
+}
+
+func (v Semver) Value() interface{} {
+	return v.Version
+}
+
+// SemverLibrary returns the environment options declaring the semantic version functions:
+//
+//	semver(<string>) <Semver>                  parses a version, a leading `v` and missing minor and patch versions are tolerated
+//	isSemver(<string>) <bool>                  tells whether a string parses as a version
+//	<Semver>.compareTo(<Semver>) <int>         returns -1, 0 or 1 when the version is lower, equal or greater
+//	<Semver>.isGreaterThan(<Semver>) <bool>
+//	<Semver>.isLessThan(<Semver>) <bool>
+//	<Semver>.satisfies(<string>) <bool>        tells whether the version is in a range, e.g. `>=1.25.0 <2.0.0`
+//	<Semver>.major() <int>, <Semver>.minor() <int>, <Semver>.patch() <int>
+//
+// For example `semver(object.spec.version).satisfies('>=1.25.0')`.
+func SemverLibrary() environment.VersionedOptions {
+	return environment.VersionedOptions{
+		IntroducedVersion: version.MajorMinor(1, 0),
+		EnvOptions:        []celgo.EnvOption{celgo.Lib(semverLib)},
+	}
+}
+
+var semverLib = &semverLibrary{}
+
+type semverLibrary struct{}
+
+func (*semverLibrary) LibraryName() string {
+	return "kyverno.semver"
+}
+
+var semverLibraryDecls = map[string][]celgo.FunctionOpt{
+	"semver": {
+		celgo.Overload("string_to_semver", []*celgo.Type{celgo.StringType}, SemverType, celgo.UnaryBinding(stringToSemver)),
+	},
+	"isSemver": {
+		celgo.Overload("is_semver_string", []*celgo.Type{celgo.StringType}, celgo.BoolType, celgo.UnaryBinding(isSemver)),
+	},
+	"compareTo": {
+		celgo.MemberOverload("semver_compare_to", []*celgo.Type{SemverType, SemverType}, celgo.IntType, celgo.BinaryBinding(semverCompareTo)),
+	},
+	"isGreaterThan": {
+		celgo.MemberOverload("semver_is_greater_than", []*celgo.Type{SemverType, SemverType}, celgo.BoolType, celgo.BinaryBinding(semverIsGreaterThan)),
+	},
+	"isLessThan": {
+		celgo.MemberOverload("semver_is_less_than", []*celgo.Type{SemverType, SemverType}, celgo.BoolType, celgo.BinaryBinding(semverIsLessThan)),
+	},
+	"satisfies": {
+		celgo.MemberOverload("semver_satisfies_string", []*celgo.Type{SemverType, celgo.StringType}, celgo.BoolType, celgo.BinaryBinding(semverSatisfies)),
+	},
+	"major": {
+		celgo.MemberOverload("semver_major", []*celgo.Type{SemverType}, celgo.IntType, celgo.UnaryBinding(semverMajor)),
+	},
+	"minor": {
+		celgo.MemberOverload("semver_minor", []*celgo.Type{SemverType}, celgo.IntType, celgo.UnaryBinding(semverMinor)),
+	},
+	"patch": {
+		celgo.MemberOverload("semver_patch", []*celgo.Type{SemverType}, celgo.IntType, celgo.UnaryBinding(semverPatch)),
+	},
+}
+
+func (*semverLibrary) CompileOptions() []celgo.EnvOption {
+	options := make([]celgo.EnvOption, 0, len(semverLibraryDecls))
+	for name, overloads := range semverLibraryDecls {
+		options = append(options, celgo.Function(name, overloads...))
+	}
+	return options
+}
+
+func (*semverLibrary) ProgramOptions() []celgo.ProgramOption {
+	return []celgo.ProgramOption{}
+}
+
+func stringToSemver(arg ref.Val) ref.Val {
+	str, ok := arg.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	v, err := semver.ParseTolerant(str)
+	if err != nil {
+		return types.NewErr("invalid semantic version %q: %v", str, err)
+	}
+	return Semver{Version: v}
+}
+
+func isSemver(arg ref.Val) ref.Val {
+	str, ok := arg.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	_, err := semver.ParseTolerant(str)
+	return types.Bool(err == nil)
+}
+
+func semverCompareTo(lhs, rhs ref.Val) ref.Val {
+	v, ok := lhs.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(lhs)
+	}
+	other, ok := rhs.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(rhs)
+	}
+	return types.Int(v.Version.Compare(other.Version))
+}
+
+func semverIsGreaterThan(lhs, rhs ref.Val) ref.Val {
+	result := semverCompareTo(lhs, rhs)
+	if types.IsError(result) {
+		return result
+	}
+	return types.Bool(result.(types.Int) > 0)
+}
+
+func semverIsLessThan(lhs, rhs ref.Val) ref.Val {
+	result := semverCompareTo(lhs, rhs)
+	if types.IsError(result) {
+		return result
+	}
+	return types.Bool(result.(types.Int) < 0)
+}
+
+func semverSatisfies(lhs, rhs ref.Val) ref.Val {
+	v, ok := lhs.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(lhs)
+	}
+	str, ok := rhs.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(rhs)
+	}
+	versionRange, err := semver.ParseRange(str)
+	if err != nil {
+		return types.NewErr("invalid semantic version range %q: %v", str, err)
+	}
+	return types.Bool(versionRange(v.Version))
+}
+
+func semverMajor(arg ref.Val) ref.Val {
+	v, ok := arg.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	return types.Int(v.Version.Major)
+}
+
+func semverMinor(arg ref.Val) ref.Val {
+	v, ok := arg.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	return types.Int(v.Version.Minor)
+}
+
+func semverPatch(arg ref.Val) ref.Val {
+	v, ok := arg.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	return types.Int(v.Version.Patch)
+}
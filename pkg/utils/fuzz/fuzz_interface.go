@@ -60,6 +60,10 @@ func (fi FuzzInterface) ListResource(ctx context.Context, apiVersion string, kin
 	return nil, fmt.Errorf("Not implemented")
 }
 
+func (fi FuzzInterface) ListResourcePage(ctx context.Context, apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector, limit int64, continueToken string) (*unstructured.UnstructuredList, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
 func (fi FuzzInterface) DeleteResource(ctx context.Context, apiVersion string, kind string, namespace string, name string, dryRun bool) error {
 	return fmt.Errorf("Not implemented")
 }
@@ -128,6 +128,37 @@ func EngineResponseToReportResults(response engineapi.EngineResponse) []policyre
 					}
 				}
 			}
+			// audit annotations are named after the policy, as they are for ValidatingAdmissionPolicies
+			for key, value := range ruleResult.AuditAnnotations() {
+				if result.Properties == nil {
+					result.Properties = map[string]string{}
+				}
+				result.Properties[pol.GetName()+"/"+key] = value
+			}
+			if reason := ruleResult.Reason(); reason != "" {
+				if result.Properties == nil {
+					result.Properties = map[string]string{}
+				}
+				result.Properties["reason"] = string(reason)
+			}
+			if paths := ruleResult.FieldPaths(); len(paths) != 0 {
+				if result.Properties == nil {
+					result.Properties = map[string]string{}
+				}
+				result.Properties["fieldPaths"] = strings.Join(paths, ",")
+			}
+			if validationType := ruleResult.ValidationType(); validationType != "" {
+				if result.Properties == nil {
+					result.Properties = map[string]string{}
+				}
+				result.Properties["validationType"] = string(validationType)
+			}
+			if suggestion := ruleResult.Suggestion(); suggestion != "" {
+				if result.Properties == nil {
+					result.Properties = map[string]string{}
+				}
+				result.Properties["suggestion"] = suggestion
+			}
 			if result.Result == "fail" && !result.Scored {
 				result.Result = "warn"
 			}
@@ -78,7 +78,11 @@ func BuildValidatingAdmissionPolicyBinding(vapbinding *admissionregistrationv1al
 	// set validation action for vap binding
 	var validationActions []admissionregistrationv1alpha1.ValidationAction
 	action := cpol.GetSpec().ValidationFailureAction
-	if action.Enforce() {
+	rule := cpol.GetSpec().Rules[0]
+	if rule.Validation.CEL != nil && rule.Validation.CEL.Warn {
+		// failed expressions are returned as warnings
+		validationActions = append(validationActions, admissionregistrationv1alpha1.Warn)
+	} else if action.Enforce() {
 		validationActions = append(validationActions, admissionregistrationv1alpha1.Deny)
 	} else if action.Audit() {
 		validationActions = append(validationActions, admissionregistrationv1alpha1.Audit)
@@ -86,7 +90,6 @@ func BuildValidatingAdmissionPolicyBinding(vapbinding *admissionregistrationv1al
 	}
 
 	// set validating admission policy binding spec
-	rule := cpol.GetSpec().Rules[0]
 	vapbinding.Spec = admissionregistrationv1alpha1.ValidatingAdmissionPolicyBindingSpec{
 		PolicyName:        cpol.GetName(),
 		ParamRef:          rule.Validation.CEL.ParamRef,
@@ -1,10 +1,29 @@
 package validatingadmissionpolicy
 
 import (
+	"fmt"
+	"slices"
+
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
 	"github.com/kyverno/kyverno/ext/wildcard"
+	celutils "github.com/kyverno/kyverno/pkg/utils/cel"
 )
 
+// kyvernoFunctions are the functions Kyverno declares in CEL expressions, the API server doesn't declare them
+var kyvernoFunctions = []string{"now", "semver", "isSemver", "allContainers"}
+
+// kyvernoVariables are the variables Kyverno declares in CEL expressions, the API server doesn't declare them
+var kyvernoVariables = []string{
+	"clusterContext",
+	"context",
+	"envSources",
+	"externalData",
+	"images",
+	"lowercaseAnnotations",
+	"lowercaseLabels",
+	"relatedResources",
+}
+
 // CanGenerateVAP check if Kyverno policy can be translated to a Kubernetes ValidatingAdmissionPolicy
 func CanGenerateVAP(spec *kyvernov1.Spec) (bool, string) {
 	var msg string
@@ -19,6 +38,115 @@ func CanGenerateVAP(spec *kyvernov1.Spec) (bool, string) {
 		return false, msg
 	}
 
+	if len(rule.Validation.CEL.AllowedEnvSources) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: allowedEnvSources is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.FieldProjection) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: fieldProjection is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.ComputedLabels) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: computedLabels is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.MutuallyExclusive) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: mutuallyExclusive is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.AllowedRegistries != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: allowedRegistries is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.RequiredOwners) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: requiredOwners is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.RelatedResources) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: relatedResources is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.ExternalData != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: externalData is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.AllowedFields) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: allowedFields is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.LowercaseMetadata != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: lowercaseMetadata is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.GracePeriod != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: gracePeriod is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.MessageRedactions) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: messageRedactions is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.ExcludeSelfFromParams {
+		msg = "skip generating ValidatingAdmissionPolicy: excludeSelfFromParams is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.CostBudget != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: costBudget is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.ParamFieldSelector != "" {
+		msg = "skip generating ValidatingAdmissionPolicy: paramFieldSelector is not applicable."
+		return false, msg
+	}
+
+	if len(rule.Validation.CEL.AdditionalParams) != 0 {
+		msg = "skip generating ValidatingAdmissionPolicy: multiple param kinds are not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.NamespaceSelector != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: namespaceSelector in cel is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.ParamNamespaceSelector != nil {
+		msg = "skip generating ValidatingAdmissionPolicy: paramNamespaceSelector is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.EvaluateWithoutParams {
+		msg = "skip generating ValidatingAdmissionPolicy: evaluateWithoutParams is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.ParamsFromOwners {
+		msg = "skip generating ValidatingAdmissionPolicy: paramsFromOwners is not applicable."
+		return false, msg
+	}
+
+	if rule.Validation.CEL.SuggestionExpression != "" {
+		msg = "skip generating ValidatingAdmissionPolicy: suggestionExpression is not applicable."
+		return false, msg
+	}
+
+	if ok, msg := checkExpressions(rule); !ok {
+		return false, msg
+	}
+
 	if len(spec.ValidationFailureActionOverrides) > 1 {
 		msg = "skip generating ValidatingAdmissionPolicy: multiple validationFailureActionOverrides are not applicable."
 		return false, msg
@@ -115,3 +243,47 @@ func checkUserInfo(info kyvernov1.UserInfo) (bool, string) {
 	}
 	return true, msg
 }
+
+// checkExpressions checks that the CEL expressions of a rule only use what the API server declares.
+func checkExpressions(rule kyvernov1.Rule) (bool, string) {
+	for _, expression := range celExpressions(rule) {
+		functions, err := celutils.FunctionCalls(expression)
+		if err != nil {
+			return false, fmt.Sprintf("skip generating ValidatingAdmissionPolicy: %s.", err)
+		}
+		for _, function := range functions {
+			if slices.Contains(kyvernoFunctions, function) {
+				return false, fmt.Sprintf("skip generating ValidatingAdmissionPolicy: the %s() function in CEL expressions is not applicable.", function)
+			}
+		}
+		references, err := celutils.FieldReferences(expression, kyvernoVariables...)
+		if err != nil {
+			return false, fmt.Sprintf("skip generating ValidatingAdmissionPolicy: %s.", err)
+		}
+		if len(references) != 0 {
+			return false, fmt.Sprintf("skip generating ValidatingAdmissionPolicy: the %s variable in CEL expressions is not applicable.", references[0][0])
+		}
+	}
+	return true, ""
+}
+
+// celExpressions returns the CEL expressions of a rule, including its message expressions and preconditions.
+func celExpressions(rule kyvernov1.Rule) []string {
+	var expressions []string
+	for _, validation := range rule.Validation.CEL.Expressions {
+		expressions = append(expressions, validation.Expression)
+		if validation.MessageExpression != "" {
+			expressions = append(expressions, validation.MessageExpression)
+		}
+	}
+	for _, auditAnnotation := range rule.Validation.CEL.AuditAnnotations {
+		expressions = append(expressions, auditAnnotation.ValueExpression)
+	}
+	for _, variable := range rule.Validation.CEL.Variables {
+		expressions = append(expressions, variable.Expression)
+	}
+	for _, precondition := range rule.CELPreconditions {
+		expressions = append(expressions, precondition.Expression)
+	}
+	return expressions
+}
@@ -253,6 +253,94 @@ func Test_Can_Generate_ValidatingAdmissionPolicy(t *testing.T) {
     ]
   }
 }
+`),
+			expected: false,
+		},
+		{
+			name: "policy-with-allowed-env-sources",
+			policy: []byte(`
+{
+  "apiVersion": "kyverno.io/v1",
+  "kind": "ClusterPolicy",
+  "metadata": {
+    "name": "check-env-sources"
+  },
+  "spec": {
+    "validationFailureAction": "Enforce",
+    "rules": [
+      {
+        "name": "check-env-sources",
+        "match": {
+          "any": [
+            {
+              "resources": {
+                "kinds": [
+                  "Pod"
+                ]
+              }
+            }
+          ]
+        },
+        "validate": {
+          "cel": {
+            "allowedEnvSources": [
+              "Value"
+            ],
+            "expressions": [
+              {
+                "expression": "envSources.all(e, e.resolved)"
+              }
+            ]
+          }
+        }
+      }
+    ]
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "policy-with-computed-labels",
+			policy: []byte(`
+{
+  "apiVersion": "kyverno.io/v1",
+  "kind": "ClusterPolicy",
+  "metadata": {
+    "name": "check-team"
+  },
+  "spec": {
+    "validationFailureAction": "Enforce",
+    "rules": [
+      {
+        "name": "check-team",
+        "match": {
+          "any": [
+            {
+              "resources": {
+                "kinds": [
+                  "Deployment"
+                ]
+              }
+            }
+          ]
+        },
+        "validate": {
+          "cel": {
+            "computedLabels": {
+              "team": "payments"
+            },
+            "expressions": [
+              {
+                "expression": "object.metadata.labels['team'] == 'payments'"
+              }
+            ]
+          }
+        }
+      }
+    ]
+  }
+}
 `),
 			expected: false,
 		},
@@ -485,3 +573,183 @@ func Test_Can_Generate_ValidatingAdmissionPolicy(t *testing.T) {
 		})
 	}
 }
+
+func Test_Check_Expressions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     []byte
+		expected bool
+	}{
+		{
+			name: "standard-functions",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "object.spec.containers.all(container, !container.image.endsWith(':latest'))"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: true,
+		},
+		{
+			name: "now-in-expression",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "now() < timestamp(object.metadata.annotations.expires)"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "now-in-variable",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "variables": [
+        {
+          "name": "expired",
+          "expression": "timestamp(object.metadata.annotations.expires) < now()"
+        }
+      ],
+      "expressions": [
+        {
+          "expression": "!variables.expired"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "semver-in-precondition",
+			rule: []byte(`
+{
+  "celPreconditions": [
+    {
+      "name": "versioned",
+      "expression": "semver(object.spec.version).isGreaterThan(semver('1.0.0'))"
+    }
+  ],
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "object.spec.replicas <= 5"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "cluster-context-in-message-expression",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "object.spec.replicas <= 5",
+          "messageExpression": "'too many replicas in ' + clusterContext.env"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "images-in-audit-annotation",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "object.spec.replicas <= 5"
+        }
+      ],
+      "auditAnnotations": [
+        {
+          "key": "images",
+          "valueExpression": "images.join(', ')"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "context-in-variable",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "variables": [
+        {
+          "name": "maxReplicas",
+          "expression": "int(context.limits.data.maxReplicas)"
+        }
+      ],
+      "expressions": [
+        {
+          "expression": "object.spec.replicas <= variables.maxReplicas"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: false,
+		},
+		{
+			name: "now-in-string-literal",
+			rule: []byte(`
+{
+  "validate": {
+    "cel": {
+      "expressions": [
+        {
+          "expression": "object.metadata.name != 'now()'"
+        }
+      ]
+    }
+  }
+}
+`),
+			expected: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			var rule kyvernov1.Rule
+			err := json.Unmarshal(test.rule, &rule)
+			assert.NilError(t, err)
+			out, _ := checkExpressions(rule)
+			assert.Equal(t, out, test.expected)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	kyverno "github.com/kyverno/kyverno/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/policy/validate"
 
 	fuzz "github.com/AdaLogics/go-fuzz-headers"
 )
@@ -14,6 +15,6 @@ func FuzzValidatePolicy(f *testing.F) {
 		p := &kyverno.ClusterPolicy{}
 		ff.GenerateStruct(p)
 
-		Validate(p, nil, nil, nil, true, "admin")
+		Validate(p, nil, nil, nil, true, "admin", validate.DefaultOptions())
 	})
 }
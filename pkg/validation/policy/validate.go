@@ -27,6 +27,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/variables/operator"
 	"github.com/kyverno/kyverno/pkg/engine/variables/regex"
 	"github.com/kyverno/kyverno/pkg/logging"
+	"github.com/kyverno/kyverno/pkg/policy/validate"
 	apiutils "github.com/kyverno/kyverno/pkg/utils/api"
 	datautils "github.com/kyverno/kyverno/pkg/utils/data"
 	kubeutils "github.com/kyverno/kyverno/pkg/utils/kube"
@@ -127,8 +128,9 @@ func checkValidationFailureAction(spec *kyvernov1.Spec) []string {
 	return nil
 }
 
-// Validate checks the policy and rules declarations for required configurations
-func Validate(policy, oldPolicy kyvernov1.PolicyInterface, client dclient.Interface, kyvernoClient versioned.Interface, mock bool, username string) ([]string, error) {
+// Validate checks the policy and rules declarations for required configurations,
+// the checks of validate rules depending on the configuration of Kyverno are set by validateOptions
+func Validate(policy, oldPolicy kyvernov1.PolicyInterface, client dclient.Interface, kyvernoClient versioned.Interface, mock bool, username string, validateOptions validate.Options) ([]string, error) {
 	var warnings []string
 	spec := policy.GetSpec()
 	background := spec.BackgroundProcessingEnabled()
@@ -315,7 +317,7 @@ func Validate(policy, oldPolicy kyvernov1.PolicyInterface, client dclient.Interf
 			}
 		}
 
-		msg, err := validateActions(i, &rules[i], client, mock, username)
+		msg, err := validateActions(i, &rules[i], client, mock, username, validateOptions)
 		if err != nil {
 			return warnings, err
 		} else {
@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/kyverno/kyverno/pkg/client/clientset/versioned"
 	"github.com/kyverno/kyverno/pkg/clients/dclient"
+	"github.com/kyverno/kyverno/pkg/policy/validate"
 	admissionutils "github.com/kyverno/kyverno/pkg/utils/admission"
 	policyvalidate "github.com/kyverno/kyverno/pkg/validation/policy"
 	"github.com/kyverno/kyverno/pkg/webhooks"
@@ -17,13 +18,15 @@ type policyHandlers struct {
 	client                       dclient.Interface
 	kyvernoClient                versioned.Interface
 	backgroundServiceAccountName string
+	validateOptions              validate.Options
 }
 
-func NewHandlers(client dclient.Interface, kyvernoClient versioned.Interface, serviceaccount string) webhooks.PolicyHandlers {
+func NewHandlers(client dclient.Interface, kyvernoClient versioned.Interface, serviceaccount string, validateOptions validate.Options) webhooks.PolicyHandlers {
 	return &policyHandlers{
 		client:                       client,
 		kyvernoClient:                kyvernoClient,
 		backgroundServiceAccountName: serviceaccount,
+		validateOptions:              validateOptions,
 	}
 }
 
@@ -33,7 +36,7 @@ func (h *policyHandlers) Validate(ctx context.Context, logger logr.Logger, reque
 		logger.Error(err, "failed to unmarshal policies from admission request")
 		return admissionutils.Response(request.UID, err)
 	}
-	warnings, err := policyvalidate.Validate(policy, oldPolicy, h.client, h.kyvernoClient, false, h.backgroundServiceAccountName)
+	warnings, err := policyvalidate.Validate(policy, oldPolicy, h.client, h.kyvernoClient, false, h.backgroundServiceAccountName, h.validateOptions)
 	if err != nil {
 		logger.Error(err, "policy validation errors")
 	}